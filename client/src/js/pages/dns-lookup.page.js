@@ -99,7 +99,6 @@ const BLACKLIST_PROVIDERS = [
     { host: "dnsbl.dronebl.org", level: "Medium" },             // DRONE BL
     { host: "dnsbl.zapbl.net", level: "Medium" },               // ZapBL
     { host: "hostkarma.junkemailfilter.com", level: "Medium" }, // Hostkarma Black
-    { host: "woodys.smtp.blacklist", level: "Medium" },         // Woodys SMTP (hay timeout)
     { host: "lashback.uoregon.edu", level: "Medium" },          // LASHBACK
     { host: "rbl.schulte.org", level: "Medium" },               // Manitu (Schulte)
     { host: "dnsbl.konstant.no", level: "Medium" },             // Konstant
@@ -136,8 +135,6 @@ const BLACKLIST_PROVIDERS = [
     { host: "relays.nether.net", level: "Low" },                // NETHERRELAYS
     { host: "unsure.nether.net", level: "Low" },                // NETHERUNSURE
     { host: "rbl.triumf.ca", level: "Low" },                    // TRIUMF
-    { host: "hil.habeas.com", level: "Low" },                   // HIL
-    { host: "hil2.habeas.com", level: "Low" },                  // HIL2
 ];
 
 
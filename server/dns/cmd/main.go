@@ -5,8 +5,10 @@
 package main
 
 import (
+	"context"
 	"log"
 
+	"tools.bctechvibe.io.vn/server/internal/dns"
 	"tools.bctechvibe.io.vn/server/internal/handlers"
 
 	"github.com/gin-contrib/cors"
@@ -29,8 +31,13 @@ func main() {
 	{
 		api.POST("/dns/lookup", handlers.HandleDNSLookup)
 		api.GET("/dns/blacklist-stream/:ip", handlers.HandleBlacklistStream)
+		api.GET("/dns/blacklist-report/:ip", handlers.HandleBlacklistReport)
+		api.GET("/dns/blacklist-providers", handlers.HandleBlacklistProviders)
 	}
 
+	// Blocklist registry: file reload + probes of the test entries
+	dns.DefaultRBLRegistry.Start(context.Background())
+
 	log.Println("🚀 DNS Lookup Server started on :3101")
 	router.Run(":3101")
 }
@@ -0,0 +1,523 @@
+/*
+File: server/internal/dns/authoritative.go
+Description: Authoritative nameserver consistency - asks every nameserver
+of a zone directly and compares SOA serials and record sets, the parent
+delegation with the zone's own NS RRset, glue and network diversity.
+*/
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/internal/models"
+
+	"github.com/miekg/dns"
+)
+
+// Issue severities; an error breaks resolution for some clients
+const (
+	IssueError   = "error"
+	IssueWarning = "warning"
+)
+
+// Record types compared between the nameservers
+var nsCheckTypes = []uint16{dns.TypeNS, dns.TypeA, dns.TypeAAAA, dns.TypeMX, dns.TypeTXT, dns.TypeCAA}
+
+// NameserverChecker queries the authoritative servers of a zone without
+// recursion. Resolver is only used to find the parent zone and the
+// addresses of nameservers that have no glue.
+type NameserverChecker struct {
+	Resolver Resolver
+	Timeout  time.Duration
+
+	addr func(ip net.IP) string         // where queries for ip are sent
+	asn  func(ip net.IP) (uint, string) // AS number and organisation
+}
+
+func NewNameserverChecker(r Resolver) *NameserverChecker {
+	return &NameserverChecker{
+		Resolver: r,
+		Timeout:  3 * time.Second,
+		addr:     func(ip net.IP) string { return net.JoinHostPort(ip.String(), "53") },
+		asn:      lookupASN,
+	}
+}
+
+// CheckNameservers checks zone through the given provider / transport.
+func CheckNameservers(serverKey, transport, zone string) *models.NameserverCheck {
+	r := &fallbackResolver{rm: ResolverManagerFor(serverKey), transport: transport}
+	return NewNameserverChecker(r).Check(zone)
+}
+
+// fallbackResolver queries through a manager, fallback chain included.
+type fallbackResolver struct {
+	rm        *ResolverManager
+	transport string
+}
+
+func (f *fallbackResolver) Query(domain string, qtype uint16) (*Result, error) {
+	return f.rm.ResolveFallback(domain, qtype, f.transport)
+}
+
+func lookupASN(ip net.IP) (uint, string) {
+	if GeoASNDB == nil {
+		return 0, ""
+	}
+
+	asn, err := GeoASNDB.ASN(ip)
+	if err != nil {
+		return 0, ""
+	}
+	return asn.AutonomousSystemNumber, strings.TrimSpace(asn.AutonomousSystemOrganization)
+}
+
+// authProbe is one nameserver address and what it answered.
+type authProbe struct {
+	server models.AuthServer
+	ip     net.IP
+	sets   map[uint16][]string
+}
+
+func (p *authProbe) label() string {
+	if p.server.Address == "" {
+		return p.server.Nameserver
+	}
+	return fmt.Sprintf("%s (%s)", p.server.Nameserver, p.server.Address)
+}
+
+// Check runs every consistency check on zone.
+func (c *NameserverChecker) Check(zone string) *models.NameserverCheck {
+	zone = dns.CanonicalName(zone)
+
+	info := &models.NameserverCheck{
+		Zone:    trimDot(zone),
+		Servers: []models.AuthServer{},
+		Records: []models.RecordSetCheck{},
+		Issues:  []models.NameserverIssue{},
+	}
+	issue := func(severity, code, msg string, servers ...string) {
+		info.Issues = append(info.Issues, models.NameserverIssue{
+			Severity: severity, Code: code, Message: msg, Servers: servers,
+		})
+	}
+
+	// 1. Delegation at the parent, with its glue
+	parent, parentNS, glue, err := c.delegation(zone)
+	info.Parent = trimDot(parent)
+	info.ParentNS = trimDots(parentNS)
+	if err != nil {
+		issue(IssueWarning, "parent_unreachable", "could not read the delegation from the parent zone: "+err.Error())
+	}
+
+	// 2. Nameservers: the delegation, then what the resolver has cached
+	hosts := slices.Clone(parentNS)
+	if res, err := c.Resolver.Query(zone, dns.TypeNS); err == nil {
+		for _, host := range nsNames(res.Records) {
+			if !slices.Contains(hosts, host) {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		issue(IssueError, "no_nameservers", "no nameserver found for "+info.Zone)
+		return info
+	}
+
+	// 3. One probe per address; glue for in-zone names, the resolver otherwise
+	var probes []*authProbe
+	for _, host := range hosts {
+		inZone := dns.IsSubDomain(zone, host)
+
+		ips, fromGlue := glue[host], len(glue[host]) > 0
+		if !fromGlue {
+			ips = c.addresses(host)
+		}
+		if inZone && !fromGlue && slices.Contains(parentNS, host) {
+			issue(IssueError, "missing_glue", fmt.Sprintf("%s is inside the zone but the parent has no glue for it", trimDot(host)), trimDot(host))
+		}
+
+		if len(ips) == 0 {
+			probes = append(probes, &authProbe{server: models.AuthServer{
+				Nameserver: trimDot(host), InBailiwick: inZone, Lame: true, Error: "no address",
+			}})
+			continue
+		}
+		for _, ip := range ips {
+			probes = append(probes, &authProbe{ip: ip, server: models.AuthServer{
+				Nameserver: trimDot(host), Address: ip.String(), InBailiwick: inZone, Glue: fromGlue,
+			}})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, p := range probes {
+		if p.ip == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.probe(p, zone)
+		}()
+	}
+	wg.Wait()
+
+	for _, p := range probes {
+		info.Servers = append(info.Servers, p.server)
+	}
+
+	// 4. Lame and unreachable servers
+	var lame, unreachable []string
+	for _, p := range probes {
+		switch {
+		case p.server.Lame:
+			lame = append(lame, p.label())
+		case p.server.Error != "":
+			unreachable = append(unreachable, p.label())
+		}
+	}
+	if len(lame) > 0 {
+		issue(IssueError, "lame", "lame delegation: not authoritative for "+info.Zone, lame...)
+	}
+	if len(unreachable) > 0 {
+		issue(IssueWarning, "unreachable", "no answer from some nameservers", unreachable...)
+	}
+
+	// 5. SOA serials
+	serials := map[uint32][]string{}
+	var order []uint32
+	for _, p := range probes {
+		if !p.server.Authoritative || p.server.Lame {
+			continue
+		}
+		if _, ok := serials[p.server.Serial]; !ok {
+			order = append(order, p.server.Serial)
+		}
+		serials[p.server.Serial] = append(serials[p.server.Serial], p.label())
+	}
+	if len(order) > 1 {
+		var parts []string
+		for _, serial := range order {
+			parts = append(parts, fmt.Sprintf("%d on %s", serial, strings.Join(serials[serial], ", ")))
+		}
+		issue(IssueWarning, "serial_mismatch", "SOA serials differ: "+strings.Join(parts, "; "))
+	}
+
+	// 6. Record sets
+	var childNS []string
+	for _, qtype := range nsCheckTypes {
+		check := compareSets(probes, qtype)
+		if check == nil {
+			continue
+		}
+		info.Records = append(info.Records, *check)
+
+		if !check.Consistent {
+			issue(IssueWarning, "records_mismatch", fmt.Sprintf("%s records differ between nameservers", check.Type))
+		}
+		if qtype == dns.TypeNS {
+			for _, answer := range check.Answers {
+				for _, ns := range answer.Values {
+					if !slices.Contains(childNS, ns) {
+						childNS = append(childNS, ns)
+					}
+				}
+			}
+		}
+	}
+	slices.Sort(childNS)
+	info.ChildNS = childNS
+
+	// 7. Parent / child NS
+	if len(parentNS) > 0 && len(childNS) > 0 {
+		onlyParent, onlyChild := difference(info.ParentNS, childNS), difference(childNS, info.ParentNS)
+		if len(onlyParent) > 0 || len(onlyChild) > 0 {
+			msg := "parent and child NS sets differ"
+			if len(onlyParent) > 0 {
+				msg += "; only at the parent: " + strings.Join(onlyParent, ", ")
+			}
+			if len(onlyChild) > 0 {
+				msg += "; only in the zone: " + strings.Join(onlyChild, ", ")
+			}
+			issue(IssueWarning, "ns_mismatch", msg)
+		}
+	}
+
+	// 8. Network diversity (RFC 2182): same /24, same AS
+	subnets, asns := map[string][]string{}, map[uint][]string{}
+	var subnetOrder []string
+	var asnOrder []uint
+	for i, p := range probes {
+		if p.ip == nil {
+			continue
+		}
+
+		if v4 := p.ip.To4(); v4 != nil {
+			subnet := fmt.Sprintf("%d.%d.%d.0/24", v4[0], v4[1], v4[2])
+			if _, ok := subnets[subnet]; !ok {
+				subnetOrder = append(subnetOrder, subnet)
+			}
+			if !slices.Contains(subnets[subnet], p.server.Nameserver) {
+				subnets[subnet] = append(subnets[subnet], p.server.Nameserver)
+			}
+		}
+
+		asn, org := c.asn(p.ip)
+		if asn == 0 {
+			continue
+		}
+		info.Servers[i].ASN, info.Servers[i].ASOrg = asn, org
+		if _, ok := asns[asn]; !ok {
+			asnOrder = append(asnOrder, asn)
+		}
+		if !slices.Contains(asns[asn], p.server.Nameserver) {
+			asns[asn] = append(asns[asn], p.server.Nameserver)
+		}
+	}
+	for _, subnet := range subnetOrder {
+		if len(subnets[subnet]) > 1 {
+			issue(IssueWarning, "same_subnet", "nameservers share the network "+subnet, subnets[subnet]...)
+		}
+	}
+	for _, asn := range asnOrder {
+		if len(asns[asn]) > 1 {
+			issue(IssueWarning, "same_asn", fmt.Sprintf("nameservers share AS%d", asn), asns[asn]...)
+		}
+	}
+
+	info.Healthy = !slices.ContainsFunc(info.Issues, func(i models.NameserverIssue) bool {
+		return i.Severity == IssueError
+	})
+
+	return info
+}
+
+// delegation asks a server of the closest enclosing zone for the NS of
+// zone and returns the referral and its glue.
+func (c *NameserverChecker) delegation(zone string) (string, []string, map[string][]net.IP, error) {
+	labels := dns.SplitDomainName(zone)
+
+	var parent string
+	var servers []string
+	for i := 1; i <= len(labels) && len(servers) == 0; i++ {
+		parent = dns.Fqdn(strings.Join(labels[i:], "."))
+
+		res, err := c.Resolver.Query(parent, dns.TypeNS)
+		if err != nil {
+			return parent, nil, nil, err
+		}
+		servers = nsNames(res.Records)
+	}
+	if len(servers) == 0 {
+		return parent, nil, nil, errors.New("no nameserver found for the parent zone")
+	}
+
+	var lastErr error
+	for _, host := range servers {
+		for _, ip := range c.addresses(host) {
+			resp, err := c.ask(c.addr(ip), zone, dns.TypeNS)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+
+			if ns, glue := referral(resp, zone); len(ns) > 0 {
+				return parent, ns, glue, nil
+			}
+			lastErr = fmt.Errorf("%s returned no delegation (%s)", trimDot(host), dns.RcodeToString[resp.Rcode])
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.New("the parent nameservers have no address")
+	}
+
+	return parent, nil, nil, lastErr
+}
+
+// referral reads the NS of zone from a referral (or an answer, when the
+// parent server is also authoritative for zone) and the glue addresses.
+func referral(resp *dns.Msg, zone string) ([]string, map[string][]net.IP) {
+	var ns []string
+	for _, rr := range append(slices.Clone(resp.Answer), resp.Ns...) {
+		if n, ok := rr.(*dns.NS); ok && strings.EqualFold(n.Hdr.Name, zone) {
+			host := dns.CanonicalName(n.Ns)
+			if !slices.Contains(ns, host) {
+				ns = append(ns, host)
+			}
+		}
+	}
+
+	glue := map[string][]net.IP{}
+	for _, rr := range resp.Extra {
+		name := dns.CanonicalName(rr.Header().Name)
+		switch a := rr.(type) {
+		case *dns.A:
+			glue[name] = append(glue[name], a.A)
+		case *dns.AAAA:
+			glue[name] = append(glue[name], a.AAAA)
+		}
+	}
+
+	return ns, glue
+}
+
+// addresses resolves host (A and AAAA) through the recursive resolver.
+func (c *NameserverChecker) addresses(host string) []net.IP {
+	var ips []net.IP
+
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		res, err := c.Resolver.Query(host, qtype)
+		if err != nil {
+			continue
+		}
+		for _, rec := range res.Records {
+			if ip := net.ParseIP(rec.Address); ip != nil && rec.Type == dns.TypeToString[qtype] {
+				ips = append(ips, ip)
+			}
+		}
+	}
+
+	return ips
+}
+
+// probe asks one address for the SOA and, when it is authoritative, for
+// every compared record set.
+func (c *NameserverChecker) probe(p *authProbe, zone string) {
+	addr := c.addr(p.ip)
+
+	start := time.Now()
+	resp, err := c.ask(addr, zone, dns.TypeSOA)
+	p.server.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		p.server.Error = err.Error()
+		return
+	}
+
+	p.server.Rcode = dns.RcodeToString[resp.Rcode]
+	p.server.Authoritative = resp.Authoritative
+	if resp.Rcode != dns.RcodeSuccess || !resp.Authoritative {
+		p.server.Lame = true
+		return
+	}
+	for _, rr := range resp.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			p.server.Serial = soa.Serial
+		}
+	}
+
+	p.sets = map[uint16][]string{}
+	for _, qtype := range nsCheckTypes {
+		resp, err := c.ask(addr, zone, qtype)
+		if err != nil || resp.Rcode != dns.RcodeSuccess {
+			continue
+		}
+		p.sets[qtype] = answerValues(resp, zone, qtype)
+	}
+}
+
+func (c *NameserverChecker) ask(addr, name string, qtype uint16) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	msg.RecursionDesired = false
+	msg.SetEdns0(1232, false)
+
+	client := &dns.Client{Timeout: c.Timeout}
+	resp, _, err := client.Exchange(msg, addr)
+	if err == nil && resp.Truncated {
+		client.Net = "tcp"
+		resp, _, err = client.Exchange(msg, addr)
+	}
+
+	return resp, err
+}
+
+// answerValues is the sorted data of the zone's qtype RRset in resp.
+func answerValues(resp *dns.Msg, zone string, qtype uint16) []string {
+	values := []string{}
+
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype != qtype || !strings.EqualFold(rr.Header().Name, zone) {
+			continue
+		}
+		if rec := parseRR(rr, ""); rec != nil {
+			values = append(values, recordValue(*rec))
+		}
+	}
+	slices.Sort(values)
+
+	return values
+}
+
+// compareSets groups the servers by their qtype RRset; nil when no
+// server answered it.
+func compareSets(probes []*authProbe, qtype uint16) *models.RecordSetCheck {
+	check := &models.RecordSetCheck{Type: dns.TypeToString[qtype]}
+	index := map[string]int{}
+
+	for _, p := range probes {
+		values, ok := p.sets[qtype]
+		if !ok {
+			continue
+		}
+
+		key := strings.Join(values, "\n")
+		i, seen := index[key]
+		if !seen {
+			i = len(check.Answers)
+			index[key] = i
+			check.Answers = append(check.Answers, models.RecordSetAnswer{Values: values})
+		}
+		check.Answers[i].Servers = append(check.Answers[i].Servers, p.label())
+	}
+
+	if len(check.Answers) == 0 {
+		return nil
+	}
+	check.Consistent = len(check.Answers) == 1
+
+	return check
+}
+
+// nsNames returns the NS targets of records as canonical FQDNs.
+func nsNames(records []models.DNSRecord) []string {
+	var names []string
+	for _, rec := range records {
+		if rec.Type == "NS" && rec.Nameserver != "" {
+			names = append(names, dns.CanonicalName(rec.Nameserver))
+		}
+	}
+	return names
+}
+
+func trimDot(name string) string {
+	if name == "." {
+		return name
+	}
+	return strings.TrimSuffix(name, ".")
+}
+
+func trimDots(names []string) []string {
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		out = append(out, trimDot(name))
+	}
+	slices.Sort(out)
+	return out
+}
+
+// difference lists the names of a that are not in b.
+func difference(a, b []string) []string {
+	var out []string
+	for _, name := range a {
+		if !slices.Contains(b, name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
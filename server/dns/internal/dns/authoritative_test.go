@@ -0,0 +1,219 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"tools.bctechvibe.io.vn/server/internal/models"
+)
+
+// mapResolver is a recursive resolver backed by a map of "name type".
+type mapResolver map[string][]models.DNSRecord
+
+func (m mapResolver) Query(domain string, qtype uint16) (*Result, error) {
+	return &Result{Records: m[dns.CanonicalName(domain)+" "+dns.TypeToString[qtype]]}, nil
+}
+
+// serveAuth starts a UDP server answering with fn.
+func serveAuth(t *testing.T, fn func(r *dns.Msg) *dns.Msg) string {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		w.WriteMsg(fn(r))
+	})}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+
+	return pc.LocalAddr().String()
+}
+
+// childZone serves example.test. with the given serial and A record.
+func childZone(t *testing.T, serial uint32, a string) func(r *dns.Msg) *dns.Msg {
+	return func(r *dns.Msg) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Authoritative = true
+
+		q := r.Question[0]
+		if !strings.EqualFold(q.Name, "example.test.") {
+			m.Rcode = dns.RcodeNameError
+			return m
+		}
+
+		switch q.Qtype {
+		case dns.TypeSOA:
+			m.Answer = append(m.Answer, mustRR(t, fmt.Sprintf(
+				"example.test. 300 IN SOA ns1.example.test. hostmaster.example.test. %d 3600 600 86400 300", serial)))
+		case dns.TypeNS:
+			for _, ns := range []string{"ns1.example.test.", "NS2.example.test.", "ns3.example.test."} {
+				m.Answer = append(m.Answer, mustRR(t, "example.test. 300 IN NS "+ns))
+			}
+		case dns.TypeA:
+			m.Answer = append(m.Answer, mustRR(t, "example.test. 300 IN A "+a))
+		}
+		return m
+	}
+}
+
+func TestNameserverCheck(t *testing.T) {
+	parent := serveAuth(t, func(r *dns.Msg) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Ns = []dns.RR{
+			mustRR(t, "example.test. 3600 IN NS ns1.example.test."),
+			mustRR(t, "example.test. 3600 IN NS ns2.example.test."),
+			mustRR(t, "example.test. 3600 IN NS ns.other.test."),
+		}
+		// no glue for ns2
+		m.Extra = []dns.RR{mustRR(t, "ns1.example.test. 3600 IN A 192.0.2.1")}
+		return m
+	})
+
+	stubs := map[string]string{
+		"192.0.2.100":  parent,
+		"192.0.2.1":    serveAuth(t, childZone(t, 5, "203.0.113.10")),
+		"192.0.2.2":    serveAuth(t, childZone(t, 4, "203.0.113.20")),
+		"192.0.2.3":    serveAuth(t, childZone(t, 5, "203.0.113.10")),
+		"198.51.100.7": serveAuth(t, func(r *dns.Msg) *dns.Msg { return new(dns.Msg).SetRcode(r, dns.RcodeRefused) }),
+	}
+
+	recursive := mapResolver{
+		"test. NS":            {{Type: "NS", Nameserver: "ns.parent.test"}},
+		"ns.parent.test. A":   {{Type: "A", Address: "192.0.2.100"}},
+		"example.test. NS":    {{Type: "NS", Nameserver: "ns1.example.test"}, {Type: "NS", Nameserver: "ns3.example.test"}},
+		"ns2.example.test. A": {{Type: "A", Address: "192.0.2.2"}},
+		"ns3.example.test. A": {{Type: "A", Address: "192.0.2.3"}},
+		"ns.other.test. A":    {{Type: "A", Address: "198.51.100.7"}},
+	}
+
+	c := NewNameserverChecker(recursive)
+	c.Timeout = 2 * time.Second
+	c.addr = func(ip net.IP) string { return stubs[ip.String()] }
+	c.asn = func(ip net.IP) (uint, string) {
+		if strings.HasPrefix(ip.String(), "192.0.2.") {
+			return 64500, "Example Net"
+		}
+		return 64501, "Other Net"
+	}
+
+	info := c.Check("Example.Test")
+
+	if info.Zone != "example.test" || info.Parent != "test" {
+		t.Errorf("zone %q parent %q", info.Zone, info.Parent)
+	}
+	if want := []string{"ns.other.test", "ns1.example.test", "ns2.example.test"}; !slices.Equal(info.ParentNS, want) {
+		t.Errorf("parent NS %v, want %v", info.ParentNS, want)
+	}
+	if want := []string{"ns1.example.test", "ns2.example.test", "ns3.example.test"}; !slices.Equal(info.ChildNS, want) {
+		t.Errorf("child NS %v, want %v", info.ChildNS, want)
+	}
+	if len(info.Servers) != 4 {
+		t.Fatalf("%d servers: %+v", len(info.Servers), info.Servers)
+	}
+
+	ns1, ns2, other := info.Servers[0], info.Servers[1], info.Servers[2]
+	if !ns1.Glue || !ns1.InBailiwick || !ns1.Authoritative || ns1.Serial != 5 || ns1.ASN != 64500 {
+		t.Errorf("ns1 %+v", ns1)
+	}
+	if ns2.Glue || ns2.Serial != 4 {
+		t.Errorf("ns2 %+v", ns2)
+	}
+	if !other.Lame || other.Rcode != "REFUSED" || other.InBailiwick {
+		t.Errorf("lame server %+v", other)
+	}
+
+	issues := map[string]models.NameserverIssue{}
+	for _, i := range info.Issues {
+		issues[i.Code] = i
+	}
+	for code, severity := range map[string]string{
+		"lame":             IssueError,
+		"missing_glue":     IssueError,
+		"serial_mismatch":  IssueWarning,
+		"records_mismatch": IssueWarning,
+		"ns_mismatch":      IssueWarning,
+		"same_subnet":      IssueWarning,
+		"same_asn":         IssueWarning,
+	} {
+		if got, ok := issues[code]; !ok || got.Severity != severity {
+			t.Errorf("issue %s: %+v", code, got)
+		}
+	}
+	if len(issues) != 7 {
+		t.Errorf("unexpected issues %+v", info.Issues)
+	}
+
+	if s := issues["missing_glue"].Servers; !slices.Equal(s, []string{"ns2.example.test"}) {
+		t.Errorf("missing glue for %v", s)
+	}
+	if s := issues["same_subnet"].Servers; len(s) != 3 {
+		t.Errorf("same /24 %v", s)
+	}
+	if m := issues["ns_mismatch"].Message; !strings.Contains(m, "only at the parent: ns.other.test") || !strings.Contains(m, "only in the zone: ns3.example.test") {
+		t.Errorf("ns mismatch message %q", m)
+	}
+	if info.Healthy {
+		t.Error("lame delegation should make the zone unhealthy")
+	}
+
+	for _, rs := range info.Records {
+		if rs.Type == "NS" && !rs.Consistent {
+			t.Errorf("NS sets should match regardless of case: %+v", rs.Answers)
+		}
+		if rs.Type == "A" && (rs.Consistent || len(rs.Answers) != 2) {
+			t.Errorf("A sets %+v", rs)
+		}
+	}
+}
+
+func TestNameserverCheckHealthy(t *testing.T) {
+	zone := childZone(t, 7, "203.0.113.10")
+	parent := serveAuth(t, func(r *dns.Msg) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		for _, ns := range []string{"ns1.example.test.", "ns2.example.test.", "ns3.example.test."} {
+			m.Ns = append(m.Ns, mustRR(t, "example.test. 3600 IN NS "+ns))
+		}
+		m.Extra = []dns.RR{
+			mustRR(t, "ns1.example.test. 3600 IN A 192.0.2.1"),
+			mustRR(t, "ns2.example.test. 3600 IN A 198.51.100.2"),
+			mustRR(t, "ns3.example.test. 3600 IN A 203.0.113.3"),
+		}
+		return m
+	})
+
+	stubs := map[string]string{
+		"192.0.2.100":  parent,
+		"192.0.2.1":    serveAuth(t, zone),
+		"198.51.100.2": serveAuth(t, zone),
+		"203.0.113.3":  serveAuth(t, zone),
+	}
+
+	c := NewNameserverChecker(mapResolver{
+		"test. NS":          {{Type: "NS", Nameserver: "ns.parent.test"}},
+		"ns.parent.test. A": {{Type: "A", Address: "192.0.2.100"}},
+	})
+	c.addr = func(ip net.IP) string { return stubs[ip.String()] }
+	c.asn = func(net.IP) (uint, string) { return 0, "" }
+
+	info := c.Check("example.test.")
+
+	if !info.Healthy || len(info.Issues) != 0 {
+		t.Errorf("issues %+v", info.Issues)
+	}
+	for _, rs := range info.Records {
+		if !rs.Consistent {
+			t.Errorf("%s differs: %+v", rs.Type, rs.Answers)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package dns
 
 import (
+	"cmp"
 	"fmt"
+	"math"
+	"net"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -13,18 +17,34 @@ import (
 const (
 	rblMaxConcurrency = 10
 	rblTimeout        = 1200 * time.Millisecond
+
+	// Addresses of a domain input checked at most (each one is a query
+	// per provider)
+	rblMaxTargets = 8
 )
 
 // =======================
 // HELPERS
 // =======================
 
+// ReverseIP returns the DNSBL label of ip: the octets reversed for IPv4,
+// the 32 nibbles reversed for IPv6. Empty when ip isn't an address.
 func ReverseIP(ip string) string {
-	parts := strings.Split(ip, ".")
-	if len(parts) != 4 {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
 		return ""
 	}
-	return fmt.Sprintf("%s.%s.%s.%s", parts[3], parts[2], parts[1], parts[0])
+
+	if v4 := parsed.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d", v4[3], v4[2], v4[1], v4[0])
+	}
+
+	const hex = "0123456789abcdef"
+	labels := make([]string, 0, 32)
+	for i := len(parsed) - 1; i >= 0; i-- {
+		labels = append(labels, string(hex[parsed[i]&0x0f]), string(hex[parsed[i]>>4]))
+	}
+	return strings.Join(labels, ".")
 }
 
 func FormatRBLProviderName(host string) string {
@@ -41,199 +61,467 @@ func FormatRBLProviderName(host string) string {
 }
 
 // =======================
-// DNS LOW LEVEL
+// CHECKER
 // =======================
 
-func newDNSClient() *dns.Client {
-	return &dns.Client{
-		Net:     "udp",
-		Timeout: rblTimeout,
+// BlacklistChecker checks an IP, or a domain with its MX and A hosts,
+// against the IP and domain blocklists.
+type BlacklistChecker struct {
+	Resolver        Resolver // MX / A / AAAA of a domain input
+	IPProviders     []models.RBLProvider
+	DomainProviders []models.RBLProvider
+
+	// query asks the list for qname, DefaultRBLResolver outside tests
+	query func(qname, provider string, qtype uint16) ([]dns.RR, error)
+}
+
+func NewBlacklistChecker(r Resolver) *BlacklistChecker {
+	return &BlacklistChecker{
+		Resolver:        r,
+		IPProviders:     DefaultRBLRegistry.Providers(RBLTypeIP),
+		DomainProviders: DefaultRBLRegistry.Providers(RBLTypeDomain),
+		query:           DefaultRBLResolver.Query,
 	}
 }
 
-func lookupNS(zone string) ([]string, error) {
-	m := new(dns.Msg)
-	m.SetQuestion(dns.Fqdn(zone), dns.TypeNS)
+func newServerBlacklistChecker(serverKey string) *BlacklistChecker {
+	return NewBlacklistChecker(&fallbackResolver{rm: ResolverManagerFor(serverKey), transport: TransportDoH})
+}
 
-	c := newDNSClient()
-	r, _, err := c.Exchange(m, "1.1.1.1:53")
-	if err != nil {
-		return nil, err
+// Targets lists what input is checked as: the IP itself, or for a domain
+// its registrable domain, its A / AAAA addresses and those of its MX hosts.
+func (b *BlacklistChecker) Targets(input string) []models.BlacklistTarget {
+	input = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(input), "."))
+	if input == "" {
+		return nil
+	}
+	if ip := net.ParseIP(input); ip != nil {
+		return []models.BlacklistTarget{{IP: ip.String(), Source: "INPUT"}}
 	}
 
-	var out []string
-	for _, ans := range r.Answer {
-		if ns, ok := ans.(*dns.NS); ok {
-			out = append(out, ns.Ns)
+	targets := []models.BlacklistTarget{{Domain: orgDomain(input), Source: "DOMAIN"}}
+	seen := map[string]bool{}
+	add := func(host, source string) {
+		for _, ip := range b.addresses(host) {
+			if len(seen) < rblMaxTargets && !seen[ip] {
+				seen[ip] = true
+				targets = append(targets, models.BlacklistTarget{IP: ip, Host: host, Source: source})
+			}
 		}
 	}
-	return out, nil
-}
 
-func queryRBL(qname, provider string) ([]dns.RR, error) {
-	nsList, err := lookupNS(provider)
-	if err != nil || len(nsList) == 0 {
-		return nil, err
-	}
+	add(input, "A")
 
-	m := new(dns.Msg)
-	m.SetQuestion(dns.Fqdn(qname), dns.TypeA)
+	res, err := b.Resolver.Query(input, dns.TypeMX)
+	if err == nil {
+		var mx []models.DNSRecord
+		for _, r := range res.Records {
+			// "." is a null MX (RFC 7505)
+			if r.Type == "MX" && r.Exchange != "" && r.Exchange != "." {
+				mx = append(mx, r)
+			}
+		}
+		slices.SortStableFunc(mx, func(x, y models.DNSRecord) int { return int(x.Priority) - int(y.Priority) })
+		for _, r := range mx {
+			add(strings.ToLower(r.Exchange), "MX")
+		}
+	}
 
-	c := newDNSClient()
+	return targets
+}
 
-	for _, ns := range nsList {
-		r, _, err := c.Exchange(m, ns+":53")
-		if err == nil && r != nil {
-			return r.Answer, nil
+// addresses returns the IPv4 then IPv6 addresses of host.
+func (b *BlacklistChecker) addresses(host string) []string {
+	var out []string
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		res, err := b.Resolver.Query(host, qtype)
+		if err != nil {
+			continue
+		}
+		for _, r := range res.Records {
+			if (r.Type == "A" || r.Type == "AAAA") && r.Address != "" {
+				out = append(out, r.Address)
+			}
 		}
 	}
+	return out
+}
 
-	return nil, fmt.Errorf("all NS failed")
+// rblJob is one blocklist query.
+type rblJob struct {
+	provider models.RBLProvider
+	qname    string
+	target   models.BlacklistTarget
 }
 
-// =======================
-// NON STREAM
-// =======================
+func (b *BlacklistChecker) jobs(targets []models.BlacklistTarget) []rblJob {
+	var jobs []rblJob
 
-func CheckBlacklist(ip string) ([]models.BlacklistRecord, int, int) {
-	reversed := ReverseIP(ip)
-	if reversed == "" {
-		return nil, 0, 0
+	for _, t := range targets {
+		if t.Domain != "" {
+			for _, p := range b.DomainProviders {
+				jobs = append(jobs, rblJob{provider: p, qname: t.Domain + "." + p.Host, target: t})
+			}
+			continue
+		}
+
+		reversed := ReverseIP(t.IP)
+		if reversed == "" {
+			continue
+		}
+		v6 := net.ParseIP(t.IP).To4() == nil
+		for _, p := range b.IPProviders {
+			// Most IPv4 lists answer NXDOMAIN for any IPv6 name, skip them
+			if v6 && !p.IPv6 {
+				continue
+			}
+			jobs = append(jobs, rblJob{provider: p, qname: reversed + "." + p.Host, target: t})
+		}
 	}
 
-	var (
-		wg      sync.WaitGroup
-		mu      sync.Mutex
-		records []models.BlacklistRecord
-		checked int
-		listed  int
-	)
+	return jobs
+}
 
+// rblResult is the decoded answer of one blocklist query.
+type rblResult struct {
+	status   string // OK | LISTED | TIMEOUT | ERROR_BLOCKED
+	listings []models.RBLListing
+	reason   string
+}
+
+// run queries every job, at most rblMaxConcurrency at a time, and calls
+// done with each result as it comes in.
+func (b *BlacklistChecker) run(jobs []rblJob, done func(rblJob, rblResult)) {
+	var wg sync.WaitGroup
 	sem := make(chan struct{}, rblMaxConcurrency)
 
-	for _, rbl := range RBLProviders {
+	for _, job := range jobs {
 		wg.Add(1)
 		sem <- struct{}{}
 
-		go func(rbl models.RBLProvider) {
+		go func() {
 			defer wg.Done()
 			defer func() { <-sem }()
 
-			query := fmt.Sprintf("%s.%s", reversed, rbl.Host)
-			recs, err := queryRBL(query, rbl.Host)
+			done(job, b.lookup(job))
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (b *BlacklistChecker) lookup(job rblJob) rblResult {
+	recs, err := b.query(job.qname, job.provider.Host, dns.TypeA)
+	if err != nil {
+		return rblResult{status: "TIMEOUT"}
+	}
 
-			status := "OK"
-			if err != nil {
-				status = "TIMEOUT"
-			} else if len(recs) > 0 {
-				status = "LISTED"
+	res := decodeRBL(job.provider, recs)
+	if res.status != "LISTED" {
+		return res
+	}
+
+	// Most lists publish the reason / delisting link as TXT
+	if txts, err := b.query(job.qname, job.provider.Host, dns.TypeTXT); err == nil {
+		var reasons []string
+		for _, rr := range txts {
+			if txt, ok := rr.(*dns.TXT); ok {
+				reasons = append(reasons, strings.Join(txt.Txt, ""))
 			}
+		}
+		res.reason = strings.Join(reasons, " | ")
+	}
+
+	return res
+}
 
-			mu.Lock()
-			if err == nil {
-				checked++
-				if status == "LISTED" {
-					listed++
+// decodeRBL maps the A answers of a query to the sub-lists of p.
+// Answers outside 127.0.0.0/8 are not listings (RFC 5782 2.3), and
+// 127.255.255.x or a code marked Error means the list refused the query.
+func decodeRBL(p models.RBLProvider, answers []dns.RR) rblResult {
+	res := rblResult{status: "OK"}
+	seen := map[string]bool{}
+
+	add := func(code string) bool {
+		rc, known := p.Codes[code]
+		if rc.Error {
+			return false
+		}
+		if !seen[code] {
+			seen[code] = true
+			l := models.RBLListing{Code: code, Removal: p.Removal}
+			if known {
+				l.List, l.Meaning = rc.List, rc.Meaning
+				if rc.Removal != "" {
+					l.Removal = rc.Removal
 				}
 			}
-			records = append(records, models.BlacklistRecord{
-				Type:     "BLACKLIST",
-				Provider: rbl.Host,
-				Level:    rbl.Level,
-				Status:   status,
-				IP:       ip,
-			})
-			mu.Unlock()
-		}(rbl)
+			res.listings = append(res.listings, l)
+		}
+		return true
 	}
 
-	wg.Wait()
-	return records, checked, listed
+	for _, rr := range answers {
+		a, ok := rr.(*dns.A)
+		if !ok {
+			continue
+		}
+		ip := a.A.To4()
+		if ip == nil || ip[0] != 127 {
+			continue
+		}
+		if ip[1] == 255 && ip[2] == 255 {
+			return rblResult{status: "ERROR_BLOCKED"}
+		}
+
+		if !p.Bitmask {
+			if !add(ip.String()) {
+				return rblResult{status: "ERROR_BLOCKED"}
+			}
+			continue
+		}
+		for bit := 1; bit < 256; bit <<= 1 {
+			if int(ip[3])&bit != 0 && !add(fmt.Sprintf("%d.%d.%d.%d", ip[0], ip[1], ip[2], bit)) {
+				return rblResult{status: "ERROR_BLOCKED"}
+			}
+		}
+	}
+
+	if len(res.listings) > 0 {
+		res.status = "LISTED"
+	}
+	return res
+}
+
+// record is the result of one job as a blacklist record.
+func (job rblJob) record(res rblResult) models.BlacklistRecord {
+	weight := job.provider.Weight
+	if weight == 0 {
+		weight = levelWeight(job.provider.Level)
+	}
+
+	return models.BlacklistRecord{
+		Type:      "BLACKLIST",
+		Provider:  job.provider.Host,
+		Level:     job.provider.Level,
+		Status:    res.status,
+		IP:        job.target.IP,
+		Host:      job.target.Host,
+		Domain:    job.target.Domain,
+		Listings:  res.listings,
+		Reason:    res.reason,
+		Weight:    weight,
+		CheckedAt: time.Now().UTC(),
+	}
 }
 
 // =======================
-// STREAM
+// SCORE
 // =======================
 
-func StreamBlacklist(ip string, cb func(models.BlacklistStreamEvent)) {
-	total := len(RBLProviders) // ← đưa lên đầu
-	reversed := ReverseIP(ip)
+// rblHighListedMaxScore caps the score once a High list (Spamhaus ZEN,
+// SBL...) lists the input: mail from it is effectively undeliverable,
+// however many other lists answered OK.
+const rblHighListedMaxScore = 94
+
+// ScoreBlacklist is the weighted reputation of records: 100 minus the
+// share of the answering lists' weight that lists the input, so IPs and
+// domains (checked on more targets) score on the same scale. A listing on
+// a High list rates at least "poor". Only the lists that answered count,
+// TIMEOUT / ERROR_BLOCKED say nothing.
+func ScoreBlacklist(records []models.BlacklistRecord) models.BlacklistScore {
+	var (
+		s    models.BlacklistScore
+		high bool
+	)
 
-	// === PHASE 1: INIT ===
-	cb(models.BlacklistStreamEvent{
-		Type:   "BLACKLIST_INIT",
-		IP:     ip,
-		Listed: 0,
-		Total:  total,
-	})
+	for _, r := range records {
+		switch r.Status {
+		case "LISTED":
+			s.Penalty += r.Weight
+			s.Weight += r.Weight
+			high = high || r.Level == "High"
+		case "OK":
+			s.Weight += r.Weight
+		}
+	}
 
-	if reversed == "" {
-		cb(models.BlacklistStreamEvent{
-			Type:   "BLACKLIST_SUMMARY",
-			IP:     ip,
-			Listed: 0,
-			Total:  total,
-		})
-		return
+	if s.Weight == 0 {
+		s.Rating = "unknown"
+		return s
 	}
 
-	// total := len(RBLProviders)   // ← đưa lên trên
+	// A few dozen lists answer for every target: a listing only costs a
+	// few points of the share, High lists are capped below
+	s.Score = 100 - int(math.Ceil(100*s.Penalty/s.Weight))
+	if high {
+		s.Score = min(s.Score, rblHighListedMaxScore)
+	}
 
-	type result struct {
-		host   string
-		level  string
-		status string
+	switch {
+	case s.Penalty == 0:
+		s.Rating = "clean"
+	case s.Score >= 95:
+		s.Rating = "fair"
+	case s.Score >= 85:
+		s.Rating = "poor"
+	default:
+		s.Rating = "bad"
 	}
 
-	results := make(chan result, total)
-	sem := make(chan struct{}, rblMaxConcurrency)
+	return s
+}
 
-	for _, rbl := range RBLProviders {
-		sem <- struct{}{}
-		go func(rbl models.RBLProvider) {
-			defer func() { <-sem }()
+// =======================
+// NON STREAM
+// =======================
 
-			query := fmt.Sprintf("%s.%s", reversed, rbl.Host)
-			recs, err := queryRBL(query, rbl.Host)
+// CheckBlacklist checks input (IP or domain), resolving domains through
+// serverKey.
+func CheckBlacklist(serverKey, input string) ([]models.BlacklistRecord, int, int) {
+	return newServerBlacklistChecker(serverKey).Check(input)
+}
 
-			status := "OK"
-			if err != nil {
-				status = "TIMEOUT"
-			} else if len(recs) > 0 {
-				status = "LISTED"
-			}
+func (b *BlacklistChecker) Check(input string) ([]models.BlacklistRecord, int, int) {
+	return b.check(b.jobs(b.Targets(input)))
+}
 
-			results <- result{
-				host:   rbl.Host,
-				level:  rbl.Level,
-				status: status,
-			}
-		}(rbl)
+func (b *BlacklistChecker) check(jobs []rblJob) ([]models.BlacklistRecord, int, int) {
+	var (
+		mu      sync.Mutex
+		records []models.BlacklistRecord
+		checked int
+		listed  int
+	)
+
+	b.run(jobs, func(job rblJob, res rblResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch res.status {
+		case "LISTED":
+			listed++
+			checked++
+		case "OK":
+			checked++
+		}
+		records = append(records, job.record(res))
+	})
+
+	return records, checked, listed
+}
+
+// ReportBlacklist checks input and builds the exportable report.
+func ReportBlacklist(serverKey, input string) *models.BlacklistReport {
+	return newServerBlacklistChecker(serverKey).Report(input)
+}
+
+func (b *BlacklistChecker) Report(input string) *models.BlacklistReport {
+	report := &models.BlacklistReport{
+		Input:     input,
+		CheckedAt: time.Now().UTC(),
+		Targets:   []models.BlacklistTarget{},
+		Listings:  []models.BlacklistRecord{},
+		Records:   []models.BlacklistRecord{},
+	}
+	report.Targets = append(report.Targets, b.Targets(input)...)
+
+	jobs := b.jobs(report.Targets)
+	records, checked, listed := b.check(jobs)
+	report.Records = append(report.Records, records...)
+	report.Checked, report.Listed, report.Total = checked, listed, len(jobs)
+
+	// Listings first, the heaviest lists on top
+	slices.SortStableFunc(report.Records, func(x, y models.BlacklistRecord) int {
+		return cmp.Or(
+			cmp.Compare(rblStatusOrder(x.Status), rblStatusOrder(y.Status)),
+			cmp.Compare(y.Weight, x.Weight),
+			strings.Compare(x.Provider, y.Provider),
+			strings.Compare(x.IP+x.Domain, y.IP+y.Domain),
+		)
+	})
+	for _, r := range report.Records {
+		if r.Status == "LISTED" {
+			report.Listings = append(report.Listings, r)
+		}
 	}
 
-	listed := 0
+	report.Score = ScoreBlacklist(report.Records)
+	return report
+}
 
-	for i := 0; i < total; i++ {
-		r := <-results
+func rblStatusOrder(status string) int {
+	switch status {
+	case "LISTED":
+		return 0
+	case "ERROR_BLOCKED", "TIMEOUT":
+		return 1
+	default:
+		return 2
+	}
+}
 
-		if r.status == "LISTED" {
+// =======================
+// STREAM
+// =======================
+
+// StreamBlacklist streams the check of input (IP or domain), resolving
+// domains through serverKey.
+func StreamBlacklist(serverKey, input string, cb func(models.BlacklistStreamEvent)) {
+	newServerBlacklistChecker(serverKey).Stream(input, cb)
+}
+
+func (b *BlacklistChecker) Stream(input string, cb func(models.BlacklistStreamEvent)) {
+	targets := b.Targets(input)
+	jobs := b.jobs(targets)
+	total := len(jobs)
+
+	// === PHASE 1: INIT ===
+	cb(models.BlacklistStreamEvent{
+		Type:    "BLACKLIST_INIT",
+		IP:      input,
+		Listed:  0,
+		Total:   total,
+		Targets: targets,
+	})
+
+	// === PHASE 2: one event per query, in completion order ===
+	var (
+		mu      sync.Mutex
+		records []models.BlacklistRecord
+		listed  int
+	)
+
+	b.run(jobs, func(job rblJob, res rblResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if res.status == "LISTED" {
 			listed++
 		}
+		records = append(records, job.record(res))
 
 		cb(models.BlacklistStreamEvent{
 			Type:     "BLACKLIST",
-			Provider: r.host,
-			Status:   r.status,
-			Level:    r.level,
+			Provider: job.provider.Host,
+			Status:   res.status,
+			Level:    job.provider.Level,
+			IP:       job.target.IP,
+			Host:     job.target.Host,
+			Domain:   job.target.Domain,
+			Listings: res.listings,
+			Reason:   res.reason,
 		})
-	}
+	})
 
+	score := ScoreBlacklist(records)
 	cb(models.BlacklistStreamEvent{
 		Type:     "BLACKLIST_SUMMARY",
 		Provider: "",
 		Status:   "",
-		IP:       ip,
+		IP:       input,
 		Listed:   listed,
 		Total:    total,
+		Score:    &score,
 	})
 }
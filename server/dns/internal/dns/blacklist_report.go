@@ -0,0 +1,171 @@
+/*
+File: server/internal/dns/blacklist_report.go
+Description: Export of a blacklist report - CSV (one row per listing)
+and a standalone HTML page laid out for printing / saving as PDF.
+*/
+package dns
+
+import (
+	"encoding/csv"
+	"html/template"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/internal/models"
+)
+
+const rblReportTimeFormat = "2006-01-02 15:04:05 MST"
+
+// ============================================
+// CSV
+// ============================================
+
+var rblCSVHeader = []string{
+	"checked_at", "input", "target", "host", "provider", "level", "weight",
+	"code", "list", "meaning", "reason", "removal",
+}
+
+// WriteBlacklistCSV writes one row per listing code of the report.
+func WriteBlacklistCSV(w io.Writer, r *models.BlacklistReport) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(rblCSVHeader); err != nil {
+		return err
+	}
+
+	for _, rec := range r.Listings {
+		listings := rec.Listings
+		if len(listings) == 0 {
+			listings = []models.RBLListing{{}}
+		}
+
+		for _, l := range listings {
+			row := []string{
+				rec.CheckedAt.Format(time.RFC3339), r.Input, rblTarget(rec), rec.Host,
+				rec.Provider, rec.Level, strconv.FormatFloat(rec.Weight, 'f', -1, 64),
+				l.Code, l.List, l.Meaning, rec.Reason, l.Removal,
+			}
+			for i := range row {
+				row[i] = csvSafe(row[i])
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvSafe stops spreadsheets from running a cell as a formula: reasons
+// come from the lists' TXT records.
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+func rblTarget(rec models.BlacklistRecord) string {
+	if rec.Domain != "" {
+		return rec.Domain
+	}
+	return rec.IP
+}
+
+// ============================================
+// HTML
+// ============================================
+
+// WriteBlacklistHTML writes the report as a standalone page.
+func WriteBlacklistHTML(w io.Writer, r *models.BlacklistReport) error {
+	return rblReportTemplate.Execute(w, r)
+}
+
+var rblReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time":   func(t time.Time) string { return t.UTC().Format(rblReportTimeFormat) },
+	"target": rblTarget,
+	"removal": func(rec models.BlacklistRecord) []string {
+		var out []string
+		for _, l := range rec.Listings {
+			if l.Removal != "" && !slices.Contains(out, l.Removal) {
+				out = append(out, l.Removal)
+			}
+		}
+		return out
+	},
+	"safeURL": func(u string) template.URL {
+		// Only web links, anything else is printed as text
+		if strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "http://") {
+			return template.URL(u)
+		}
+		return template.URL("#")
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Blacklist report - {{.Input}}</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Roboto, Arial, sans-serif; color: #222; margin: 2em; font-size: 14px; }
+  h1 { font-size: 1.5em; margin-bottom: .2em; }
+  h2 { font-size: 1.15em; margin-top: 1.6em; border-bottom: 1px solid #ccc; padding-bottom: .2em; }
+  table { border-collapse: collapse; width: 100%; margin-top: .5em; }
+  th, td { border: 1px solid #ccc; padding: .35em .5em; text-align: left; vertical-align: top; }
+  th { background: #f2f2f2; }
+  .meta td { border: none; padding: .1em 1em .1em 0; }
+  .score { font-size: 1.3em; font-weight: bold; }
+  .clean { color: #1b7f3b; } .fair { color: #a86b00; } .poor, .bad { color: #b3261e; }
+  .muted { color: #666; }
+  a { color: #1a56db; word-break: break-all; }
+  @media print {
+    body { margin: 0; font-size: 11px; }
+    a { color: #222; text-decoration: none; }
+    a[href^="http"]::after { content: " (" attr(href) ")"; font-size: .9em; }
+    tr { page-break-inside: avoid; }
+  }
+</style>
+</head>
+<body>
+<h1>Blacklist report</h1>
+<table class="meta">
+  <tr><td>Checked</td><td><strong>{{.Input}}</strong></td></tr>
+  <tr><td>Date</td><td>{{time .CheckedAt}}</td></tr>
+  <tr><td>Reputation</td><td><span class="score {{.Score.Rating}}">{{.Score.Score}}/100 ({{.Score.Rating}})</span></td></tr>
+  <tr><td>Result</td><td>listed on {{.Listed}} of {{.Checked}} lists that answered ({{.Total}} queried)</td></tr>
+</table>
+
+{{if gt (len .Targets) 1}}
+<h2>Checked addresses</h2>
+<table>
+  <tr><th>Address / domain</th><th>Host</th><th>Source</th></tr>
+  {{range .Targets}}<tr><td>{{if .Domain}}{{.Domain}}{{else}}{{.IP}}{{end}}</td><td>{{.Host}}</td><td>{{.Source}}</td></tr>
+  {{end}}
+</table>
+{{end}}
+
+<h2>Listings</h2>
+{{if .Listings}}
+<table>
+  <tr><th>Blocklist</th><th>Level</th><th>Listed</th><th>Reason</th><th>Delisting</th><th>Checked</th></tr>
+  {{range .Listings}}
+  <tr>
+    <td>{{.Provider}}</td>
+    <td>{{.Level}}</td>
+    <td>{{target .}}{{if .Host}} <span class="muted">({{.Host}})</span>{{end}}
+      {{range .Listings}}<br><span class="muted">{{.Code}}</span>{{if .List}} {{.List}}{{end}}{{if .Meaning}}: {{.Meaning}}{{end}}{{end}}</td>
+    <td>{{.Reason}}</td>
+    <td>{{range removal .}}<a href="{{safeURL .}}">{{.}}</a><br>{{else}}<span class="muted">-</span>{{end}}</td>
+    <td>{{time .CheckedAt}}</td>
+  </tr>
+  {{end}}
+</table>
+{{else}}
+<p class="clean">Not listed on any of the lists that answered.</p>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,365 @@
+package dns
+
+import (
+	"encoding/csv"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"tools.bctechvibe.io.vn/server/internal/models"
+)
+
+func TestReverseIP(t *testing.T) {
+	tests := map[string]string{
+		"192.0.2.1":       "1.2.0.192",
+		"2001:db8::1":     "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2",
+		"::ffff:10.0.0.1": "1.0.0.10",
+		"example.com":     "",
+		"1.2.3":           "",
+	}
+
+	for in, want := range tests {
+		if got := ReverseIP(in); got != want {
+			t.Errorf("ReverseIP(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// blacklistStub lists each qname of listed with the given A answer.
+func blacklistStub(listed map[string]string) *BlacklistChecker {
+	b := NewBlacklistChecker(mapResolver{
+		"mail.example.co.uk. A": {{Type: "A", Address: "192.0.2.1"}},
+		"mail.example.co.uk. MX": {
+			{Type: "MX", Priority: 20, Exchange: "mx2.example.net"},
+			{Type: "MX", Priority: 10, Exchange: "mx1.example.net"},
+		},
+		"mx1.example.net. A":    {{Type: "A", Address: "198.51.100.10"}},
+		"mx1.example.net. AAAA": {{Type: "AAAA", Address: "2001:db8::10"}},
+		// Shared with the A host, checked once
+		"mx2.example.net. A": {{Type: "A", Address: "192.0.2.1"}},
+	})
+	b.IPProviders = []models.RBLProvider{
+		{Host: "v4.rbl.test", Level: "High"},
+		{Host: "v6.rbl.test", Level: "Medium", IPv6: true},
+	}
+	b.DomainProviders = []models.RBLProvider{{Host: "dbl.rbl.test", Level: "High"}}
+	b.query = func(qname, zone string, qtype uint16) ([]dns.RR, error) {
+		if zone == "down.rbl.test" {
+			return nil, errors.New("all NS failed")
+		}
+		code, ok := listed[qname]
+		if !ok {
+			return nil, nil
+		}
+		if qtype == dns.TypeTXT {
+			return []dns.RR{&dns.TXT{Txt: []string{"Listed, see ", "https://rbl.test/" + qname}}}, nil
+		}
+		return []dns.RR{&dns.A{A: net.ParseIP(code)}}, nil
+	}
+	return b
+}
+
+func TestBlacklistTargets(t *testing.T) {
+	b := blacklistStub(nil)
+
+	got := b.Targets("Mail.Example.co.uk.")
+	want := []models.BlacklistTarget{
+		{Domain: "example.co.uk", Source: "DOMAIN"},
+		{IP: "192.0.2.1", Host: "mail.example.co.uk", Source: "A"},
+		{IP: "198.51.100.10", Host: "mx1.example.net", Source: "MX"},
+		{IP: "2001:db8::10", Host: "mx1.example.net", Source: "MX"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("targets %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("target %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if got := b.Targets("2001:DB8::1"); len(got) != 1 || got[0].IP != "2001:db8::1" {
+		t.Errorf("IPv6 targets %+v", got)
+	}
+}
+
+func TestBlacklistJobs(t *testing.T) {
+	b := blacklistStub(nil)
+
+	jobs := b.jobs([]models.BlacklistTarget{
+		{Domain: "example.co.uk"},
+		{IP: "192.0.2.1"},
+		{IP: "2001:db8::1"},
+	})
+
+	var qnames []string
+	for _, j := range jobs {
+		qnames = append(qnames, j.qname)
+	}
+	want := []string{
+		"example.co.uk.dbl.rbl.test",
+		"1.2.0.192.v4.rbl.test",
+		"1.2.0.192.v6.rbl.test",
+		ReverseIP("2001:db8::1") + ".v6.rbl.test", // no IPv6 query to v4.rbl.test
+	}
+	if strings.Join(qnames, " ") != strings.Join(want, " ") {
+		t.Errorf("queries %v, want %v", qnames, want)
+	}
+}
+
+func TestBlacklistStream(t *testing.T) {
+	b := blacklistStub(map[string]string{
+		"example.co.uk.dbl.rbl.test":               "127.0.1.2",
+		ReverseIP("2001:db8::10") + ".v6.rbl.test": "127.0.0.2",
+		"1.2.0.192.v6.rbl.test":                    "127.255.255.254",
+	})
+	b.IPProviders = append(b.IPProviders, models.RBLProvider{Host: "down.rbl.test", Level: "Low"})
+
+	var (
+		mu     sync.Mutex
+		events []models.BlacklistStreamEvent
+	)
+	b.Stream("mail.example.co.uk", func(e models.BlacklistStreamEvent) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	})
+
+	// 1 domain list, 3 IPv4 lists x 2 addresses, 1 IPv6 list
+	init, summary := events[0], events[len(events)-1]
+	if init.Type != "BLACKLIST_INIT" || init.Total != 8 || len(init.Targets) != 4 {
+		t.Fatalf("init %+v", init)
+	}
+	if summary.Type != "BLACKLIST_SUMMARY" || summary.Listed != 2 || summary.Total != 8 || len(events) != 10 {
+		t.Errorf("summary %+v after %d events", summary, len(events))
+	}
+	// dbl.rbl.test (High, 3) and v6.rbl.test (Medium, 2) of 13
+	if summary.Score == nil || summary.Score.Score != 61 {
+		t.Errorf("score %+v", summary.Score)
+	}
+
+	status := map[string]string{}
+	for _, e := range events[1 : len(events)-1] {
+		status[e.Provider+" "+e.IP+e.Domain] = e.Status
+	}
+	if status["dbl.rbl.test example.co.uk"] != "LISTED" ||
+		status["v6.rbl.test 2001:db8::10"] != "LISTED" ||
+		status["down.rbl.test 198.51.100.10"] != "TIMEOUT" ||
+		status["v4.rbl.test 192.0.2.1"] != "OK" ||
+		status["v6.rbl.test 192.0.2.1"] != "ERROR_BLOCKED" {
+		t.Errorf("statuses %v", status)
+	}
+}
+
+func TestBlacklistCheck(t *testing.T) {
+	b := blacklistStub(map[string]string{"1.2.0.192.v4.rbl.test": "127.0.0.2"})
+
+	records, checked, listed := b.Check("192.0.2.1")
+
+	if len(records) != 2 || checked != 2 || listed != 1 {
+		t.Errorf("%d records, %d checked, %d listed", len(records), checked, listed)
+	}
+	for _, r := range records {
+		if r.Provider == "v4.rbl.test" && (len(r.Listings) != 1 || r.Reason != "Listed, see https://rbl.test/1.2.0.192.v4.rbl.test") {
+			t.Errorf("listing %+v", r)
+		}
+	}
+
+	if records, _, _ := b.Check(""); len(records) != 0 {
+		t.Errorf("records for an empty input: %+v", records)
+	}
+}
+
+func TestDecodeRBL(t *testing.T) {
+	provider := func(host string) models.RBLProvider {
+		for _, p := range append(RBLProviders, DomainRBLProviders...) {
+			if p.Host == host {
+				return p
+			}
+		}
+		t.Fatalf("no provider %s", host)
+		return models.RBLProvider{}
+	}
+	zen := provider("zen.spamhaus.org")
+	barracuda := provider("b.barracudacentral.org")
+	uribl := provider("multi.uribl.com")
+	plain := models.RBLProvider{Host: "plain.rbl.test", Removal: "https://plain.rbl.test/"}
+
+	answers := func(ips ...string) []dns.RR {
+		var rrs []dns.RR
+		for _, ip := range ips {
+			rrs = append(rrs, &dns.A{A: net.ParseIP(ip)})
+		}
+		return rrs
+	}
+
+	tests := []struct {
+		name     string
+		provider models.RBLProvider
+		answers  []dns.RR
+		status   string
+		lists    []string
+	}{
+		{"ZEN SBL and PBL", zen, answers("127.0.0.2", "127.0.0.10"), "LISTED", []string{"SBL", "PBL"}},
+		{"ZEN XBL", zen, answers("127.0.0.4"), "LISTED", []string{"XBL"}},
+		{"ZEN open resolver", zen, answers("127.255.255.254"), "ERROR_BLOCKED", nil},
+		{"refusal on any list", plain, answers("127.255.255.255"), "ERROR_BLOCKED", nil},
+		{"Barracuda", barracuda, answers("127.0.0.2"), "LISTED", []string{"BRBL"}},
+		{"URIBL bitmask", uribl, answers("127.0.0.10"), "LISTED", []string{"black", "red"}},
+		{"URIBL refused", uribl, answers("127.0.0.1"), "ERROR_BLOCKED", nil},
+		{"unknown code", plain, answers("127.0.0.5"), "LISTED", []string{""}},
+		{"outside 127/8", plain, answers("192.0.2.1"), "OK", nil},
+		{"no answer", zen, nil, "OK", nil},
+	}
+
+	for _, tt := range tests {
+		res := decodeRBL(tt.provider, tt.answers)
+
+		var lists []string
+		for _, l := range res.listings {
+			lists = append(lists, l.List)
+			if l.Removal == "" {
+				t.Errorf("%s: %s has no removal URL", tt.name, l.Code)
+			}
+		}
+		if res.status != tt.status || strings.Join(lists, ",") != strings.Join(tt.lists, ",") {
+			t.Errorf("%s: %s %v, want %s %v", tt.name, res.status, lists, tt.status, tt.lists)
+		}
+	}
+
+	if res := decodeRBL(zen, answers("127.0.0.10")); res.listings[0].Meaning == "" || res.listings[0].Code != "127.0.0.10" {
+		t.Errorf("PBL listing %+v", res.listings[0])
+	}
+}
+
+func TestScoreBlacklist(t *testing.T) {
+	rec := func(status string, weight float64) models.BlacklistRecord {
+		return models.BlacklistRecord{Status: status, Level: "Medium", Weight: weight}
+	}
+	high := func(status string) models.BlacklistRecord {
+		return models.BlacklistRecord{Status: status, Level: "High", Weight: 3}
+	}
+
+	tests := []struct {
+		name    string
+		records []models.BlacklistRecord
+		score   int
+		rating  string
+	}{
+		{"clean", []models.BlacklistRecord{high("OK"), rec("OK", 1)}, 100, "clean"},
+		{"one Medium list", []models.BlacklistRecord{rec("LISTED", 2), rec("OK", 115)}, 98, "fair"},
+		{"one High list", []models.BlacklistRecord{high("LISTED"), rec("OK", 114)}, 94, "poor"},
+		{"High list not answering", []models.BlacklistRecord{high("TIMEOUT"), rec("LISTED", 2), rec("OK", 115)}, 98, "fair"},
+		{"three High lists", []models.BlacklistRecord{high("LISTED"), high("LISTED"), high("LISTED"), rec("OK", 91)}, 91, "poor"},
+		{"many lists", []models.BlacklistRecord{high("LISTED"), high("LISTED"), high("LISTED"), high("LISTED"), rec("OK", 8)}, 40, "bad"},
+		{"errors don't count", []models.BlacklistRecord{high("TIMEOUT"), high("ERROR_BLOCKED"), rec("OK", 1)}, 100, "clean"},
+		{"nothing answered", []models.BlacklistRecord{high("TIMEOUT")}, 0, "unknown"},
+	}
+
+	for _, tt := range tests {
+		s := ScoreBlacklist(tt.records)
+		if s.Score != tt.score || s.Rating != tt.rating {
+			t.Errorf("%s: %d %s, want %d %s", tt.name, s.Score, s.Rating, tt.score, tt.rating)
+		}
+	}
+
+	if s := ScoreBlacklist([]models.BlacklistRecord{rec("LISTED", 3), rec("OK", 2), rec("TIMEOUT", 1)}); s.Penalty != 3 || s.Weight != 5 {
+		t.Errorf("penalty %v, weight %v", s.Penalty, s.Weight)
+	}
+
+	// The same share of listings scores the same for one IP and for a
+	// domain checked on four targets
+	ip := []models.BlacklistRecord{rec("LISTED", 2), rec("OK", 48)}
+	var domain []models.BlacklistRecord
+	for range 4 {
+		domain = append(domain, ip...)
+	}
+	if a, b := ScoreBlacklist(ip), ScoreBlacklist(domain); a.Score != b.Score {
+		t.Errorf("IP scores %d, domain %d", a.Score, b.Score)
+	}
+}
+
+func TestBlacklistReport(t *testing.T) {
+	b := blacklistStub(map[string]string{
+		"example.co.uk.dbl.rbl.test": "127.0.1.2", // High
+		"10.100.51.198.v6.rbl.test":  "127.0.0.2", // Medium
+	})
+
+	r := b.Report("mail.example.co.uk")
+
+	if r.Total != 6 || r.Listed != 2 || len(r.Listings) != 2 || len(r.Records) != 6 || len(r.Targets) != 4 {
+		t.Fatalf("report %d total, %d listed, %d listings, %d records", r.Total, r.Listed, len(r.Listings), len(r.Records))
+	}
+	// High (3) before Medium (2), listings before the rest
+	if r.Listings[0].Provider != "dbl.rbl.test" || r.Listings[1].IP != "198.51.100.10" || r.Records[2].Status == "LISTED" {
+		t.Errorf("order %+v", r.Listings)
+	}
+	if r.Score.Score != 66 || r.Score.Rating != "bad" || r.CheckedAt.IsZero() || r.Listings[0].CheckedAt.IsZero() {
+		t.Errorf("score %+v, checked %v", r.Score, r.CheckedAt)
+	}
+
+	empty := b.Report("")
+	if empty.Targets == nil || empty.Listings == nil || empty.Records == nil || empty.Score.Rating != "unknown" {
+		t.Errorf("empty report %+v", empty)
+	}
+}
+
+func TestBlacklistReportExport(t *testing.T) {
+	at := time.Date(2026, 10, 17, 8, 30, 0, 0, time.UTC)
+	r := &models.BlacklistReport{
+		Input:     "example.com",
+		CheckedAt: at,
+		Targets:   []models.BlacklistTarget{{Domain: "example.com", Source: "DOMAIN"}, {IP: "192.0.2.1", Host: "example.com", Source: "A"}},
+		Checked:   2, Listed: 1, Total: 2,
+		Score: models.BlacklistScore{Score: 70, Rating: "fair"},
+		Listings: []models.BlacklistRecord{{
+			Provider: "zen.spamhaus.org", Level: "High", Status: "LISTED", IP: "192.0.2.1", Host: "example.com", Weight: 3, CheckedAt: at,
+			Listings: []models.RBLListing{
+				{Code: "127.0.0.2", List: "SBL", Meaning: "Spam source", Removal: "https://check.spamhaus.org/"},
+				{Code: "127.0.0.4", List: "XBL", Meaning: "Infected host", Removal: "https://check.spamhaus.org/"},
+			},
+			Reason: "=HYPERLINK(\"http://evil\") <script>",
+		}},
+	}
+
+	var csvOut strings.Builder
+	if err := WriteBlacklistCSV(&csvOut, r); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(csvOut.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[1][4] != "zen.spamhaus.org" || rows[2][8] != "XBL" || rows[1][0] != "2026-10-17T08:30:00Z" {
+		t.Errorf("CSV %q", rows)
+	}
+	if !strings.HasPrefix(rows[1][10], "'=") {
+		t.Errorf("formula not neutralized: %q", rows[1][10])
+	}
+
+	var html strings.Builder
+	if err := WriteBlacklistHTML(&html, r); err != nil {
+		t.Fatal(err)
+	}
+	page := html.String()
+	for _, want := range []string{
+		"70/100 (fair)",
+		`<a href="https://check.spamhaus.org/">`,
+		"127.0.0.4</span> XBL: Infected host",
+		"2026-10-17 08:30:00 UTC",
+		"&lt;script&gt;",
+		"Checked addresses",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("HTML has no %q", want)
+		}
+	}
+	// One link for the two codes with the same page
+	if n := strings.Count(page, `href="https://check.spamhaus.org/"`); n != 1 {
+		t.Errorf("%d removal links", n)
+	}
+}
@@ -12,13 +12,22 @@ var DNSServers = map[string]string{
 	"cloudflare": "1.1.1.1:53",
 	"quad9":      "9.9.9.9:53",
 	"opendns":    "208.67.222.222:53",
+	"adguard":    "94.140.14.14:53",
 }
 
+// Built-in IP blocklists, the registry default when DNS_RBL_PROVIDERS
+// isn't set
 var RBLProviders = []models.RBLProvider{
 	// High Priority RBLs
-	{Host: "b.barracudacentral.org", Level: "High"},         // BARRACUDA
-	{Host: "zen.spamhaus.org", Level: "High"},               // Spamhaus ZEN (gộp SBL/XBL/PBL)
-	{Host: "bl.spamcop.net", Level: "High"},                 // SPAMCOP
+	// BARRACUDA
+	{Host: "b.barracudacentral.org", Level: "High",
+		Codes: barracudaCodes, Removal: "https://www.barracudacentral.org/rbl/removal-request"},
+	// Spamhaus ZEN (gộp SBL/XBL/PBL)
+	{Host: "zen.spamhaus.org", Level: "High", IPv6: true,
+		Codes: spamhausZENCodes, Removal: "https://check.spamhaus.org/"},
+	// SPAMCOP
+	{Host: "bl.spamcop.net", Level: "High",
+		Codes: spamcopCodes, Removal: "https://www.spamcop.net/bl.shtml"},
 	{Host: "dnsbl-1.uceprotect.net", Level: "High"},         // UCEPROTECT Level 1
 	{Host: "dnsbl.blocklist.de", Level: "High"},             // BLOCKLIST.DE
 	{Host: "bl.mailspike.net", Level: "High"},               // MAILSPIKE BL
@@ -32,7 +41,7 @@ var RBLProviders = []models.RBLProvider{
 	{Host: "dnsbl-2.uceprotect.net", Level: "Medium"},        // UCEPROTECT Level 2
 	{Host: "dnsbl.0spam.org", Level: "Medium"},               // 0SPAM
 	{Host: "dbl.0spam.org", Level: "Medium"},                 // 0SPAM NBL
-	{Host: "mail.abusix.zone", Level: "Medium"},              // Abusix Mail Intel
+	{Host: "mail.abusix.zone", Level: "Medium", IPv6: true},  // Abusix Mail Intel
 	{Host: "rbl.0spam.org", Level: "Medium"},                 // 0SPAM RBL
 	{Host: "dyna.spamrats.com", Level: "Medium"},             // RATS Dyna
 	{Host: "noptr.spamrats.com", Level: "Medium"},            // RATS NoPtr
@@ -40,14 +49,13 @@ var RBLProviders = []models.RBLProvider{
 	{Host: "z.mailspike.net", Level: "Medium"},               // MAILSPIKE Z
 	{Host: "sem.blacklist.spamhaus.org", Level: "Medium"},    // SEM BLACK
 	{Host: "cbl.abuseat.org", Level: "Medium"},               // Abuseat CBL
-	{Host: "dnsbl.dronebl.org", Level: "Medium"},             // DRONE BL
+	{Host: "dnsbl.dronebl.org", Level: "Medium", IPv6: true}, // DRONE BL
 	{Host: "dnsbl.zapbl.net", Level: "Medium"},               // ZapBL
 	{Host: "hostkarma.junkemailfilter.com", Level: "Medium"}, // Hostkarma Black
-	{Host: "woodys.smtp.blacklist", Level: "Medium"},         // Woodys SMTP (hay timeout)
 	{Host: "lashback.uoregon.edu", Level: "Medium"},          // LASHBACK
 	{Host: "rbl.schulte.org", Level: "Medium"},               // Manitu (Schulte)
 	{Host: "dnsbl.konstant.no", Level: "Medium"},             // Konstant
-	{Host: "dnsbl.spfbl.net", Level: "Medium"},               // SPFBL DNSBL
+	{Host: "dnsbl.spfbl.net", Level: "Medium", IPv6: true},   // SPFBL DNSBL
 	{Host: "rbl.interserver.net", Level: "Medium"},           // INTERSERVER
 	{Host: "surgate.net", Level: "Medium"},                   // Surgate
 	{Host: "spamsources.fabel.dk", Level: "Medium"},          // FABELSOURCES
@@ -60,8 +68,8 @@ var RBLProviders = []models.RBLProvider{
 	// Low Priority RBLs
 	{Host: "dnsbl-3.uceprotect.net", Level: "Low"},           // UCEPROTECT Level 3
 	{Host: "backscatter.spameatingmonkey.net", Level: "Low"}, // SEM BACKSCATTER
-	{Host: "tor.dan.me.uk", Level: "Low"},                    // DAN TOR
-	{Host: "torexit.dan.me.uk", Level: "Low"},                // DAN TOREXIT
+	{Host: "tor.dan.me.uk", Level: "Low", IPv6: true},        // DAN TOR
+	{Host: "torexit.dan.me.uk", Level: "Low", IPv6: true},    // DAN TOREXIT
 	{Host: "http.dnsbl.sorbs.net", Level: "Low"},             // SORBS HTTP
 	{Host: "socks.dnsbl.sorbs.net", Level: "Low"},            // SORBS SOCKS
 	{Host: "misc.dnsbl.sorbs.net", Level: "Low"},             // SORBS Misc
@@ -80,8 +88,21 @@ var RBLProviders = []models.RBLProvider{
 	{Host: "relays.nether.net", Level: "Low"},                // NETHERRELAYS
 	{Host: "unsure.nether.net", Level: "Low"},                // NETHERUNSURE
 	{Host: "rbl.triumf.ca", Level: "Low"},                    // TRIUMF
-	{Host: "hil.habeas.com", Level: "Low"},                   // HIL
-	{Host: "hil2.habeas.com", Level: "Low"},                  // HIL2
+}
+
+// Domain blocklists, queried with the registrable domain (<domain>.<zone>)
+var DomainRBLProviders = []models.RBLProvider{
+	// Spamhaus DBL
+	{Host: "dbl.spamhaus.org", Level: "High",
+		Codes: spamhausDBLCodes, Removal: "https://check.spamhaus.org/", Test: "dbltest.com"},
+	// URIBL
+	{Host: "multi.uribl.com", Level: "High",
+		Codes: uriblCodes, Bitmask: true, Removal: "https://admin.uribl.com/", Test: "test.uribl.com"},
+	// SURBL
+	{Host: "multi.surbl.org", Level: "High",
+		Codes: surblCodes, Bitmask: true, Removal: "https://www.surbl.org/surbl-analysis", Test: "test.surbl.org"},
+	{Host: "dblack.mail.abusix.zone", Level: "Medium"}, // Abusix Domain Blacklist
+	{Host: "uribl.spameatingmonkey.net", Level: "Low"}, // SEM URI
 }
 
 func ResolveUDPServer(serverKey string) string {
@@ -91,3 +112,71 @@ func ResolveUDPServer(serverKey string) string {
 	// fallback an toàn
 	return "8.8.8.8:53"
 }
+
+// =======================
+// RETURN CODES
+// =======================
+
+// Spamhaus answers 127.255.255.x instead of a listing when it refuses the
+// query (public / open resolver, over the free usage limit, typo)
+var spamhausErrorCodes = map[string]models.RBLReturnCode{
+	"127.255.255.252": {Meaning: "Typing error in the DNSBL name", Error: true},
+	"127.255.255.254": {Meaning: "Query through a public / open resolver refused", Error: true},
+	"127.255.255.255": {Meaning: "Excessive number of queries", Error: true},
+}
+
+var spamhausZENCodes = withCodes(spamhausErrorCodes, map[string]models.RBLReturnCode{
+	"127.0.0.2":  {List: "SBL", Meaning: "Spam source or spam operation"},
+	"127.0.0.3":  {List: "SBL CSS", Meaning: "Snowshoe spam source"},
+	"127.0.0.4":  {List: "XBL", Meaning: "Infected or exploited host (CBL)"},
+	"127.0.0.9":  {List: "SBL DROP", Meaning: "Hijacked or criminal netblock"},
+	"127.0.0.10": {List: "PBL", Meaning: "End-user range, the ISP does not allow direct mail"},
+	"127.0.0.11": {List: "PBL", Meaning: "End-user range, should not send mail directly"},
+})
+
+var spamhausDBLCodes = withCodes(spamhausErrorCodes, map[string]models.RBLReturnCode{
+	"127.0.1.2":   {List: "DBL", Meaning: "Spam domain"},
+	"127.0.1.4":   {List: "DBL", Meaning: "Phishing domain"},
+	"127.0.1.5":   {List: "DBL", Meaning: "Malware domain"},
+	"127.0.1.6":   {List: "DBL", Meaning: "Botnet C&C domain"},
+	"127.0.1.102": {List: "DBL", Meaning: "Abused legit domain, spam"},
+	"127.0.1.103": {List: "DBL", Meaning: "Abused legit domain, spammed redirector"},
+	"127.0.1.104": {List: "DBL", Meaning: "Abused legit domain, phishing"},
+	"127.0.1.105": {List: "DBL", Meaning: "Abused legit domain, malware"},
+	"127.0.1.106": {List: "DBL", Meaning: "Abused legit domain, botnet C&C"},
+	"127.0.1.255": {Meaning: "IP queries are not allowed on the DBL", Error: true},
+})
+
+var barracudaCodes = map[string]models.RBLReturnCode{
+	"127.0.0.2": {List: "BRBL", Meaning: "Poor sending reputation (Barracuda Reputation Block List)"},
+}
+
+var spamcopCodes = map[string]models.RBLReturnCode{
+	"127.0.0.2": {List: "SCBL", Meaning: "Reported as a spam source by SpamCop users"},
+}
+
+// Bitmask codes, 127.0.0.1 is the refusal
+var uriblCodes = map[string]models.RBLReturnCode{
+	"127.0.0.1": {Meaning: "Query refused, the resolver is blocked or over the free limit", Error: true},
+	"127.0.0.2": {List: "black", Meaning: "Domain actively used in spam"},
+	"127.0.0.4": {List: "grey", Meaning: "Domain of bulk / grey mail"},
+	"127.0.0.8": {List: "red", Meaning: "Domain seen in spam, not yet confirmed"},
+}
+
+var surblCodes = map[string]models.RBLReturnCode{
+	"127.0.0.1":   {Meaning: "Query refused, the resolver is blocked", Error: true},
+	"127.0.0.8":   {List: "PH", Meaning: "Phishing domain"},
+	"127.0.0.16":  {List: "MW", Meaning: "Malware domain"},
+	"127.0.0.64":  {List: "ABUSE", Meaning: "Spam or abuse domain"},
+	"127.0.0.128": {List: "CR", Meaning: "Cracked site"},
+}
+
+func withCodes(maps ...map[string]models.RBLReturnCode) map[string]models.RBLReturnCode {
+	out := map[string]models.RBLReturnCode{}
+	for _, m := range maps {
+		for k, v := range m {
+			out[k] = v
+		}
+	}
+	return out
+}
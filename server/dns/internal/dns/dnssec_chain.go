@@ -0,0 +1,555 @@
+/*
+File: server/internal/dns/dnssec_chain.go
+Description: DNSSEC chain-of-trust validation, from the root trust anchor
+down to the queried name.
+*/
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/internal/models"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	DNSSECSecure   = "SECURE"
+	DNSSECInsecure = "INSECURE"
+	DNSSECBogus    = "BOGUS"
+	DNSSECError    = "ERROR"
+)
+
+// Steps of the chain where validation can break
+const (
+	stepDS     = "DS"
+	stepDNSKEY = "DNSKEY"
+	stepRRSIG  = "RRSIG"
+	stepNSEC   = "NSEC"
+)
+
+// IANA root zone KSKs (KSK-2017 and KSK-2024)
+var rootAnchorsText = []string{
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+// rootAnchors is parsed once at start-up; TestRootTrustAnchors keeps the
+// text valid.
+var rootAnchors = parseAnchors(rootAnchorsText)
+
+// RootTrustAnchors returns the built-in root trust anchors.
+func RootTrustAnchors() []*dns.DS {
+	return slices.Clone(rootAnchors)
+}
+
+func parseAnchors(text []string) []*dns.DS {
+	var out []*dns.DS
+
+	for _, s := range text {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			log.Printf("DNSSEC trust anchor %q: %v", s, err)
+			continue
+		}
+		if ds, ok := rr.(*dns.DS); ok {
+			out = append(out, ds)
+		}
+	}
+
+	return out
+}
+
+var (
+	errNoRRSIG        = errors.New("no RRSIG covers the RRset")
+	errDenialNotShown = errors.New("parent zone returned no NSEC/NSEC3 proof for the missing DS")
+)
+
+// ChainValidator walks the chain of trust through a recursive resolver.
+// Queries set the CD bit so bogus data is returned instead of SERVFAIL and
+// every check is done locally.
+type ChainValidator struct {
+	Resolver Exchanger // recursive resolver, on the transport of the lookup
+	Anchors  []*dns.DS // trust anchors for the root zone
+
+	now   func() time.Time
+	cache map[string]*dns.Msg
+}
+
+func NewChainValidator(r Exchanger) *ChainValidator {
+	return &ChainValidator{
+		Resolver: r,
+		Anchors:  RootTrustAnchors(),
+		now:      time.Now,
+		cache:    map[string]*dns.Msg{},
+	}
+}
+
+// chainState is what one validation collects on the way down.
+type chainState struct {
+	info models.DNSSECInfo
+
+	zone string
+	keys []*dns.DNSKEY
+}
+
+func (st *chainState) link(zone, status, step, msg string, keyTags ...uint16) {
+	st.info.Chain = append(st.info.Chain, models.DNSSECLink{
+		Zone:    zone,
+		Status:  status,
+		Step:    step,
+		KeyTags: keyTags,
+		Message: msg,
+	})
+}
+
+func (st *chainState) stop(status, at, step, msg string) models.DNSSECInfo {
+	// BOGUS means signatures are published but don't validate
+	st.info.Enabled = status == DNSSECBogus
+	st.info.Status = status
+	st.info.BrokenAt = at
+	st.info.BrokenStep = step
+	st.info.Message = msg
+	return st.info
+}
+
+// Validate checks the chain of trust for name and the RRSIGs of its qtype
+// RRset.
+func (v *ChainValidator) Validate(name string, qtype uint16) models.DNSSECInfo {
+	name = dns.CanonicalName(name)
+
+	st := &chainState{zone: "."}
+
+	// 1. Root DNSKEY set against the trust anchors
+	keys, err := v.zoneKeys(st, ".", v.Anchors)
+	if err != nil {
+		return st.info
+	}
+	st.keys = keys
+
+	// 2. One delegation at a time
+	labels := dns.SplitDomainName(name)
+
+	for i := len(labels) - 1; i >= 0; i-- {
+		child := dns.Fqdn(strings.Join(labels[i:], "."))
+
+		dsMsg, err := v.query(child, dns.TypeDS)
+		if err != nil {
+			st.info.Status = DNSSECError
+			st.info.Message = err.Error()
+			return st.info
+		}
+
+		dsSet := rrsetOf(dsMsg.Answer, child, dns.TypeDS)
+
+		if len(dsSet) > 0 {
+			if _, err := v.verify(dsSet, sigsFor(dsMsg.Answer, child, dns.TypeDS), st.keys, st.zone); err != nil {
+				msg := fmt.Sprintf("DS set for %s is not validly signed by %s: %v", child, st.zone, err)
+				st.link(child, DNSSECBogus, stepDS, msg)
+				return st.stop(DNSSECBogus, child, stepDS, msg)
+			}
+
+			var ds []*dns.DS
+			for _, rr := range dsSet {
+				ds = append(ds, rr.(*dns.DS))
+			}
+
+			keys, err := v.zoneKeys(st, child, ds)
+			if err != nil {
+				return st.info
+			}
+
+			st.zone, st.keys = child, keys
+			continue
+		}
+
+		// No DS: either not a zone cut or an unsigned delegation
+		cut, err := v.isZoneCut(child)
+		if err != nil {
+			st.info.Status = DNSSECError
+			st.info.Message = err.Error()
+			return st.info
+		}
+		if !cut {
+			continue
+		}
+
+		if err := v.verifyDSDenial(dsMsg, child, st.keys, st.zone); err != nil {
+			msg := fmt.Sprintf("%s has no DS record but the denial is not valid: %v", child, err)
+			st.link(child, DNSSECBogus, stepNSEC, msg)
+			return st.stop(DNSSECBogus, child, stepNSEC, msg)
+		}
+
+		msg := fmt.Sprintf("%s is an unsigned delegation (no DS record in %s)", child, st.zone)
+		st.info.Records = nil // the parent's keys, not the domain's
+		st.link(child, DNSSECInsecure, stepDS, msg)
+		return st.stop(DNSSECInsecure, child, stepDS, msg)
+	}
+
+	// 3. The answer itself
+	st.info.Enabled = true
+
+	ans, err := v.query(name, qtype)
+	if err != nil {
+		st.info.Status = DNSSECError
+		st.info.Message = err.Error()
+		return st.info
+	}
+
+	rrtype := qtype
+	rrset := rrsetOf(ans.Answer, name, qtype)
+
+	// An alias answers every type, its own signature is what counts
+	if len(rrset) == 0 {
+		if cname := rrsetOf(ans.Answer, name, dns.TypeCNAME); len(cname) > 0 {
+			rrtype, rrset = dns.TypeCNAME, cname
+		}
+	}
+
+	if len(rrset) == 0 {
+		st.info.Denial = v.analyzeDenial(ans, name, qtype, st.keys, st.zone)
+		if !st.info.Denial.Valid {
+			msg := fmt.Sprintf("%s %s does not exist but the denial is not valid: %s", name, dns.TypeToString[qtype], st.info.Denial.Message)
+			return st.stop(DNSSECBogus, name, stepNSEC, msg)
+		}
+
+		st.info.Status = DNSSECSecure
+		st.info.Message = fmt.Sprintf("chain of trust is valid, %s", st.info.Denial.Message)
+		return st.info
+	}
+
+	sigs := sigsFor(ans.Answer, name, rrtype)
+
+	tag, err := v.verify(rrset, sigs, st.keys, st.zone)
+	if err != nil {
+		msg := fmt.Sprintf("%s %s RRset: %v", name, dns.TypeToString[rrtype], err)
+		return st.stop(DNSSECBogus, name, stepRRSIG, msg)
+	}
+
+	// Fewer RRSIG labels than the owner: synthesized from a wildcard, the
+	// proof that name has no closer match must come with it
+	if labels, ok := wildcardLabels(name, sigs, tag); ok {
+		st.info.Denial = v.analyzeWildcard(ans, name, qtype, labels, st.keys, st.zone)
+		if !st.info.Denial.Valid {
+			msg := fmt.Sprintf("%s %s is synthesized from a wildcard but the proof is not valid: %s", name, dns.TypeToString[rrtype], st.info.Denial.Message)
+			return st.stop(DNSSECBogus, name, stepNSEC, msg)
+		}
+	}
+
+	st.info.Status = DNSSECSecure
+	return st.info
+}
+
+// zoneKeys fetches zone's DNSKEY set and authenticates it with ds: a key
+// must match a DS digest and sign the whole set. On failure the link is
+// recorded, st.info finalized and a non-nil error returned.
+func (v *ChainValidator) zoneKeys(st *chainState, zone string, ds []*dns.DS) ([]*dns.DNSKEY, error) {
+	msg, err := v.query(zone, dns.TypeDNSKEY)
+	if err != nil {
+		st.info.Status = DNSSECError
+		st.info.Message = err.Error()
+		return nil, err
+	}
+
+	keySet := rrsetOf(msg.Answer, zone, dns.TypeDNSKEY)
+	keySigs := sigsFor(msg.Answer, zone, dns.TypeDNSKEY)
+
+	// Every key is shown, but only zone keys may sign zone data (RFC 4034
+	// 2.1.1)
+	var all, keys []*dns.DNSKEY
+	for _, rr := range keySet {
+		k := rr.(*dns.DNSKEY)
+		all = append(all, k)
+		if k.Flags&dns.ZONE != 0 {
+			keys = append(keys, k)
+		}
+	}
+
+	st.info.Records = dnssecRecords(all, ds, keySigs)
+
+	supported := supportedDS(ds)
+	if len(supported) == 0 {
+		msg := fmt.Sprintf("DS records of %s only use unsupported algorithms / digest types", zone)
+		st.link(zone, DNSSECInsecure, stepDS, msg)
+		st.stop(DNSSECInsecure, zone, stepDS, msg)
+		return nil, errors.New(msg)
+	}
+
+	if len(keys) == 0 {
+		msg := fmt.Sprintf("%s has DS records but no DNSKEY", zone)
+		if len(all) > 0 {
+			msg = fmt.Sprintf("%s has DS records but no DNSKEY with the Zone Key flag", zone)
+		}
+		st.link(zone, DNSSECBogus, stepDNSKEY, msg)
+		st.stop(DNSSECBogus, zone, stepDNSKEY, msg)
+		return nil, errors.New(msg)
+	}
+
+	var trusted []*dns.DNSKEY
+	for _, k := range keys {
+		for _, d := range supported {
+			if dsMatches(k, d) {
+				trusted = append(trusted, k)
+				break
+			}
+		}
+	}
+
+	if len(trusted) == 0 {
+		var tags []string
+		for _, d := range supported {
+			tags = append(tags, fmt.Sprint(d.KeyTag))
+		}
+		msg := fmt.Sprintf("no DNSKEY of %s matches the DS digest (key tag %s)", zone, strings.Join(tags, ", "))
+		st.link(zone, DNSSECBogus, stepDS, msg)
+		st.stop(DNSSECBogus, zone, stepDS, msg)
+		return nil, errors.New(msg)
+	}
+
+	tag, err := v.verify(keySet, keySigs, trusted, zone)
+	if err != nil {
+		msg := fmt.Sprintf("DNSKEY set of %s: %v", zone, err)
+		st.link(zone, DNSSECBogus, stepDNSKEY, msg)
+		st.stop(DNSSECBogus, zone, stepDNSKEY, msg)
+		return nil, err
+	}
+
+	st.link(zone, DNSSECSecure, "", "", tag)
+
+	return keys, nil
+}
+
+// verify accepts rrset when one RRSIG made by signer with one of keys is
+// currently valid. It returns the key tag that verified.
+func (v *ChainValidator) verify(rrset []dns.RR, sigs []*dns.RRSIG, keys []*dns.DNSKEY, signer string) (uint16, error) {
+	if len(sigs) == 0 {
+		return 0, errNoRRSIG
+	}
+
+	now := v.now()
+
+	var lastErr error
+
+	for _, sig := range sigs {
+		if !strings.EqualFold(sig.SignerName, signer) {
+			lastErr = fmt.Errorf("RRSIG key tag %d is signed by %s instead of %s", sig.KeyTag, sig.SignerName, signer)
+			continue
+		}
+
+		var key *dns.DNSKEY
+		for _, k := range keys {
+			if k.KeyTag() == sig.KeyTag && k.Algorithm == sig.Algorithm {
+				key = k
+				break
+			}
+		}
+		if key == nil {
+			lastErr = fmt.Errorf("no trusted DNSKEY with key tag %d", sig.KeyTag)
+			continue
+		}
+
+		if !sig.ValidityPeriod(now) {
+			inception := time.Unix(int64(sig.Inception), 0).UTC()
+			expiration := time.Unix(int64(sig.Expiration), 0).UTC()
+
+			if now.Before(inception) {
+				lastErr = fmt.Errorf("RRSIG key tag %d is not valid before %s", sig.KeyTag, inception.Format(time.RFC3339))
+			} else {
+				lastErr = fmt.Errorf("RRSIG key tag %d expired on %s", sig.KeyTag, expiration.Format(time.RFC3339))
+			}
+			continue
+		}
+
+		if err := sig.Verify(key, rrset); err != nil {
+			lastErr = fmt.Errorf("RRSIG key tag %d does not verify: %v", sig.KeyTag, err)
+			continue
+		}
+
+		return sig.KeyTag, nil
+	}
+
+	return 0, lastErr
+}
+
+// wildcardLabels returns the labels of the RRSIG that verified when it
+// shows a wildcard expansion (RFC 4035 5.3.2).
+func wildcardLabels(name string, sigs []*dns.RRSIG, tag uint16) (int, bool) {
+	owner := dns.CountLabel(name)
+	if strings.HasPrefix(name, "*.") {
+		owner-- // the wildcard itself was asked for
+	}
+
+	for _, sig := range sigs {
+		if sig.KeyTag == tag && int(sig.Labels) < owner {
+			return int(sig.Labels), true
+		}
+	}
+	return 0, false
+}
+
+// verifyDSDenial checks the parent's signed proof that child has no DS:
+// the record matching child must be a delegation, NS without SOA (RFC
+// 4035 5.2), or an NSEC3 opt-out span must cover it (RFC 5155 8.6).
+func (v *ChainValidator) verifyDSDenial(msg *dns.Msg, child string, keys []*dns.DNSKEY, zone string) error {
+	d := v.analyzeDenial(msg, child, dns.TypeDS, keys, zone)
+	if d.Method == DenialNone {
+		return errDenialNotShown
+	}
+	if !d.Valid {
+		return errors.New(d.Message)
+	}
+
+	for _, rec := range d.Records {
+		switch {
+		case slices.Contains(rec.Proves, proveOptOut):
+			return nil
+		case slices.Contains(rec.Proves, proveNoData):
+			if !slices.Contains(rec.Types, "NS") {
+				return fmt.Errorf("%s for %s has no NS bit, %s is not a delegation", rec.Type, child, child)
+			}
+			if slices.Contains(rec.Types, "SOA") {
+				return fmt.Errorf("%s for %s is from the child zone, not the parent", rec.Type, child)
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no %s record matches the delegation %s", d.Method, child)
+}
+
+// isZoneCut reports whether name is the apex of its own zone.
+func (v *ChainValidator) isZoneCut(name string) (bool, error) {
+	msg, err := v.query(name, dns.TypeSOA)
+	if err != nil {
+		return false, err
+	}
+
+	return len(rrsetOf(msg.Answer, name, dns.TypeSOA)) > 0, nil
+}
+
+func (v *ChainValidator) query(name string, qtype uint16) (*dns.Msg, error) {
+	key := dns.CanonicalName(name) + "|" + dns.TypeToString[qtype]
+
+	if msg, ok := v.cache[key]; ok {
+		return msg, nil
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.RecursionDesired = true
+	m.CheckingDisabled = true
+	m.SetEdns0(4096, true)
+
+	resp, err := v.Resolver.Exchange(m)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s query failed: %w", name, dns.TypeToString[qtype], err)
+	}
+
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("%s %s query failed: %s", name, dns.TypeToString[qtype], dns.RcodeToString[resp.Rcode])
+	}
+
+	v.cache[key] = resp
+	return resp, nil
+}
+
+/* ===========================
+   HELPERS
+=========================== */
+
+func rrsetOf(rrs []dns.RR, owner string, qtype uint16) []dns.RR {
+	var out []dns.RR
+	for _, rr := range rrs {
+		h := rr.Header()
+		if h.Rrtype == qtype && strings.EqualFold(h.Name, owner) {
+			out = append(out, rr)
+		}
+	}
+	return out
+}
+
+func sigsFor(rrs []dns.RR, owner string, covered uint16) []*dns.RRSIG {
+	var out []*dns.RRSIG
+	for _, rr := range rrs {
+		if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == covered && strings.EqualFold(sig.Hdr.Name, owner) {
+			out = append(out, sig)
+		}
+	}
+	return out
+}
+
+// rrsetsByOwner groups the RRs of the given types by owner and type.
+func rrsetsByOwner(rrs []dns.RR, types ...uint16) [][]dns.RR {
+	var (
+		order []string
+		sets  = map[string][]dns.RR{}
+	)
+
+	for _, rr := range rrs {
+		h := rr.Header()
+
+		match := false
+		for _, t := range types {
+			if h.Rrtype == t {
+				match = true
+			}
+		}
+		if !match {
+			continue
+		}
+
+		key := dns.CanonicalName(h.Name) + "|" + dns.TypeToString[h.Rrtype]
+		if _, ok := sets[key]; !ok {
+			order = append(order, key)
+		}
+		sets[key] = append(sets[key], rr)
+	}
+
+	out := make([][]dns.RR, 0, len(order))
+	for _, k := range order {
+		out = append(out, sets[k])
+	}
+	return out
+}
+
+var supportedAlgorithms = map[uint8]bool{
+	dns.RSASHA1:          true,
+	dns.RSASHA1NSEC3SHA1: true,
+	dns.RSASHA256:        true,
+	dns.RSASHA512:        true,
+	dns.ECDSAP256SHA256:  true,
+	dns.ECDSAP384SHA384:  true,
+	dns.ED25519:          true,
+}
+
+var supportedDigests = map[uint8]bool{
+	dns.SHA1:   true,
+	dns.SHA256: true,
+	dns.SHA384: true,
+}
+
+// supportedDS drops DS records we can't check; a zone signed only with
+// those is treated as insecure (RFC 4035 5.2).
+func supportedDS(ds []*dns.DS) []*dns.DS {
+	var out []*dns.DS
+	for _, d := range ds {
+		if supportedAlgorithms[d.Algorithm] && supportedDigests[d.DigestType] {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func dsMatches(k *dns.DNSKEY, d *dns.DS) bool {
+	if k.KeyTag() != d.KeyTag || k.Algorithm != d.Algorithm {
+		return false
+	}
+
+	got := k.ToDS(d.DigestType)
+	return got != nil && strings.EqualFold(got.Digest, d.Digest)
+}
@@ -0,0 +1,407 @@
+package dns
+
+import (
+	"crypto"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+/* ===========================
+   SIGNED TEST ZONES
+===========================
+
+   . → test. → example.test. (signed)
+           └→ insecure.test. (unsigned delegation, NSEC proof in test.)
+*/
+
+type testKey struct {
+	zone string
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newTestKey(t *testing.T, zone string) *testKey {
+	t.Helper()
+
+	k := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: zone, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+
+	priv, err := k.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testKey{zone: zone, key: k, priv: priv.(crypto.Signer)}
+}
+
+func (k *testKey) signWindow(t *testing.T, rrset []dns.RR, inception, expiration time.Time) *dns.RRSIG {
+	t.Helper()
+
+	sig := &dns.RRSIG{
+		Algorithm:  k.key.Algorithm,
+		SignerName: k.zone,
+		KeyTag:     k.key.KeyTag(),
+		Inception:  uint32(inception.Unix()),
+		Expiration: uint32(expiration.Unix()),
+	}
+	if err := sig.Sign(k.priv, rrset); err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func (k *testKey) sign(t *testing.T, rrset ...dns.RR) []dns.RR {
+	t.Helper()
+
+	now := time.Now()
+	sig := k.signWindow(t, rrset, now.Add(-time.Hour), now.Add(24*time.Hour))
+	return append(rrset, sig)
+}
+
+type testZones struct {
+	mu sync.Mutex
+
+	// name|TYPE → answer / authority
+	answers   map[string][]dns.RR
+	authority map[string][]dns.RR
+	nxdomain  map[string]bool
+
+	root, tld, example *testKey
+}
+
+func zoneKey(name string, qtype uint16) string {
+	return strings.ToLower(name) + "|" + dns.TypeToString[qtype]
+}
+
+func newTestZones(t *testing.T) *testZones {
+	t.Helper()
+
+	z := &testZones{
+		answers:   map[string][]dns.RR{},
+		authority: map[string][]dns.RR{},
+		nxdomain:  map[string]bool{},
+		root:      newTestKey(t, "."),
+		tld:       newTestKey(t, "test."),
+		example:   newTestKey(t, "example.test."),
+	}
+
+	soa := func(zone string) dns.RR {
+		return mustRR(t, zone+" 300 IN SOA ns."+strings.TrimPrefix(zone, ".")+" hostmaster.test. 1 7200 3600 1209600 300")
+	}
+
+	for _, k := range []*testKey{z.root, z.tld, z.example} {
+		z.set(k.zone, dns.TypeDNSKEY, k.sign(t, k.key)...)
+		if k.zone != "." {
+			z.set(k.zone, dns.TypeSOA, k.sign(t, soa(k.zone))...)
+		}
+	}
+
+	z.set("test.", dns.TypeDS, z.root.sign(t, z.tld.key.ToDS(dns.SHA256))...)
+	z.set("example.test.", dns.TypeDS, z.tld.sign(t, z.example.key.ToDS(dns.SHA256))...)
+
+	z.set("example.test.", dns.TypeA, z.example.sign(t, mustRR(t, "example.test. 300 IN A 192.0.2.1"))...)
+	z.set("www.example.test.", dns.TypeA, z.example.sign(t, mustRR(t, "www.example.test. 300 IN A 192.0.2.2"))...)
+
+	// Unsigned child: SOA only, DS absence proven by a signed NSEC
+	z.set("insecure.test.", dns.TypeSOA, soa("insecure.test."))
+	z.set("insecure.test.", dns.TypeA, mustRR(t, "insecure.test. 300 IN A 192.0.2.3"))
+	z.authority[zoneKey("insecure.test.", dns.TypeDS)] = z.tld.sign(t,
+		mustRR(t, "insecure.test. 300 IN NSEC z.test. NS RRSIG NSEC"),
+	)
+
+	return z
+}
+
+func (z *testZones) set(name string, qtype uint16, rrs ...dns.RR) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	z.answers[zoneKey(name, qtype)] = rrs
+}
+
+func (z *testZones) serve(t *testing.T) string {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		z.mu.Lock()
+		defer z.mu.Unlock()
+
+		q := r.Question[0]
+
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = z.answers[zoneKey(q.Name, q.Qtype)]
+		m.Ns = z.authority[zoneKey(q.Name, q.Qtype)]
+		if z.nxdomain[strings.ToLower(q.Name)] {
+			m.Rcode = dns.RcodeNameError
+		}
+		w.WriteMsg(m)
+	})
+
+	srv := &dns.Server{PacketConn: pc, Handler: handler}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+
+	return pc.LocalAddr().String()
+}
+
+func (z *testZones) validator(t *testing.T) *ChainValidator {
+	v := NewChainValidator(&UDPResolver{Server: z.serve(t), Timeout: 2 * time.Second})
+	v.Anchors = []*dns.DS{z.root.key.ToDS(dns.SHA256)}
+	return v
+}
+
+/* ===========================
+   TESTS
+=========================== */
+
+func TestChainSecure(t *testing.T) {
+	z := newTestZones(t)
+
+	info := z.validator(t).Validate("www.example.test.", dns.TypeA)
+
+	if info.Status != DNSSECSecure || !info.Enabled {
+		t.Fatalf("expected SECURE, got %+v", info)
+	}
+
+	var zones []string
+	for _, l := range info.Chain {
+		zones = append(zones, l.Zone)
+	}
+	if strings.Join(zones, " ") != ". test. example.test." {
+		t.Errorf("chain = %v", zones)
+	}
+
+	if len(info.Records) == 0 {
+		t.Error("expected the zone's DNSKEY / DS / RRSIG records")
+	}
+}
+
+func TestChainBogus(t *testing.T) {
+	cases := []struct {
+		name   string
+		mutate func(t *testing.T, z *testZones)
+		at     string
+		step   string
+		reason string
+	}{
+		{
+			name: "tampered answer",
+			mutate: func(t *testing.T, z *testZones) {
+				rrs := z.answers[zoneKey("www.example.test.", dns.TypeA)]
+				rrs[0] = mustRR(t, "www.example.test. 300 IN A 198.51.100.1")
+			},
+			at: "www.example.test.", step: stepRRSIG, reason: "does not verify",
+		},
+		{
+			name: "expired signature",
+			mutate: func(t *testing.T, z *testZones) {
+				a := mustRR(t, "www.example.test. 300 IN A 192.0.2.2")
+				sig := z.example.signWindow(t, []dns.RR{a}, time.Now().Add(-48*time.Hour), time.Now().Add(-time.Hour))
+				z.set("www.example.test.", dns.TypeA, a, sig)
+			},
+			at: "www.example.test.", step: stepRRSIG, reason: "expired",
+		},
+		{
+			name: "DS does not match child key",
+			mutate: func(t *testing.T, z *testZones) {
+				other := newTestKey(t, "example.test.")
+				z.set("example.test.", dns.TypeDS, z.tld.sign(t, other.key.ToDS(dns.SHA256))...)
+			},
+			at: "example.test.", step: stepDS, reason: "matches the DS digest",
+		},
+		{
+			name: "unsigned DNSKEY set",
+			mutate: func(t *testing.T, z *testZones) {
+				z.set("example.test.", dns.TypeDNSKEY, z.example.key)
+			},
+			at: "example.test.", step: stepDNSKEY, reason: "no RRSIG",
+		},
+		{
+			name: "DNSKEY without the Zone Key flag",
+			mutate: func(t *testing.T, z *testZones) {
+				k := newTestKey(t, "example.test.")
+				k.key.Flags = dns.SEP
+				z.set("example.test.", dns.TypeDS, z.tld.sign(t, k.key.ToDS(dns.SHA256))...)
+				z.set("example.test.", dns.TypeDNSKEY, k.sign(t, k.key)...)
+			},
+			at: "example.test.", step: stepDNSKEY, reason: "Zone Key flag",
+		},
+		{
+			name: "DS signed by the wrong zone",
+			mutate: func(t *testing.T, z *testZones) {
+				z.set("example.test.", dns.TypeDS, z.example.sign(t, z.example.key.ToDS(dns.SHA256))...)
+			},
+			at: "example.test.", step: stepDS, reason: "instead of test.",
+		},
+		{
+			name: "missing DS denial",
+			mutate: func(t *testing.T, z *testZones) {
+				delete(z.authority, zoneKey("insecure.test.", dns.TypeDS))
+			},
+			at: "insecure.test.", step: stepNSEC, reason: "no NSEC/NSEC3",
+		},
+		{
+			name: "NSEC without the NS bit",
+			mutate: func(t *testing.T, z *testZones) {
+				z.authority[zoneKey("insecure.test.", dns.TypeDS)] = z.tld.sign(t,
+					mustRR(t, "insecure.test. 300 IN NSEC z.test. A RRSIG NSEC"))
+			},
+			at: "insecure.test.", step: stepNSEC, reason: "no NS bit",
+		},
+		{
+			name: "NSEC only covering the delegation",
+			mutate: func(t *testing.T, z *testZones) {
+				z.authority[zoneKey("insecure.test.", dns.TypeDS)] = z.tld.sign(t,
+					mustRR(t, "a.test. 300 IN NSEC z.test. NS RRSIG NSEC"))
+			},
+			at: "insecure.test.", step: stepNSEC, reason: "no NSEC record matches",
+		},
+		{
+			name: "NSEC3 for an unrelated owner",
+			mutate: func(t *testing.T, z *testZones) {
+				other := dns.HashName("other.test.", dns.SHA1, 0, "")
+				z.authority[zoneKey("insecure.test.", dns.TypeDS)] = z.tld.sign(t,
+					mustRR(t, fmt.Sprintf("%s.test. 300 IN NSEC3 1 1 0 - %s NS", other, other)))
+			},
+			at: "insecure.test.", step: stepNSEC, reason: "closest encloser",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			z := newTestZones(t)
+			c.mutate(t, z)
+
+			name := "www.example.test."
+			if c.at == "insecure.test." {
+				name = c.at
+			}
+
+			info := z.validator(t).Validate(name, dns.TypeA)
+
+			if info.Status != DNSSECBogus || info.BrokenAt != c.at || info.BrokenStep != c.step {
+				t.Fatalf("expected BOGUS at %s/%s, got %s at %s/%s: %s",
+					c.at, c.step, info.Status, info.BrokenAt, info.BrokenStep, info.Message)
+			}
+
+			if !strings.Contains(info.Message, c.reason) {
+				t.Errorf("message %q does not mention %q", info.Message, c.reason)
+			}
+		})
+	}
+}
+
+func TestChainInsecureDelegation(t *testing.T) {
+	z := newTestZones(t)
+
+	info := z.validator(t).Validate("insecure.test.", dns.TypeA)
+
+	if info.Status != DNSSECInsecure || info.Enabled || info.BrokenAt != "insecure.test." {
+		t.Fatalf("expected INSECURE at insecure.test., got %+v", info)
+	}
+
+	last := info.Chain[len(info.Chain)-1]
+	if last.Zone != "insecure.test." || last.Status != DNSSECInsecure {
+		t.Errorf("last link %+v", last)
+	}
+}
+
+func TestChainInsecureOptOut(t *testing.T) {
+	z := newTestZones(t)
+
+	// One opt-out NSEC3 at the apex of test. spanning the whole zone
+	apex := dns.HashName("test.", dns.SHA1, 0, "")
+	z.authority[zoneKey("insecure.test.", dns.TypeDS)] = z.tld.sign(t,
+		mustRR(t, fmt.Sprintf("%s.test. 300 IN NSEC3 1 1 0 - %s NS SOA RRSIG DNSKEY NSEC3PARAM", apex, apex)))
+
+	info := z.validator(t).Validate("insecure.test.", dns.TypeA)
+
+	if info.Status != DNSSECInsecure || info.BrokenAt != "insecure.test." {
+		t.Fatalf("expected INSECURE at insecure.test., got %s: %s", info.Status, info.Message)
+	}
+}
+
+func TestRootTrustAnchors(t *testing.T) {
+	anchors := RootTrustAnchors()
+	if len(anchors) != len(rootAnchorsText) {
+		t.Fatalf("%d of %d anchors parsed", len(anchors), len(rootAnchorsText))
+	}
+	for i, tag := range []uint16{20326, 38696} {
+		if anchors[i].KeyTag != tag || anchors[i].DigestType != dns.SHA256 {
+			t.Errorf("anchor %d %v", i, anchors[i])
+		}
+	}
+}
+
+func TestChainWildcard(t *testing.T) {
+	cases := []struct {
+		name   string
+		proof  func(t *testing.T, z *testZones) []dns.RR
+		status string
+		reason string
+	}{
+		{
+			name: "NSEC covers the name",
+			proof: func(t *testing.T, z *testZones) []dns.RR {
+				return z.example.sign(t, mustRR(t, "example.test. 300 IN NSEC www.example.test. A NS SOA RRSIG NSEC DNSKEY"))
+			},
+			status: DNSSECSecure,
+		},
+		{
+			name:   "NSEC3 covers the next closer name",
+			proof:  func(t *testing.T, z *testZones) []dns.RR { return nsec3Chain(t, z, 0, "") },
+			status: DNSSECSecure,
+		},
+		{
+			name:   "no proof",
+			proof:  func(t *testing.T, z *testZones) []dns.RR { return nil },
+			status: DNSSECBogus, reason: "no NSEC/NSEC3",
+		},
+		{
+			name: "NSEC does not cover the name",
+			proof: func(t *testing.T, z *testZones) []dns.RR {
+				return z.example.sign(t, mustRR(t, "www.example.test. 300 IN NSEC z.example.test. A RRSIG NSEC"))
+			},
+			status: DNSSECBogus, reason: "no NSEC record covers a.example.test.",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			z := newTestZones(t)
+
+			// Signed at *.example.test., served as a.example.test.
+			signed := z.example.sign(t, mustRR(t, "*.example.test. 300 IN A 192.0.2.9"))
+			for _, rr := range signed {
+				rr.Header().Name = "a.example.test."
+			}
+			z.set("a.example.test.", dns.TypeA, signed...)
+			z.authority[zoneKey("a.example.test.", dns.TypeA)] = c.proof(t, z)
+
+			info := z.validator(t).Validate("a.example.test.", dns.TypeA)
+
+			if info.Status != c.status || info.Denial == nil || info.Denial.Result != DenialWildcard {
+				t.Fatalf("expected %s with a wildcard proof, got %s: %s", c.status, info.Status, info.Message)
+			}
+			if c.status == DNSSECBogus && (info.BrokenStep != stepNSEC || !strings.Contains(info.Message, c.reason)) {
+				t.Errorf("expected %q at %s, got %s: %s", c.reason, stepNSEC, info.BrokenStep, info.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,599 @@
+/*
+File: server/internal/dns/dnssec_denial.go
+Description: Authenticated denial of existence (NSEC / NSEC3, RFC 4035
+and RFC 5155) - which records prove a name or type doesn't exist, and
+whether that proof validates.
+*/
+package dns
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"tools.bctechvibe.io.vn/server/internal/models"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	DenialNXDomain = "NXDOMAIN"
+	DenialNoData   = "NODATA"
+	DenialWildcard = "WILDCARD" // positive answer synthesized from a wildcard
+
+	DenialNone = "NONE"
+)
+
+// What a single NSEC / NSEC3 record proves
+const (
+	proveNoData          = "nodata"
+	proveName            = "name"
+	proveWildcard        = "wildcard"
+	proveClosestEncloser = "closest_encloser"
+	proveNextCloser      = "next_closer"
+	proveOptOut          = "opt_out"
+)
+
+// RFC 9276 asks for 0 extra iterations; above this count validators are
+// allowed to treat the answer as insecure (or SERVFAIL) instead.
+const nsec3MaxIterations = 100
+
+// AnalyzeDenial explains why name has no qtype records, through the
+// resolver of serverKey on transport. Unsigned zones get Method NONE.
+func AnalyzeDenial(serverKey, transport, name string, qtype uint16) *models.DenialInfo {
+	fqdn := dns.CanonicalName(dns.Fqdn(name))
+
+	v := chainValidatorFor(serverKey, transport)
+
+	info := v.Validate(fqdn, qtype)
+	if info.Denial != nil {
+		return info.Denial
+	}
+
+	d := &models.DenialInfo{
+		Name:   fqdn,
+		Type:   dns.TypeToString[qtype],
+		Result: DenialNoData,
+		Method: DenialNone,
+	}
+
+	// Cached by Validate, only needed for the RCODE
+	if msg, err := v.query(fqdn, qtype); err == nil && msg.Rcode == dns.RcodeNameError {
+		d.Result = DenialNXDomain
+	}
+
+	switch info.Status {
+	case DNSSECInsecure:
+		d.Message = "zone is not signed, the absence of records cannot be proven"
+	case DNSSECSecure:
+		d.Message = fmt.Sprintf("%s has %s records, nothing to deny", fqdn, d.Type)
+	default:
+		d.Message = info.Message
+	}
+
+	return d
+}
+
+// denialProof is the NSEC / NSEC3 records of one response, with the
+// signature check of each.
+type denialProof struct {
+	info *models.DenialInfo
+
+	zone string
+	rrs  []dns.RR // parallel to info.Records
+	errs []error
+}
+
+func (p *denialProof) mark(rr dns.RR, role string) {
+	for i, r := range p.rrs {
+		if r == rr {
+			p.info.Records[i].Proves = append(p.info.Records[i].Proves, role)
+		}
+	}
+}
+
+// analyzeDenial checks the proof in the authority section of msg (the
+// answer to name / qtype) against the keys of zone.
+func (v *ChainValidator) analyzeDenial(msg *dns.Msg, name string, qtype uint16, keys []*dns.DNSKEY, zone string) *models.DenialInfo {
+	p := &denialProof{
+		info: &models.DenialInfo{
+			Name:   name,
+			Type:   dns.TypeToString[qtype],
+			Result: DenialNoData,
+			Method: DenialNone,
+		},
+		zone: zone,
+	}
+	if msg.Rcode == dns.RcodeNameError {
+		p.info.Result = DenialNXDomain
+	}
+
+	nsecs, nsec3s := v.collectDenial(p, msg, keys, zone)
+
+	var err error
+
+	switch {
+	case len(nsec3s) > 0:
+		p.info.Method = "NSEC3"
+		p.nsec3Params(nsec3s)
+		err = p.proveNSEC3(nsec3s, name, qtype)
+
+	case len(nsecs) > 0:
+		p.info.Method = "NSEC"
+		p.nsecWalkable(nsecs)
+		err = p.proveNSEC(nsecs, name, qtype)
+
+	default:
+		p.info.Message = fmt.Sprintf("%s returned no NSEC/NSEC3 records for %s %s", zone, name, p.info.Type)
+		return p.info
+	}
+
+	if err == nil {
+		err = p.signatures()
+	}
+
+	if err != nil {
+		p.info.Message = err.Error()
+		return p.info
+	}
+
+	p.info.Valid = true
+	if p.info.Result == DenialNXDomain {
+		p.info.Message = fmt.Sprintf("%s proof shows %s does not exist", p.info.Method, name)
+	} else {
+		p.info.Message = fmt.Sprintf("%s proof shows %s has no %s records", p.info.Method, name, p.info.Type)
+	}
+
+	return p.info
+}
+
+// analyzeWildcard checks the proof that an answer synthesized from the
+// wildcard at the labels-label closest encloser had no closer match: name
+// itself doesn't exist (RFC 4035 5.3.4, RFC 5155 8.8).
+func (v *ChainValidator) analyzeWildcard(msg *dns.Msg, name string, qtype uint16, labels int, keys []*dns.DNSKEY, zone string) *models.DenialInfo {
+	p := &denialProof{
+		info: &models.DenialInfo{
+			Name:   name,
+			Type:   dns.TypeToString[qtype],
+			Result: DenialWildcard,
+			Method: DenialNone,
+		},
+		zone: zone,
+	}
+
+	nsecs, nsec3s := v.collectDenial(p, msg, keys, zone)
+
+	var err error
+
+	switch {
+	case len(nsec3s) > 0:
+		p.info.Method = "NSEC3"
+		p.nsec3Params(nsec3s)
+
+		nextCloser := ancestor(name, labels+1)
+		if n := nsec3Covering(nsec3s, nextCloser); n != nil {
+			p.mark(n, proveNextCloser)
+		} else {
+			err = fmt.Errorf("no NSEC3 record covers the next closer name %s", nextCloser)
+		}
+
+	case len(nsecs) > 0:
+		p.info.Method = "NSEC"
+		p.nsecWalkable(nsecs)
+
+		if n := nsecCovering(nsecs, name); n != nil {
+			p.mark(n, proveName)
+		} else {
+			err = fmt.Errorf("no NSEC record covers %s", name)
+		}
+
+	default:
+		p.info.Message = fmt.Sprintf("%s returned no NSEC/NSEC3 records proving %s has no closer match than the wildcard", zone, name)
+		return p.info
+	}
+
+	if err == nil {
+		err = p.signatures()
+	}
+
+	if err != nil {
+		p.info.Message = err.Error()
+		return p.info
+	}
+
+	p.info.Valid = true
+	p.info.Message = fmt.Sprintf("%s proof shows %s is synthesized from *.%s", p.info.Method, name, strings.TrimPrefix(ancestor(name, labels), "."))
+
+	return p.info
+}
+
+// collectDenial adds the NSEC / NSEC3 records of msg's authority section
+// to p, each set checked against the keys of zone.
+func (v *ChainValidator) collectDenial(p *denialProof, msg *dns.Msg, keys []*dns.DNSKEY, zone string) ([]*dns.NSEC, []*dns.NSEC3) {
+	var (
+		nsecs  []*dns.NSEC
+		nsec3s []*dns.NSEC3
+	)
+
+	for _, set := range rrsetsByOwner(msg.Ns, dns.TypeNSEC, dns.TypeNSEC3) {
+		h := set[0].Header()
+		_, err := v.verify(set, sigsFor(msg.Ns, h.Name, h.Rrtype), keys, zone)
+
+		for _, rr := range set {
+			p.rrs = append(p.rrs, rr)
+			p.errs = append(p.errs, err)
+			p.info.Records = append(p.info.Records, denialRecord(rr, err == nil))
+
+			switch n := rr.(type) {
+			case *dns.NSEC:
+				nsecs = append(nsecs, n)
+			case *dns.NSEC3:
+				nsec3s = append(nsec3s, n)
+			}
+		}
+	}
+
+	return nsecs, nsec3s
+}
+
+// signatures fails when a record the proof relies on isn't validly signed.
+func (p *denialProof) signatures() error {
+	for i, rec := range p.info.Records {
+		if len(rec.Proves) > 0 && p.errs[i] != nil {
+			h := p.rrs[i].Header()
+			return fmt.Errorf("%s %s: %v", dns.TypeToString[h.Rrtype], h.Name, p.errs[i])
+		}
+	}
+	return nil
+}
+
+/* ===========================
+   NSEC (RFC 4035 5.4)
+=========================== */
+
+func (p *denialProof) proveNSEC(nsecs []*dns.NSEC, name string, qtype uint16) error {
+	typeName := dns.TypeToString[qtype]
+
+	if n := nsecMatching(nsecs, name); n != nil {
+		if p.info.Result == DenialNXDomain {
+			return fmt.Errorf("NXDOMAIN, but the NSEC record shows %s exists", name)
+		}
+		if hasType(n.TypeBitMap, qtype) || hasType(n.TypeBitMap, dns.TypeCNAME) {
+			return fmt.Errorf("NSEC for %s lists %s, the records should exist", name, typeOrCNAME(n.TypeBitMap, qtype))
+		}
+		p.mark(n, proveNoData)
+		return nil
+	}
+
+	cover := nsecCovering(nsecs, name)
+	if cover == nil {
+		return fmt.Errorf("no NSEC record matches or covers %s", name)
+	}
+
+	// Empty non-terminal: the name only exists because of names below it
+	if p.info.Result == DenialNoData && dns.IsSubDomain(name, cover.NextDomain) {
+		p.mark(cover, proveNoData)
+		return nil
+	}
+
+	p.mark(cover, proveName)
+
+	ce := ancestor(name, max(
+		dns.CompareDomainName(name, cover.Hdr.Name),
+		dns.CompareDomainName(name, cover.NextDomain),
+	))
+	wildcard := "*." + strings.TrimPrefix(ce, ".")
+
+	if w := nsecMatching(nsecs, wildcard); w != nil {
+		if p.info.Result == DenialNXDomain {
+			return fmt.Errorf("%s exists, %s should have been synthesized from it", wildcard, name)
+		}
+		if hasType(w.TypeBitMap, qtype) || hasType(w.TypeBitMap, dns.TypeCNAME) {
+			return fmt.Errorf("NSEC for %s lists %s, the records should exist", wildcard, typeOrCNAME(w.TypeBitMap, qtype))
+		}
+		p.mark(w, proveWildcard)
+		return nil
+	}
+
+	if p.info.Result == DenialNoData {
+		return fmt.Errorf("no NSEC record matches %s or a wildcard for %s", name, typeName)
+	}
+
+	w := nsecCovering(nsecs, wildcard)
+	if w == nil {
+		return fmt.Errorf("no NSEC record covers the wildcard %s", wildcard)
+	}
+	p.mark(w, proveWildcard)
+
+	return nil
+}
+
+// nsecWalkable flags plain NSEC chains. Compact denial ("black lies",
+// next name \000.owner) only ever shows the queried name.
+func (p *denialProof) nsecWalkable(nsecs []*dns.NSEC) {
+	for _, n := range nsecs {
+		if !strings.HasPrefix(n.NextDomain, `\000.`) {
+			p.info.Walkable = true
+			p.info.Warnings = append(p.info.Warnings,
+				fmt.Sprintf("%s uses NSEC: every name in the zone can be listed by walking the chain (zone enumeration)", p.zone))
+			return
+		}
+	}
+}
+
+func nsecMatching(nsecs []*dns.NSEC, name string) *dns.NSEC {
+	for _, n := range nsecs {
+		if canonicalCompare(n.Hdr.Name, name) == 0 {
+			return n
+		}
+	}
+	return nil
+}
+
+func nsecCovering(nsecs []*dns.NSEC, name string) *dns.NSEC {
+	for _, n := range nsecs {
+		if nsecCovers(n, name) {
+			return n
+		}
+	}
+	return nil
+}
+
+// nsecCovers reports whether name sorts strictly between the owner and the
+// next name. The last NSEC of the zone wraps around to the apex.
+func nsecCovers(n *dns.NSEC, name string) bool {
+	afterOwner := canonicalCompare(n.Hdr.Name, name) < 0
+	beforeNext := canonicalCompare(name, n.NextDomain) < 0
+
+	if canonicalCompare(n.Hdr.Name, n.NextDomain) < 0 {
+		return afterOwner && beforeNext
+	}
+	return afterOwner || beforeNext
+}
+
+/* ===========================
+   NSEC3 (RFC 5155 8)
+=========================== */
+
+func (p *denialProof) proveNSEC3(nsec3s []*dns.NSEC3, name string, qtype uint16) error {
+	for _, n := range nsec3s {
+		if n.Hash != dns.SHA1 {
+			return fmt.Errorf("NSEC3 %s uses unknown hash algorithm %d", n.Hdr.Name, n.Hash)
+		}
+	}
+
+	if n := nsec3Matching(nsec3s, name); n != nil {
+		if p.info.Result == DenialNXDomain {
+			return fmt.Errorf("NXDOMAIN, but an NSEC3 record matches %s", name)
+		}
+		if hasType(n.TypeBitMap, qtype) || hasType(n.TypeBitMap, dns.TypeCNAME) {
+			return fmt.Errorf("NSEC3 for %s lists %s, the records should exist", name, typeOrCNAME(n.TypeBitMap, qtype))
+		}
+		p.mark(n, proveNoData)
+		return nil
+	}
+
+	ce, nc, err := p.closestEncloser(nsec3s, name)
+	if err != nil {
+		return err
+	}
+
+	optOut := nc.Flags&1 == 1
+
+	// DS of an unsigned delegation in an opt-out span (RFC 5155 8.6)
+	if p.info.Result == DenialNoData && qtype == dns.TypeDS && optOut {
+		p.mark(nc, proveOptOut)
+		return nil
+	}
+
+	wildcard := "*." + strings.TrimPrefix(ce, ".")
+
+	if p.info.Result == DenialNoData {
+		w := nsec3Matching(nsec3s, wildcard)
+		if w == nil {
+			return fmt.Errorf("no NSEC3 record matches %s or the wildcard %s", name, wildcard)
+		}
+		if hasType(w.TypeBitMap, qtype) || hasType(w.TypeBitMap, dns.TypeCNAME) {
+			return fmt.Errorf("NSEC3 for %s lists %s, the records should exist", wildcard, typeOrCNAME(w.TypeBitMap, qtype))
+		}
+		p.mark(w, proveWildcard)
+		return nil
+	}
+
+	w := nsec3Covering(nsec3s, wildcard)
+	if w == nil {
+		return fmt.Errorf("no NSEC3 record covers the wildcard %s", wildcard)
+	}
+	p.mark(w, proveWildcard)
+
+	if optOut {
+		p.info.Warnings = append(p.info.Warnings,
+			fmt.Sprintf("the NSEC3 covering %s has opt-out set: an unsigned delegation may exist there", name))
+	}
+
+	return nil
+}
+
+// closestEncloser finds the longest existing ancestor of name and the
+// NSEC3 covering the next closer name below it (RFC 5155 8.3).
+func (p *denialProof) closestEncloser(nsec3s []*dns.NSEC3, name string) (string, *dns.NSEC3, error) {
+	labels := dns.CountLabel(name)
+
+	for n := labels - 1; n >= 0; n-- {
+		ce := ancestor(name, n)
+		if !dns.IsSubDomain(p.zone, ce) {
+			break
+		}
+
+		match := nsec3Matching(nsec3s, ce)
+		if match == nil {
+			continue
+		}
+
+		nextCloser := ancestor(name, n+1)
+
+		cover := nsec3Covering(nsec3s, nextCloser)
+		if cover == nil {
+			return "", nil, fmt.Errorf("no NSEC3 record covers the next closer name %s", nextCloser)
+		}
+
+		p.mark(match, proveClosestEncloser)
+		p.mark(cover, proveNextCloser)
+		return ce, cover, nil
+	}
+
+	return "", nil, fmt.Errorf("no NSEC3 record proves a closest encloser for %s", name)
+}
+
+// nsec3Params reports the hash parameters and the RFC 9276 guidance.
+func (p *denialProof) nsec3Params(nsec3s []*dns.NSEC3) {
+	n := nsec3s[0]
+
+	params := &models.NSEC3Params{
+		HashAlgorithm: n.Hash,
+		Iterations:    n.Iterations,
+	}
+	if n.Salt != "-" {
+		params.Salt = strings.ToLower(n.Salt)
+	}
+	for _, r := range nsec3s {
+		if r.Flags&1 == 1 {
+			params.OptOut = true
+		}
+	}
+	p.info.NSEC3 = params
+
+	switch {
+	case params.Iterations > nsec3MaxIterations:
+		p.info.Warnings = append(p.info.Warnings, fmt.Sprintf(
+			"NSEC3 uses %d extra iterations: RFC 9276 recommends 0, validators may treat answers above %d as insecure",
+			params.Iterations, nsec3MaxIterations))
+	case params.Iterations > 0:
+		p.info.Warnings = append(p.info.Warnings, fmt.Sprintf(
+			"NSEC3 uses %d extra iterations, RFC 9276 recommends 0", params.Iterations))
+	}
+
+	if params.Salt != "" {
+		p.info.Warnings = append(p.info.Warnings, "NSEC3 uses a salt, RFC 9276 recommends an empty salt")
+	}
+}
+
+func nsec3Matching(nsec3s []*dns.NSEC3, name string) *dns.NSEC3 {
+	for _, n := range nsec3s {
+		if n.Match(name) {
+			return n
+		}
+	}
+	return nil
+}
+
+func nsec3Covering(nsec3s []*dns.NSEC3, name string) *dns.NSEC3 {
+	for _, n := range nsec3s {
+		if n.Cover(name) {
+			return n
+		}
+	}
+	return nil
+}
+
+/* ===========================
+   HELPERS
+=========================== */
+
+func denialRecord(rr dns.RR, signed bool) models.DenialRecord {
+	rec := models.DenialRecord{SignatureValid: signed}
+
+	var bitmap []uint16
+
+	switch n := rr.(type) {
+	case *dns.NSEC:
+		rec.Type = "NSEC"
+		rec.Owner = n.Hdr.Name
+		rec.Next = n.NextDomain
+		bitmap = n.TypeBitMap
+
+	case *dns.NSEC3:
+		rec.Type = "NSEC3"
+		rec.Owner = strings.ToUpper(dns.SplitDomainName(n.Hdr.Name)[0])
+		rec.Next = n.NextDomain
+		bitmap = n.TypeBitMap
+	}
+
+	for _, t := range bitmap {
+		rec.Types = append(rec.Types, dns.Type(t).String())
+	}
+
+	return rec
+}
+
+func hasType(bitmap []uint16, t uint16) bool {
+	for _, b := range bitmap {
+		if b == t {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOrCNAME(bitmap []uint16, t uint16) string {
+	if hasType(bitmap, t) {
+		return dns.TypeToString[t]
+	}
+	return "CNAME"
+}
+
+// ancestor is the last n labels of name.
+func ancestor(name string, n int) string {
+	labels := dns.SplitDomainName(name)
+	if n <= 0 {
+		return "."
+	}
+	if n > len(labels) {
+		n = len(labels)
+	}
+	return dns.Fqdn(strings.Join(labels[len(labels)-n:], "."))
+}
+
+// canonicalCompare orders names as in RFC 4034 6.1: label by label from
+// the root, each label compared as lowercase bytes.
+func canonicalCompare(a, b string) int {
+	la, lb := wireLabels(a), wireLabels(b)
+
+	for i := 0; i < len(la) && i < len(lb); i++ {
+		if c := bytes.Compare(la[i], lb[i]); c != 0 {
+			return c
+		}
+	}
+
+	return len(la) - len(lb)
+}
+
+// wireLabels returns the unescaped, lowercased labels of name, root first.
+func wireLabels(name string) [][]byte {
+	buf := make([]byte, 256)
+
+	n, err := dns.PackDomainName(dns.Fqdn(name), buf, 0, nil, false)
+	if err != nil {
+		return nil
+	}
+	buf = buf[:n]
+
+	var labels [][]byte
+	for off := 0; off < len(buf) && buf[off] != 0; {
+		l := int(buf[off])
+
+		// ASCII only, other bytes are compared as is
+		label := make([]byte, l)
+		for i, c := range buf[off+1 : off+1+l] {
+			if 'A' <= c && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			label[i] = c
+		}
+
+		labels = append([][]byte{label}, labels...)
+		off += 1 + l
+	}
+
+	return labels
+}
@@ -0,0 +1,205 @@
+package dns
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// nsec3Chain builds the signed NSEC3 chain of example.test. (apex and www)
+func nsec3Chain(t *testing.T, z *testZones, iterations uint16, salt string) []dns.RR {
+	t.Helper()
+
+	apex := dns.HashName("example.test.", dns.SHA1, iterations, salt)
+	www := dns.HashName("www.example.test.", dns.SHA1, iterations, salt)
+
+	presentation := salt
+	if salt == "" {
+		presentation = "-"
+	}
+
+	rr := func(owner, next, types string) []dns.RR {
+		return z.example.sign(t, mustRR(t, fmt.Sprintf("%s.example.test. 300 IN NSEC3 1 0 %d %s %s %s",
+			owner, iterations, presentation, next, types)))
+	}
+
+	return append(
+		rr(apex, www, "A NS SOA RRSIG DNSKEY NSEC3PARAM"),
+		rr(www, apex, "A RRSIG")...,
+	)
+}
+
+func TestDenialNSEC(t *testing.T) {
+	cases := []struct {
+		name   string
+		qname  string
+		qtype  uint16
+		nsec   []string
+		nx     bool
+		result string
+		proves string
+	}{
+		{
+			name:  "nodata",
+			qname: "www.example.test.", qtype: dns.TypeAAAA,
+			nsec:   []string{"www.example.test. 300 IN NSEC z.example.test. A RRSIG NSEC"},
+			result: DenialNoData, proves: "nodata",
+		},
+		{
+			name:  "nxdomain",
+			qname: "nope.example.test.", qtype: dns.TypeA, nx: true,
+			nsec:   []string{"example.test. 300 IN NSEC www.example.test. A NS SOA RRSIG NSEC DNSKEY"},
+			result: DenialNXDomain, proves: "name,wildcard",
+		},
+		{
+			name:  "empty non-terminal",
+			qname: "b.example.test.", qtype: dns.TypeA,
+			nsec:   []string{"a.example.test. 300 IN NSEC c.b.example.test. A RRSIG NSEC"},
+			result: DenialNoData, proves: "nodata",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			z := newTestZones(t)
+
+			var proof []dns.RR
+			for _, s := range c.nsec {
+				proof = append(proof, z.example.sign(t, mustRR(t, s))...)
+			}
+			z.authority[zoneKey(c.qname, c.qtype)] = proof
+			z.nxdomain[c.qname] = c.nx
+
+			info := z.validator(t).Validate(c.qname, c.qtype)
+
+			if info.Status != DNSSECSecure || info.Denial == nil {
+				t.Fatalf("expected SECURE with a denial, got %s: %s", info.Status, info.Message)
+			}
+
+			d := info.Denial
+			if !d.Valid || d.Method != "NSEC" || d.Result != c.result || !d.Walkable {
+				t.Fatalf("unexpected denial %+v", d)
+			}
+			if got := strings.Join(d.Records[0].Proves, ","); got != c.proves || !d.Records[0].SignatureValid {
+				t.Errorf("record %+v, want proves %s", d.Records[0], c.proves)
+			}
+		})
+	}
+}
+
+func TestDenialNSEC3(t *testing.T) {
+	cases := []struct {
+		name       string
+		iterations uint16
+		salt       string
+		warning    string
+	}{
+		{name: "rfc 9276 parameters"},
+		{name: "salted", iterations: 10, salt: "AABBCCDD", warning: "extra iterations, RFC 9276 recommends 0"},
+		{name: "too many iterations", iterations: 150, warning: "may treat answers above 100 as insecure"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			z := newTestZones(t)
+
+			z.authority[zoneKey("nope.example.test.", dns.TypeA)] = nsec3Chain(t, z, c.iterations, c.salt)
+			z.nxdomain["nope.example.test."] = true
+
+			info := z.validator(t).Validate("nope.example.test.", dns.TypeA)
+
+			d := info.Denial
+			if info.Status != DNSSECSecure || d == nil || !d.Valid {
+				t.Fatalf("expected a valid proof, got %s: %s", info.Status, info.Message)
+			}
+
+			if d.Method != "NSEC3" || d.Result != DenialNXDomain || d.Walkable {
+				t.Errorf("unexpected denial %+v", d)
+			}
+			if d.NSEC3.Iterations != c.iterations || d.NSEC3.HashAlgorithm != dns.SHA1 || d.NSEC3.OptOut {
+				t.Errorf("params %+v", d.NSEC3)
+			}
+
+			var proves []string
+			for _, r := range d.Records {
+				proves = append(proves, r.Proves...)
+			}
+			for _, want := range []string{"closest_encloser", "next_closer", "wildcard"} {
+				if !strings.Contains(strings.Join(proves, " "), want) {
+					t.Errorf("no record proves %s: %+v", want, d.Records)
+				}
+			}
+
+			warnings := strings.Join(d.Warnings, "\n")
+			if c.warning == "" && warnings != "" {
+				t.Errorf("unexpected warnings %q", warnings)
+			}
+			if !strings.Contains(warnings, c.warning) {
+				t.Errorf("warnings %q do not mention %q", warnings, c.warning)
+			}
+			if c.salt != "" && !strings.Contains(warnings, "empty salt") {
+				t.Errorf("salt %s not flagged: %q", c.salt, warnings)
+			}
+		})
+	}
+}
+
+func TestDenialBogus(t *testing.T) {
+	cases := []struct {
+		name   string
+		proof  func(t *testing.T, z *testZones) []dns.RR
+		reason string
+	}{
+		{
+			name: "bitmap lists the type",
+			proof: func(t *testing.T, z *testZones) []dns.RR {
+				return z.example.sign(t, mustRR(t, "www.example.test. 300 IN NSEC z.example.test. A AAAA RRSIG NSEC"))
+			},
+			reason: "lists AAAA",
+		},
+		{
+			name: "unsigned NSEC",
+			proof: func(t *testing.T, z *testZones) []dns.RR {
+				return []dns.RR{mustRR(t, "www.example.test. 300 IN NSEC z.example.test. A RRSIG NSEC")}
+			},
+			reason: "no RRSIG",
+		},
+		{
+			name:   "no proof",
+			proof:  func(t *testing.T, z *testZones) []dns.RR { return nil },
+			reason: "no NSEC/NSEC3",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			z := newTestZones(t)
+			z.authority[zoneKey("www.example.test.", dns.TypeAAAA)] = c.proof(t, z)
+
+			info := z.validator(t).Validate("www.example.test.", dns.TypeAAAA)
+
+			if info.Status != DNSSECBogus || info.BrokenStep != stepNSEC || info.Denial.Valid {
+				t.Fatalf("expected BOGUS at NSEC, got %s/%s: %s", info.Status, info.BrokenStep, info.Message)
+			}
+			if !strings.Contains(info.Message, c.reason) {
+				t.Errorf("message %q does not mention %q", info.Message, c.reason)
+			}
+		})
+	}
+}
+
+func TestCanonicalOrder(t *testing.T) {
+	// RFC 4034 6.1 example
+	names := []string{
+		"example.", "a.example.", "yljkjljk.a.example.", "Z.a.example.",
+		`zABC.a.EXAMPLE.`, "z.example.", `\001.z.example.`, `*.z.example.`, `\200.z.example.`,
+	}
+
+	for i := 1; i < len(names); i++ {
+		if canonicalCompare(names[i-1], names[i]) >= 0 {
+			t.Errorf("%s should sort before %s", names[i-1], names[i])
+		}
+	}
+}
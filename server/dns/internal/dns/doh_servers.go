@@ -1,7 +1,12 @@
 // internal/dns/doh_servers.go
 package dns
 
-import "time"
+import (
+	"crypto/x509"
+	"net/http"
+	"sync"
+	"time"
+)
 
 // DoHResolver handles DNS-over-HTTPS queries
 type DoHResolver struct {
@@ -10,6 +15,11 @@ type DoHResolver struct {
 	Endpoint     string
 	Timeout      time.Duration
 	SupportsJSON bool
+	RootCAs      *x509.CertPool // nil = system roots
+
+	// Shared by every query so HTTP/2 connections are reused
+	clientOnce sync.Once
+	client     *http.Client
 }
 
 // DoHServers defines supported DNS-over-HTTPS providers
@@ -42,4 +52,11 @@ var DoHServers = map[string]*DoHResolver{
 		Timeout:      5 * time.Second,
 		SupportsJSON: false,
 	},
+	"adguard": {
+		Key:          "adguard",
+		Name:         "AdGuard DNS",
+		Endpoint:     "https://dns.adguard-dns.com/dns-query",
+		Timeout:      5 * time.Second,
+		SupportsJSON: false,
+	},
 }
@@ -0,0 +1,36 @@
+// internal/dns/doq_servers.go
+package dns
+
+import (
+	"crypto/x509"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// DoQResolver handles DNS-over-QUIC queries (RFC 9250)
+type DoQResolver struct {
+	Key        string
+	Name       string
+	Server     string // ip:853 (UDP)
+	ServerName string // name on the server certificate
+	Timeout    time.Duration
+	RootCAs    *x509.CertPool // nil = system roots
+
+	// One QUIC connection per server, each query on its own stream
+	mu   sync.Mutex
+	conn *quic.Conn
+}
+
+// DoQServers defines supported DNS-over-QUIC providers.
+// Google, Cloudflare and Quad9 don't offer DoQ.
+var DoQServers = map[string]*DoQResolver{
+	"adguard": {
+		Key:        "adguard",
+		Name:       "AdGuard DNS",
+		Server:     "94.140.14.14:853",
+		ServerName: "dns.adguard-dns.com",
+		Timeout:    5 * time.Second,
+	},
+}
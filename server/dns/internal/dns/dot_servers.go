@@ -0,0 +1,49 @@
+// internal/dns/dot_servers.go
+package dns
+
+import (
+	"crypto/x509"
+	"time"
+)
+
+// DoTResolver handles DNS-over-TLS queries (RFC 7858)
+type DoTResolver struct {
+	Key        string
+	Name       string
+	Server     string // ip:853
+	ServerName string // name on the server certificate
+	Timeout    time.Duration
+	RootCAs    *x509.CertPool // nil = system roots
+}
+
+// DoTServers defines supported DNS-over-TLS providers
+var DoTServers = map[string]*DoTResolver{
+	"google": {
+		Key:        "google",
+		Name:       "Google DNS",
+		Server:     "8.8.8.8:853",
+		ServerName: "dns.google",
+		Timeout:    5 * time.Second,
+	},
+	"cloudflare": {
+		Key:        "cloudflare",
+		Name:       "Cloudflare",
+		Server:     "1.1.1.1:853",
+		ServerName: "cloudflare-dns.com",
+		Timeout:    5 * time.Second,
+	},
+	"quad9": {
+		Key:        "quad9",
+		Name:       "Quad9",
+		Server:     "9.9.9.9:853",
+		ServerName: "dns.quad9.net",
+		Timeout:    5 * time.Second,
+	},
+	"adguard": {
+		Key:        "adguard",
+		Name:       "AdGuard DNS",
+		Server:     "94.140.14.14:853",
+		ServerName: "dns.adguard-dns.com",
+		Timeout:    5 * time.Second,
+	},
+}
@@ -0,0 +1,407 @@
+/*
+File: server/internal/dns/mail.go
+Description: Mail deliverability audit of a domain - SPF, DKIM, DMARC,
+MTA-STS, TLS-RPT and BIMI, each reported as pass / warn / fail findings.
+*/
+package dns
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/internal/models"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Finding statuses, from best to worst
+const (
+	MailPass = "pass"
+	MailWarn = "warn"
+	MailFail = "fail"
+)
+
+// MailAuditor runs the mail checks through a recursive resolver.
+type MailAuditor struct {
+	Resolver   Resolver
+	HTTPClient *http.Client // MTA-STS policy fetch
+
+	stsURL func(domain string) string
+}
+
+func NewMailAuditor(r Resolver) *MailAuditor {
+	return &MailAuditor{
+		Resolver: r,
+		HTTPClient: &http.Client{
+			Timeout: 5 * time.Second,
+			// RFC 8461 3.3: redirects are not followed
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		stsURL: func(domain string) string {
+			return "https://mta-sts." + domain + "/.well-known/mta-sts.txt"
+		},
+	}
+}
+
+// AuditMail audits domain through the given provider / transport.
+func AuditMail(serverKey, transport, domain string, selectors []string) *models.MailAudit {
+	r := &fallbackResolver{rm: ResolverManagerFor(serverKey), transport: transport}
+	return NewMailAuditor(r).Audit(domain, selectors)
+}
+
+// Audit runs every check. selectors are the DKIM selectors to try,
+// CommonDKIMSelectors when empty.
+func (a *MailAuditor) Audit(domain string, selectors []string) *models.MailAudit {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	audit := &models.MailAudit{Domain: domain}
+
+	var wg sync.WaitGroup
+	run := func(check func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			check()
+		}()
+	}
+	run(func() { audit.SPF = a.checkSPF(domain) })
+	run(func() { audit.DKIM = a.checkDKIM(domain, selectors) })
+	run(func() { audit.DMARC = a.checkDMARC(domain) })
+	run(func() { audit.MTASTS = a.checkMTASTS(domain) })
+	run(func() { audit.TLSRPT = a.checkTLSRPT(domain) })
+	wg.Wait()
+
+	// BIMI is only honoured with an enforced DMARC policy
+	audit.BIMI = a.checkBIMI(domain, audit.DMARC)
+
+	audit.Status = worstStatus(
+		audit.SPF.Status, audit.DKIM.Status, audit.DMARC.Status,
+		audit.MTASTS.Status, audit.TLSRPT.Status, audit.BIMI.Status,
+	)
+
+	return audit
+}
+
+// ============================================
+// Findings
+// ============================================
+
+func addFinding(c *models.MailCheck, status, format string, args ...any) {
+	c.Findings = append(c.Findings, models.MailFinding{Status: status, Message: fmt.Sprintf(format, args...)})
+	c.Status = worstStatus(c.Status, status)
+}
+
+func worstStatus(statuses ...string) string {
+	rank := map[string]int{MailPass: 1, MailWarn: 2, MailFail: 3}
+
+	worst := ""
+	for _, s := range statuses {
+		if rank[s] > rank[worst] {
+			worst = s
+		}
+	}
+	return worst
+}
+
+// ============================================
+// TXT records
+// ============================================
+
+// txt returns the TXT records of name, the character-strings of each
+// concatenated without a separator (RFC 7208 3.3). NXDOMAIN / NODATA is no
+// record, other RCODEs are errors.
+func (a *MailAuditor) txt(name string) ([]string, error) {
+	res, err := a.Resolver.Query(dns.Fqdn(name), dns.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	if res.Rcode != dns.RcodeSuccess && res.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("%s for %s", dns.RcodeToString[res.Rcode], name)
+	}
+
+	var out []string
+	for _, rec := range res.Records {
+		if rec.Type != "TXT" {
+			continue
+		}
+		value := rec.Value
+		if len(rec.TXTStrings) > 0 {
+			value = strings.Join(rec.TXTStrings, "")
+		}
+		out = append(out, strings.TrimSpace(value))
+	}
+	return out, nil
+}
+
+// tagRecords returns the tag=value records of name whose v= is version
+// (DMARC1, STSv1, TLSRPTv1, BIMI1).
+func (a *MailAuditor) tagRecords(name, version string) ([]string, error) {
+	records, err := a.txt(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, r := range records {
+		if strings.EqualFold(parseTags(r)["v"], version) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// parseTags splits a "k=v; k=v" record. Keys are lowercased.
+func parseTags(record string) map[string]string {
+	tags := map[string]string{}
+
+	for _, part := range strings.Split(record, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		k = strings.ToLower(strings.TrimSpace(k))
+		if _, dup := tags[k]; !dup {
+			tags[k] = strings.TrimSpace(v)
+		}
+	}
+	return tags
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// orgDomain is the registrable domain (example.co.uk for a.b.example.co.uk).
+func orgDomain(domain string) string {
+	if org, err := publicsuffix.EffectiveTLDPlusOne(domain); err == nil {
+		return org
+	}
+	return domain
+}
+
+// ============================================
+// DMARC (RFC 7489)
+// ============================================
+
+func (a *MailAuditor) checkDMARC(domain string) models.DMARCResult {
+	r := models.DMARCResult{Domain: domain, Percent: 100, ADKIM: "r", ASPF: "r"}
+
+	records, err := a.tagRecords("_dmarc."+domain, "DMARC1")
+
+	// No record: receivers fall back to the organizational domain
+	if org := orgDomain(domain); err == nil && len(records) == 0 && org != domain {
+		r.Domain = org
+		records, err = a.tagRecords("_dmarc."+org, "DMARC1")
+	}
+
+	switch {
+	case err != nil:
+		addFinding(&r.MailCheck, MailFail, "DMARC lookup failed: %v", err)
+		return r
+	case len(records) == 0:
+		addFinding(&r.MailCheck, MailFail, "no DMARC record, receivers apply no policy to spoofed mail")
+		return r
+	case len(records) > 1:
+		addFinding(&r.MailCheck, MailFail, "%d DMARC records, receivers ignore them all", len(records))
+		return r
+	}
+
+	r.Record = records[0]
+	tags := parseTags(r.Record)
+
+	r.Policy = strings.ToLower(tags["p"])
+	r.SubPol = strings.ToLower(tags["sp"])
+
+	// Inherited from the organizational domain: sp= applies
+	policy := r.Policy
+	if r.Domain != domain {
+		addFinding(&r.MailCheck, MailPass, "no record on %s, the policy of %s applies", domain, r.Domain)
+		if r.SubPol != "" {
+			policy = r.SubPol
+		}
+	}
+
+	switch policy {
+	case "reject", "quarantine":
+		addFinding(&r.MailCheck, MailPass, "policy %s", policy)
+	case "none":
+		addFinding(&r.MailCheck, MailWarn, "p=none only monitors, spoofed mail is still delivered")
+	case "":
+		addFinding(&r.MailCheck, MailFail, "the required p= tag is missing")
+	default:
+		addFinding(&r.MailCheck, MailFail, "unknown policy %q", policy)
+	}
+
+	if v, ok := tags["pct"]; ok {
+		pct, err := strconv.Atoi(v)
+		if err != nil || pct < 0 || pct > 100 {
+			addFinding(&r.MailCheck, MailFail, "invalid pct=%s", v)
+		} else {
+			r.Percent = pct
+		}
+	}
+	if r.Percent < 100 && policy != "none" {
+		addFinding(&r.MailCheck, MailWarn, "the policy applies to %d%% of failing mail only", r.Percent)
+	}
+
+	// Alignment: relaxed (organizational domain) or strict (exact domain)
+	for _, al := range []struct {
+		tag  string
+		mode *string
+	}{{"adkim", &r.ADKIM}, {"aspf", &r.ASPF}} {
+		if v, ok := tags[al.tag]; ok {
+			*al.mode = strings.ToLower(v)
+		}
+		if *al.mode != "r" && *al.mode != "s" {
+			addFinding(&r.MailCheck, MailFail, "invalid %s=%s, use r or s", al.tag, *al.mode)
+		}
+	}
+	addFinding(&r.MailCheck, MailPass, "DKIM alignment %s, SPF alignment %s", alignmentName(r.ADKIM), alignmentName(r.ASPF))
+
+	r.RUA = splitList(tags["rua"])
+	r.RUF = splitList(tags["ruf"])
+	if len(r.RUA) == 0 {
+		addFinding(&r.MailCheck, MailWarn, "no rua= address, no aggregate reports are sent")
+	}
+	for _, uri := range append(slices.Clone(r.RUA), r.RUF...) {
+		a.checkReportURI(&r, uri)
+	}
+
+	return r
+}
+
+func alignmentName(mode string) string {
+	if mode == "s" {
+		return "strict"
+	}
+	return "relaxed"
+}
+
+// checkReportURI checks a rua / ruf address, and that a domain outside
+// the organization accepts reports for it (RFC 7489 7.1).
+func (a *MailAuditor) checkReportURI(r *models.DMARCResult, uri string) {
+	// size limit suffix: mailto:dmarc@example.com!10m
+	uri, _, _ = strings.Cut(uri, "!")
+
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "mailto" || !strings.Contains(u.Opaque, "@") {
+		addFinding(&r.MailCheck, MailFail, "invalid report address %q", uri)
+		return
+	}
+
+	_, host, _ := strings.Cut(u.Opaque, "@")
+	host = strings.ToLower(host)
+	if orgDomain(host) == orgDomain(r.Domain) {
+		return
+	}
+
+	records, err := a.tagRecords(r.Domain+"._report._dmarc."+host, "DMARC1")
+	if err == nil && len(records) == 0 {
+		addFinding(&r.MailCheck, MailWarn, "%s doesn't accept DMARC reports for %s (no %s._report._dmarc.%s record)",
+			host, r.Domain, r.Domain, host)
+	}
+}
+
+// ============================================
+// TLS-RPT (RFC 8460)
+// ============================================
+
+func (a *MailAuditor) checkTLSRPT(domain string) models.TLSRPTResult {
+	var r models.TLSRPTResult
+
+	records, err := a.tagRecords("_smtp._tls."+domain, "TLSRPTv1")
+	switch {
+	case err != nil:
+		addFinding(&r.MailCheck, MailFail, "TLS-RPT lookup failed: %v", err)
+		return r
+	case len(records) == 0:
+		addFinding(&r.MailCheck, MailWarn, "no TLS-RPT record, TLS failures delivering to this domain aren't reported")
+		return r
+	case len(records) > 1:
+		addFinding(&r.MailCheck, MailFail, "%d TLS-RPT records, senders ignore them all", len(records))
+		return r
+	}
+
+	r.Record = records[0]
+	r.RUA = splitList(parseTags(r.Record)["rua"])
+	if len(r.RUA) == 0 {
+		addFinding(&r.MailCheck, MailFail, "the required rua= tag is missing")
+		return r
+	}
+
+	for _, uri := range r.RUA {
+		u, err := url.Parse(uri)
+		if err != nil || (u.Scheme != "mailto" && u.Scheme != "https") {
+			addFinding(&r.MailCheck, MailFail, "invalid report address %q, use mailto: or https:", uri)
+		}
+	}
+	if r.Status == "" {
+		addFinding(&r.MailCheck, MailPass, "reports go to %s", strings.Join(r.RUA, ", "))
+	}
+
+	return r
+}
+
+// ============================================
+// BIMI
+// ============================================
+
+func (a *MailAuditor) checkBIMI(domain string, dmarc models.DMARCResult) models.BIMIResult {
+	var r models.BIMIResult
+
+	records, err := a.tagRecords("default._bimi."+domain, "BIMI1")
+	switch {
+	case err != nil:
+		addFinding(&r.MailCheck, MailFail, "BIMI lookup failed: %v", err)
+		return r
+	case len(records) == 0:
+		addFinding(&r.MailCheck, MailWarn, "no BIMI record, mailboxes show no brand logo")
+		return r
+	case len(records) > 1:
+		addFinding(&r.MailCheck, MailFail, "%d BIMI records on default._bimi", len(records))
+		return r
+	}
+
+	r.Record = records[0]
+	tags := parseTags(r.Record)
+	r.Logo = tags["l"]
+	r.Authority = tags["a"]
+
+	switch logo, err := url.Parse(r.Logo); {
+	case r.Logo == "":
+		addFinding(&r.MailCheck, MailWarn, "empty l=, the domain declines to show a logo")
+	case err != nil || logo.Scheme != "https" || !strings.HasSuffix(strings.ToLower(logo.Path), ".svg"):
+		addFinding(&r.MailCheck, MailFail, "the logo must be an SVG served over https: %s", r.Logo)
+	default:
+		addFinding(&r.MailCheck, MailPass, "logo %s", r.Logo)
+	}
+
+	switch vmc, err := url.Parse(r.Authority); {
+	case r.Authority == "":
+		addFinding(&r.MailCheck, MailWarn, "no certificate (a=), Gmail and Apple Mail don't show the logo")
+	case err != nil || vmc.Scheme != "https":
+		addFinding(&r.MailCheck, MailFail, "the certificate must be served over https: %s", r.Authority)
+	}
+
+	policy := dmarc.Policy
+	if dmarc.Domain != domain && dmarc.SubPol != "" {
+		policy = dmarc.SubPol
+	}
+	if (policy != "quarantine" && policy != "reject") || dmarc.Percent < 100 {
+		addFinding(&r.MailCheck, MailFail, "BIMI needs DMARC p=quarantine or p=reject at pct=100")
+	}
+
+	return r
+}
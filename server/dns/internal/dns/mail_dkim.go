@@ -0,0 +1,161 @@
+/*
+File: server/internal/dns/mail_dkim.go
+Description: DKIM keys (RFC 6376) on the common or user-supplied
+selectors - key type, size and testing flag.
+*/
+package dns
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"tools.bctechvibe.io.vn/server/internal/models"
+)
+
+// CommonDKIMSelectors are tried when the user gives none.
+var CommonDKIMSelectors = []string{
+	"default", "dkim", "mail", "smtp",
+	"google",                 // Google Workspace
+	"selector1", "selector2", // Microsoft 365
+	"k1", "k2", "k3", // Mailchimp / Mandrill
+	"s1", "s2", // SendGrid
+	"zoho", "mxvault", "everlytickey1",
+}
+
+var selectorRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]{0,62})$`)
+
+// ============================================
+// DKIM
+// ============================================
+
+func (a *MailAuditor) checkDKIM(domain string, selectors []string) models.DKIMCheck {
+	supplied := len(selectors) > 0
+	if !supplied {
+		selectors = CommonDKIMSelectors
+	}
+
+	check := models.DKIMCheck{Tried: []string{}, Keys: []models.DKIMResult{}}
+	for _, s := range selectors {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if selectorRegex.MatchString(s) && !slices.Contains(check.Tried, s) {
+			check.Tried = append(check.Tried, s)
+		}
+	}
+
+	found := make([]*models.DKIMResult, len(check.Tried))
+	var wg sync.WaitGroup
+	for i, s := range check.Tried {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			records, err := a.txt(s + "._domainkey." + domain)
+			if err != nil {
+				return
+			}
+			for _, r := range records {
+				// A DKIM key record has p= (v=DKIM1 is optional)
+				if _, ok := parseTags(r)["p"]; ok {
+					key := dkimKey(s, r)
+					found[i] = &key
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, key := range found {
+		if key != nil {
+			check.Keys = append(check.Keys, *key)
+			check.Status = worstStatus(check.Status, key.Status)
+		}
+	}
+
+	switch {
+	case len(check.Keys) > 0:
+		addFinding(&check.MailCheck, MailPass, "%d DKIM key(s) found", len(check.Keys))
+	case supplied:
+		addFinding(&check.MailCheck, MailFail, "no DKIM key on selector(s) %s", strings.Join(check.Tried, ", "))
+	default:
+		addFinding(&check.MailCheck, MailWarn, "no DKIM key on the common selectors, give the selector of your mail provider")
+	}
+
+	return check
+}
+
+// dkimKey checks the key record of one selector.
+func dkimKey(selector, record string) models.DKIMResult {
+	r := models.DKIMResult{Selector: selector, Record: record}
+	tags := parseTags(record)
+
+	if v, ok := tags["v"]; ok && v != "DKIM1" {
+		addFinding(&r.MailCheck, MailFail, "invalid version v=%s", v)
+	}
+
+	r.KeyType = strings.ToLower(tags["k"])
+	if r.KeyType == "" {
+		r.KeyType = "rsa"
+	}
+
+	if slices.Contains(strings.Split(tags["t"], ":"), "y") {
+		r.Testing = true
+		addFinding(&r.MailCheck, MailWarn, "testing mode (t=y), verifiers may treat signed mail as unsigned")
+	}
+
+	// Long keys are split in several strings, drop the whitespace
+	p := strings.Join(strings.Fields(tags["p"]), "")
+	if p == "" {
+		addFinding(&r.MailCheck, MailWarn, "key revoked (empty p=)")
+		return r
+	}
+
+	der, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		addFinding(&r.MailCheck, MailFail, "p= is not valid base64")
+		return r
+	}
+
+	switch r.KeyType {
+	case "rsa":
+		pub, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			// Some signers publish a bare PKCS#1 key
+			pub, err = x509.ParsePKCS1PublicKey(der)
+		}
+		key, ok := pub.(*rsa.PublicKey)
+		if err != nil || !ok {
+			addFinding(&r.MailCheck, MailFail, "p= is not an RSA public key")
+			return r
+		}
+
+		r.KeyBits = key.N.BitLen()
+		switch {
+		case r.KeyBits < 1024:
+			addFinding(&r.MailCheck, MailFail, "%d-bit RSA key, verifiers reject keys under 1024 bits", r.KeyBits)
+		case r.KeyBits < 2048:
+			addFinding(&r.MailCheck, MailWarn, "%d-bit RSA key, use 2048 bits", r.KeyBits)
+		default:
+			addFinding(&r.MailCheck, MailPass, "%d-bit RSA key", r.KeyBits)
+		}
+
+	case "ed25519":
+		if len(der) != ed25519.PublicKeySize {
+			addFinding(&r.MailCheck, MailFail, "p= is not an Ed25519 public key")
+			return r
+		}
+		r.KeyBits = 256
+		addFinding(&r.MailCheck, MailPass, "Ed25519 key")
+
+	default:
+		addFinding(&r.MailCheck, MailFail, "unknown key type k=%s", r.KeyType)
+	}
+
+	return r
+}
@@ -0,0 +1,155 @@
+/*
+File: server/internal/dns/mail_mtasts.go
+Description: MTA-STS (RFC 8461) - the _mta-sts record, the policy file
+served over HTTPS and its mx patterns against the domain's MX records.
+*/
+package dns
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"tools.bctechvibe.io.vn/server/internal/models"
+
+	"github.com/miekg/dns"
+)
+
+var stsIDRegex = regexp.MustCompile(`^[A-Za-z0-9]{1,32}$`)
+
+// One day: shorter policies expire between two visits of a sender
+const stsMinMaxAge = 86400
+
+func (a *MailAuditor) checkMTASTS(domain string) models.MTASTSResult {
+	var r models.MTASTSResult
+
+	records, err := a.tagRecords("_mta-sts."+domain, "STSv1")
+	switch {
+	case err != nil:
+		addFinding(&r.MailCheck, MailFail, "MTA-STS lookup failed: %v", err)
+		return r
+	case len(records) == 0:
+		addFinding(&r.MailCheck, MailWarn, "no MTA-STS, senders deliver without verifying TLS")
+		return r
+	case len(records) > 1:
+		addFinding(&r.MailCheck, MailFail, "%d MTA-STS records, senders ignore them all", len(records))
+		return r
+	}
+
+	r.Record = records[0]
+	r.ID = parseTags(r.Record)["id"]
+	if !stsIDRegex.MatchString(r.ID) {
+		addFinding(&r.MailCheck, MailFail, "invalid id=%q, use 1 to 32 letters or digits", r.ID)
+	}
+
+	policy, err := a.fetchSTSPolicy(domain)
+	if err != nil {
+		addFinding(&r.MailCheck, MailFail, "policy %s: %v", a.stsURL(domain), err)
+		return r
+	}
+
+	maxAge := ""
+	for _, line := range strings.Split(policy, "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		v = strings.TrimSpace(v)
+
+		switch strings.TrimSpace(k) {
+		case "version":
+			if v != "STSv1" {
+				addFinding(&r.MailCheck, MailFail, "policy version %q, want STSv1", v)
+			}
+		case "mode":
+			r.Mode = v
+		case "mx":
+			r.MX = append(r.MX, strings.ToLower(v))
+		case "max_age":
+			maxAge = v
+		}
+	}
+
+	switch r.Mode {
+	case "enforce":
+		addFinding(&r.MailCheck, MailPass, "mode enforce")
+	case "testing":
+		addFinding(&r.MailCheck, MailWarn, "mode testing, TLS failures are only reported")
+	case "none":
+		addFinding(&r.MailCheck, MailWarn, "mode none, the policy is withdrawn")
+	default:
+		addFinding(&r.MailCheck, MailFail, "invalid mode %q", r.Mode)
+	}
+
+	if age, err := strconv.Atoi(maxAge); err != nil || age < 0 {
+		addFinding(&r.MailCheck, MailFail, "invalid max_age %q", maxAge)
+	} else {
+		r.MaxAge = age
+		if age < stsMinMaxAge {
+			addFinding(&r.MailCheck, MailWarn, "max_age %d, senders cache the policy for less than a day", age)
+		}
+	}
+
+	if r.Mode == "none" {
+		return r
+	}
+	if len(r.MX) == 0 {
+		addFinding(&r.MailCheck, MailFail, "the policy lists no mx")
+		return r
+	}
+
+	// Every MX must match a pattern, or delivery to it fails in enforce mode
+	res, err := a.Resolver.Query(dns.Fqdn(domain), dns.TypeMX)
+	if err != nil {
+		return r
+	}
+	for _, rec := range res.Records {
+		host := strings.ToLower(strings.TrimSuffix(rec.Exchange, "."))
+		if rec.Type != "MX" || host == "" {
+			continue
+		}
+		if !slices.ContainsFunc(r.MX, func(pattern string) bool { return stsMXMatch(pattern, host) }) {
+			addFinding(&r.MailCheck, MailFail, "MX %s is not in the policy", host)
+		}
+	}
+
+	return r
+}
+
+func (a *MailAuditor) fetchSTSPolicy(domain string) (string, error) {
+	resp, err := a.HTTPClient.Get(a.stsURL(domain))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	if mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mt != "text/plain" {
+		return "", fmt.Errorf("Content-Type %q, want text/plain", resp.Header.Get("Content-Type"))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(string(body), "\r", ""), nil
+}
+
+// stsMXMatch matches an MX host against a policy pattern; "*." matches
+// exactly one label.
+func stsMXMatch(pattern, host string) bool {
+	pattern = strings.TrimSuffix(pattern, ".")
+
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		label, rest, found := strings.Cut(host, ".")
+		return found && label != "" && "."+rest == suffix
+	}
+	return pattern == host
+}
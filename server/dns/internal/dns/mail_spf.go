@@ -0,0 +1,261 @@
+/*
+File: server/internal/dns/mail_spf.go
+Description: SPF (RFC 7208) - parse the record, expand include: and
+redirect= recursively and count DNS lookups against the limit of 10.
+*/
+package dns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"tools.bctechvibe.io.vn/server/internal/models"
+)
+
+// spfLookupLimit is the DNS lookup limit of one SPF evaluation (RFC 7208 4.6.4)
+const spfLookupLimit = 10
+
+type spfTerm struct {
+	qualifier byte // + - ~ ?
+	name      string
+	value     string
+	modifier  bool // redirect= / exp=
+	text      string
+}
+
+// isSPF reports whether a TXT record is an SPF record ("v=spf1" token).
+func isSPF(record string) bool {
+	fields := strings.Fields(record)
+	return len(fields) > 0 && strings.EqualFold(fields[0], "v=spf1")
+}
+
+func parseSPF(record string) ([]spfTerm, error) {
+	var terms []spfTerm
+
+	for _, f := range strings.Fields(record)[1:] {
+		t := spfTerm{qualifier: '+', text: f}
+
+		// Modifier: name=value, the name has no ":" or "/"
+		if i := strings.IndexByte(f, '='); i > 0 && !strings.ContainsAny(f[:i], ":/") {
+			t.modifier = true
+			t.name, t.value = strings.ToLower(f[:i]), f[i+1:]
+			terms = append(terms, t)
+			continue
+		}
+
+		if strings.ContainsRune("+-~?", rune(f[0])) {
+			t.qualifier, f = f[0], f[1:]
+		}
+		t.name = f
+		if i := strings.IndexAny(f, ":/"); i >= 0 {
+			t.name, t.value = f[:i], strings.TrimPrefix(f[i:], ":")
+		}
+		t.name = strings.ToLower(t.name)
+
+		switch t.name {
+		case "all", "a", "mx", "ptr":
+		case "include", "exists":
+			if t.value == "" {
+				return nil, fmt.Errorf("%s needs a domain", t.text)
+			}
+		case "ip4", "ip6":
+			if !validSPFNetwork(t.name, t.value) {
+				return nil, fmt.Errorf("invalid network in %s", t.text)
+			}
+		default:
+			return nil, fmt.Errorf("unknown mechanism %q", t.text)
+		}
+
+		terms = append(terms, t)
+	}
+
+	return terms, nil
+}
+
+func validSPFNetwork(mech, value string) bool {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		var err error
+		if ip, _, err = net.ParseCIDR(value); err != nil {
+			return false
+		}
+	}
+	return (ip.To4() != nil) == (mech == "ip4")
+}
+
+// spfRecords returns the SPF records of domain.
+func (a *MailAuditor) spfRecords(domain string) ([]string, error) {
+	records, err := a.txt(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, r := range records {
+		if isSPF(r) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (a *MailAuditor) checkSPF(domain string) models.SPFResult {
+	var r models.SPFResult
+
+	records, err := a.spfRecords(domain)
+	switch {
+	case err != nil:
+		addFinding(&r.MailCheck, MailFail, "SPF lookup failed: %v", err)
+		return r
+	case len(records) == 0:
+		addFinding(&r.MailCheck, MailFail, "no SPF record, receivers can't tell which servers may send for the domain")
+		return r
+	case len(records) > 1:
+		addFinding(&r.MailCheck, MailFail, "%d SPF records, evaluation fails with permerror", len(records))
+		return r
+	}
+
+	r.Record = records[0]
+	terms, err := parseSPF(r.Record)
+	if err != nil {
+		addFinding(&r.MailCheck, MailFail, "invalid SPF record: %v", err)
+		return r
+	}
+
+	redirect := false
+	for _, t := range terms {
+		r.Mechanisms = append(r.Mechanisms, t.text)
+
+		switch {
+		case t.name == "all" && !t.modifier:
+			r.All = string(t.qualifier) + "all"
+		case t.name == "redirect" && t.modifier:
+			redirect = true
+		case t.name == "ptr":
+			addFinding(&r.MailCheck, MailWarn, "ptr is deprecated and slow, many receivers skip it (RFC 7208 5.5)")
+		}
+	}
+
+	if redirect && r.All != "" {
+		addFinding(&r.MailCheck, MailWarn, "redirect= is ignored because the record has an all mechanism")
+	}
+
+	w := &spfWalk{auditor: a, result: &r, path: map[string]bool{strings.ToLower(domain): true}}
+	w.walk(terms, 0)
+	r.Lookups = w.count
+
+	switch {
+	case r.Lookups > spfLookupLimit:
+		addFinding(&r.MailCheck, MailFail, "more than the limit of %d DNS lookups, expansion stopped: evaluation fails with permerror", spfLookupLimit)
+	case r.Lookups >= spfLookupLimit-2:
+		addFinding(&r.MailCheck, MailWarn, "%d of %d DNS lookups used, adding a provider will break SPF", r.Lookups, spfLookupLimit)
+	default:
+		addFinding(&r.MailCheck, MailPass, "%d of %d DNS lookups", r.Lookups, spfLookupLimit)
+	}
+
+	switch r.All {
+	case "-all":
+		addFinding(&r.MailCheck, MailPass, "-all: other servers fail SPF")
+	case "~all":
+		addFinding(&r.MailCheck, MailPass, "~all: other servers softfail, DMARC decides")
+	case "?all":
+		addFinding(&r.MailCheck, MailWarn, "?all: other servers are neutral, SPF protects nothing")
+	case "+all":
+		addFinding(&r.MailCheck, MailFail, "+all: any server on the Internet passes SPF")
+	default:
+		if !redirect {
+			addFinding(&r.MailCheck, MailWarn, "no all mechanism, other servers are neutral")
+		}
+	}
+
+	return r
+}
+
+// spfWalk expands include: and redirect= depth first. count is shared by
+// the whole tree, so expansion stops as soon as the limit is exceeded
+// instead of fanning out further (RFC 7208 4.6.4).
+type spfWalk struct {
+	auditor *MailAuditor
+	result  *models.SPFResult
+	path    map[string]bool // domains being expanded, for loops
+	count   int             // DNS lookups so far
+}
+
+// hasAll reports whether terms have an all mechanism; redirect= is then
+// ignored (RFC 7208 6.1).
+func hasAll(terms []spfTerm) bool {
+	for _, t := range terms {
+		if t.name == "all" && !t.modifier {
+			return true
+		}
+	}
+	return false
+}
+
+// walk counts the DNS lookups of terms and expands the records they include.
+func (w *spfWalk) walk(terms []spfTerm, depth int) {
+	all := hasAll(terms)
+
+	for _, t := range terms {
+		// Past the limit the result is permerror, stop querying
+		if w.count > spfLookupLimit {
+			return
+		}
+
+		switch {
+		case t.name == "include" && !t.modifier, t.name == "redirect" && t.modifier && !all:
+			w.count++
+			w.include(t.name, t.value, depth+1)
+		case !t.modifier && (t.name == "a" || t.name == "mx" || t.name == "ptr" || t.name == "exists"):
+			w.count++
+		}
+	}
+}
+
+func (w *spfWalk) include(kind, domain string, depth int) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	i := len(w.result.Includes)
+	w.result.Includes = append(w.result.Includes, models.SPFInclude{Domain: domain, Depth: depth})
+	fail := func(format string, args ...any) {
+		w.result.Includes[i].Error = fmt.Sprintf(format, args...)
+		addFinding(&w.result.MailCheck, MailFail, "%s:%s: %s", kind, domain, w.result.Includes[i].Error)
+	}
+
+	if w.count > spfLookupLimit {
+		fail("not fetched, over the lookup limit (permerror)")
+		return
+	}
+	if w.path[domain] {
+		fail("include loop")
+		return
+	}
+
+	records, err := w.auditor.spfRecords(domain)
+	switch {
+	case err != nil:
+		fail("lookup failed: %v", err)
+		return
+	case len(records) == 0:
+		fail("no SPF record (permerror)")
+		return
+	case len(records) > 1:
+		fail("%d SPF records (permerror)", len(records))
+		return
+	}
+
+	w.result.Includes[i].Record = records[0]
+	terms, err := parseSPF(records[0])
+	if err != nil {
+		fail("%v", err)
+		return
+	}
+
+	before := w.count
+	w.path[domain] = true
+	w.walk(terms, depth)
+	delete(w.path, domain)
+
+	w.result.Includes[i].Lookups = w.count - before
+}
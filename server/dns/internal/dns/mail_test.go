@@ -0,0 +1,473 @@
+package dns
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"tools.bctechvibe.io.vn/server/internal/models"
+)
+
+// txtZone builds a mapResolver from "name" → TXT values. `" "` splits a
+// value into character-strings, as in the zone file.
+func txtZone(txt map[string][]string) mapResolver {
+	m := mapResolver{}
+	for name, values := range txt {
+		for _, v := range values {
+			key := strings.ToLower(name) + ". TXT"
+			rr := &dns.TXT{Hdr: dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeTXT}, Txt: strings.Split(v, `" "`)}
+			m[key] = append(m[key], *parseRR(rr, name))
+		}
+	}
+	return m
+}
+
+func hasFinding(c models.MailCheck, status, substr string) bool {
+	return slices.ContainsFunc(c.Findings, func(f models.MailFinding) bool {
+		return f.Status == status && strings.Contains(f.Message, substr)
+	})
+}
+
+func TestSPF(t *testing.T) {
+	chain := map[string][]string{"example.test": {"v=spf1 include:i0.test -all"}}
+	for i := 0; i < 11; i++ {
+		chain[fmt.Sprintf("i%d.test", i)] = []string{fmt.Sprintf("v=spf1 include:i%d.test", i+1)}
+	}
+	chain["i11.test"] = []string{"v=spf1 -all"}
+
+	cases := []struct {
+		name    string
+		txt     map[string][]string
+		status  string
+		lookups int
+		finding string
+	}{
+		{
+			name: "includes expanded",
+			txt: map[string][]string{
+				"example.test": {"v=spf1 include:_spf.a.test include:b.test ip4:192.0.2.0/24 mx -all", "google-site-verification=x"},
+				"_spf.a.test":  {"v=spf1 include:c.test a ~all"},
+				"c.test":       {"v=spf1 ip4:198.51.100.1 ip6:2001:db8::/32 -all"},
+				"b.test":       {"v=spf1 exists:%{i}.b.test -all"},
+			},
+			status: MailPass, lookups: 6, finding: "6 of 10",
+		},
+		{name: "over the limit", txt: chain, status: MailFail, lookups: 11, finding: "more than the limit"},
+		{
+			name: "include loop",
+			txt: map[string][]string{
+				"example.test": {"v=spf1 include:a.test -all"},
+				"a.test":       {"v=spf1 include:example.test -all"},
+			},
+			status: MailFail, lookups: 2, finding: "include loop",
+		},
+		{
+			name:   "missing include",
+			txt:    map[string][]string{"example.test": {"v=spf1 include:gone.test -all"}},
+			status: MailFail, lookups: 1, finding: "include:gone.test: no SPF record",
+		},
+		{name: "+all", txt: map[string][]string{"example.test": {"v=spf1 +all"}}, status: MailFail, finding: "any server"},
+		{name: "?all", txt: map[string][]string{"example.test": {"v=spf1 mx ?all"}}, status: MailWarn, lookups: 1, finding: "neutral"},
+		{name: "no all", txt: map[string][]string{"example.test": {"v=spf1 a"}}, status: MailWarn, lookups: 1, finding: "no all"},
+		{
+			name: "redirect",
+			txt: map[string][]string{
+				"example.test":      {"v=spf1 redirect=_spf.example.test"},
+				"_spf.example.test": {"v=spf1 a mx -all"},
+			},
+			status: MailPass, lookups: 3,
+		},
+		{
+			name: "redirect with all",
+			txt: map[string][]string{
+				"example.test":      {"v=spf1 mx redirect=_spf.example.test -all"},
+				"_spf.example.test": {"v=spf1 a mx -all"},
+			},
+			status: MailWarn, lookups: 1, finding: "redirect= is ignored",
+		},
+		{name: "two records", txt: map[string][]string{"example.test": {"v=spf1 -all", "v=spf1 a -all"}}, status: MailFail, finding: "2 SPF records"},
+		{name: "bad network", txt: map[string][]string{"example.test": {"v=spf1 ip4:2001:db8::1 -all"}}, status: MailFail, finding: "invalid network"},
+		{name: "unknown mechanism", txt: map[string][]string{"example.test": {"v=spf1 ipv4:192.0.2.1 -all"}}, status: MailFail, finding: "unknown mechanism"},
+		{
+			name: "split mid-token",
+			txt: map[string][]string{
+				"example.test":     {`v=spf1 ip4:192.0.2.0/24 include:_spf.goo" "gle.test ~all`},
+				"_spf.google.test": {"v=spf1 ip4:198.51.100.0/24 -all"},
+			},
+			status: MailPass, lookups: 1,
+		},
+		{name: "spf2 is not spf1", txt: map[string][]string{"example.test": {"v=spf10 -all"}}, status: MailFail, finding: "no SPF record"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := NewMailAuditor(txtZone(c.txt)).checkSPF("example.test")
+
+			if r.Status != c.status || r.Lookups != c.lookups {
+				t.Errorf("status %s lookups %d, want %s %d: %+v", r.Status, r.Lookups, c.status, c.lookups, r.Findings)
+			}
+			if c.finding != "" && !hasFinding(r.MailCheck, c.status, c.finding) {
+				t.Errorf("no %s finding %q in %+v", c.status, c.finding, r.Findings)
+			}
+		})
+	}
+}
+
+func TestSPFIncludeTree(t *testing.T) {
+	r := NewMailAuditor(txtZone(map[string][]string{
+		"example.test": {"v=spf1 include:a.test -all"},
+		"a.test":       {"v=spf1 include:b.test mx -all"},
+		"b.test":       {"v=spf1 a -all"},
+	})).checkSPF("example.test")
+
+	want := []models.SPFInclude{
+		{Domain: "a.test", Record: "v=spf1 include:b.test mx -all", Depth: 1, Lookups: 3},
+		{Domain: "b.test", Record: "v=spf1 a -all", Depth: 2, Lookups: 1},
+	}
+	if !slices.Equal(r.Includes, want) || r.All != "-all" || r.Lookups != 4 {
+		t.Errorf("includes %+v, all %s, lookups %d", r.Includes, r.All, r.Lookups)
+	}
+}
+
+// countingResolver counts the queries sent to a resolver.
+type countingResolver struct {
+	Resolver
+	n int
+}
+
+func (c *countingResolver) Query(domain string, qtype uint16) (*Result, error) {
+	c.n++
+	return c.Resolver.Query(domain, qtype)
+}
+
+// A tree fanning out to distinct names must stop at the limit, not be
+// expanded in full.
+func TestSPFLookupLimitFanOut(t *testing.T) {
+	txt := map[string][]string{}
+	var build func(name string, depth int)
+	build = func(name string, depth int) {
+		if depth == 5 {
+			txt[name] = []string{"v=spf1 -all"}
+			return
+		}
+		txt[name] = []string{fmt.Sprintf("v=spf1 include:a.%s include:b.%s include:c.%s -all", name, name, name)}
+		for _, c := range []string{"a", "b", "c"} {
+			build(c+"."+name, depth+1)
+		}
+	}
+	build("example.test", 0)
+
+	res := &countingResolver{Resolver: txtZone(txt)}
+	r := NewMailAuditor(res).checkSPF("example.test")
+
+	if r.Status != MailFail || r.Lookups != spfLookupLimit+1 {
+		t.Errorf("status %s lookups %d: %+v", r.Status, r.Lookups, r.Findings)
+	}
+	if res.n > spfLookupLimit+1 {
+		t.Errorf("%d TXT queries, want at most %d", res.n, spfLookupLimit+1)
+	}
+}
+
+func dkimRecord(t *testing.T, k string, pub any) string {
+	t.Helper()
+
+	if k == "ed25519" {
+		return "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub.(ed25519.PublicKey))
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Split in two character-strings, like a key longer than 255 bytes
+	p := base64.StdEncoding.EncodeToString(der)
+	return "v=DKIM1; k=rsa; p=" + p[:100] + `" "` + p[100:]
+}
+
+func TestDKIM(t *testing.T) {
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewMailAuditor(txtZone(map[string][]string{
+		"google._domainkey.example.test":    {dkimRecord(t, "rsa", &rsa2048.PublicKey)},
+		"old._domainkey.example.test":       {dkimRecord(t, "rsa", &rsa1024.PublicKey)},
+		"ed._domainkey.example.test":        {dkimRecord(t, "ed25519", edPub)},
+		"revoked._domainkey.example.test":   {"v=DKIM1; p="},
+		"test._domainkey.example.test":      {"v=DKIM1; t=y; " + strings.TrimPrefix(dkimRecord(t, "rsa", &rsa2048.PublicKey), "v=DKIM1; ")},
+		"garbage._domainkey.example.test":   {"v=DKIM1; p=not*base64"},
+		"unrelated._domainkey.example.test": {"some verification token"},
+	}))
+
+	check := a.checkDKIM("example.test", []string{"Google", "old", "ed", "revoked", "test", "garbage", "unrelated", "google", "bad selector"})
+
+	if want := []string{"google", "old", "ed", "revoked", "test", "garbage", "unrelated"}; !slices.Equal(check.Tried, want) {
+		t.Errorf("tried %v", check.Tried)
+	}
+
+	keys := map[string]models.DKIMResult{}
+	for _, k := range check.Keys {
+		keys[k.Selector] = k
+	}
+	if len(keys) != 6 {
+		t.Fatalf("%d keys: %+v", len(keys), check.Keys)
+	}
+	for sel, want := range map[string]struct {
+		status string
+		bits   int
+	}{
+		"google": {MailPass, 2048}, "old": {MailWarn, 1024}, "ed": {MailPass, 256},
+		"revoked": {MailWarn, 0}, "test": {MailWarn, 2048}, "garbage": {MailFail, 0},
+	} {
+		if k := keys[sel]; k.Status != want.status || k.KeyBits != want.bits {
+			t.Errorf("%s: status %s bits %d, want %s %d", sel, k.Status, k.KeyBits, want.status, want.bits)
+		}
+	}
+	if !keys["test"].Testing || check.Status != MailFail {
+		t.Errorf("testing %v, status %s", keys["test"].Testing, check.Status)
+	}
+
+	if c := a.checkDKIM("example.test", []string{"missing"}); c.Status != MailFail || len(c.Keys) != 0 {
+		t.Errorf("supplied selector without key: %+v", c)
+	}
+	if c := a.checkDKIM("other.test", nil); c.Status != MailWarn || len(c.Tried) != len(CommonDKIMSelectors) {
+		t.Errorf("common selectors without key: %+v", c)
+	}
+}
+
+func TestDMARC(t *testing.T) {
+	cases := []struct {
+		name    string
+		domain  string
+		txt     map[string][]string
+		status  string
+		finding string
+	}{
+		{
+			name: "reject", domain: "example.test",
+			txt:    map[string][]string{"_dmarc.example.test": {"v=DMARC1; p=reject; adkim=s; rua=mailto:dmarc@example.test"}},
+			status: MailPass, finding: "DKIM alignment strict, SPF alignment relaxed",
+		},
+		{
+			name: "monitoring", domain: "example.test",
+			txt:    map[string][]string{"_dmarc.example.test": {"v=DMARC1; p=none; rua=mailto:d@example.test"}},
+			status: MailWarn, finding: "p=none",
+		},
+		{
+			name: "partial", domain: "example.test",
+			txt:    map[string][]string{"_dmarc.example.test": {"v=DMARC1; p=quarantine; pct=25; rua=mailto:d@example.test"}},
+			status: MailWarn, finding: "25%",
+		},
+		{
+			name: "organizational domain", domain: "mail.example.com",
+			txt:    map[string][]string{"_dmarc.example.com": {"v=DMARC1; p=reject; sp=none; rua=mailto:d@example.com"}},
+			status: MailWarn, finding: "p=none",
+		},
+		{
+			name: "external rua not authorized", domain: "example.test",
+			txt:    map[string][]string{"_dmarc.example.test": {"v=DMARC1; p=reject; rua=mailto:d@reports.test!10m"}},
+			status: MailWarn, finding: "example.test._report._dmarc.reports.test",
+		},
+		{
+			name: "external rua authorized", domain: "example.test",
+			txt: map[string][]string{
+				"_dmarc.example.test":                      {"v=DMARC1; p=reject; rua=mailto:d@reports.test"},
+				"example.test._report._dmarc.reports.test": {"v=DMARC1"},
+			},
+			status: MailPass,
+		},
+		{name: "missing", domain: "example.test", txt: nil, status: MailFail, finding: "no DMARC record"},
+		{
+			name: "no policy", domain: "example.test",
+			txt:    map[string][]string{"_dmarc.example.test": {"v=DMARC1; rua=mailto:d@example.test"}},
+			status: MailFail, finding: "p= tag is missing",
+		},
+		{
+			name: "bad alignment", domain: "example.test",
+			txt:    map[string][]string{"_dmarc.example.test": {"v=DMARC1; p=reject; aspf=x; rua=mailto:d@example.test"}},
+			status: MailFail, finding: "aspf=x",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := NewMailAuditor(txtZone(c.txt)).checkDMARC(c.domain)
+
+			if r.Status != c.status {
+				t.Errorf("status %s, want %s: %+v", r.Status, c.status, r.Findings)
+			}
+			if c.finding != "" && !hasFinding(r.MailCheck, c.status, c.finding) {
+				t.Errorf("no %s finding %q in %+v", c.status, c.finding, r.Findings)
+			}
+		})
+	}
+
+	r := NewMailAuditor(txtZone(map[string][]string{
+		"_dmarc.example.com": {"v=DMARC1; p=reject; sp=quarantine; rua=mailto:a@example.com,mailto:b@example.com"},
+	})).checkDMARC("mail.example.com")
+	if r.Domain != "example.com" || r.Policy != "reject" || r.SubPol != "quarantine" || len(r.RUA) != 2 || r.Percent != 100 {
+		t.Errorf("parsed %+v", r)
+	}
+}
+
+func TestMTASTS(t *testing.T) {
+	policies := map[string]string{
+		"enforce": "version: STSv1\r\nmode: enforce\r\nmx: mx1.example.test\r\nmx: *.backup.example.test\r\nmax_age: 604800\r\n",
+		"testing": "version: STSv1\nmode: testing\nmx: *.example.test\nmx: *.backup.example.test\nmax_age: 3600\n",
+		"partial": "version: STSv1\nmode: enforce\nmx: mx1.example.test\nmax_age: 604800\n",
+	}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if name == "html" {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(policies["enforce"]))
+			return
+		}
+		policy, ok := policies[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(policy))
+	}))
+	defer srv.Close()
+
+	zone := txtZone(map[string][]string{"_mta-sts.example.test": {"v=STSv1; id=20260101T000000"}})
+	zone["example.test. MX"] = []models.DNSRecord{
+		{Type: "MX", Exchange: "mx1.example.test"},
+		{Type: "MX", Exchange: "mx2.backup.example.test."},
+	}
+
+	cases := []struct {
+		policy  string
+		status  string
+		finding string
+	}{
+		{"enforce", MailPass, "mode enforce"},
+		{"testing", MailWarn, "max_age 3600"},
+		{"partial", MailFail, "MX mx2.backup.example.test is not in the policy"},
+		{"html", MailFail, "text/plain"},
+		{"missing", MailFail, "HTTP 404"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.policy, func(t *testing.T) {
+			a := NewMailAuditor(zone)
+			a.HTTPClient = srv.Client()
+			a.stsURL = func(string) string { return srv.URL + "/" + c.policy }
+
+			r := a.checkMTASTS("example.test")
+			if r.Status != c.status || !hasFinding(r.MailCheck, c.status, c.finding) {
+				t.Errorf("status %s, want %s with %q: %+v", r.Status, c.status, c.finding, r.Findings)
+			}
+		})
+	}
+
+	if r := NewMailAuditor(mapResolver{}).checkMTASTS("example.test"); r.Status != MailWarn {
+		t.Errorf("no record: %+v", r)
+	}
+}
+
+func TestSTSMXMatch(t *testing.T) {
+	for _, c := range []struct {
+		pattern, host string
+		want          bool
+	}{
+		{"mx.example.test", "mx.example.test", true},
+		{"mx.example.test.", "mx.example.test", true},
+		{"*.example.test", "mx.example.test", true},
+		{"*.example.test", "a.mx.example.test", false},
+		{"*.example.test", "example.test", false},
+		{"mx.example.test", "mx2.example.test", false},
+	} {
+		if got := stsMXMatch(c.pattern, c.host); got != c.want {
+			t.Errorf("stsMXMatch(%q, %q) = %v", c.pattern, c.host, got)
+		}
+	}
+}
+
+func TestTLSRPTAndBIMI(t *testing.T) {
+	reject := models.DMARCResult{Domain: "example.test", Policy: "reject", Percent: 100}
+	monitor := models.DMARCResult{Domain: "example.test", Policy: "none", Percent: 100}
+
+	cases := []struct {
+		name    string
+		txt     map[string][]string
+		dmarc   models.DMARCResult
+		tlsrpt  string
+		bimi    string
+		finding string
+	}{
+		{
+			name: "complete",
+			txt: map[string][]string{
+				"_smtp._tls.example.test":    {"v=TLSRPTv1; rua=mailto:tls@example.test,https://report.example.test/tls"},
+				"default._bimi.example.test": {"v=BIMI1; l=https://example.test/logo.svg; a=https://example.test/vmc.pem"},
+			},
+			dmarc: reject, tlsrpt: MailPass, bimi: MailPass,
+		},
+		{
+			name: "bimi without enforcement",
+			txt: map[string][]string{
+				"_smtp._tls.example.test":    {"v=TLSRPTv1; rua=ftp://example.test"},
+				"default._bimi.example.test": {"v=BIMI1; l=http://example.test/logo.png"},
+			},
+			dmarc: monitor, tlsrpt: MailFail, bimi: MailFail, finding: "BIMI needs DMARC",
+		},
+		{name: "none", dmarc: reject, tlsrpt: MailWarn, bimi: MailWarn},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			a := NewMailAuditor(txtZone(c.txt))
+
+			tlsrpt := a.checkTLSRPT("example.test")
+			bimi := a.checkBIMI("example.test", c.dmarc)
+
+			if tlsrpt.Status != c.tlsrpt || bimi.Status != c.bimi {
+				t.Errorf("TLS-RPT %s %+v, BIMI %s %+v", tlsrpt.Status, tlsrpt.Findings, bimi.Status, bimi.Findings)
+			}
+			if c.finding != "" && !hasFinding(bimi.MailCheck, MailFail, c.finding) {
+				t.Errorf("no finding %q in %+v", c.finding, bimi.Findings)
+			}
+		})
+	}
+}
+
+func TestMailAudit(t *testing.T) {
+	a := NewMailAuditor(txtZone(map[string][]string{
+		"example.test":        {"v=spf1 mx -all"},
+		"_dmarc.example.test": {"v=DMARC1; p=reject; rua=mailto:d@example.test"},
+	}))
+	a.stsURL = func(string) string { return "http://127.0.0.1:1/" }
+
+	audit := a.Audit("Example.Test.", nil)
+
+	if audit.Domain != "example.test" || audit.SPF.Status != MailPass || audit.DMARC.Status != MailPass {
+		t.Errorf("audit %+v", audit)
+	}
+	// no DKIM on the common selectors, no MTA-STS / TLS-RPT / BIMI
+	if audit.Status != MailWarn || audit.DKIM.Status != MailWarn || audit.MTASTS.Status != MailWarn {
+		t.Errorf("status %s, DKIM %s, MTA-STS %s", audit.Status, audit.DKIM.Status, audit.MTASTS.Status)
+	}
+}
@@ -0,0 +1,242 @@
+// ============================================
+// FILE: internal/dns/propagation.go
+// PURPOSE:
+//   - Send one name/type to every provider (DoH + UDP) and
+//     a list of regional public resolvers, in parallel
+//   - Group the answers by value and flag resolvers that
+//     disagree with the majority
+//
+// ============================================
+package dns
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/internal/models"
+
+	dnslib "github.com/miekg/dns"
+)
+
+// RegionalResolvers are asked by the propagation check besides the
+// registered providers. DNS_PROPAGATION_RESOLVERS replaces them:
+// "name|region|ip[:port],..."
+var RegionalResolvers = regionalResolvers(os.Getenv("DNS_PROPAGATION_RESOLVERS"))
+
+var defaultRegionalResolvers = []models.PropagationResolver{
+	{Name: "Level3", Region: "US", Server: "4.2.2.1:53"},
+	{Name: "Verisign", Region: "US", Server: "64.6.64.6:53"},
+	{Name: "DNS.WATCH", Region: "DE", Server: "84.200.69.80:53"},
+	{Name: "Yandex", Region: "RU", Server: "77.88.8.8:53"},
+	{Name: "AliDNS", Region: "CN", Server: "223.5.5.5:53"},
+	{Name: "DNSPod", Region: "CN", Server: "119.29.29.29:53"},
+	{Name: "VNPT", Region: "VN", Server: "203.162.4.191:53"},
+	{Name: "Viettel", Region: "VN", Server: "203.113.131.1:53"},
+}
+
+// propagationTimeout bounds each resolver, a slow one shows as failed
+const propagationTimeout = 4 * time.Second
+
+// regionalResolvers parses DNS_PROPAGATION_RESOLVERS. Malformed entries
+// are skipped; an empty value keeps the defaults.
+func regionalResolvers(env string) []models.PropagationResolver {
+	if strings.TrimSpace(env) == "" {
+		return defaultRegionalResolvers
+	}
+
+	var list []models.PropagationResolver
+	for _, entry := range strings.Split(env, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), "|")
+		if len(parts) != 3 {
+			log.Printf("DNS_PROPAGATION_RESOLVERS: ignoring %q", entry)
+			continue
+		}
+
+		addr := strings.TrimSpace(parts[2])
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "53")
+		}
+
+		list = append(list, models.PropagationResolver{
+			Name:   strings.TrimSpace(parts[0]),
+			Region: strings.TrimSpace(parts[1]),
+			Server: addr,
+		})
+	}
+
+	return list
+}
+
+type propagationTarget struct {
+	name      string
+	region    string
+	transport string
+	resolver  Resolver
+}
+
+// propagationTargets lists every DoH and UDP provider, then the
+// regional resolvers, in a stable order.
+func propagationTargets() []propagationTarget {
+	var targets []propagationTarget
+
+	for _, key := range sortedKeys(DoHServers) {
+		targets = append(targets, propagationTarget{key, "", TransportDoH, ResolverManagerFor(key).Default})
+	}
+	for _, key := range sortedKeys(DNSServers) {
+		targets = append(targets, propagationTarget{key, "", TransportUDP, ResolverManagerFor(key).UDP})
+	}
+	for _, r := range RegionalResolvers {
+		targets = append(targets, propagationTarget{
+			r.Name, r.Region, TransportUDP,
+			&UDPResolver{Server: r.Server, Timeout: propagationTimeout},
+		})
+	}
+
+	return targets
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// CheckPropagation asks every provider and regional resolver for
+// domain/qtype and compares their answers.
+func CheckPropagation(domain string, qtype uint16) *models.PropagationInfo {
+	return checkPropagation(propagationTargets(), dnslib.Fqdn(domain), qtype)
+}
+
+func checkPropagation(targets []propagationTarget, domain string, qtype uint16) *models.PropagationInfo {
+	answers := make([]models.PropagationAnswer, len(targets))
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			answers[i] = queryPropagation(t, domain, qtype)
+		}()
+	}
+	wg.Wait()
+
+	info := &models.PropagationInfo{
+		Name:    strings.TrimSuffix(domain, "."),
+		Type:    dnslib.TypeToString[qtype],
+		Total:   len(answers),
+		Answers: answers,
+		Groups:  []models.PropagationGroup{},
+	}
+
+	// Group by RCODE + sorted values, in first-seen order
+	index := map[string]int{}
+	for _, a := range answers {
+		if a.Error != "" {
+			info.Failed++
+			continue
+		}
+		info.Answered++
+
+		key := a.Rcode + "\x00" + strings.Join(a.Values, "\n")
+		i, ok := index[key]
+		if !ok {
+			i = len(info.Groups)
+			index[key] = i
+			info.Groups = append(info.Groups, models.PropagationGroup{Rcode: a.Rcode, Values: a.Values})
+		}
+		info.Groups[i].Resolvers = append(info.Groups[i].Resolvers, a.Resolver)
+	}
+
+	// Largest group first; ties keep the provider order
+	slices.SortStableFunc(info.Groups, func(a, b models.PropagationGroup) int {
+		return len(b.Resolvers) - len(a.Resolvers)
+	})
+	if len(info.Groups) == 0 {
+		return info
+	}
+
+	majority := &info.Groups[0]
+	majority.Majority = true
+	info.Agreeing = len(majority.Resolvers)
+	info.Consistent = len(info.Groups) == 1
+
+	for i := range answers {
+		a := &answers[i]
+		if a.Error != "" {
+			continue
+		}
+
+		a.Agrees = a.Rcode == majority.Rcode && slices.Equal(a.Values, majority.Values)
+		if !a.Agrees {
+			info.Disagreeing = append(info.Disagreeing, a.Resolver)
+		}
+	}
+
+	return info
+}
+
+func queryPropagation(t propagationTarget, domain string, qtype uint16) models.PropagationAnswer {
+	answer := models.PropagationAnswer{
+		Resolver:  t.name,
+		Region:    t.region,
+		Transport: t.transport,
+		Values:    []string{},
+	}
+
+	if t.resolver == nil {
+		answer.Error = fmt.Sprintf("no %s resolver", t.transport)
+		return answer
+	}
+
+	start := time.Now()
+	res, err := t.resolver.Query(domain, qtype)
+	answer.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		answer.Error = err.Error()
+		return answer
+	}
+
+	answer.Rcode = dnslib.RcodeToString[res.Rcode]
+
+	qtypeName := dnslib.TypeToString[qtype]
+	for i, rec := range res.Records {
+		value := recordValue(rec)
+		// CNAMEs on the way to the answer are part of it
+		if rec.Type != qtypeName {
+			value = rec.Type + " " + value
+		}
+		answer.Values = append(answer.Values, value)
+
+		if i == 0 || rec.TTL < answer.TTL {
+			answer.TTL = rec.TTL
+		}
+	}
+	slices.Sort(answer.Values)
+
+	return answer
+}
+
+// recordValue is the comparable data of a record. Names are lowercased,
+// resolvers don't agree on the case they return.
+func recordValue(rec models.DNSRecord) string {
+	switch {
+	case rec.Address != "":
+		return rec.Address
+	case rec.Nameserver != "":
+		return strings.ToLower(rec.Nameserver)
+	case rec.Exchange != "" || rec.Type == "MX":
+		return fmt.Sprintf("%d %s", rec.Priority, strings.ToLower(rec.Exchange))
+	case rec.Type == "CNAME" || rec.Type == "PTR":
+		return strings.ToLower(rec.Value)
+	}
+	return rec.Value
+}
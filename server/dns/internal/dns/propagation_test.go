@@ -0,0 +1,124 @@
+package dns
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+	"tools.bctechvibe.io.vn/server/internal/models"
+)
+
+// answerResolver answers every query with res.
+type answerResolver struct {
+	res *Result
+}
+
+func (a answerResolver) Query(domain string, qtype uint16) (*Result, error) {
+	return a.res, nil
+}
+
+func aRecords(ttl uint32, addrs ...string) *Result {
+	res := &Result{}
+	for _, addr := range addrs {
+		res.Records = append(res.Records, models.DNSRecord{Type: "A", Address: addr, TTL: ttl})
+	}
+	return res
+}
+
+func TestCheckPropagation(t *testing.T) {
+	targets := []propagationTarget{
+		{"google", "", TransportDoH, answerResolver{aRecords(300, "192.0.2.2", "192.0.2.1")}},
+		{"cloudflare", "", TransportDoH, answerResolver{aRecords(120, "192.0.2.1", "192.0.2.2")}},
+		{"quad9", "", TransportUDP, answerResolver{aRecords(3600, "198.51.100.1")}},
+		{"Yandex", "RU", TransportUDP, answerResolver{&Result{Rcode: dns.RcodeNameError}}},
+		{"down", "VN", TransportUDP, &stubResolver{err: errors.New("i/o timeout")}},
+		{"none", "", TransportDoH, nil},
+	}
+
+	info := checkPropagation(targets, "example.test.", dns.TypeA)
+
+	if info.Name != "example.test" || info.Type != "A" {
+		t.Errorf("name %q type %q", info.Name, info.Type)
+	}
+	if info.Total != 6 || info.Answered != 4 || info.Failed != 2 || info.Agreeing != 2 || info.Consistent {
+		t.Errorf("total %d answered %d failed %d agreeing %d consistent %v",
+			info.Total, info.Answered, info.Failed, info.Agreeing, info.Consistent)
+	}
+
+	if len(info.Groups) != 3 {
+		t.Fatalf("%d groups: %+v", len(info.Groups), info.Groups)
+	}
+	majority := info.Groups[0]
+	if !majority.Majority || !slices.Equal(majority.Resolvers, []string{"google", "cloudflare"}) ||
+		!slices.Equal(majority.Values, []string{"192.0.2.1", "192.0.2.2"}) {
+		t.Errorf("majority %+v", majority)
+	}
+	if g := info.Groups[2]; g.Rcode != "NXDOMAIN" || len(g.Values) != 0 || g.Majority {
+		t.Errorf("NXDOMAIN group %+v", g)
+	}
+
+	if !slices.Equal(info.Disagreeing, []string{"quad9", "Yandex"}) {
+		t.Errorf("disagreeing %v", info.Disagreeing)
+	}
+
+	google, cloudflare, down := info.Answers[0], info.Answers[1], info.Answers[4]
+	if !google.Agrees || google.TTL != 300 || cloudflare.TTL != 120 || google.Rcode != "NOERROR" {
+		t.Errorf("google %+v, cloudflare %+v", google, cloudflare)
+	}
+	if down.Agrees || down.Error == "" || down.Region != "VN" {
+		t.Errorf("failed resolver %+v", down)
+	}
+}
+
+func TestCheckPropagationConsistent(t *testing.T) {
+	cname := &Result{Records: []models.DNSRecord{
+		{Type: "CNAME", Value: "Edge.Example.test"},
+		{Type: "A", Address: "192.0.2.1"},
+	}}
+	lower := &Result{Records: []models.DNSRecord{
+		{Type: "CNAME", Value: "edge.example.test"},
+		{Type: "A", Address: "192.0.2.1"},
+	}}
+
+	info := checkPropagation([]propagationTarget{
+		{"a", "", TransportDoH, answerResolver{cname}},
+		{"b", "", TransportUDP, answerResolver{lower}},
+	}, "www.example.test.", dns.TypeA)
+
+	if !info.Consistent || len(info.Disagreeing) != 0 {
+		t.Fatalf("case of names should not matter: %+v", info.Groups)
+	}
+	if want := []string{"192.0.2.1", "CNAME edge.example.test"}; !slices.Equal(info.Groups[0].Values, want) {
+		t.Errorf("values %v, want %v", info.Groups[0].Values, want)
+	}
+}
+
+func TestRegionalResolvers(t *testing.T) {
+	if got := regionalResolvers(""); len(got) != len(defaultRegionalResolvers) {
+		t.Errorf("empty env should keep the defaults, got %v", got)
+	}
+
+	got := regionalResolvers("Local|VN|10.0.0.1, v6|DE|2001:db8::1, Alt|US|192.0.2.53:5353, broken")
+	want := []models.PropagationResolver{
+		{Name: "Local", Region: "VN", Server: "10.0.0.1:53"},
+		{Name: "v6", Region: "DE", Server: "[2001:db8::1]:53"},
+		{Name: "Alt", Region: "US", Server: "192.0.2.53:5353"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestPropagationTargets(t *testing.T) {
+	targets := propagationTargets()
+
+	if len(targets) != len(DoHServers)+len(DNSServers)+len(RegionalResolvers) {
+		t.Fatalf("%d targets", len(targets))
+	}
+	for _, target := range targets {
+		if target.resolver == nil {
+			t.Errorf("%s/%s has no resolver", target.name, target.transport)
+		}
+	}
+}
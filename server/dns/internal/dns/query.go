@@ -40,34 +40,60 @@ var GeoASNDB *geoip2.Reader
 //   - This function preserves backward compatibility
 //   - Internal architecture remains clean & extensible
 func QueryDNS(server string, domain string, qtype uint16) []interface{} {
-	// 1. Resolve DoH provider by key
-	doh, ok := DoHServers[server]
-	if !ok {
-		log.Printf("Unknown DoH server key: %s", server)
-		return []interface{}{}
+	return QueryDNSVia(server, TransportDoH, domain, qtype)
+}
+
+// QueryDNSVia queries through the given transport of a provider
+// (doh | udp | tcp | dot | doq), falling back to the provider's other
+// transports when it fails.
+func QueryDNSVia(server, transport string, domain string, qtype uint16) []interface{} {
+	result, _ := QueryDNSTransport(server, transport, domain, qtype)
+	return result
+}
+
+// QueryDNSTransport is QueryDNSVia that also reports how the query was
+// answered: transport used, RCODE, flags, error and latency.
+func QueryDNSTransport(server, transport string, domain string, qtype uint16) ([]interface{}, models.ResponseInfo) {
+	info := models.ResponseInfo{
+		Name:      strings.TrimSuffix(domain, "."),
+		Type:      dns.TypeToString[qtype],
+		Server:    server,
+		Transport: transport,
+	}
+
+	// 1. Resolve provider by key (long-lived, pooled connections)
+	rm := ResolverManagerFor(server)
+	if !rm.Supports(transport) {
+		info.Error = fmt.Sprintf("DNS server %s has no %s resolver", server, transport)
+		log.Print(info.Error)
+		return []interface{}{}, info
 	}
 
-	// 2. Build resolver manager
-	rm := NewResolverManager(
-		doh,
-		&UDPResolver{
-			Server:  "8.8.8.8:53",
-			Timeout: 5 * time.Second,
-		},
-	)
-
-	// 3. Execute query (default = DoH)
-	records, err := rm.Resolve(domain, qtype, "doh")
+	// 2. Execute query, with fallback
+	res, err := rm.ResolveFallback(domain, qtype, transport)
 	if err != nil {
-		log.Printf("Resolver error: %v", err)
-		return []interface{}{}
+		log.Printf("Resolver error (%s/%s): %v", server, transport, err)
+		info.Error = err.Error()
+		return []interface{}{}, info
 	}
 
-	// 4. Convert to generic interface slice
-	result := make([]interface{}, 0, len(records))
+	info.Transport = res.Transport
+	info.Fallback = res.Transport != transport
+	info.Rcode = dns.RcodeToString[res.Rcode]
+	info.AD = res.AuthenticatedData
+	info.TC = res.Truncated
+	info.RA = res.RecursionAvailable
+	info.LatencyMs = res.Latency.Milliseconds()
+	info.Answers = len(res.Records)
+	if res.FallbackErr != nil {
+		info.Error = res.FallbackErr.Error()
+	}
 
-	for i := range records {
-		rec := records[i]
+	// 3. Convert to generic interface slice
+	result := make([]interface{}, 0, len(res.Records))
+
+	for i := range res.Records {
+		rec := res.Records[i]
 
 		switch rec.Type {
 		case "A", "AAAA":
@@ -82,7 +108,7 @@ func QueryDNS(server string, domain string, qtype uint16) []interface{} {
 		result = append(result, rec)
 	}
 
-	return result
+	return result, info
 }
 
 // ============================================
@@ -119,68 +145,19 @@ func QueryDNSUDP(server, domain string, qtype uint16) []interface{} {
 
 	log.Printf("DNS query success for %s (type %d): %d answers", domain, qtype, len(resp.Answer))
 
-	// Parse answers
+	// Parse answers the same way as every other transport
 	for _, answer := range resp.Answer {
-		switch rr := answer.(type) {
-		case *dns.A:
-			record := models.DNSRecord{
-				Type:    "A",
-				Address: rr.A.String(),
-				TTL:     rr.Hdr.Ttl,
-			}
-			enrichIPInfo(&record, rr.A)
-			records = append(records, record)
-
-		case *dns.AAAA:
-			record := models.DNSRecord{
-				Type:    "AAAA",
-				Address: rr.AAAA.String(),
-				TTL:     rr.Hdr.Ttl,
-			}
-			enrichIPInfo(&record, rr.AAAA)
-			records = append(records, record)
-
-		case *dns.NS:
-			records = append(records, models.DNSRecord{
-				Type:       "NS",
-				Nameserver: rr.Ns,
-				TTL:        rr.Hdr.Ttl,
-			})
-
-		case *dns.MX:
-			records = append(records, models.DNSRecord{
-				Type:     "MX",
-				Exchange: rr.Mx,
-				Priority: rr.Preference,
-				TTL:      rr.Hdr.Ttl,
-			})
-
-		case *dns.CNAME:
-			records = append(records, models.DNSRecord{
-				Type:  "CNAME",
-				Value: rr.Target,
-				TTL:   rr.Hdr.Ttl,
-			})
-
-		case *dns.TXT:
-			txtValue := strings.Join(rr.Txt, " ")
-			records = append(records, models.DNSRecord{
-				Type:  "TXT",
-				Value: txtValue,
-				TTL:   rr.Hdr.Ttl,
-			})
-
-		case *dns.PTR:
-			record := models.DNSRecord{
-				Type:  "PTR",
-				Value: rr.Ptr,
-				TTL:   rr.Hdr.Ttl,
-			}
-			records = append(records, record)
+		rec := parseRR(answer, domain)
+		if rec == nil {
+			log.Printf("Unknown record type: %T", answer)
+			continue
+		}
 
-		default:
-			log.Printf("Unknown record type: %T", rr)
+		if rec.Type == "A" || rec.Type == "AAAA" {
+			EnrichIPInfoByString(rec, rec.Address)
 		}
+
+		records = append(records, *rec)
 	}
 
 	return records
@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -15,7 +16,10 @@ import (
 
 // ✅ FIX: Add Authority section to struct
 type dohResponse struct {
-	Status    int         `json:"Status"`
+	Status    int         `json:"Status"` // RCODE
+	TC        bool        `json:"TC"`
+	RA        bool        `json:"RA"`
+	AD        bool        `json:"AD"`
 	Answer    []dohRecord `json:"Answer,omitempty"`
 	Authority []dohRecord `json:"Authority,omitempty"` // ✅ NEW
 }
@@ -27,19 +31,45 @@ type dohRecord struct {
 	Data string `json:"data"`
 }
 
-func (r *DoHResolver) Query(domain string, qtype uint16) ([]models.DNSRecord, error) {
+// httpClient returns the resolver's long-lived client. Its transport keeps
+// one pooled HTTP/2 connection to the endpoint.
+func (r *DoHResolver) httpClient() *http.Client {
+	r.clientOnce.Do(func() {
+		timeout := r.Timeout
+		if timeout == 0 {
+			timeout = 5 * time.Second
+		}
+
+		r.client = &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				TLSClientConfig:     &tls.Config{RootCAs: r.RootCAs, MinVersion: tls.VersionTLS12},
+				ForceAttemptHTTP2:   true,
+				MaxIdleConns:        100,
+				MaxIdleConnsPerHost: 16,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: timeout,
+			},
+		}
+	})
+
+	return r.client
+}
+
+func (r *DoHResolver) Query(domain string, qtype uint16) (*Result, error) {
 	if r.SupportsJSON {
 		return r.queryJSON(domain, qtype)
 	}
 	return r.queryRFC8484(domain, qtype)
 }
 
-func (r *DoHResolver) queryJSON(domain string, qtype uint16) ([]models.DNSRecord, error) {
+func (r *DoHResolver) queryJSON(domain string, qtype uint16) (*Result, error) {
 	var records []models.DNSRecord
 
 	req, err := http.NewRequest("GET", r.Endpoint, nil)
 	if err != nil {
-		return records, err
+		return nil, err
 	}
 
 	q := req.URL.Query()
@@ -48,25 +78,30 @@ func (r *DoHResolver) queryJSON(domain string, qtype uint16) ([]models.DNSRecord
 	req.URL.RawQuery = q.Encode()
 	req.Header.Set("Accept", "application/dns-json")
 
-	timeout := r.Timeout
-	if timeout == 0 {
-		timeout = 5 * time.Second
-	}
-
-	client := &http.Client{Timeout: timeout}
-	resp, err := client.Do(req)
+	resp, err := r.httpClient().Do(req)
 	if err != nil {
-		return records, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH %s: HTTP %d", r.Endpoint, resp.StatusCode)
+	}
+
 	var result dohResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return records, err
+		return nil, err
 	}
 
-	if result.Status != 0 {
-		return records, nil
+	res := &Result{
+		Rcode:              result.Status,
+		AuthenticatedData:  result.AD,
+		Truncated:          result.TC,
+		RecursionAvailable: result.RA,
+	}
+
+	if result.Status != dns.RcodeSuccess {
+		return res, nil
 	}
 
 	// ✅ Parse Answer section first
@@ -90,7 +125,19 @@ func (r *DoHResolver) queryJSON(domain string, qtype uint16) ([]models.DNSRecord
 		}
 	}
 
-	return records, nil
+	// Below the zone apex the SOA only shows up in Authority
+	if qtype == dns.TypeSOA && len(records) == 0 {
+		for _, auth := range result.Authority {
+			if auth.Type == int(dns.TypeSOA) {
+				if rec := parseDohRecord(auth, domain); rec != nil {
+					records = append(records, *rec)
+				}
+			}
+		}
+	}
+
+	res.Records = records
+	return res, nil
 }
 
 // ✅ Helper function to parse individual DoH record
@@ -129,6 +176,17 @@ func parseDohRecord(ans dohRecord, domain string) *models.DNSRecord {
 	case 16: // TXT
 		rec.Type = "TXT"
 		rec.Value = strings.Trim(ans.Data, "\"")
+
+		rec.TXTStrings = []string{rec.Value}
+
+		// Quoted data is the presentation format: "part 1" "part 2".
+		// Unquoted data is one string, spaces included.
+		if strings.HasPrefix(ans.Data, `"`) {
+			if rr, err := dns.NewRR(". 0 IN TXT " + ans.Data); err == nil && rr != nil {
+				rec.TXTStrings = rr.(*dns.TXT).Txt
+				rec.Value = strings.Join(rec.TXTStrings, " ")
+			}
+		}
 		return &rec
 
 	case 15: // MX
@@ -143,124 +201,161 @@ func parseDohRecord(ans dohRecord, domain string) *models.DNSRecord {
 		return nil
 
 	default:
-		return nil
+		// SOA, CAA, SRV, TLSA, HTTPS, ... come in presentation format
+		rr, err := rrFromPresentation(ans.Name, uint16(ans.Type), ans.TTL, ans.Data)
+		if err != nil {
+			return nil
+		}
+		return parseRR(rr, domain)
 	}
 }
 
-func (r *DoHResolver) queryRFC8484(domain string, qtype uint16) ([]models.DNSRecord, error) {
-	var records []models.DNSRecord
-
+func (r *DoHResolver) queryRFC8484(domain string, qtype uint16) (*Result, error) {
 	m := new(dns.Msg)
 	m.SetQuestion(dns.Fqdn(domain), qtype)
+	m.SetEdns0(4096, true)
+	m.AuthenticatedData = true // resolvers only set AD when asked (RFC 6840 5.7)
+
+	msg, err := r.exchangeRFC8484(m)
+	if err != nil {
+		return nil, err
+	}
+
+	// ✅ Parse Answer section (RCODE / flags included)
+	res := resultFromMsg(msg, domain)
+	if res.Rcode != dns.RcodeSuccess {
+		return res, nil
+	}
+
+	// ✅ FIX: Parse Authority section for NS records when Answer is empty
+	if qtype == dns.TypeNS && len(res.Records) == 0 {
+		for _, auth := range msg.Ns {
+			if nsRec, ok := auth.(*dns.NS); ok {
+				res.Records = append(res.Records, models.DNSRecord{
+					Type:       "NS",
+					Domain:     domain,
+					Nameserver: strings.TrimSuffix(nsRec.Ns, "."),
+					TTL:        nsRec.Hdr.Ttl,
+				})
+			}
+		}
+	}
+
+	// Below the zone apex the SOA only shows up in Authority
+	if qtype == dns.TypeSOA && len(res.Records) == 0 {
+		for _, auth := range msg.Ns {
+			if soa, ok := auth.(*dns.SOA); ok {
+				res.Records = append(res.Records, *parseRR(soa, domain))
+			}
+		}
+	}
+
+	return res, nil
+}
 
+// Exchange sends msg to the endpoint: RFC 8484 wire format, or for JSON
+// endpoints a JSON query rebuilt into a message.
+func (r *DoHResolver) Exchange(msg *dns.Msg) (*dns.Msg, error) {
+	if r.SupportsJSON {
+		return r.exchangeJSON(msg)
+	}
+	return r.exchangeRFC8484(msg)
+}
+
+func (r *DoHResolver) exchangeRFC8484(m *dns.Msg) (*dns.Msg, error) {
 	payload, err := m.Pack()
 	if err != nil {
-		return records, err
+		return nil, err
 	}
 
 	req, err := http.NewRequest("POST", r.Endpoint, strings.NewReader(string(payload)))
 	if err != nil {
-		return records, err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/dns-message")
 	req.Header.Set("Accept", "application/dns-message")
 
-	client := &http.Client{Timeout: r.Timeout}
-	resp, err := client.Do(req)
+	resp, err := r.httpClient().Do(req)
 	if err != nil {
-		return records, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH %s: HTTP %d", r.Endpoint, resp.StatusCode)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return records, err
+		return nil, err
 	}
 
 	msg := new(dns.Msg)
 	if err := msg.Unpack(body); err != nil {
-		return records, err
+		return nil, err
 	}
 
-	// ✅ Parse Answer section
-	for _, ans := range msg.Answer {
-		if rec := parseRFC8484Record(ans, domain); rec != nil {
-			records = append(records, *rec)
-		}
+	return msg, nil
+}
+
+// exchangeJSON asks the JSON API with the DO / CD bits of m. Records come
+// back in presentation format, RRSIG and NSEC / NSEC3 included.
+func (r *DoHResolver) exchangeJSON(m *dns.Msg) (*dns.Msg, error) {
+	if len(m.Question) != 1 {
+		return nil, fmt.Errorf("DoH %s: one question per query", r.Endpoint)
 	}
+	question := m.Question[0]
 
-	// ✅ FIX: Parse Authority section for NS records when Answer is empty
-	if qtype == dns.TypeNS && len(records) == 0 {
-		for _, auth := range msg.Ns {
-			if nsRec, ok := auth.(*dns.NS); ok {
-				records = append(records, models.DNSRecord{
-					Type:       "NS",
-					Domain:     domain,
-					Nameserver: strings.TrimSuffix(nsRec.Ns, "."),
-					TTL:        nsRec.Hdr.Ttl,
-				})
-			}
-		}
+	req, err := http.NewRequest("GET", r.Endpoint, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	return records, nil
-}
+	q := req.URL.Query()
+	q.Set("name", question.Name)
+	q.Set("type", fmt.Sprintf("%d", question.Qtype))
+	if opt := m.IsEdns0(); opt != nil && opt.Do() {
+		q.Set("do", "1")
+	}
+	if m.CheckingDisabled {
+		q.Set("cd", "1")
+	}
+	req.URL.RawQuery = q.Encode()
+	req.Header.Set("Accept", "application/dns-json")
 
-// ✅ Helper function to parse RFC8484 records
-func parseRFC8484Record(ans dns.RR, domain string) *models.DNSRecord {
-	switch rr := ans.(type) {
-	case *dns.A:
-		return &models.DNSRecord{
-			Type:    "A",
-			Domain:  domain,
-			Address: rr.A.String(),
-			TTL:     rr.Hdr.Ttl,
-		}
-	case *dns.AAAA:
-		return &models.DNSRecord{
-			Type:    "AAAA",
-			Domain:  domain,
-			Address: rr.AAAA.String(),
-			TTL:     rr.Hdr.Ttl,
-		}
-	case *dns.CNAME:
-		return &models.DNSRecord{
-			Type:   "CNAME",
-			Domain: domain,
-			Value:  strings.TrimSuffix(rr.Target, "."),
-			TTL:    rr.Hdr.Ttl,
-		}
-	case *dns.MX:
-		return &models.DNSRecord{
-			Type:     "MX",
-			Domain:   domain,
-			Exchange: strings.TrimSuffix(rr.Mx, "."),
-			Priority: rr.Preference,
-			TTL:      rr.Hdr.Ttl,
-		}
-	case *dns.NS:
-		return &models.DNSRecord{
-			Type:       "NS",
-			Domain:     domain,
-			Nameserver: strings.TrimSuffix(rr.Ns, "."),
-			TTL:        rr.Hdr.Ttl,
-		}
-	case *dns.TXT:
-		return &models.DNSRecord{
-			Type:   "TXT",
-			Domain: domain,
-			Value:  strings.Join(rr.Txt, " "),
-			TTL:    rr.Hdr.Ttl,
+	resp, err := r.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH %s: HTTP %d", r.Endpoint, resp.StatusCode)
+	}
+
+	var result dohResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	msg := new(dns.Msg)
+	msg.SetReply(m)
+	msg.Rcode = result.Status
+	msg.Truncated = result.TC
+	msg.RecursionAvailable = result.RA
+	msg.AuthenticatedData = result.AD
+
+	for _, ans := range result.Answer {
+		if rr, err := rrFromPresentation(ans.Name, uint16(ans.Type), ans.TTL, ans.Data); err == nil {
+			msg.Answer = append(msg.Answer, rr)
 		}
-	case *dns.PTR:
-		return &models.DNSRecord{
-			Type:   "PTR",
-			Domain: domain,
-			Value:  strings.TrimSuffix(rr.Ptr, "."),
-			TTL:    rr.Hdr.Ttl,
+	}
+	for _, auth := range result.Authority {
+		if rr, err := rrFromPresentation(auth.Name, uint16(auth.Type), auth.TTL, auth.Data); err == nil {
+			msg.Ns = append(msg.Ns, rr)
 		}
-	default:
-		return nil
 	}
+
+	return msg, nil
 }
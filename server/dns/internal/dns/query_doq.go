@@ -0,0 +1,145 @@
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// doqALPN is the TLS application protocol of DNS-over-QUIC
+const doqALPN = "doq"
+
+// DOQ_NO_ERROR, closes the connection normally
+const doqNoError quic.ApplicationErrorCode = 0
+
+// Query performs a DNS-over-QUIC query on the server's connection,
+// dialing it again when the server closed it.
+func (r *DoQResolver) Query(domain string, qtype uint16) (*Result, error) {
+	resp, err := r.Exchange(newQueryMsg(domain, qtype, true))
+	if err != nil {
+		return nil, err
+	}
+
+	return resultFromMsg(resp, domain), nil
+}
+
+// Exchange sends msg on the server's connection.
+func (r *DoQResolver) Exchange(msg *dns.Msg) (*dns.Msg, error) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for {
+		conn, reused, err := r.connection(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := doqExchange(ctx, conn, msg)
+		if err == nil {
+			return resp, nil
+		}
+
+		// The connection is gone (idle timeout, server closed it): a reused
+		// one gets one more try on a new connection
+		if conn.Context().Err() == nil {
+			return nil, err
+		}
+		r.drop(conn)
+		if !reused || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+}
+
+// connection returns the open connection to the server, dialing one when
+// there is none.
+func (r *DoQResolver) connection(ctx context.Context) (*quic.Conn, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn != nil && r.conn.Context().Err() == nil {
+		return r.conn, true, nil
+	}
+
+	conn, err := quic.DialAddr(ctx, r.Server, &tls.Config{
+		ServerName: r.ServerName,
+		RootCAs:    r.RootCAs,
+		NextProtos: []string{doqALPN},
+		MinVersion: tls.VersionTLS13,
+	}, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	r.conn = conn
+	return conn, false, nil
+}
+
+func (r *DoQResolver) drop(conn *quic.Conn) {
+	r.mu.Lock()
+	if r.conn == conn {
+		r.conn = nil
+	}
+	r.mu.Unlock()
+
+	conn.CloseWithError(doqNoError, "")
+}
+
+// doqExchange sends msg on its own stream: 2-byte length prefix, ID 0
+// and the stream closed after the query (RFC 9250 4.2).
+func doqExchange(ctx context.Context, conn *quic.Conn, msg *dns.Msg) (*dns.Msg, error) {
+	msg.Id = 0
+
+	wire, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(deadline)
+	}
+
+	buf := make([]byte, 2+len(wire))
+	binary.BigEndian.PutUint16(buf, uint16(len(wire)))
+	copy(buf[2:], wire)
+
+	if _, err := stream.Write(buf); err != nil {
+		return nil, err
+	}
+	// FIN: no more queries on this stream
+	if err := stream.Close(); err != nil {
+		return nil, err
+	}
+
+	var size [2]byte
+	if _, err := io.ReadFull(stream, size[:]); err != nil {
+		return nil, fmt.Errorf("DoQ response: %w", err)
+	}
+
+	raw := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(stream, raw); err != nil {
+		return nil, fmt.Errorf("DoQ response: %w", err)
+	}
+
+	resp := new(dns.Msg)
+	if err := resp.Unpack(raw); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
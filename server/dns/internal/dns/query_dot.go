@@ -0,0 +1,40 @@
+package dns
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Query performs a single DNS-over-TLS query. The certificate must be
+// valid for ServerName.
+//
+// Each query opens its own connection on purpose: the lookups of a check
+// run in parallel, and a shared dns.Conn would answer them one at a time
+// (it doesn't pipeline, RFC 7766 6.2.1.1).
+func (r *DoTResolver) Query(domain string, qtype uint16) (*Result, error) {
+	return exchange(r.client(), r.Server, domain, qtype)
+}
+
+// Exchange sends msg over its own TLS connection.
+func (r *DoTResolver) Exchange(msg *dns.Msg) (*dns.Msg, error) {
+	return exchangeMsg(r.client(), r.Server, msg)
+}
+
+func (r *DoTResolver) client() *dns.Client {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &dns.Client{
+		Net:     "tcp-tls",
+		Timeout: timeout,
+		TLSConfig: &tls.Config{
+			ServerName: r.ServerName,
+			RootCAs:    r.RootCAs,
+			MinVersion: tls.VersionTLS12,
+		},
+	}
+}
@@ -0,0 +1,39 @@
+package dns
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// TCPResolver implements plain DNS queries over TCP (port 53).
+//
+// Use cases:
+//   - Large answers that don't fit in a UDP datagram
+//   - Networks that drop or rate-limit UDP/53
+type TCPResolver struct {
+	Server  string        // DNS server address (ip:port)
+	Timeout time.Duration // Query timeout
+}
+
+// Query performs a single TCP DNS query.
+func (r *TCPResolver) Query(domain string, qtype uint16) (*Result, error) {
+	return exchange(r.client(), r.Server, domain, qtype)
+}
+
+// Exchange sends msg over TCP.
+func (r *TCPResolver) Exchange(msg *dns.Msg) (*dns.Msg, error) {
+	return exchangeMsg(r.client(), r.Server, msg)
+}
+
+func (r *TCPResolver) client() *dns.Client {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &dns.Client{
+		Net:     "tcp",
+		Timeout: timeout,
+	}
+}
@@ -0,0 +1,256 @@
+package dns
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// selfSigned returns a server certificate for dns.test / 127.0.0.1 and a
+// pool that trusts it.
+func selfSigned(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "dns.test"},
+		DNSNames:              []string{"dns.test"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}, pool
+}
+
+// stubAnswer answers every A question with 192.0.2.53.
+func stubAnswer(t *testing.T, r *dns.Msg) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Answer = append(m.Answer, mustRR(t, r.Question[0].Name+" 60 IN A 192.0.2.53"))
+	return m
+}
+
+func serveStream(t *testing.T, ln net.Listener) string {
+	t.Helper()
+
+	srv := &dns.Server{
+		Listener: ln,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			w.WriteMsg(stubAnswer(t, r))
+		}),
+	}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+
+	return ln.Addr().String()
+}
+
+// serveDoQ is a minimal RFC 9250 server: one query per stream.
+func serveDoQ(t *testing.T, cert tls.Certificate) string {
+	t.Helper()
+
+	ln, err := quic.ListenAddr("127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{doqALPN},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept(context.Background())
+			if err != nil {
+				return
+			}
+
+			go func() {
+				for {
+					stream, err := conn.AcceptStream(context.Background())
+					if err != nil {
+						return
+					}
+
+					var size [2]byte
+					if _, err := io.ReadFull(stream, size[:]); err != nil {
+						return
+					}
+					raw := make([]byte, binary.BigEndian.Uint16(size[:]))
+					if _, err := io.ReadFull(stream, raw); err != nil {
+						return
+					}
+
+					q := new(dns.Msg)
+					if err := q.Unpack(raw); err != nil || q.Id != 0 {
+						// DOQ_PROTOCOL_ERROR
+						conn.CloseWithError(0x2, "bad query")
+						return
+					}
+
+					wire, _ := stubAnswer(t, q).Pack()
+					out := binary.BigEndian.AppendUint16(nil, uint16(len(wire)))
+					stream.Write(append(out, wire...))
+					stream.Close()
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestTransportResolvers(t *testing.T) {
+	cert, pool := selfSigned(t)
+
+	tcpLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsLn, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolvers := map[string]Resolver{
+		TransportTCP: &TCPResolver{Server: serveStream(t, tcpLn), Timeout: 2 * time.Second},
+		TransportDoT: &DoTResolver{Server: serveStream(t, tlsLn), ServerName: "dns.test", RootCAs: pool, Timeout: 2 * time.Second},
+		TransportDoQ: &DoQResolver{Server: serveDoQ(t, cert), ServerName: "dns.test", RootCAs: pool, Timeout: 2 * time.Second},
+	}
+
+	for transport, r := range resolvers {
+		t.Run(transport, func(t *testing.T) {
+			rm := &ResolverManager{TCP: resolvers[TransportTCP], DoT: resolvers[TransportDoT], DoQ: resolvers[TransportDoQ]}
+
+			res, err := rm.Resolve("example.test.", dns.TypeA, transport)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Records) != 1 || res.Records[0].Address != "192.0.2.53" {
+				t.Fatalf("unexpected records %+v", res.Records)
+			}
+			if res.Transport != transport || res.Rcode != dns.RcodeSuccess || res.Latency <= 0 {
+				t.Errorf("transport %s, rcode %d, latency %v", res.Transport, res.Rcode, res.Latency)
+			}
+
+			if _, err := r.Query("www.example.test.", dns.TypeA); err != nil {
+				t.Errorf("second query: %v", err)
+			}
+
+			// The DNSSEC validator sends its own messages
+			resp, err := r.(Exchanger).Exchange(newQueryMsg("example.test.", dns.TypeA, true))
+			if err != nil || len(resp.Answer) != 1 {
+				t.Errorf("exchange: %v %v", resp, err)
+			}
+		})
+	}
+}
+
+func TestTLSResolversVerifyServerName(t *testing.T) {
+	cert, pool := selfSigned(t)
+
+	tlsLn, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, r := range map[string]Resolver{
+		"dot":         &DoTResolver{Server: serveStream(t, tlsLn), ServerName: "other.test", RootCAs: pool, Timeout: 2 * time.Second},
+		"doq":         &DoQResolver{Server: serveDoQ(t, cert), ServerName: "other.test", RootCAs: pool, Timeout: 2 * time.Second},
+		"doq untrust": &DoQResolver{Server: serveDoQ(t, cert), ServerName: "dns.test", Timeout: 2 * time.Second},
+	} {
+		if _, err := r.Query("example.test.", dns.TypeA); err == nil {
+			t.Errorf("%s: expected a certificate error", name)
+		}
+	}
+}
+
+func TestResolverManagerFor(t *testing.T) {
+	cases := []struct {
+		server string
+		want   map[string]bool
+	}{
+		{"adguard", map[string]bool{TransportDoH: true, TransportUDP: true, TransportTCP: true, TransportDoT: true, TransportDoQ: true}},
+		{"google", map[string]bool{TransportDoH: true, TransportUDP: true, TransportTCP: true, TransportDoT: true}},
+		{"opendns", map[string]bool{TransportDoH: true, TransportUDP: true, TransportTCP: true}},
+		{"unknown", map[string]bool{}},
+	}
+
+	for _, c := range cases {
+		rm := ResolverManagerFor(c.server)
+
+		for _, transport := range Transports {
+			if got := rm.Supports(transport); got != c.want[transport] {
+				t.Errorf("%s %s: supported = %v", c.server, transport, got)
+			}
+		}
+
+		if !c.want[TransportDoQ] {
+			if _, err := rm.Resolve("example.test.", dns.TypeA, TransportDoQ); err == nil {
+				t.Errorf("%s: DoQ should not be configured", c.server)
+			}
+		}
+	}
+}
+
+func TestDoQReusesConnection(t *testing.T) {
+	cert, pool := selfSigned(t)
+	r := &DoQResolver{Server: serveDoQ(t, cert), ServerName: "dns.test", RootCAs: pool, Timeout: 2 * time.Second}
+
+	query := func() {
+		t.Helper()
+		if res, err := r.Query("example.test.", dns.TypeA); err != nil || len(res.Records) != 1 {
+			t.Fatalf("records %v, err %v", res, err)
+		}
+	}
+
+	query()
+	first := r.conn
+	query()
+	if r.conn != first {
+		t.Error("second query opened a new connection")
+	}
+
+	// Closed by the server: dialed again
+	first.CloseWithError(doqNoError, "")
+	<-first.Context().Done()
+	query()
+	if r.conn == first {
+		t.Error("closed connection reused")
+	}
+}
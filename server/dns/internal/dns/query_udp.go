@@ -2,11 +2,9 @@ package dns
 
 import (
 	"context"
-	"strings"
 	"time"
 
 	"github.com/miekg/dns"
-	"tools.bctechvibe.io.vn/server/internal/models"
 )
 
 // UDPResolver implements raw DNS queries over UDP.
@@ -26,9 +24,28 @@ type UDPResolver struct {
 }
 
 // Query performs a single UDP DNS query.
-func (r *UDPResolver) Query(domain string, qtype uint16) ([]models.DNSRecord, error) {
-	var records []models.DNSRecord
+func (r *UDPResolver) Query(domain string, qtype uint16) (*Result, error) {
+	// Build DNS message
+	msg := new(dns.Msg)
+	msg.SetQuestion(domain, qtype)
+	msg.RecursionDesired = true
+
+	// Enable EDNS0 only if allowed
+	if !r.NoEDNS0 {
+		msg.SetEdns0(4096, true)
+	}
 
+	resp, err := r.Exchange(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse answers (RCODE / flags kept for the caller)
+	return resultFromMsg(resp, domain), nil
+}
+
+// Exchange sends msg over UDP.
+func (r *UDPResolver) Exchange(msg *dns.Msg) (*dns.Msg, error) {
 	// Default timeout
 	timeout := r.Timeout
 	if timeout <= 0 {
@@ -41,16 +58,6 @@ func (r *UDPResolver) Query(domain string, qtype uint16) ([]models.DNSRecord, er
 		Timeout: timeout,
 	}
 
-	// Build DNS message
-	msg := new(dns.Msg)
-	msg.SetQuestion(domain, qtype)
-	msg.RecursionDesired = true
-
-	// Enable EDNS0 only if allowed
-	if !r.NoEDNS0 {
-		msg.SetEdns0(4096, true)
-	}
-
 	// Hard timeout using context (CRITICAL for RBL)
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -58,57 +65,17 @@ func (r *UDPResolver) Query(domain string, qtype uint16) ([]models.DNSRecord, er
 	// Execute query
 	resp, _, err := client.ExchangeContext(ctx, msg, r.Server)
 	if err != nil {
-		return records, err
+		return nil, err
 	}
 
-	if resp == nil || resp.Rcode != dns.RcodeSuccess {
-		return records, nil
-	}
-
-	// Parse answers
-	for _, ans := range resp.Answer {
-		rec := models.DNSRecord{
-			Domain: domain,
-			TTL:    ans.Header().Ttl,
-		}
-
-		switch rr := ans.(type) {
-
-		case *dns.A:
-			rec.Type = "A"
-			rec.Address = rr.A.String()
-
-		case *dns.AAAA:
-			rec.Type = "AAAA"
-			rec.Address = rr.AAAA.String()
-
-		case *dns.CNAME:
-			rec.Type = "CNAME"
-			rec.Value = strings.TrimSuffix(rr.Target, ".")
-
-		case *dns.MX:
-			rec.Type = "MX"
-			rec.Priority = rr.Preference
-			rec.Exchange = strings.TrimSuffix(rr.Mx, ".")
-
-		case *dns.NS:
-			rec.Type = "NS"
-			rec.Nameserver = strings.TrimSuffix(rr.Ns, ".")
-
-		case *dns.TXT:
-			rec.Type = "TXT"
-			rec.Value = strings.Join(rr.Txt, " ")
-
-		case *dns.PTR:
-			rec.Type = "PTR"
-			rec.Value = strings.TrimSuffix(rr.Ptr, ".")
-
-		default:
-			continue
+	// Too large for a datagram: ask again over TCP (RFC 7766 5). If that
+	// fails too, the truncated answer is still better than nothing.
+	if resp.Truncated {
+		tcp := &TCPResolver{Server: r.Server, Timeout: timeout}
+		if full, err := tcp.Exchange(msg); err == nil {
+			return full, nil
 		}
-
-		records = append(records, rec)
 	}
 
-	return records, nil
+	return resp, nil
 }
@@ -0,0 +1,424 @@
+/*
+File: server/internal/dns/rbl_registry.go
+Description: Blocklist provider registry - loaded from a YAML / JSON file
+(DNS_RBL_PROVIDERS) and reloaded when it changes, each list probed on its
+test entries and disabled while it is dead or lists everything.
+*/
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/miekg/dns"
+	"tools.bctechvibe.io.vn/server/internal/models"
+)
+
+const (
+	RBLTypeIP     = "ip"
+	RBLTypeDomain = "domain"
+)
+
+// Probe states of a provider
+const (
+	RBLStateUnknown    = "unknown"
+	RBLStateOK         = "ok"
+	RBLStateBlocked    = "blocked"     // refuses our resolver, still queried
+	RBLStateDead       = "dead"        // no answer or test entry missing
+	RBLStateListingAll = "listing_all" // lists the entries it must not list
+	RBLStateDisabled   = "disabled"    // disabled in the file
+)
+
+const (
+	rblReloadInterval = 30 * time.Second
+	rblProbeInterval  = 30 * time.Minute
+
+	// Consecutive dead probes before a list is disabled
+	rblMaxProbeFailures = 3
+)
+
+// Test entries of RFC 5782 5: lists have the first, never the second
+const (
+	rblTestIP         = "127.0.0.2"
+	rblNegativeIP     = "127.0.0.1"
+	rblTestDomain     = "test"
+	rblNegativeDomain = "invalid"
+)
+
+// DefaultRBLRegistry holds the providers of the blacklist checks.
+// DNS_RBL_PROVIDERS is the path of a YAML / JSON list replacing the
+// built-in one.
+var DefaultRBLRegistry = NewRBLRegistry(os.Getenv("DNS_RBL_PROVIDERS"))
+
+// rblFile is the registry file: {"providers": [...]}
+type rblFile struct {
+	Providers []models.RBLProvider `json:"providers" yaml:"providers"`
+}
+
+// RBLRegistry is the list of providers with their probe state.
+type RBLRegistry struct {
+	Path string // YAML / JSON file, the built-in list when empty
+
+	mu        sync.RWMutex
+	providers []models.RBLProvider
+	health    map[string]*models.RBLHealth // by host
+	modTime   time.Time
+
+	query func(qname, provider string, qtype uint16) ([]dns.RR, error)
+	now   func() time.Time
+}
+
+func NewRBLRegistry(path string) *RBLRegistry {
+	g := &RBLRegistry{
+		Path:   path,
+		health: map[string]*models.RBLHealth{},
+		query:  DefaultRBLResolver.Query,
+		now:    time.Now,
+	}
+
+	g.set(defaultRBLProviders())
+	if path != "" {
+		if err := g.Load(); err != nil {
+			log.Printf("DNS_RBL_PROVIDERS: %v, using the built-in list", err)
+		}
+	}
+
+	return g
+}
+
+func defaultRBLProviders() []models.RBLProvider {
+	var out []models.RBLProvider
+	for _, p := range RBLProviders {
+		p.Type = RBLTypeIP
+		out = append(out, p)
+	}
+	for _, p := range DomainRBLProviders {
+		p.Type = RBLTypeDomain
+		out = append(out, p)
+	}
+	for i := range out {
+		normalizeRBLProvider(&out[i])
+	}
+	return out
+}
+
+// levelWeight is the score weight of a provider without one.
+func levelWeight(level string) float64 {
+	switch level {
+	case "High":
+		return 3
+	case "Low":
+		return 1
+	default:
+		return 2
+	}
+}
+
+func normalizeRBLProvider(p *models.RBLProvider) {
+	p.Host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(p.Host), "."))
+	p.Type = strings.ToLower(p.Type)
+	if p.Type == "" {
+		p.Type = RBLTypeIP
+	}
+	if p.Level == "" {
+		p.Level = "Medium"
+	}
+	if p.Weight == 0 {
+		p.Weight = levelWeight(p.Level)
+	}
+}
+
+// ============================================
+// LOAD / RELOAD
+// ============================================
+
+// Load reads the registry file. On error the current list stays.
+func (g *RBLRegistry) Load() error {
+	info, err := os.Stat(g.Path)
+	if err != nil {
+		return err
+	}
+	providers, err := readRBLFile(g.Path)
+	if err != nil {
+		return err
+	}
+
+	g.set(providers)
+
+	g.mu.Lock()
+	g.modTime = info.ModTime()
+	g.mu.Unlock()
+
+	return nil
+}
+
+// Reload loads the file again when it changed since the last load.
+func (g *RBLRegistry) Reload() (bool, error) {
+	if g.Path == "" {
+		return false, nil
+	}
+
+	info, err := os.Stat(g.Path)
+	if err != nil {
+		return false, err
+	}
+
+	g.mu.RLock()
+	unchanged := info.ModTime().Equal(g.modTime)
+	g.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	return true, g.Load()
+}
+
+func readRBLFile(path string) ([]models.RBLProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file rblFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if len(file.Providers) == 0 {
+		return nil, fmt.Errorf("%s: no providers", path)
+	}
+
+	seen := map[string]bool{}
+	for i := range file.Providers {
+		p := &file.Providers[i]
+		normalizeRBLProvider(p)
+
+		switch {
+		case p.Host == "" || !strings.Contains(p.Host, "."):
+			return nil, fmt.Errorf("%s: provider %d: invalid host %q", path, i+1, p.Host)
+		case p.Type != RBLTypeIP && p.Type != RBLTypeDomain:
+			return nil, fmt.Errorf("%s: %s: type must be ip or domain", path, p.Host)
+		case !slices.Contains([]string{"High", "Medium", "Low"}, p.Level):
+			return nil, fmt.Errorf("%s: %s: level must be High, Medium or Low", path, p.Host)
+		case p.Weight < 0:
+			return nil, fmt.Errorf("%s: %s: negative weight", path, p.Host)
+		case seen[p.Host]:
+			return nil, fmt.Errorf("%s: %s listed twice", path, p.Host)
+		}
+		seen[p.Host] = true
+	}
+
+	return file.Providers, nil
+}
+
+// set replaces the providers, keeping the probe state of the hosts that
+// stay.
+func (g *RBLRegistry) set(providers []models.RBLProvider) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	health := map[string]*models.RBLHealth{}
+	for _, p := range providers {
+		h := g.health[p.Host]
+		if h == nil {
+			h = &models.RBLHealth{Host: p.Host, Enabled: true, State: RBLStateUnknown}
+		}
+		h.Type, h.Level = p.Type, p.Level
+
+		switch {
+		case p.Disabled:
+			h.Enabled, h.State = false, RBLStateDisabled
+		case h.State == RBLStateDisabled:
+			h.Enabled, h.State = true, RBLStateUnknown
+		}
+		health[p.Host] = h
+	}
+
+	g.providers, g.health = providers, health
+}
+
+// ============================================
+// PROVIDERS
+// ============================================
+
+// Providers returns the enabled providers of a type (RBLTypeIP /
+// RBLTypeDomain).
+func (g *RBLRegistry) Providers(kind string) []models.RBLProvider {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var out []models.RBLProvider
+	for _, p := range g.providers {
+		if p.Type == kind && g.health[p.Host].Enabled {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Health returns the state of every provider, in file order.
+func (g *RBLRegistry) Health() []models.RBLHealth {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	out := make([]models.RBLHealth, 0, len(g.providers))
+	for _, p := range g.providers {
+		out = append(out, *g.health[p.Host])
+	}
+	return out
+}
+
+// ============================================
+// PROBE
+// ============================================
+
+// Start reloads the file and probes the providers in the background
+// until ctx is done.
+func (g *RBLRegistry) Start(ctx context.Context) {
+	go func() {
+		g.Probe()
+
+		reload := time.NewTicker(rblReloadInterval)
+		probe := time.NewTicker(rblProbeInterval)
+		defer reload.Stop()
+		defer probe.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reload.C:
+				changed, err := g.Reload()
+				if err != nil {
+					log.Printf("DNS_RBL_PROVIDERS: reload: %v", err)
+				} else if changed {
+					log.Printf("DNS_RBL_PROVIDERS: reloaded %s", g.Path)
+					g.Probe()
+				}
+			case <-probe.C:
+				g.Probe()
+			}
+		}
+	}()
+}
+
+// Probe checks every provider not disabled in the file on its test
+// entries.
+func (g *RBLRegistry) Probe() {
+	g.mu.RLock()
+	providers := slices.Clone(g.providers)
+	g.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, rblMaxConcurrency)
+
+	for _, p := range providers {
+		if p.Disabled {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			state, err := g.probe(p)
+			g.record(p.Host, state, err)
+		}()
+	}
+
+	wg.Wait()
+}
+
+// probe asks p for its test entry, which must be listed, and for the
+// entry no list may have, which must not.
+func (g *RBLRegistry) probe(p models.RBLProvider) (string, error) {
+	listed, negative := rblTestIP, rblNegativeIP
+	if p.Type == RBLTypeDomain {
+		listed, negative = rblTestDomain, rblNegativeDomain
+	}
+	if p.Test != "" {
+		listed = p.Test
+	}
+
+	res, err := g.ask(p, listed)
+	switch {
+	case err != nil:
+		return RBLStateDead, err
+	case res.status == "ERROR_BLOCKED":
+		return RBLStateBlocked, errors.New("the list refuses our resolver")
+	case res.status != "LISTED":
+		return RBLStateDead, fmt.Errorf("test entry %s not listed", listed)
+	}
+
+	if res, err := g.ask(p, negative); err == nil && res.status == "LISTED" {
+		return RBLStateListingAll, fmt.Errorf("%s is listed, the list answers listed for everything", negative)
+	}
+
+	return RBLStateOK, nil
+}
+
+func (g *RBLRegistry) ask(p models.RBLProvider, entry string) (rblResult, error) {
+	qname := entry + "." + p.Host
+	if p.Type == RBLTypeIP {
+		qname = ReverseIP(entry) + "." + p.Host
+	}
+
+	answers, err := g.query(qname, p.Host, dns.TypeA)
+	if err != nil {
+		return rblResult{}, err
+	}
+	return decodeRBL(p, answers), nil
+}
+
+// record stores a probe result. A list listing everything is disabled at
+// once, a dead one after rblMaxProbeFailures probes in a row; both come
+// back with the next good probe.
+func (g *RBLRegistry) record(host, state string, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	h := g.health[host]
+	if h == nil || h.State == RBLStateDisabled {
+		return // removed or disabled by a reload meanwhile
+	}
+
+	h.State, h.Error, h.CheckedAt = state, "", g.now()
+	if err != nil {
+		h.Error = err.Error()
+	}
+
+	switch state {
+	case RBLStateDead:
+		h.Failures++
+		if h.Failures >= rblMaxProbeFailures && h.Enabled {
+			h.Enabled = false
+			log.Printf("RBL %s disabled: %s", host, h.Error)
+		}
+	case RBLStateListingAll:
+		h.Failures++
+		if h.Enabled {
+			h.Enabled = false
+			log.Printf("RBL %s disabled: %s", host, h.Error)
+		}
+	default:
+		h.Failures = 0
+		h.Enabled = true
+	}
+}
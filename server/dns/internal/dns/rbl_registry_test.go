@@ -0,0 +1,235 @@
+package dns
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const rblTestYAML = `providers:
+  - host: zen.example.test
+    level: High
+    ipv6: true
+    removal: https://example.test/removal
+    codes:
+      "127.0.0.2": {list: SBL, meaning: Spam source}
+      "127.255.255.254": {meaning: Public resolver, error: true}
+  - host: old.example.test
+    level: Low
+    disabled: true
+  - host: dbl.example.test
+    type: domain
+    weight: 5
+    test: dbltest.com
+`
+
+func writeRBLFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRBLRegistryLoad(t *testing.T) {
+	g := NewRBLRegistry(writeRBLFile(t, "rbl.yaml", rblTestYAML))
+
+	ip := g.Providers(RBLTypeIP)
+	if len(ip) != 1 || ip[0].Host != "zen.example.test" || !ip[0].IPv6 || ip[0].Weight != 3 {
+		t.Fatalf("IP providers %+v", ip)
+	}
+	if c := ip[0].Codes["127.0.0.2"]; c.List != "SBL" || !ip[0].Codes["127.255.255.254"].Error {
+		t.Errorf("codes %+v", ip[0].Codes)
+	}
+
+	domain := g.Providers(RBLTypeDomain)
+	if len(domain) != 1 || domain[0].Weight != 5 || domain[0].Level != "Medium" || domain[0].Test != "dbltest.com" {
+		t.Errorf("domain providers %+v", domain)
+	}
+
+	health := g.Health()
+	if len(health) != 3 || health[1].Enabled || health[1].State != RBLStateDisabled || health[0].State != RBLStateUnknown {
+		t.Errorf("health %+v", health)
+	}
+}
+
+func TestRBLRegistryJSON(t *testing.T) {
+	path := writeRBLFile(t, "rbl.json", `{"providers": [{"host": "bl.example.test", "level": "Low", "bitmask": true}]}`)
+
+	ip := NewRBLRegistry(path).Providers(RBLTypeIP)
+	if len(ip) != 1 || ip[0].Host != "bl.example.test" || !ip[0].Bitmask || ip[0].Weight != 1 {
+		t.Errorf("providers %+v", ip)
+	}
+}
+
+func TestRBLRegistryInvalidFile(t *testing.T) {
+	tests := map[string]string{
+		"no providers": "providers: []\n",
+		"bad type":     "providers:\n  - host: a.example.test\n    type: url\n",
+		"bad level":    "providers:\n  - host: a.example.test\n    level: Urgent\n",
+		"duplicate":    "providers:\n  - host: a.example.test\n  - host: A.example.test.\n",
+		"bad host":     "providers:\n  - host: localhost\n",
+		"not YAML":     "providers: [\n",
+	}
+
+	for name, content := range tests {
+		if _, err := readRBLFile(writeRBLFile(t, "rbl.yaml", content)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+
+	// The built-in list stays when the file can't be loaded
+	g := NewRBLRegistry(writeRBLFile(t, "rbl.yaml", tests["bad type"]))
+	if len(g.Providers(RBLTypeIP)) != len(RBLProviders) {
+		t.Errorf("%d IP providers", len(g.Providers(RBLTypeIP)))
+	}
+}
+
+func TestRBLRegistryReload(t *testing.T) {
+	path := writeRBLFile(t, "rbl.yaml", rblTestYAML)
+	g := NewRBLRegistry(path)
+
+	if changed, err := g.Reload(); changed || err != nil {
+		t.Errorf("unchanged file: changed %v, err %v", changed, err)
+	}
+
+	later := time.Now().Add(time.Minute)
+	update := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		later = later.Add(time.Minute)
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	update(strings.Replace(rblTestYAML, "zen.example.test", "new.example.test", 1))
+	if changed, err := g.Reload(); !changed || err != nil {
+		t.Fatalf("changed %v, err %v", changed, err)
+	}
+	if ip := g.Providers(RBLTypeIP); len(ip) != 1 || ip[0].Host != "new.example.test" {
+		t.Errorf("after reload %+v", ip)
+	}
+
+	// A broken edit keeps the last good list
+	update("providers: [\n")
+	if _, err := g.Reload(); err == nil {
+		t.Error("no error for a broken file")
+	}
+	if ip := g.Providers(RBLTypeIP); len(ip) != 1 || ip[0].Host != "new.example.test" {
+		t.Errorf("after broken reload %+v", ip)
+	}
+}
+
+func TestRBLRegistryProbe(t *testing.T) {
+	g := NewRBLRegistry(writeRBLFile(t, "rbl.yaml", `providers:
+  - host: ok.rbl.test
+  - host: all.rbl.test
+  - host: dead.rbl.test
+  - host: empty.rbl.test
+  - host: blocked.rbl.test
+  - host: dbl.rbl.test
+    type: domain
+    test: dbltest.com
+`))
+
+	dead := true
+	g.query = func(qname, provider string, qtype uint16) ([]dns.RR, error) {
+		answer := func(ip string) []dns.RR { return []dns.RR{&dns.A{A: net.ParseIP(ip)}} }
+
+		switch provider {
+		case "ok.rbl.test":
+			if qname == "2.0.0.127.ok.rbl.test" {
+				return answer("127.0.0.2"), nil
+			}
+		case "all.rbl.test":
+			return answer("127.0.0.2"), nil
+		case "dead.rbl.test":
+			if dead {
+				return nil, errors.New("all NS failed")
+			}
+			if qname == "2.0.0.127.dead.rbl.test" {
+				return answer("127.0.0.2"), nil
+			}
+		case "blocked.rbl.test":
+			return answer("127.255.255.254"), nil
+		case "dbl.rbl.test":
+			if qname == "dbltest.com.dbl.rbl.test" {
+				return answer("127.0.1.2"), nil
+			}
+		}
+		return nil, nil // NXDOMAIN
+	}
+
+	state := func() map[string]string {
+		out := map[string]string{}
+		for _, h := range g.Health() {
+			out[h.Host] = h.State
+			if !h.Enabled {
+				out[h.Host] += " (off)"
+			}
+		}
+		return out
+	}
+
+	g.Probe()
+	want := map[string]string{
+		"ok.rbl.test":      RBLStateOK,
+		"all.rbl.test":     RBLStateListingAll + " (off)",
+		"dead.rbl.test":    RBLStateDead,
+		"empty.rbl.test":   RBLStateDead,
+		"blocked.rbl.test": RBLStateBlocked,
+		"dbl.rbl.test":     RBLStateOK,
+	}
+	for host, s := range state() {
+		if s != want[host] {
+			t.Errorf("first probe: %s %s, want %s", host, s, want[host])
+		}
+	}
+
+	g.Probe()
+	g.Probe()
+	if s := state(); s["dead.rbl.test"] != "dead (off)" || s["empty.rbl.test"] != "dead (off)" {
+		t.Errorf("after 3 probes %v", s)
+	}
+	if n := len(g.Providers(RBLTypeIP)); n != 2 {
+		t.Errorf("%d enabled IP providers, want ok and blocked", n)
+	}
+
+	// Back with the next good probe
+	dead = false
+	g.Probe()
+	if s := state(); s["dead.rbl.test"] != RBLStateOK {
+		t.Errorf("recovered list %s", s["dead.rbl.test"])
+	}
+}
+
+func TestDefaultRBLProviders(t *testing.T) {
+	for _, p := range defaultRBLProviders() {
+		if strings.Contains(p.Host, "woodys") || strings.Contains(p.Host, "habeas") {
+			t.Errorf("dead list %s", p.Host)
+		}
+		if p.Weight == 0 || (p.Type != RBLTypeIP && p.Type != RBLTypeDomain) {
+			t.Errorf("provider %+v", p)
+		}
+	}
+}
+
+func TestRBLExampleFile(t *testing.T) {
+	providers, err := readRBLFile("../../config/rbl_providers.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := providers[0]; p.Host != "zen.spamhaus.org" || p.Codes["127.0.0.10"].List != "PBL" || !p.Codes["127.255.255.254"].Error {
+		t.Errorf("first provider %+v", p)
+	}
+}
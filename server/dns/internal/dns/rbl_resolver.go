@@ -0,0 +1,260 @@
+/*
+File: server/internal/dns/rbl_resolver.go
+Description: DNSBL queries - straight to each list's nameservers, found
+through a shared pool of recursive resolvers and cached for their TTL, or
+through a local recursive resolver (DNS_RBL_RESOLVER).
+*/
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Cache bounds of a list's nameserver addresses
+const (
+	rblMinTTL      = time.Minute
+	rblMaxTTL      = 24 * time.Hour
+	rblNegativeTTL = 30 * time.Second // failed lookups, retried after
+
+	rblMaxNameservers = 4
+)
+
+// DefaultRBLResolver runs the blocklist queries. DNS_RBL_RESOLVER sends
+// them through a recursive resolver of ours ("ip[:port]") instead of the
+// lists' nameservers: many lists refuse public resolvers but answer a
+// registered / low-volume one.
+var DefaultRBLResolver = NewRBLResolver(rblLocalResolver(os.Getenv("DNS_RBL_RESOLVER")),
+	DNSServers["cloudflare"], DNSServers["google"], DNSServers["quad9"])
+
+func rblLocalResolver(env string) string {
+	addr := strings.TrimSpace(env)
+	if addr == "" {
+		return ""
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		log.Printf("DNS_RBL_RESOLVER: ignoring %q", env)
+		return ""
+	}
+	return addr
+}
+
+// resolverPool spreads lookups over a few recursive resolvers, round
+// robin, trying the next one when a resolver fails.
+type resolverPool struct {
+	servers []string
+	next    atomic.Uint32
+}
+
+func (p *resolverPool) exchange(client *dns.Client, msg *dns.Msg) (*dns.Msg, error) {
+	if len(p.servers) == 0 {
+		return nil, errors.New("no recursive resolver")
+	}
+
+	start := int(p.next.Add(1))
+	var err error
+	for i := range p.servers {
+		var resp *dns.Msg
+		resp, _, err = client.Exchange(msg, p.servers[(start+i)%len(p.servers)])
+		if err == nil && resp.Rcode != dns.RcodeServerFailure && resp.Rcode != dns.RcodeRefused {
+			return resp, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("%s", dns.RcodeToString[resp.Rcode])
+		}
+	}
+	return nil, err
+}
+
+// RBLResolver queries the blocklists.
+type RBLResolver struct {
+	Local   string // recursive resolver for the queries, the lists' nameservers when empty
+	Timeout time.Duration
+
+	pool *resolverPool // NS / address lookups
+
+	mu    sync.Mutex
+	zones map[string]*rblZone // by provider host
+
+	addr func(net.IP) string // nameserver address to dial, ip:53 outside tests
+	now  func() time.Time
+}
+
+// rblZone is the cached nameserver addresses of one list. ready is closed
+// once the lookup is done, so concurrent queries wait for one lookup.
+type rblZone struct {
+	ready   chan struct{}
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+func NewRBLResolver(local string, pool ...string) *RBLResolver {
+	return &RBLResolver{
+		Local:   local,
+		Timeout: rblTimeout,
+		pool:    &resolverPool{servers: pool},
+		zones:   map[string]*rblZone{},
+		addr:    func(ip net.IP) string { return net.JoinHostPort(ip.String(), "53") },
+		now:     time.Now,
+	}
+}
+
+// Query asks the list provider for qname.
+func (r *RBLResolver) Query(qname, provider string, qtype uint16) ([]dns.RR, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(qname), qtype)
+
+	if r.Local != "" {
+		resp, err := r.exchange(msg, r.Local)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Answer, nil
+	}
+
+	addrs, err := r.servers(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.RecursionDesired = false
+	var errs []error
+	for _, addr := range addrs {
+		resp, err := r.exchange(msg, addr)
+		if err == nil {
+			return resp.Answer, nil
+		}
+		errs = append(errs, err)
+	}
+
+	return nil, fmt.Errorf("all NS of %s failed: %w", provider, errors.Join(errs...))
+}
+
+// exchange sends msg to addr, over TCP when the answer is truncated. Only
+// NOERROR and NXDOMAIN are answers.
+func (r *RBLResolver) exchange(msg *dns.Msg, addr string) (*dns.Msg, error) {
+	client := &dns.Client{Timeout: r.Timeout}
+	resp, _, err := client.Exchange(msg, addr)
+	if err == nil && resp.Truncated {
+		client.Net = "tcp"
+		resp, _, err = client.Exchange(msg, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("%s: %s", addr, dns.RcodeToString[resp.Rcode])
+	}
+	return resp, nil
+}
+
+// servers returns the nameserver addresses of provider, from the cache
+// while their TTL lasts.
+func (r *RBLResolver) servers(provider string) ([]string, error) {
+	provider = dns.CanonicalName(provider)
+
+	r.mu.Lock()
+	z := r.zones[provider]
+	if z != nil {
+		select {
+		case <-z.ready:
+			if !r.now().Before(z.expires) {
+				z = nil
+			}
+		default: // lookup running
+		}
+	}
+	if z == nil {
+		z = &rblZone{ready: make(chan struct{})}
+		r.zones[provider] = z
+		r.mu.Unlock()
+
+		var ttl time.Duration
+		z.addrs, ttl, z.err = r.lookupServers(provider)
+		if z.err != nil {
+			ttl = rblNegativeTTL
+		}
+		z.expires = r.now().Add(ttl)
+		close(z.ready)
+
+		return z.addrs, z.err
+	}
+	r.mu.Unlock()
+
+	<-z.ready
+	return z.addrs, z.err
+}
+
+// lookupServers finds the nameservers of the zone holding provider (the
+// provider itself or a parent: http.dnsbl.sorbs.net is served by the
+// dnsbl.sorbs.net servers) and their IPv4 addresses.
+func (r *RBLResolver) lookupServers(provider string) ([]string, time.Duration, error) {
+	client := &dns.Client{Timeout: r.Timeout}
+
+	for zone := provider; dns.CountLabel(zone) >= 2; {
+		msg := new(dns.Msg)
+		msg.SetQuestion(zone, dns.TypeNS)
+
+		resp, err := r.pool.exchange(client, msg)
+		if err != nil {
+			return nil, 0, fmt.Errorf("NS %s: %w", trimDot(zone), err)
+		}
+		if resp.Rcode == dns.RcodeNameError {
+			return nil, 0, fmt.Errorf("%s does not exist", trimDot(zone))
+		}
+
+		var names []string
+		ttl := rblMaxTTL
+		for _, rr := range resp.Answer {
+			if ns, ok := rr.(*dns.NS); ok && dns.CanonicalName(ns.Hdr.Name) == zone {
+				names = append(names, ns.Ns)
+				ttl = min(ttl, time.Duration(ns.Hdr.Ttl)*time.Second)
+			}
+		}
+
+		if len(names) == 0 {
+			// Not a zone cut, the parent serves it
+			i, _ := dns.NextLabel(zone, 0)
+			zone = zone[i:]
+			continue
+		}
+
+		var addrs []string
+		for _, name := range names[:min(len(names), rblMaxNameservers)] {
+			// IPv4 only: the host may have no IPv6 route
+			msg := new(dns.Msg)
+			msg.SetQuestion(dns.CanonicalName(name), dns.TypeA)
+
+			resp, err := r.pool.exchange(client, msg)
+			if err != nil {
+				continue
+			}
+			for _, rr := range resp.Answer {
+				if a, ok := rr.(*dns.A); ok {
+					addrs = append(addrs, r.addr(a.A))
+					ttl = min(ttl, time.Duration(a.Hdr.Ttl)*time.Second)
+				}
+			}
+		}
+
+		if len(addrs) == 0 {
+			return nil, 0, fmt.Errorf("no address for the nameservers of %s", trimDot(zone))
+		}
+		return addrs, max(ttl, rblMinTTL), nil
+	}
+
+	return nil, 0, fmt.Errorf("no nameserver for %s", trimDot(provider))
+}
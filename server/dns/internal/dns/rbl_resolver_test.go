@@ -0,0 +1,181 @@
+package dns
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// rblStubs is a recursive resolver knowing the nameservers of rbl.test.
+// and its nameserver; ns1 doesn't answer, ns2 lists 127.0.0.2.
+func rblStubs(t *testing.T) (*RBLResolver, *atomic.Int32) {
+	t.Helper()
+
+	var lookups atomic.Int32
+	recursive := serveAuth(t, func(r *dns.Msg) *dns.Msg {
+		lookups.Add(1)
+
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		switch {
+		case q.Name == "rbl.test." && q.Qtype == dns.TypeNS:
+			m.Answer = append(m.Answer,
+				mustRR(t, "rbl.test. 300 IN NS ns1.rbl.test."),
+				mustRR(t, "rbl.test. 300 IN NS ns2.rbl.test."))
+		case q.Name == "ns1.rbl.test." && q.Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, mustRR(t, "ns1.rbl.test. 120 IN A 192.0.2.53"))
+		case q.Name == "ns2.rbl.test." && q.Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, mustRR(t, "ns2.rbl.test. 120 IN A 192.0.2.54"))
+		case dns.IsSubDomain("rbl.test.", q.Name):
+			// NODATA, not a zone cut
+		default:
+			m.Rcode = dns.RcodeNameError
+		}
+		return m
+	})
+
+	auth := serveAuth(t, func(r *dns.Msg) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if r.RecursionDesired {
+			m.Rcode = dns.RcodeRefused
+			return m
+		}
+		m.Authoritative = true
+		if r.Question[0].Name == "2.0.0.127.rbl.test." {
+			m.Answer = append(m.Answer, mustRR(t, "2.0.0.127.rbl.test. 60 IN A 127.0.0.2"))
+		} else {
+			m.Rcode = dns.RcodeNameError
+		}
+		return m
+	})
+
+	// ns1: nothing answers on this socket
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	stubs := map[string]string{"192.0.2.53": pc.LocalAddr().String(), "192.0.2.54": auth}
+
+	r := NewRBLResolver("", recursive)
+	r.Timeout = 200 * time.Millisecond
+	r.addr = func(ip net.IP) string { return stubs[ip.String()] }
+
+	return r, &lookups
+}
+
+func TestRBLResolverCache(t *testing.T) {
+	r, lookups := rblStubs(t)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	for range 2 {
+		answers, err := r.Query("2.0.0.127.rbl.test", "rbl.test", dns.TypeA)
+		if err != nil || len(answers) != 1 {
+			t.Fatalf("answers %v, err %v", answers, err)
+		}
+	}
+	// NS, then A of ns1 and ns2, once
+	if n := lookups.Load(); n != 3 {
+		t.Errorf("%d lookups, want 3", n)
+	}
+
+	answers, err := r.Query("3.0.0.127.rbl.test", "rbl.test", dns.TypeA)
+	if err != nil || len(answers) != 0 {
+		t.Errorf("NXDOMAIN: answers %v, err %v", answers, err)
+	}
+
+	// The address TTL (120s) bounds the entry
+	now = now.Add(2*time.Minute + time.Second)
+	if _, err := r.Query("2.0.0.127.rbl.test", "rbl.test", dns.TypeA); err != nil {
+		t.Fatal(err)
+	}
+	if n := lookups.Load(); n != 6 {
+		t.Errorf("%d lookups after expiry, want 6", n)
+	}
+}
+
+func TestRBLResolverParentZone(t *testing.T) {
+	r, lookups := rblStubs(t)
+
+	// sub.rbl.test has no NS of its own
+	if _, err := r.Query("2.0.0.127.rbl.test", "sub.rbl.test", dns.TypeA); err != nil {
+		t.Fatal(err)
+	}
+	if n := lookups.Load(); n != 4 {
+		t.Errorf("%d lookups, want 4", n)
+	}
+}
+
+func TestRBLResolverConcurrent(t *testing.T) {
+	r, lookups := rblStubs(t)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.Query("2.0.0.127.rbl.test", "rbl.test", dns.TypeA); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := lookups.Load(); n != 3 {
+		t.Errorf("%d lookups, want one NS lookup shared", n)
+	}
+}
+
+func TestRBLResolverNegative(t *testing.T) {
+	r, lookups := rblStubs(t)
+
+	for range 2 {
+		if _, err := r.Query("2.0.0.127.list.dead.example", "list.dead.example", dns.TypeA); err == nil {
+			t.Error("no error for a list that doesn't exist")
+		}
+	}
+	// NXDOMAIN: no walk up to dead.example, cached as failed
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("%d lookups, want 1", n)
+	}
+}
+
+func TestRBLResolverLocal(t *testing.T) {
+	local := serveAuth(t, func(r *dns.Msg) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.RecursionAvailable = true
+		if r.RecursionDesired {
+			m.Answer = append(m.Answer, mustRR(t, r.Question[0].Name+" 60 IN A 127.0.0.4"))
+		}
+		return m
+	})
+
+	r := NewRBLResolver(local) // no pool: NS lookups would fail
+	answers, err := r.Query("2.0.0.127.zen.spamhaus.org", "zen.spamhaus.org", dns.TypeA)
+	if err != nil || len(answers) != 1 {
+		t.Errorf("answers %v, err %v", answers, err)
+	}
+}
+
+func TestRBLLocalResolver(t *testing.T) {
+	tests := map[string]string{
+		"":               "",
+		"127.0.0.1":      "127.0.0.1:53",
+		"10.0.0.1:5353 ": "10.0.0.1:5353",
+		"::1":            "[::1]:53",
+	}
+	for in, want := range tests {
+		if got := rblLocalResolver(in); got != want {
+			t.Errorf("rblLocalResolver(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// ============================================
+// FILE: internal/dns/records.go
+// PURPOSE:
+//   - Convert miekg/dns RRs to models.DNSRecord
+//   - Shared by the RFC 8484, DoH JSON and UDP parsers
+//
+// ============================================
+package dns
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+	"tools.bctechvibe.io.vn/server/internal/models"
+)
+
+// parseRR converts one answer RR. Types we don't present return nil.
+func parseRR(ans dns.RR, domain string) *models.DNSRecord {
+	rec := models.DNSRecord{
+		Domain: domain,
+		TTL:    ans.Header().Ttl,
+	}
+
+	switch rr := ans.(type) {
+	case *dns.A:
+		rec.Type = "A"
+		rec.Address = rr.A.String()
+
+	case *dns.AAAA:
+		rec.Type = "AAAA"
+		rec.Address = rr.AAAA.String()
+
+	case *dns.CNAME:
+		rec.Type = "CNAME"
+		rec.Value = strings.TrimSuffix(rr.Target, ".")
+
+	case *dns.MX:
+		rec.Type = "MX"
+		rec.Priority = rr.Preference
+		rec.Exchange = strings.TrimSuffix(rr.Mx, ".")
+
+	case *dns.NS:
+		rec.Type = "NS"
+		rec.Nameserver = strings.TrimSuffix(rr.Ns, ".")
+
+	case *dns.TXT:
+		rec.Type = "TXT"
+		rec.Value = strings.Join(rr.Txt, " ")
+		rec.TXTStrings = rr.Txt
+
+	case *dns.PTR:
+		rec.Type = "PTR"
+		rec.Value = strings.TrimSuffix(rr.Ptr, ".")
+
+	default:
+		if !parseStructuredRR(ans, &rec) {
+			return nil
+		}
+
+		// Structured types also carry the presentation format for display
+		rec.Value = rdata(ans)
+	}
+
+	return &rec
+}
+
+// parseStructuredRR fills the typed sub-struct of rec.
+func parseStructuredRR(ans dns.RR, rec *models.DNSRecord) bool {
+	switch rr := ans.(type) {
+	case *dns.SOA:
+		rec.Type = "SOA"
+		rec.SOA = &models.SOAData{
+			PrimaryNS: strings.TrimSuffix(rr.Ns, "."),
+			Mailbox:   strings.TrimSuffix(rr.Mbox, "."),
+			Serial:    rr.Serial,
+			Refresh:   rr.Refresh,
+			Retry:     rr.Retry,
+			Expire:    rr.Expire,
+			Minimum:   rr.Minttl,
+		}
+
+	case *dns.CAA:
+		rec.Type = "CAA"
+		rec.CAA = &models.CAAData{
+			Flag:     rr.Flag,
+			Critical: rr.Flag&0x80 != 0,
+			Tag:      rr.Tag,
+			Value:    rr.Value,
+		}
+
+	case *dns.SRV:
+		rec.Type = "SRV"
+		rec.SRV = &models.SRVData{
+			Priority: rr.Priority,
+			Weight:   rr.Weight,
+			Port:     rr.Port,
+			Target:   strings.TrimSuffix(rr.Target, "."),
+		}
+
+	case *dns.TLSA:
+		rec.Type = "TLSA"
+		rec.TLSA = &models.TLSAData{
+			Usage:        rr.Usage,
+			Selector:     rr.Selector,
+			MatchingType: rr.MatchingType,
+			Certificate:  strings.ToLower(rr.Certificate),
+		}
+
+	case *dns.HTTPS:
+		rec.Type = "HTTPS"
+		rec.SVCB = svcbData(&rr.SVCB)
+
+	case *dns.SVCB:
+		rec.Type = "SVCB"
+		rec.SVCB = svcbData(rr)
+
+	case *dns.NAPTR:
+		rec.Type = "NAPTR"
+		rec.NAPTR = &models.NAPTRData{
+			Order:       rr.Order,
+			Preference:  rr.Preference,
+			Flags:       rr.Flags,
+			Service:     rr.Service,
+			Regexp:      rr.Regexp,
+			Replacement: strings.TrimSuffix(rr.Replacement, "."),
+		}
+
+	case *dns.DS:
+		rec.Type = "DS"
+		rec.DS = &models.DSData{
+			KeyTag:     rr.KeyTag,
+			Algorithm:  rr.Algorithm,
+			DigestType: rr.DigestType,
+			Digest:     strings.ToLower(rr.Digest),
+		}
+
+	case *dns.SSHFP:
+		rec.Type = "SSHFP"
+		rec.SSHFP = &models.SSHFPData{
+			Algorithm:   rr.Algorithm,
+			Type:        rr.Type,
+			Fingerprint: strings.ToLower(rr.FingerPrint),
+		}
+
+	default:
+		return false
+	}
+
+	return true
+}
+
+func svcbData(rr *dns.SVCB) *models.SVCBData {
+	out := &models.SVCBData{
+		Priority: rr.Priority,
+		Target:   rr.Target,
+	}
+
+	// "." means the owner name itself, keep it as is
+	if out.Target != "." {
+		out.Target = strings.TrimSuffix(out.Target, ".")
+	}
+
+	if len(rr.Value) > 0 {
+		out.Params = make(map[string]string, len(rr.Value))
+		for _, kv := range rr.Value {
+			out.Params[kv.Key().String()] = kv.String()
+		}
+	}
+
+	return out
+}
+
+// rdata is the RR in zone-file format without the owner / TTL / class.
+func rdata(rr dns.RR) string {
+	return strings.TrimSpace(strings.TrimPrefix(rr.String(), rr.Header().String()))
+}
+
+// rrFromPresentation rebuilds an RR from the DoH JSON "data" field, which
+// is the zone-file presentation of the rdata.
+func rrFromPresentation(name string, qtype uint16, ttl uint32, data string) (dns.RR, error) {
+	t, ok := dns.TypeToString[qtype]
+	if !ok {
+		return nil, fmt.Errorf("unknown record type %d", qtype)
+	}
+
+	return dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(name), ttl, t, data))
+}
@@ -0,0 +1,158 @@
+package dns
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+var testZone = []string{
+	"example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 2024010101 7200 3600 1209600 300",
+	`example.com. 300 IN CAA 128 issue "letsencrypt.org"`,
+	"_sip._tcp.example.com. 300 IN SRV 10 60 5060 sip.example.com.",
+	"_443._tcp.example.com. 300 IN TLSA 3 1 1 ABCDEF0123456789",
+	`example.com. 300 IN HTTPS 1 . alpn="h2,h3" port=443`,
+	`_dns.example.com. 300 IN SVCB 0 svc.example.net.`,
+	`example.com. 300 IN NAPTR 100 10 "S" "SIP+D2U" "" _sip._udp.example.com.`,
+	"example.com. 300 IN DS 12345 13 2 ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789",
+	"example.com. 300 IN SSHFP 4 2 ABCDEF0123456789",
+}
+
+func mustRR(t *testing.T, s string) dns.RR {
+	t.Helper()
+
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatalf("%s: %v", s, err)
+	}
+	return rr
+}
+
+func TestParseStructuredRecords(t *testing.T) {
+	recs := map[string]bool{}
+
+	for _, s := range testZone {
+		rec := parseRR(mustRR(t, s), "example.com")
+		if rec == nil {
+			t.Fatalf("not parsed: %s", s)
+		}
+		if rec.Value == "" || rec.TTL != 300 {
+			t.Errorf("%s: missing presentation value / TTL: %+v", rec.Type, rec)
+		}
+		recs[rec.Type] = true
+
+		switch rec.Type {
+		case "SOA":
+			if rec.SOA.Serial != 2024010101 || rec.SOA.Minimum != 300 || rec.SOA.Mailbox != "hostmaster.example.com" {
+				t.Errorf("SOA %+v", rec.SOA)
+			}
+		case "CAA":
+			if !rec.CAA.Critical || rec.CAA.Tag != "issue" || rec.CAA.Value != "letsencrypt.org" {
+				t.Errorf("CAA %+v", rec.CAA)
+			}
+		case "SRV":
+			if rec.SRV.Port != 5060 || rec.SRV.Weight != 60 || rec.SRV.Target != "sip.example.com" {
+				t.Errorf("SRV %+v", rec.SRV)
+			}
+		case "TLSA":
+			if rec.TLSA.Usage != 3 || rec.TLSA.Certificate != "abcdef0123456789" {
+				t.Errorf("TLSA %+v", rec.TLSA)
+			}
+		case "HTTPS":
+			if rec.SVCB.Priority != 1 || rec.SVCB.Target != "." || rec.SVCB.Params["alpn"] != "h2,h3" || rec.SVCB.Params["port"] != "443" {
+				t.Errorf("HTTPS %+v", rec.SVCB)
+			}
+		case "SVCB":
+			if rec.SVCB.Priority != 0 || rec.SVCB.Target != "svc.example.net" {
+				t.Errorf("SVCB %+v", rec.SVCB)
+			}
+		case "NAPTR":
+			if rec.NAPTR.Order != 100 || rec.NAPTR.Service != "SIP+D2U" || rec.NAPTR.Replacement != "_sip._udp.example.com" {
+				t.Errorf("NAPTR %+v", rec.NAPTR)
+			}
+		case "DS":
+			if rec.DS.KeyTag != 12345 || rec.DS.DigestType != 2 {
+				t.Errorf("DS %+v", rec.DS)
+			}
+		case "SSHFP":
+			if rec.SSHFP.Algorithm != 4 || rec.SSHFP.Fingerprint != "abcdef0123456789" {
+				t.Errorf("SSHFP %+v", rec.SSHFP)
+			}
+		}
+	}
+
+	if len(recs) != len(testZone) {
+		t.Errorf("parsed types %v", recs)
+	}
+}
+
+func TestParseDohJSONRecords(t *testing.T) {
+	cases := []dohRecord{
+		{Name: "example.com.", Type: int(dns.TypeCAA), TTL: 60, Data: `0 issue "pki.goog"`},
+		{Name: "example.com.", Type: int(dns.TypeSOA), TTL: 60, Data: "ns1.example.com. dns.example.com. 42 900 900 1800 60"},
+		{Name: "example.com.", Type: int(dns.TypeHTTPS), TTL: 60, Data: `1 . alpn=h2,h3`},
+	}
+
+	for _, c := range cases {
+		rec := parseDohRecord(c, "example.com")
+		if rec == nil {
+			t.Fatalf("type %d not parsed", c.Type)
+		}
+
+		switch {
+		case rec.CAA != nil && rec.CAA.Value != "pki.goog",
+			rec.SOA != nil && rec.SOA.Serial != 42,
+			rec.SVCB != nil && rec.SVCB.Params["alpn"] != "h2,h3":
+			t.Errorf("unexpected %+v", rec)
+		}
+	}
+
+	if rec := parseDohRecord(dohRecord{Type: int(dns.TypeCAA), Data: "garbage"}, "example.com"); rec != nil {
+		t.Errorf("malformed data should be dropped, got %+v", rec)
+	}
+
+	txt := map[string][]string{
+		`"v=spf1 include:_spf.goo" "gle.com ~all"`: {"v=spf1 include:_spf.goo", "gle.com ~all"},
+		`v=spf1 include:_spf.google.com ~all`:      {"v=spf1 include:_spf.google.com ~all"},
+	}
+	for data, want := range txt {
+		rec := parseDohRecord(dohRecord{Type: int(dns.TypeTXT), Data: data}, "example.com")
+		if rec == nil || !slices.Equal(rec.TXTStrings, want) {
+			t.Errorf("%s: TXT strings %+v", data, rec)
+		}
+	}
+}
+
+func TestUDPResolverStructuredRecords(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc("example.com.", func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = append(m.Answer, mustRR(t, testZone[0]), mustRR(t, testZone[1]))
+		w.WriteMsg(m)
+	})
+
+	srv := &dns.Server{PacketConn: pc, Handler: mux}
+	go srv.ActivateAndServe()
+	defer srv.Shutdown()
+
+	r := &UDPResolver{Server: pc.LocalAddr().String(), Timeout: 2 * time.Second}
+
+	res, err := r.Query("example.com.", dns.TypeSOA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := res.Records
+
+	if len(records) != 2 || records[0].SOA == nil || records[1].CAA == nil {
+		t.Fatalf("unexpected records %+v", records)
+	}
+}
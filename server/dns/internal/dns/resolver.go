@@ -3,21 +3,45 @@
 // PURPOSE:
 //   - Expose a single DNS resolver interface
 //   - Default resolver is DNS-over-HTTPS (DoH)
-//   - UDP resolver is used only when:
-//     1. User explicitly selects UDP
-//     2. (Optional) DoH fails → fallback to UDP
+//   - One long-lived manager per provider
+//   - When a transport fails → fallback chain of the
+//     same provider (DoH → DoT → UDP by default)
 //
 // ============================================
 package dns
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"tools.bctechvibe.io.vn/server/internal/models"
 
 	dnslib "github.com/miekg/dns"
 )
 
+// Transports a lookup can be sent over
+const (
+	TransportDoH = "doh" // DNS-over-HTTPS (RFC 8484 / JSON)
+	TransportUDP = "udp"
+	TransportTCP = "tcp"
+	TransportDoT = "dot" // DNS-over-TLS (RFC 7858)
+	TransportDoQ = "doq" // DNS-over-QUIC (RFC 9250)
+)
+
+// Transports lists the accepted transport names.
+var Transports = []string{TransportDoH, TransportUDP, TransportTCP, TransportDoT, TransportDoQ}
+
+// DefaultFallback is the fallback chain of new managers. DNS_FALLBACK
+// overrides it ("doh,dot,udp", "none" to disable).
+var DefaultFallback = parseFallback(os.Getenv("DNS_FALLBACK"))
+
 // ============================================
 // Resolver Interface
 // ============================================
@@ -32,7 +56,33 @@ type Resolver interface {
 	//
 	// domain MUST be a fully-qualified domain name (FQDN).
 	// qtype follows miekg/dns Type constants (TypeA, TypeAAAA, ...).
-	Query(domain string, qtype uint16) ([]models.DNSRecord, error)
+	//
+	// A DNS error (NXDOMAIN, SERVFAIL, ...) is a Result with that
+	// Rcode; the error is for transport failures (timeout, TLS, HTTP).
+	Query(domain string, qtype uint16) (*Result, error)
+}
+
+// Exchanger sends a prepared query and returns the whole response, for
+// callers that need more than the answer records (RRSIGs, NSEC / NSEC3
+// in Authority). Every transport resolver implements it.
+type Exchanger interface {
+	Exchange(msg *dnslib.Msg) (*dnslib.Msg, error)
+}
+
+// Result is one resolver answer: the records and what the server said
+// about them.
+type Result struct {
+	Records []models.DNSRecord
+
+	Rcode              int  // dnslib.RcodeSuccess, RcodeNameError, ...
+	AuthenticatedData  bool // AD
+	Truncated          bool // TC
+	RecursionAvailable bool // RA
+
+	// Set by ResolverManager
+	Transport   string
+	Latency     time.Duration
+	FallbackErr error // why the requested transport failed, when another one answered
 }
 
 // ============================================
@@ -44,10 +94,16 @@ type Resolver interface {
 // It decides:
 //   - Which resolver is default
 //   - When to fallback
-//   - How to extend future transports (TCP, DoT, DoQ, ...)
+//   - Which transports (TCP, DoT, DoQ, ...) a provider offers
 type ResolverManager struct {
 	Default Resolver
 	UDP     Resolver
+	TCP     Resolver
+	DoT     Resolver
+	DoQ     Resolver
+
+	// Transports tried in order after the requested one fails
+	Fallback []string
 }
 
 // NewResolverManager creates a resolver manager.
@@ -61,6 +117,77 @@ func NewResolverManager(doh Resolver, udp Resolver) *ResolverManager {
 	}
 }
 
+// Managers of the registered providers, built once and reused so
+// connections stay pooled between queries
+var (
+	managersMu sync.Mutex
+	managers   = map[string]*ResolverManager{}
+)
+
+// ResolverManagerFor returns the long-lived manager of one provider key,
+// with every transport the provider is registered for.
+func ResolverManagerFor(server string) *ResolverManager {
+	managersMu.Lock()
+	defer managersMu.Unlock()
+
+	if rm, ok := managers[server]; ok {
+		return rm
+	}
+
+	rm := newProviderManager(server)
+
+	// Unknown keys come from user input, don't keep them
+	if rm.Default != nil || rm.UDP != nil || rm.DoT != nil || rm.DoQ != nil {
+		managers[server] = rm
+	}
+
+	return rm
+}
+
+func newProviderManager(server string) *ResolverManager {
+	rm := &ResolverManager{Fallback: DefaultFallback}
+
+	if doh, ok := DoHServers[server]; ok {
+		rm.Default = doh
+	}
+	if addr, ok := DNSServers[server]; ok {
+		rm.UDP = &UDPResolver{Server: addr, Timeout: 5 * time.Second}
+		rm.TCP = &TCPResolver{Server: addr, Timeout: 5 * time.Second}
+	}
+	if dot, ok := DoTServers[server]; ok {
+		rm.DoT = dot
+	}
+	if doq, ok := DoQServers[server]; ok {
+		rm.DoQ = doq
+	}
+
+	return rm
+}
+
+// Supports reports whether a resolver is configured for transport.
+func (rm *ResolverManager) Supports(transport string) bool {
+	return rm.resolver(transport) != nil
+}
+
+func (rm *ResolverManager) resolver(transport string) Resolver {
+	var r Resolver
+
+	switch transport {
+	case TransportDoH, "":
+		r = rm.Default
+	case TransportUDP:
+		r = rm.UDP
+	case TransportTCP:
+		r = rm.TCP
+	case TransportDoT:
+		r = rm.DoT
+	case TransportDoQ:
+		r = rm.DoQ
+	}
+
+	return r
+}
+
 // ============================================
 // Public Resolution Logic
 // ============================================
@@ -69,33 +196,166 @@ func NewResolverManager(doh Resolver, udp Resolver) *ResolverManager {
 //
 // resolverType:
 //   - "doh" (default)
-//   - "udp" (explicit)
+//   - "udp" / "tcp" / "dot" / "doq" (explicit)
 //
 // NOTE:
 //   - DoH is always preferred
-//   - UDP fallback is OPTIONAL and intentionally disabled
+//   - Only the selected transport is used, see ResolveFallback
 func (rm *ResolverManager) Resolve(
 	domain string,
 	qtype uint16,
 	resolverType string,
-) ([]models.DNSRecord, error) {
+) (*Result, error) {
+	if resolverType == "" {
+		resolverType = TransportDoH
+	}
+
+	r := rm.resolver(resolverType)
+	if r == nil {
+		return nil, fmt.Errorf("%s resolver not configured", strings.ToUpper(resolverType))
+	}
+
+	start := time.Now()
+
+	res, err := r.Query(domain, qtype)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Transport = resolverType
+	res.Latency = time.Since(start)
 
-	// Explicit UDP selection
-	if resolverType == "udp" {
-		if rm.UDP == nil {
-			return nil, errors.New("UDP resolver not configured")
+	return res, nil
+}
+
+// ResolveFallback resolves with transport, then with the transports after
+// it in the fallback chain when a resolver fails. An empty answer is not a
+// failure. When every transport fails the requested one's error is
+// returned.
+func (rm *ResolverManager) ResolveFallback(
+	domain string,
+	qtype uint16,
+	transport string,
+) (*Result, error) {
+	if transport == "" {
+		transport = TransportDoH
+	}
+
+	chain := []string{transport}
+	if i := slices.Index(rm.Fallback, transport); i >= 0 {
+		chain = append(chain, rm.Fallback[i+1:]...)
+	}
+
+	var firstErr error
+
+	for _, t := range chain {
+		if !rm.Supports(t) {
+			continue
+		}
+
+		res, err := rm.Resolve(domain, qtype, t)
+		if err == nil {
+			res.FallbackErr = firstErr
+			return res, nil
 		}
-		return rm.UDP.Query(domain, qtype)
+
+		log.Printf("Resolver error (%s): %v", t, err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if firstErr == nil {
+		firstErr = fmt.Errorf("%s resolver not configured", strings.ToUpper(transport))
 	}
 
-	// Default: DoH
-	return rm.Default.Query(domain, qtype)
+	return nil, firstErr
 }
 
 // ============================================
 // Helpers
 // ============================================
 
+// parseFallback reads a comma separated transport list. Empty means the
+// default DoH → DoT → UDP chain.
+func parseFallback(s string) []string {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	switch s {
+	case "":
+		return []string{TransportDoH, TransportDoT, TransportUDP}
+	case "none", "off":
+		return nil
+	}
+
+	var chain []string
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		if !slices.Contains(Transports, t) {
+			log.Printf("DNS_FALLBACK: unknown transport %q ignored", t)
+			continue
+		}
+		chain = append(chain, t)
+	}
+
+	return chain
+}
+
+// newQueryMsg builds a recursive query, with EDNS0 / DO unless disabled.
+func newQueryMsg(domain string, qtype uint16, edns0 bool) *dnslib.Msg {
+	msg := new(dnslib.Msg)
+	msg.SetQuestion(domain, qtype)
+	msg.RecursionDesired = true
+
+	if edns0 {
+		msg.SetEdns0(4096, true)
+	}
+
+	return msg
+}
+
+// resultFromMsg converts a wire response. Errors (SERVFAIL, NXDOMAIN,
+// ...) have no records, only the Rcode.
+func resultFromMsg(resp *dnslib.Msg, domain string) *Result {
+	res := &Result{
+		Rcode:              resp.Rcode,
+		AuthenticatedData:  resp.AuthenticatedData,
+		Truncated:          resp.Truncated,
+		RecursionAvailable: resp.RecursionAvailable,
+	}
+
+	if resp.Rcode != dnslib.RcodeSuccess {
+		return res
+	}
+
+	for _, ans := range resp.Answer {
+		if rec := parseRR(ans, domain); rec != nil {
+			res.Records = append(res.Records, *rec)
+		}
+	}
+
+	return res
+}
+
+// exchange sends one query with a miekg client (UDP, TCP, DoT).
+func exchange(client *dnslib.Client, server, domain string, qtype uint16) (*Result, error) {
+	resp, err := exchangeMsg(client, server, newQueryMsg(domain, qtype, true))
+	if err != nil {
+		return nil, err
+	}
+
+	return resultFromMsg(resp, domain), nil
+}
+
+// exchangeMsg sends msg with a miekg client, within the client's timeout.
+func exchangeMsg(client *dnslib.Client, server string, msg *dnslib.Msg) (*dnslib.Msg, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), client.Timeout)
+	defer cancel()
+
+	resp, _, err := client.ExchangeContext(ctx, msg, server)
+	return resp, err
+}
+
 // ToQType converts string record type to DNS qtype
 func ToQType(t string) (uint16, error) {
 	switch t {
@@ -113,6 +373,24 @@ func ToQType(t string) (uint16, error) {
 		return dnslib.TypeTXT, nil
 	case "PTR":
 		return dnslib.TypePTR, nil
+	case "SOA":
+		return dnslib.TypeSOA, nil
+	case "CAA":
+		return dnslib.TypeCAA, nil
+	case "SRV":
+		return dnslib.TypeSRV, nil
+	case "TLSA":
+		return dnslib.TypeTLSA, nil
+	case "HTTPS":
+		return dnslib.TypeHTTPS, nil
+	case "SVCB":
+		return dnslib.TypeSVCB, nil
+	case "NAPTR":
+		return dnslib.TypeNAPTR, nil
+	case "DS":
+		return dnslib.TypeDS, nil
+	case "SSHFP":
+		return dnslib.TypeSSHFP, nil
 	default:
 		return 0, errors.New("unsupported DNS record type")
 	}
@@ -0,0 +1,328 @@
+package dns
+
+import (
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"tools.bctechvibe.io.vn/server/internal/models"
+)
+
+type stubResolver struct {
+	err   error
+	calls int
+}
+
+func (s *stubResolver) Query(domain string, qtype uint16) (*Result, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &Result{Records: []models.DNSRecord{{Type: "A", Domain: domain, Address: "192.0.2.1"}}}, nil
+}
+
+func TestResolveFallback(t *testing.T) {
+	down := errors.New("connection refused")
+
+	cases := []struct {
+		name      string
+		doh, dot  error
+		udp       error
+		fallback  []string
+		transport string
+		used      string
+		fail      bool
+	}{
+		{name: "doh answers", transport: TransportDoH, used: TransportDoH},
+		{name: "doh down", doh: down, transport: TransportDoH, used: TransportDoT},
+		{name: "doh and dot down", doh: down, dot: down, transport: TransportDoH, used: TransportUDP},
+		{name: "all down", doh: down, dot: down, udp: down, transport: TransportDoH, fail: true},
+		{name: "dot only falls forward", dot: down, transport: TransportDoT, used: TransportUDP},
+		{name: "udp has no fallback", udp: down, transport: TransportUDP, fail: true},
+		{name: "fallback disabled", doh: down, fallback: []string{}, transport: TransportDoH, fail: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			doh, dot, udp := &stubResolver{err: c.doh}, &stubResolver{err: c.dot}, &stubResolver{err: c.udp}
+
+			rm := &ResolverManager{Default: doh, DoT: dot, UDP: udp, Fallback: DefaultFallback}
+			if c.fallback != nil {
+				rm.Fallback = c.fallback
+			}
+
+			res, err := rm.ResolveFallback("example.test.", dns.TypeA, c.transport)
+
+			if c.fail {
+				if err == nil {
+					t.Fatalf("expected an error, %s answered", res.Transport)
+				}
+				if !errors.Is(err, down) {
+					t.Errorf("error %v, want the requested transport's", err)
+				}
+				return
+			}
+			if err != nil || res.Transport != c.used || len(res.Records) != 1 {
+				t.Fatalf("res %+v, err %v; want %s", res, err, c.used)
+			}
+			if fellBack := c.used != c.transport; fellBack != (res.FallbackErr != nil) {
+				t.Errorf("FallbackErr = %v", res.FallbackErr)
+			}
+		})
+	}
+}
+
+func TestDoHPooledHTTP2(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		conns  int
+		protos []string
+	)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		protos = append(protos, r.Proto)
+		mu.Unlock()
+
+		body, _ := io.ReadAll(r.Body)
+		q := new(dns.Msg)
+		if err := q.Unpack(body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		wire, _ := stubAnswer(t, q).Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(wire)
+	}))
+	srv.EnableHTTP2 = true
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	r := &DoHResolver{Key: "test", Endpoint: srv.URL + "/dns-query", Timeout: 2 * time.Second, RootCAs: pool}
+
+	for i := 0; i < 5; i++ {
+		res, err := r.Query("example.test.", dns.TypeA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Records) != 1 || res.Records[0].Address != "192.0.2.53" {
+			t.Fatalf("unexpected records %+v", res.Records)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if conns != 1 {
+		t.Errorf("%d connections for 5 queries, want 1", conns)
+	}
+	if slices.ContainsFunc(protos, func(p string) bool { return p != "HTTP/2.0" }) {
+		t.Errorf("protocols %v, want HTTP/2.0", protos)
+	}
+}
+
+func TestDoHHTTPErrorIsAFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	for _, json := range []bool{false, true} {
+		r := &DoHResolver{Endpoint: srv.URL, SupportsJSON: json, Timeout: 2 * time.Second}
+		if _, err := r.Query("example.test.", dns.TypeA); err == nil {
+			t.Errorf("json=%v: HTTP 502 should be an error", json)
+		}
+	}
+}
+
+func TestResolverRcodeAndFlags(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeNameError)
+		m.RecursionAvailable = true
+		m.AuthenticatedData = true
+		w.WriteMsg(m)
+	})}
+	go srv.ActivateAndServe()
+	defer srv.Shutdown()
+
+	rfc8484 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		q := new(dns.Msg)
+		q.Unpack(body)
+
+		m := new(dns.Msg)
+		m.SetRcode(q, dns.RcodeServerFailure)
+		m.RecursionAvailable = true
+		// Like public resolvers: AD only for queries with AD or DO set
+		opt := q.IsEdns0()
+		m.AuthenticatedData = q.AuthenticatedData || (opt != nil && opt.Do())
+		wire, _ := m.Pack()
+		w.Write(wire)
+	}))
+	defer rfc8484.Close()
+
+	jsonAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Status":5,"TC":true,"RA":true,"AD":false}`))
+	}))
+	defer jsonAPI.Close()
+
+	cases := []struct {
+		name   string
+		r      Resolver
+		rcode  int
+		ad, tc bool
+	}{
+		{"udp nxdomain", &UDPResolver{Server: pc.LocalAddr().String(), Timeout: 2 * time.Second}, dns.RcodeNameError, true, false},
+		{"rfc8484 servfail", &DoHResolver{Endpoint: rfc8484.URL, Timeout: 2 * time.Second}, dns.RcodeServerFailure, true, false},
+		{"json refused", &DoHResolver{Endpoint: jsonAPI.URL, SupportsJSON: true, Timeout: 2 * time.Second}, dns.RcodeRefused, false, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := c.r.Query("example.test.", dns.TypeA)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.Rcode != c.rcode || !res.RecursionAvailable || res.AuthenticatedData != c.ad || res.Truncated != c.tc {
+				t.Errorf("got %+v", res)
+			}
+			if len(res.Records) != 0 {
+				t.Errorf("error answers have no records: %+v", res.Records)
+			}
+		})
+	}
+}
+
+// JSON endpoints are turned back into messages for the DNSSEC validator
+func TestDoHJSONExchange(t *testing.T) {
+	var query url.Values
+	jsonAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`{"Status":3,"RA":true,"AD":true,
+			"Authority":[
+				{"name":"test.","type":47,"TTL":300,"data":"a.test. NS SOA RRSIG NSEC DNSKEY"},
+				{"name":"test.","type":46,"TTL":300,"data":"NSEC 13 1 300 20300101000000 20200101000000 12345 test. AAAA"}
+			]}`))
+	}))
+	defer jsonAPI.Close()
+
+	q := new(dns.Msg)
+	q.SetQuestion("missing.test.", dns.TypeA)
+	q.CheckingDisabled = true
+	q.SetEdns0(4096, true)
+
+	r := &DoHResolver{Endpoint: jsonAPI.URL, SupportsJSON: true, Timeout: 2 * time.Second}
+	resp, err := r.Exchange(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if query.Get("do") != "1" || query.Get("cd") != "1" || query.Get("name") != "missing.test." {
+		t.Errorf("query parameters %v", query)
+	}
+	if resp.Rcode != dns.RcodeNameError || !resp.AuthenticatedData || resp.Id != q.Id || len(resp.Ns) != 2 {
+		t.Fatalf("unexpected response %v", resp)
+	}
+	if _, ok := resp.Ns[1].(*dns.RRSIG); !ok {
+		t.Errorf("expected an RRSIG, got %T", resp.Ns[1])
+	}
+}
+
+func TestResolverManagerForIsLongLived(t *testing.T) {
+	if ResolverManagerFor("cloudflare") != ResolverManagerFor("cloudflare") {
+		t.Error("managers of a provider should be reused")
+	}
+
+	ResolverManagerFor("no-such-provider")
+
+	managersMu.Lock()
+	_, cached := managers["no-such-provider"]
+	managersMu.Unlock()
+
+	if cached {
+		t.Error("unknown keys should not be cached")
+	}
+
+	udp, ok := ResolverManagerFor("quad9").UDP.(*UDPResolver)
+	if !ok || udp.Server != DNSServers["quad9"] {
+		t.Errorf("UDP resolver of quad9 = %+v", ResolverManagerFor("quad9").UDP)
+	}
+}
+
+func TestParseFallback(t *testing.T) {
+	cases := map[string][]string{
+		"":              {TransportDoH, TransportDoT, TransportUDP},
+		"none":          nil,
+		"DoH, TCP":      {TransportDoH, TransportTCP},
+		"doh,bogus,udp": {TransportDoH, TransportUDP},
+	}
+
+	for in, want := range cases {
+		if got := parseFallback(in); !slices.Equal(got, want) {
+			t.Errorf("parseFallback(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestUDPTruncatedRetriesTCP(t *testing.T) {
+	tcpLn, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := net.ListenPacket("udp", tcpLn.Addr().String())
+	if err != nil {
+		tcpLn.Close()
+		t.Skipf("UDP port of %s taken: %v", tcpLn.Addr(), err)
+	}
+
+	// UDP only sets TC, TCP has the full answer
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if w.RemoteAddr().Network() == "udp" {
+			m.Truncated = true
+		} else {
+			m.Answer = append(m.Answer, mustRR(t, r.Question[0].Name+` 60 IN TXT "large"`))
+		}
+		w.WriteMsg(m)
+	})
+	for _, srv := range []*dns.Server{{PacketConn: pc, Handler: handler}, {Listener: tcpLn, Handler: handler}} {
+		go srv.ActivateAndServe()
+		defer srv.Shutdown()
+	}
+
+	res, err := (&UDPResolver{Server: pc.LocalAddr().String(), Timeout: 2 * time.Second}).Query("example.test.", dns.TypeTXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Truncated || len(res.Records) != 1 {
+		t.Errorf("got %+v", res)
+	}
+}
@@ -0,0 +1,358 @@
+/*
+File: server/internal/dns/trace.go
+Description: Iterative resolution from the root hints down through the
+TLD and delegated nameservers (dig +trace), one step per referral.
+*/
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/internal/models"
+
+	"github.com/miekg/dns"
+)
+
+// RootServers are the root hints the trace starts from (ip:port).
+// DNS_ROOT_SERVERS replaces them: "ip[:port],..."
+var RootServers = rootServers(os.Getenv("DNS_ROOT_SERVERS"))
+
+// IPv4 root hints, a to m.root-servers.net
+var defaultRootServers = []string{
+	"198.41.0.4:53",
+	"170.247.170.2:53",
+	"192.33.4.12:53",
+	"199.7.91.13:53",
+	"192.203.230.10:53",
+	"192.5.5.241:53",
+	"192.112.36.4:53",
+	"198.97.190.53:53",
+	"192.36.148.17:53",
+	"192.58.128.30:53",
+	"193.0.14.129:53",
+	"199.7.83.42:53",
+	"202.12.27.33:53",
+}
+
+// Limits of one trace
+const (
+	traceMaxSteps  = 30
+	traceMaxCNAMEs = 8
+	traceMaxDepth  = 2 // nested traces for glueless nameservers
+)
+
+func rootServers(env string) []string {
+	if strings.TrimSpace(env) == "" {
+		return defaultRootServers
+	}
+
+	var list []string
+	for _, addr := range strings.Split(env, ",") {
+		addr = strings.TrimSpace(addr)
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "53")
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			log.Printf("DNS_ROOT_SERVERS: ignoring %q", addr)
+			continue
+		}
+		list = append(list, addr)
+	}
+
+	return list
+}
+
+// Tracer resolves a name iteratively without a recursive resolver.
+type Tracer struct {
+	Roots   []string // root server addresses (ip:port)
+	Timeout time.Duration
+
+	addr func(ip net.IP) string // where queries for a nameserver address are sent
+}
+
+func NewTracer() *Tracer {
+	return &Tracer{
+		Roots:   RootServers,
+		Timeout: 3 * time.Second,
+		addr:    func(ip net.IP) string { return net.JoinHostPort(ip.String(), "53") },
+	}
+}
+
+// Trace resolves name / qtype from the root with the default tracer.
+func Trace(name string, qtype uint16) (*models.TraceInfo, []models.DNSRecord) {
+	return NewTracer().Trace(name, qtype)
+}
+
+type traceServer struct {
+	name string
+	addr string
+}
+
+// Trace follows the referrals from the root down to the final answer,
+// and CNAMEs from the root again. The records are the final answer.
+func (t *Tracer) Trace(name string, qtype uint16) (*models.TraceInfo, []models.DNSRecord) {
+	name = dns.CanonicalName(name)
+
+	info := &models.TraceInfo{
+		Name:  trimDot(name),
+		Type:  dns.TypeToString[qtype],
+		Steps: []models.TraceStep{},
+	}
+
+	answer, err := t.trace(info, name, qtype, 0)
+	if err != nil {
+		info.Message = err.Error()
+	}
+
+	var records []models.DNSRecord
+	for _, rr := range answer {
+		if rec := parseRR(rr, trimDot(rr.Header().Name)); rec != nil {
+			records = append(records, *rec)
+		}
+	}
+
+	return info, records
+}
+
+func (t *Tracer) trace(info *models.TraceInfo, name string, qtype uint16, depth int) ([]dns.RR, error) {
+	var answer []dns.RR
+
+	for cnames := 0; cnames <= traceMaxCNAMEs; cnames++ {
+		zone := "."
+		servers := make([]traceServer, 0, len(t.Roots))
+		for _, addr := range t.Roots {
+			servers = append(servers, traceServer{name: addr, addr: addr})
+		}
+
+		for {
+			if len(info.Steps) >= traceMaxSteps {
+				return answer, fmt.Errorf("gave up after %d steps", traceMaxSteps)
+			}
+
+			step, resp := t.ask(servers, zone, name, qtype)
+			info.Steps = append(info.Steps, step)
+			if resp == nil {
+				return answer, fmt.Errorf("no nameserver of %s answered", trimDot(zone))
+			}
+			info.Rcode = step.Rcode
+
+			// Referral: go one zone down
+			if next, hosts, glue := delegationOf(resp, zone, name); next != "" {
+				servers = t.nextServers(hosts, glue, depth)
+				if len(servers) == 0 {
+					return answer, fmt.Errorf("no address for the nameservers of %s", trimDot(next))
+				}
+				zone = next
+				continue
+			}
+
+			if resp.Rcode != dns.RcodeSuccess {
+				info.Complete = resp.Rcode == dns.RcodeNameError && resp.Authoritative
+				return answer, fmt.Errorf("%s answered %s", step.Server, step.Rcode)
+			}
+			if !resp.Authoritative {
+				return answer, fmt.Errorf("%s is not authoritative for %s (lame delegation)", step.Server, trimDot(zone))
+			}
+
+			// Final answer, with the CNAMEs the server chased itself
+			target, rrs := chaseAnswer(resp.Answer, name, qtype)
+			answer = append(answer, rrs...)
+			if target == name || slices.ContainsFunc(rrs, func(rr dns.RR) bool { return rr.Header().Rrtype == qtype }) {
+				info.Complete = true
+				return answer, nil
+			}
+
+			// CNAME out of the zone: start again from the root
+			name = target
+			break
+		}
+	}
+
+	return answer, fmt.Errorf("more than %d CNAMEs", traceMaxCNAMEs)
+}
+
+// ask sends the query to the servers in turn until one answers.
+func (t *Tracer) ask(servers []traceServer, zone, name string, qtype uint16) (models.TraceStep, *dns.Msg) {
+	step := models.TraceStep{
+		Zone:  trimDot(zone),
+		Query: trimDot(name) + " " + dns.TypeToString[qtype],
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, qtype)
+	msg.RecursionDesired = false
+	msg.SetEdns0(4096, true) // DO: referrals carry DS / NSEC
+
+	client := &dns.Client{Timeout: t.Timeout}
+
+	var errs []error
+	for _, s := range servers {
+		step.Server, step.Address = trimDot(s.name), s.addr
+
+		resp, rtt, err := client.Exchange(msg, s.addr)
+		if err == nil && resp.Truncated {
+			tcp := &dns.Client{Net: "tcp", Timeout: t.Timeout}
+			resp, rtt, err = tcp.Exchange(msg, s.addr)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.addr, err))
+			continue
+		}
+
+		step.LatencyMs = rtt.Milliseconds()
+		step.Rcode = dns.RcodeToString[resp.Rcode]
+		step.Authoritative = resp.Authoritative
+		describeStep(&step, resp, zone, name)
+
+		return step, resp
+	}
+
+	step.Error = errors.Join(errs...).Error()
+	return step, nil
+}
+
+// describeStep fills the referral, glue, answers and DNSSEC records.
+func describeStep(step *models.TraceStep, resp *dns.Msg, zone, name string) {
+	if next, hosts, _ := delegationOf(resp, zone, name); next != "" {
+		step.NextZone = trimDot(next)
+		step.Referral = trimDots(hosts)
+	}
+
+	for _, rr := range resp.Answer {
+		step.Answers = append(step.Answers, presentation(rr))
+	}
+	for _, rr := range append(slices.Clone(resp.Answer), resp.Ns...) {
+		switch rr.Header().Rrtype {
+		case dns.TypeDS, dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3:
+			step.DNSSEC = append(step.DNSSEC, presentation(rr))
+		}
+	}
+	for _, rr := range resp.Extra {
+		switch rr.Header().Rrtype {
+		case dns.TypeA, dns.TypeAAAA:
+			step.Glue = append(step.Glue, presentation(rr))
+		}
+	}
+}
+
+// delegationOf returns the zone a referral points to, its nameservers
+// and glue. The zone must be below the current one and contain name.
+func delegationOf(resp *dns.Msg, zone, name string) (string, []string, map[string][]net.IP) {
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) > 0 {
+		return "", nil, nil
+	}
+
+	var next string
+	var hosts []string
+	for _, rr := range resp.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+
+		owner := dns.CanonicalName(ns.Hdr.Name)
+		if owner == zone || !dns.IsSubDomain(zone, owner) || !dns.IsSubDomain(owner, name) {
+			continue
+		}
+		if next != "" && owner != next {
+			continue
+		}
+
+		next = owner
+		hosts = append(hosts, dns.CanonicalName(ns.Ns))
+	}
+	if next == "" {
+		return "", nil, nil
+	}
+
+	_, glue := referral(resp, next)
+	return next, hosts, glue
+}
+
+// nextServers lists the nameservers of a referral: glued ones first,
+// IPv4 before IPv6, then glueless ones resolved by a nested trace.
+func (t *Tracer) nextServers(hosts []string, glue map[string][]net.IP, depth int) []traceServer {
+	var v4, v6 []traceServer
+	add := func(host string, ips []net.IP) {
+		for _, ip := range ips {
+			s := traceServer{name: host, addr: t.addr(ip)}
+			if ip.To4() != nil {
+				v4 = append(v4, s)
+			} else {
+				v6 = append(v6, s)
+			}
+		}
+	}
+
+	for _, host := range hosts {
+		add(host, glue[host])
+	}
+
+	// Glueless delegation: resolve one nameserver from the root
+	if len(v4)+len(v6) == 0 && depth < traceMaxDepth {
+		for _, host := range hosts {
+			add(host, t.lookupHost(host, depth+1))
+			if len(v4)+len(v6) > 0 {
+				break
+			}
+		}
+	}
+
+	return append(v4, v6...)
+}
+
+func (t *Tracer) lookupHost(host string, depth int) []net.IP {
+	var ips []net.IP
+
+	sub := &models.TraceInfo{}
+	answer, _ := t.trace(sub, host, dns.TypeA, depth)
+	for _, rr := range answer {
+		if a, ok := rr.(*dns.A); ok {
+			ips = append(ips, a.A)
+		}
+	}
+
+	return ips
+}
+
+// chaseAnswer follows the CNAMEs of name in answer and returns where the
+// chain ends and the RRs that are part of it.
+func chaseAnswer(answer []dns.RR, name string, qtype uint16) (string, []dns.RR) {
+	var rrs []dns.RR
+
+	for range traceMaxCNAMEs {
+		var cname string
+		for _, rr := range answer {
+			if !strings.EqualFold(rr.Header().Name, name) {
+				continue
+			}
+
+			switch {
+			case rr.Header().Rrtype == qtype:
+				rrs = append(rrs, rr)
+			case rr.Header().Rrtype == dns.TypeCNAME && cname == "":
+				rrs = append(rrs, rr)
+				cname = dns.CanonicalName(rr.(*dns.CNAME).Target)
+			}
+		}
+
+		if cname == "" || qtype == dns.TypeCNAME {
+			break
+		}
+		name = cname
+	}
+
+	return name, rrs
+}
+
+// presentation is the zone file form of rr on one line.
+func presentation(rr dns.RR) string {
+	return strings.ReplaceAll(rr.String(), "\t", " ")
+}
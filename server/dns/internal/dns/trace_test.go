@@ -0,0 +1,183 @@
+package dns
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// traceStubs is a root, a test. TLD and one authoritative server that
+// hosts example.test and other.test.
+func traceStubs(t *testing.T) (*Tracer, map[string]string) {
+	t.Helper()
+
+	referral := func(r *dns.Msg, zone string, ns string, glue ...string) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Ns = append(m.Ns, mustRR(t, zone+" 3600 IN NS "+ns))
+		for _, g := range glue {
+			m.Extra = append(m.Extra, mustRR(t, g))
+		}
+		return m
+	}
+	under := func(r *dns.Msg, zone string) bool {
+		return dns.IsSubDomain(zone, dns.CanonicalName(r.Question[0].Name))
+	}
+
+	root := serveAuth(t, func(r *dns.Msg) *dns.Msg {
+		if under(r, "test.") {
+			m := referral(r, "test.", "ns.nic.test.", "ns.nic.test. 3600 IN A 192.0.2.10")
+			m.Ns = append(m.Ns, mustRR(t, "test. 86400 IN DS 12345 13 2 "+strings.Repeat("ab", 32)))
+			return m
+		}
+		return new(dns.Msg).SetRcode(r, dns.RcodeNameError)
+	})
+
+	tld := serveAuth(t, func(r *dns.Msg) *dns.Msg {
+		switch {
+		case under(r, "example.test."):
+			return referral(r, "example.test.", "ns1.example.test.", "ns1.example.test. 3600 IN A 192.0.2.20")
+		case under(r, "other.test."):
+			// glueless, the nameserver is in another zone
+			return referral(r, "other.test.", "ns1.example.test.")
+		}
+		m := new(dns.Msg).SetRcode(r, dns.RcodeNameError)
+		m.Authoritative = true
+		return m
+	})
+
+	auth := serveAuth(t, func(r *dns.Msg) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Authoritative = true
+
+		q := r.Question[0]
+		switch {
+		case q.Name == "www.example.test." && q.Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, mustRR(t, "www.example.test. 300 IN CNAME edge.other.test."))
+		case q.Name == "ns1.example.test." && q.Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, mustRR(t, "ns1.example.test. 300 IN A 192.0.2.20"))
+		case q.Name == "edge.other.test." && q.Qtype == dns.TypeA:
+			m.Answer = append(m.Answer, mustRR(t, "edge.other.test. 300 IN A 203.0.113.5"))
+		case q.Name == "mail.example.test." && q.Qtype == dns.TypeA:
+			m.Answer = append(m.Answer,
+				mustRR(t, "mail.example.test. 300 IN CNAME mx.example.test."),
+				mustRR(t, "mx.example.test. 300 IN A 203.0.113.25"))
+		default:
+			m.Rcode = dns.RcodeNameError
+		}
+		return m
+	})
+
+	stubs := map[string]string{"192.0.2.10": tld, "192.0.2.20": auth}
+
+	tr := NewTracer()
+	tr.Roots = []string{root}
+	tr.Timeout = 2 * time.Second
+	tr.addr = func(ip net.IP) string { return stubs[ip.String()] }
+
+	return tr, stubs
+}
+
+func TestTrace(t *testing.T) {
+	tr, stubs := traceStubs(t)
+
+	info, records := tr.Trace("www.example.test", dns.TypeA)
+
+	if !info.Complete || info.Message != "" {
+		t.Fatalf("incomplete trace: %q", info.Message)
+	}
+
+	// root, test., example.test. (CNAME), then root, test., other.test.
+	var zones []string
+	for _, s := range info.Steps {
+		zones = append(zones, s.Zone)
+	}
+	if want := []string{".", "test", "example.test", ".", "test", "other.test"}; !slices.Equal(zones, want) {
+		t.Errorf("zones %v, want %v", zones, want)
+	}
+
+	root := info.Steps[0]
+	if root.NextZone != "test" || !slices.Equal(root.Referral, []string{"ns.nic.test"}) ||
+		len(root.Glue) != 1 || len(root.DNSSEC) != 1 || root.Authoritative {
+		t.Errorf("root step %+v", root)
+	}
+	if !strings.Contains(root.DNSSEC[0], "IN DS 12345") || strings.Contains(root.DNSSEC[0], "\t") {
+		t.Errorf("DS %q", root.DNSSEC[0])
+	}
+
+	tld := info.Steps[1]
+	if tld.Server != "ns.nic.test" || tld.Address != stubs["192.0.2.10"] || tld.NextZone != "example.test" {
+		t.Errorf("TLD step %+v", tld)
+	}
+
+	// other.test is glueless: ns1.example.test was resolved from the root
+	other := info.Steps[5]
+	if other.Server != "ns1.example.test" || other.Address != stubs["192.0.2.20"] || !other.Authoritative {
+		t.Errorf("glueless step %+v", other)
+	}
+
+	if len(records) != 2 || records[0].Type != "CNAME" || records[1].Address != "203.0.113.5" {
+		t.Errorf("records %+v", records)
+	}
+}
+
+func TestTraceInZoneCNAME(t *testing.T) {
+	tr, _ := traceStubs(t)
+
+	info, records := tr.Trace("mail.example.test.", dns.TypeA)
+
+	if !info.Complete || len(info.Steps) != 3 {
+		t.Fatalf("complete %v, %d steps", info.Complete, len(info.Steps))
+	}
+	if len(records) != 2 || records[1].Address != "203.0.113.25" {
+		t.Errorf("records %+v", records)
+	}
+	if len(info.Steps[2].Answers) != 2 {
+		t.Errorf("answers %v", info.Steps[2].Answers)
+	}
+}
+
+func TestTraceNXDOMAIN(t *testing.T) {
+	tr, _ := traceStubs(t)
+
+	info, records := tr.Trace("nope.test.", dns.TypeA)
+
+	if !info.Complete || info.Rcode != "NXDOMAIN" || len(records) != 0 || len(info.Steps) != 2 {
+		t.Errorf("info %+v", info)
+	}
+}
+
+func TestTraceUnreachableRoot(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing answers on this socket
+	defer pc.Close()
+
+	tr := NewTracer()
+	tr.Roots = []string{pc.LocalAddr().String()}
+	tr.Timeout = 200 * time.Millisecond
+
+	info, _ := tr.Trace("example.test.", dns.TypeA)
+
+	if info.Complete || len(info.Steps) != 1 || info.Steps[0].Error == "" || !strings.Contains(info.Message, "no nameserver of .") {
+		t.Errorf("info %+v", info)
+	}
+}
+
+func TestRootServers(t *testing.T) {
+	if got := rootServers(""); len(got) != 13 {
+		t.Errorf("%d default root servers", len(got))
+	}
+
+	got := rootServers("127.0.0.1:5353, 192.0.2.1, ::1")
+	if want := []string{"127.0.0.1:5353", "192.0.2.1:53", "[::1]:53"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
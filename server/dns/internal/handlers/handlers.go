@@ -10,6 +10,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 
 	// "time"
@@ -75,6 +76,39 @@ func isSubdomain(hostname string) bool {
 	return hostname != etldPlus1
 }
 
+// lookup runs one query and keeps its RCODE / flags / latency in the response
+func lookup(response *models.DNSLookupResponse, serverKey, transport, name string, qtype uint16) ([]interface{}, models.ResponseInfo) {
+	records, info := dns.QueryDNSTransport(serverKey, transport, name, qtype)
+	response.Data.Responses = append(response.Data.Responses, info)
+	return records, info
+}
+
+// Record which transport answered the main query (fallback included)
+func setTransport(response *models.DNSLookupResponse, requested string, info models.ResponseInfo) {
+	if info.Rcode == "" {
+		return // no transport answered
+	}
+	response.Data.Query.Transport = info.Transport
+	response.Data.Query.Fallback = info.Transport != requested
+}
+
+// emptyMessage explains an empty answer: the RCODE or transport error
+func emptyMessage(info models.ResponseInfo) string {
+	switch {
+	case info.Rcode == "" && info.Error != "":
+		return "Không thể truy vấn máy chủ DNS: " + info.Error
+	case info.Rcode == "NXDOMAIN":
+		return "Tên miền không tồn tại (NXDOMAIN)"
+	case info.Rcode == "SERVFAIL":
+		return "Máy chủ DNS không thể phân giải tên miền này (SERVFAIL)"
+	case info.Rcode == "REFUSED":
+		return "Máy chủ DNS từ chối truy vấn (REFUSED)"
+	case info.Rcode != "" && info.Rcode != "NOERROR":
+		return "Máy chủ DNS trả về lỗi " + info.Rcode
+	}
+	return "Không tìm thấy bản ghi DNS cho loại truy vấn này"
+}
+
 // Normalize hostname: strip http/https, port, path, trailing slash
 func normalizeHostname(input string) string {
 	input = strings.TrimSpace(input)
@@ -125,11 +159,32 @@ func HandleDNSLookup(c *gin.Context) {
 		return
 	}
 
+	// ✅ transport: doh (mặc định) | udp | tcp | dot | doq
+	req.Transport = strings.ToLower(strings.TrimSpace(req.Transport))
+	if req.Transport == "" {
+		req.Transport = dns.TransportDoH
+	}
+	if !slices.Contains(dns.Transports, req.Transport) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Giao thức truy vấn không hợp lệ (doh, udp, tcp, dot, doq)",
+		})
+		return
+	}
+	if !dns.ResolverManagerFor(serverKey).Supports(req.Transport) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("Máy chủ DNS %s không hỗ trợ giao thức %s", serverKey, strings.ToUpper(req.Transport)),
+		})
+		return
+	}
+
 	var response models.DNSLookupResponse
 	response.Success = true
 	response.Data.Query.Hostname = req.Hostname
 	response.Data.Query.Type = req.Type
 	response.Data.Query.Server = serverKey
+	response.Data.Query.Transport = req.Transport
 
 	if !isIPAddress(req.Hostname) {
 		response.Data.Query.IsSubdomain = isSubdomain(req.Hostname)
@@ -140,6 +195,12 @@ func HandleDNSLookup(c *gin.Context) {
 		handlePTRLookup(c, serverKey, &req, &response)
 	case "DNSSEC":
 		handleDNSSECLookup(c, serverKey, &req, &response)
+	case "PROPAGATION":
+		handlePropagationLookup(c, &req, &response)
+	case "TRACE":
+		handleTraceLookup(c, &req, &response)
+	case "MAIL":
+		handleMailAudit(c, serverKey, &req, &response)
 	case "BLACKLIST":
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
@@ -159,15 +220,15 @@ func handleAllRecordsV2(c *gin.Context, serverKey string, req *models.DNSLookupR
 	// Check if input is IP address
 	if isIPAddress(input) {
 		// Input is IP → Query PTR only
-		handleIPAllRecords(c, serverKey, input, response)
+		handleIPAllRecords(c, serverKey, req.Transport, input, response)
 	} else {
 		// Input is domain → Query A, AAAA, CNAME, MX, TXT, DNSSEC
-		handleDomainAllRecords(c, serverKey, input, response)
+		handleDomainAllRecords(c, serverKey, req.Transport, input, response)
 	}
 }
 
 // Handle ALL records for IP address (PTR)
-func handleIPAllRecords(c *gin.Context, serverKey string, ip string, response *models.DNSLookupResponse) {
+func handleIPAllRecords(c *gin.Context, serverKey, transport string, ip string, response *models.DNSLookupResponse) {
 	var allRecords []interface{}
 	response.Data.Query.IsSubdomain = false
 	// 1. Query PTR
@@ -180,7 +241,8 @@ func handleIPAllRecords(c *gin.Context, serverKey string, ip string, response *m
 		return
 	}
 
-	ptrRecords := dns.QueryDNS(serverKey, arpa, dnslib.TypePTR)
+	ptrRecords, queryInfo := lookup(response, serverKey, transport, arpa, dnslib.TypePTR)
+	setTransport(response, transport, queryInfo)
 
 	// Enrich PTR records with GeoIP info
 	for i := range ptrRecords {
@@ -217,7 +279,7 @@ func handleIPAllRecords(c *gin.Context, serverKey string, ip string, response *m
 
 // Handle ALL records for domain (A, AAAA, CNAME, MX, TXT, DNSSEC)
 // WITH DEDUPLICATION
-func handleDomainAllRecords(c *gin.Context, serverKey string, domain string, response *models.DNSLookupResponse) {
+func handleDomainAllRecords(c *gin.Context, serverKey, transport string, domain string, response *models.DNSLookupResponse) {
 	var allRecords []interface{}
 	fqdn := dnslib.Fqdn(domain)
 	originalDomain := strings.TrimSuffix(fqdn, ".")
@@ -242,7 +304,7 @@ func handleDomainAllRecords(c *gin.Context, serverKey string, domain string, res
 	apexFQDN := dnslib.Fqdn(apexDomain)
 
 	// 1. Query NS records (for nameservers) - always on apex domain
-	nsRecords := dns.QueryDNS(serverKey, apexFQDN, dnslib.TypeNS)
+	nsRecords, _ := lookup(response, serverKey, transport, apexFQDN, dnslib.TypeNS)
 	for _, record := range nsRecords {
 		if nsRec, ok := record.(models.DNSRecord); ok && nsRec.Type == "NS" {
 			response.Data.Nameservers = append(response.Data.Nameservers, models.NameserverInfo{
@@ -255,7 +317,7 @@ func handleDomainAllRecords(c *gin.Context, serverKey string, domain string, res
 
 	// 2. Query CNAME records FIRST (chỉ lấy record đầu tiên)
 	canonicalName := fqdn
-	cnameRecords := dns.QueryDNS(serverKey, fqdn, dnslib.TypeCNAME)
+	cnameRecords, _ := lookup(response, serverKey, transport, fqdn, dnslib.TypeCNAME)
 
 	if len(cnameRecords) > 0 {
 		// Chỉ lấy CNAME record đầu tiên
@@ -274,10 +336,11 @@ func handleDomainAllRecords(c *gin.Context, serverKey string, domain string, res
 	}
 
 	// 3. Query A records (on canonical name if CNAME exists)
-	aRecords := dns.QueryDNS(serverKey, canonicalName, dnslib.TypeA)
+	aRecords, queryInfo := lookup(response, serverKey, transport, canonicalName, dnslib.TypeA)
+	setTransport(response, transport, queryInfo)
 	// SMART FALLBACK: If querying Google returns only 1 A record, retry with Cloudflare
 	if serverKey == "google" && len(aRecords) == 1 {
-		cfA := dns.QueryDNS("cloudflare", canonicalName, dnslib.TypeA)
+		cfA, _ := lookup(response, "cloudflare", transport, canonicalName, dnslib.TypeA)
 		if len(cfA) > 1 {
 			aRecords = cfA
 			// update response to indicate data came from Cloudflare for completeness
@@ -300,10 +363,10 @@ func handleDomainAllRecords(c *gin.Context, serverKey string, domain string, res
 	}
 
 	// 4. Query AAAA records (on canonical name if CNAME exists)
-	aaaaRecords := dns.QueryDNS(serverKey, canonicalName, dnslib.TypeAAAA)
+	aaaaRecords, _ := lookup(response, serverKey, transport, canonicalName, dnslib.TypeAAAA)
 	// SMART FALLBACK: If querying Google returns only 1 AAAA record, retry with Cloudflare
 	if serverKey == "google" && len(aaaaRecords) == 1 {
-		cfAAAA := dns.QueryDNS("cloudflare", canonicalName, dnslib.TypeAAAA)
+		cfAAAA, _ := lookup(response, "cloudflare", transport, canonicalName, dnslib.TypeAAAA)
 		if len(cfAAAA) > 1 {
 			aaaaRecords = cfAAAA
 			response.Data.Query.Server = "cloudflare"
@@ -325,7 +388,7 @@ func handleDomainAllRecords(c *gin.Context, serverKey string, domain string, res
 	}
 
 	// 5. Query MX records (always on original domain)
-	mxRecords := dns.QueryDNS(serverKey, fqdn, dnslib.TypeMX)
+	mxRecords, _ := lookup(response, serverKey, transport, fqdn, dnslib.TypeMX)
 	if len(mxRecords) > 0 {
 		for _, record := range mxRecords {
 			if mxRec, ok := record.(models.DNSRecord); ok && mxRec.Type == "MX" {
@@ -340,7 +403,7 @@ func handleDomainAllRecords(c *gin.Context, serverKey string, domain string, res
 	}
 
 	// 6. Query TXT records (always on original domain)
-	txtRecords := dns.QueryDNS(serverKey, fqdn, dnslib.TypeTXT)
+	txtRecords, _ := lookup(response, serverKey, transport, fqdn, dnslib.TypeTXT)
 	if len(txtRecords) > 0 {
 		for _, record := range txtRecords {
 			if txtRec, ok := record.(models.DNSRecord); ok && txtRec.Type == "TXT" {
@@ -362,7 +425,7 @@ func handleDomainAllRecords(c *gin.Context, serverKey string, domain string, res
 	}
 
 	// 7. Check DNSSEC
-	dnssecInfo := dns.ValidateDNSSEC(serverKey, fqdn)
+	dnssecInfo := dns.ValidateDNSSEC(serverKey, transport, fqdn)
 	response.Data.DNSSEC = &dnssecInfo
 
 	if len(allRecords) == 0 {
@@ -399,7 +462,8 @@ func handlePTRLookup(c *gin.Context, serverKey string, req *models.DNSLookupRequ
 		return
 	}
 
-	records := dns.QueryDNS(serverKey, arpa, dnslib.TypePTR)
+	records, queryInfo := lookup(response, serverKey, req.Transport, arpa, dnslib.TypePTR)
+	setTransport(response, req.Transport, queryInfo)
 	// Enrich PTR records nếu có
 	for i := range records {
 		if record, ok := records[i].(models.DNSRecord); ok && record.Type == "PTR" {
@@ -441,7 +505,7 @@ func handleDNSSECLookup(c *gin.Context, serverKey string, req *models.DNSLookupR
 
 	fqdn := dnslib.Fqdn(input)
 
-	dnssecInfo := dns.ValidateDNSSEC(serverKey, fqdn)
+	dnssecInfo := dns.ValidateDNSSEC(serverKey, req.Transport, fqdn)
 
 	response.Success = true
 	response.Data.Query.IsSubdomain = isSubdomain(input)
@@ -453,6 +517,128 @@ func handleDNSSECLookup(c *gin.Context, serverKey string, req *models.DNSLookupR
 	c.JSON(http.StatusOK, response)
 }
 
+// Same name/type on every provider and regional resolver, after a change
+func handlePropagationLookup(c *gin.Context, req *models.DNSLookupRequest, response *models.DNSLookupResponse) {
+	input := strings.TrimSuffix(strings.TrimSpace(req.Hostname), ".")
+
+	if isIPAddress(input) || !validator.IsValidDomain(input) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Tên miền không hợp lệ, vui lòng nhập lại!",
+		})
+		return
+	}
+
+	recordType := strings.ToUpper(strings.TrimSpace(req.RecordType))
+	if recordType == "" {
+		recordType = "A"
+	}
+	qtype, err := dns.ToQType(recordType)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Loại bản ghi không hợp lệ",
+		})
+		return
+	}
+
+	propagation := dns.CheckPropagation(input, qtype)
+
+	response.Data.Propagation = propagation
+	response.Data.Records = []interface{}{}
+
+	switch {
+	case propagation.Answered == 0:
+		response.Message = "Không có máy chủ DNS nào phản hồi"
+	case !propagation.Consistent:
+		response.Message = fmt.Sprintf("%d/%d máy chủ DNS trả về kết quả khác với đa số", len(propagation.Disagreeing), propagation.Answered)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Iterative resolution from the root servers (dig +trace)
+func handleTraceLookup(c *gin.Context, req *models.DNSLookupRequest, response *models.DNSLookupResponse) {
+	input := strings.TrimSuffix(strings.TrimSpace(req.Hostname), ".")
+
+	recordType := strings.ToUpper(strings.TrimSpace(req.RecordType))
+	if recordType == "" {
+		recordType = "A"
+	}
+
+	// IP → trace the PTR of its reverse name
+	name := input
+	if isIPAddress(input) {
+		arpa, err := dnslib.ReverseAddr(input)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Địa chỉ IP không hợp lệ",
+			})
+			return
+		}
+		name, recordType = arpa, "PTR"
+	} else if !validator.IsValidDomain(input) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Tên miền không hợp lệ, vui lòng nhập lại!",
+		})
+		return
+	}
+
+	qtype, err := dns.ToQType(recordType)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Loại bản ghi không hợp lệ",
+		})
+		return
+	}
+
+	trace, records := dns.Trace(name, qtype)
+
+	response.Data.Trace = trace
+	response.Data.Records = []interface{}{}
+	for _, rec := range records {
+		response.Data.Records = append(response.Data.Records, rec)
+	}
+
+	if !trace.Complete {
+		response.Message = "Không thể phân giải đến máy chủ authoritative: " + trace.Message
+	} else if len(records) == 0 {
+		response.Message = emptyMessage(models.ResponseInfo{Rcode: trace.Rcode})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// SPF, DKIM, DMARC, MTA-STS, TLS-RPT và BIMI của một tên miền
+func handleMailAudit(c *gin.Context, serverKey string, req *models.DNSLookupRequest, response *models.DNSLookupResponse) {
+	input := strings.TrimSuffix(strings.TrimSpace(req.Hostname), ".")
+
+	if isIPAddress(input) || !validator.IsValidDomain(input) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Tên miền không hợp lệ, vui lòng nhập lại!",
+		})
+		return
+	}
+
+	audit := dns.AuditMail(serverKey, req.Transport, input, req.Selectors)
+
+	response.Data.Mail = audit
+	response.Data.Records = []interface{}{}
+
+	switch audit.Status {
+	case dns.MailFail:
+		response.Message = "Cấu hình email của tên miền có lỗi cần khắc phục"
+	case dns.MailWarn:
+		response.Message = "Cấu hình email của tên miền có mục cần cải thiện"
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
 func handleSpecificRecord(c *gin.Context, serverKey string, req *models.DNSLookupRequest, response *models.DNSLookupResponse) {
 	fqdn := dnslib.Fqdn(req.Hostname)
 	originalDomain := strings.TrimSuffix(fqdn, ".")
@@ -477,7 +663,7 @@ func handleSpecificRecord(c *gin.Context, serverKey string, req *models.DNSLooku
 
 	// 1. Query NS records (nameservers) - always on apex domain
 	if req.Type != "NS" {
-		nsRecords := dns.QueryDNS(serverKey, apexFQDN, dnslib.TypeNS)
+		nsRecords, _ := lookup(response, serverKey, req.Transport, apexFQDN, dnslib.TypeNS)
 		for _, record := range nsRecords {
 			if nsRec, ok := record.(models.DNSRecord); ok && nsRec.Type == "NS" {
 				response.Data.Nameservers = append(response.Data.Nameservers, models.NameserverInfo{
@@ -491,8 +677,9 @@ func handleSpecificRecord(c *gin.Context, serverKey string, req *models.DNSLooku
 
 	// 2. Resolve CNAME first (nếu record type không phải CNAME)
 	canonicalName := fqdn
-	if req.Type != "CNAME" && req.Type != "NS" && req.Type != "MX" {
-		cnameRecords := dns.QueryDNS(serverKey, fqdn, dnslib.TypeCNAME)
+	// SOA / DS belong to the zone cut itself, a CNAME can't coexist with them
+	if req.Type != "CNAME" && req.Type != "NS" && req.Type != "MX" && req.Type != "SOA" && req.Type != "DS" {
+		cnameRecords, _ := lookup(response, serverKey, req.Transport, fqdn, dnslib.TypeCNAME)
 		if len(cnameRecords) > 0 {
 			if cnameRec, ok := cnameRecords[0].(models.DNSRecord); ok && cnameRec.Type == "CNAME" {
 				// Add CNAME record với domain gốc
@@ -505,21 +692,8 @@ func handleSpecificRecord(c *gin.Context, serverKey string, req *models.DNSLooku
 	}
 
 	// 3. Query requested record type
-	var dnsType uint16
-	switch req.Type {
-	case "A":
-		dnsType = dnslib.TypeA
-	case "AAAA":
-		dnsType = dnslib.TypeAAAA
-	case "NS":
-		dnsType = dnslib.TypeNS
-	case "MX":
-		dnsType = dnslib.TypeMX
-	case "CNAME":
-		dnsType = dnslib.TypeCNAME
-	case "TXT":
-		dnsType = dnslib.TypeTXT
-	default:
+	dnsType, err := dns.ToQType(req.Type)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": "Loại bản ghi không hợp lệ",
@@ -530,19 +704,20 @@ func handleSpecificRecord(c *gin.Context, serverKey string, req *models.DNSLooku
 	// Query trên canonical name (hoặc original nếu không có CNAME)
 	queryTarget := canonicalName
 	switch req.Type {
-	case "CNAME", "MX":
-		queryTarget = fqdn // CNAME, MX luôn query trên original domain
-	case "NS":
-		queryTarget = apexFQDN // NS luôn query trên apex domain
+	case "CNAME", "MX", "DS":
+		queryTarget = fqdn // CNAME, MX, DS luôn query trên original domain
+	case "NS", "SOA":
+		queryTarget = apexFQDN // NS, SOA luôn query trên apex domain
 	}
 
-	queriedRecords := dns.QueryDNS(serverKey, queryTarget, dnsType)
+	queriedRecords, queryInfo := lookup(response, serverKey, req.Transport, queryTarget, dnsType)
+	setTransport(response, req.Transport, queryInfo)
 
 	// 🔄 SMART FALLBACK: If Google returns only 1 A/AAAA record, retry with Cloudflare
 	// This bypasses GeoDNS limitations and provides better results for the user
 	if (req.Type == "A" || req.Type == "AAAA") && len(queriedRecords) == 1 && serverKey == "google" {
 		fmt.Printf("[INFO] Google returned only 1 %s record, retrying with Cloudflare for completeness\n", req.Type)
-		cloudflareRecords := dns.QueryDNS("cloudflare", queryTarget, dnsType)
+		cloudflareRecords, _ := lookup(response, "cloudflare", req.Transport, queryTarget, dnsType)
 		if len(cloudflareRecords) > 1 {
 			queriedRecords = cloudflareRecords
 			response.Data.Query.Server = "cloudflare" // Update to show which server provided the data
@@ -557,26 +732,32 @@ func handleSpecificRecord(c *gin.Context, serverKey string, req *models.DNSLooku
 			case "CNAME":
 				// CNAME record hiển thị original domain
 				rec.Domain = originalDomain
-			case "A", "AAAA", "TXT":
-				// A/AAAA/TXT records hiển thị canonical name
+			case "MX", "DS":
+				// MX, DS records query trên original domain
+				rec.Domain = originalDomain
+			case "NS", "SOA":
+				// NS, SOA records query trên apex domain
+				rec.Domain = apexDomain
+			default:
+				// A/AAAA/TXT/CAA/SRV/... records hiển thị canonical name
 				rec.Domain = strings.TrimSuffix(canonicalName, ".")
-			case "MX", "NS":
-				// MX records query trên original domain
-				// NS records query trên apex domain
-				if rec.Type == "NS" {
-					rec.Domain = apexDomain
-				} else {
-					rec.Domain = originalDomain
-				}
 			}
 			records = append(records, rec)
 		default:
 			records = append(records, record)
 		}
 	}
+	// NS: hỏi trực tiếp từng máy chủ authoritative của apex
+	if req.Type == "NS" {
+		response.Data.NSCheck = dns.CheckNameservers(serverKey, req.Transport, apexFQDN)
+	}
+
 	if len(records) == 0 {
+		// Vùng có ký DNSSEC: hiển thị bằng chứng NSEC/NSEC3 cho việc không tồn tại
+		response.Data.Denial = dns.AnalyzeDenial(serverKey, req.Transport, queryTarget, dnsType)
+
 		response.Success = true
-		response.Message = "Không tìm thấy bản ghi DNS cho loại truy vấn này"
+		response.Message = emptyMessage(queryInfo)
 		c.JSON(http.StatusOK, response)
 		return
 	}
@@ -595,18 +776,16 @@ func sendSSE(c *gin.Context, payload interface{}) {
 	c.Writer.Flush()
 }
 
+// HandleBlacklistStream checks an IPv4 / IPv6 address, or a domain with
+// its MX and A hosts. ?server= picks the resolver for the domain lookups.
 func HandleBlacklistStream(c *gin.Context) {
-	ip := c.Param("ip")
-
-	parsedIP := net.ParseIP(ip)
-	if parsedIP == nil || parsedIP.To4() == nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "Invalid IPv4 address",
-		})
+	input, ok := blacklistInput(c)
+	if !ok {
 		return
 	}
 
+	serverKey := c.DefaultQuery("server", "cloudflare")
+
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
@@ -622,8 +801,78 @@ func HandleBlacklistStream(c *gin.Context) {
 	}
 
 	// Stream events from DNS engine
-	dns.StreamBlacklist(ip, func(e models.BlacklistStreamEvent) {
+	dns.StreamBlacklist(serverKey, input, func(e models.BlacklistStreamEvent) {
 		sendSSE(c, e)
 		flusher.Flush()
 	})
 }
+
+// HandleBlacklistProviders lists the blocklists with their probe state.
+func HandleBlacklistProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    dns.DefaultRBLRegistry.Health(),
+	})
+}
+
+// blacklistInput is the IP / domain of a blacklist route, answering 400
+// when it is neither.
+func blacklistInput(c *gin.Context) (string, bool) {
+	input := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(c.Param("ip")), "."))
+
+	if !isIPAddress(input) && !validator.IsValidDomain(input) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid IP address or domain",
+		})
+		return "", false
+	}
+
+	return input, true
+}
+
+// HandleBlacklistReport checks an IP / domain and exports the report:
+// ?format=json (default) | csv | html (printable, save as PDF).
+func HandleBlacklistReport(c *gin.Context) {
+	input, ok := blacklistInput(c)
+	if !ok {
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "json"))
+	if format != "json" && format != "csv" && format != "html" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Định dạng báo cáo không hợp lệ (json, csv, html)",
+		})
+		return
+	}
+
+	report := dns.ReportBlacklist(c.DefaultQuery("server", "cloudflare"), input)
+
+	filename := fmt.Sprintf("blacklist-%s-%s.%s",
+		strings.NewReplacer(":", "-", "/", "-").Replace(input), report.CheckedAt.Format("20060102-150405"), format)
+
+	var err error
+	switch format {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    report,
+		})
+		return
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		err = dns.WriteBlacklistCSV(c.Writer, report)
+	case "html":
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, filename))
+		err = dns.WriteBlacklistHTML(c.Writer, report)
+	}
+
+	if err != nil {
+		// Headers are sent, the client went away mid-report
+		c.Error(err)
+	}
+}
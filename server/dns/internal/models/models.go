@@ -10,6 +10,15 @@ type DNSLookupRequest struct {
 	Hostname string `json:"hostname" binding:"required"`
 	Type     string `json:"type" binding:"required"`
 	Server   string `json:"server" binding:"required"`
+
+	// doh (default) | udp | tcp | dot | doq
+	Transport string `json:"transport,omitempty"`
+
+	// Record type of a PROPAGATION / TRACE lookup (A by default)
+	RecordType string `json:"recordType,omitempty"`
+
+	// DKIM selectors of a MAIL audit (common selectors when empty)
+	Selectors []string `json:"selectors,omitempty"`
 }
 
 type DNSRecord struct {
@@ -22,6 +31,19 @@ type DNSRecord struct {
 	Value      string `json:"value,omitempty"`
 	TTL        uint32 `json:"ttl,omitempty"`
 
+	// TXT character-strings as published; Value joins them with spaces
+	TXTStrings []string `json:"txtStrings,omitempty"`
+
+	// Structured data, set only for the matching record type
+	SOA   *SOAData   `json:"soa,omitempty"`
+	CAA   *CAAData   `json:"caa,omitempty"`
+	SRV   *SRVData   `json:"srv,omitempty"`
+	TLSA  *TLSAData  `json:"tlsa,omitempty"`
+	SVCB  *SVCBData  `json:"svcb,omitempty"` // HTTPS and SVCB
+	NAPTR *NAPTRData `json:"naptr,omitempty"`
+	DS    *DSData    `json:"ds,omitempty"`
+	SSHFP *SSHFPData `json:"sshfp,omitempty"`
+
 	// GeoIP (optional)
 	Country     string `json:"country,omitempty"`
 	CountryCode string `json:"countryCode,omitempty"`
@@ -29,6 +51,65 @@ type DNSRecord struct {
 	Org         string `json:"org,omitempty"`
 }
 
+type SOAData struct {
+	PrimaryNS string `json:"primaryNs"`
+	Mailbox   string `json:"mailbox"` // hostmaster.example.com
+	Serial    uint32 `json:"serial"`
+	Refresh   uint32 `json:"refresh"`
+	Retry     uint32 `json:"retry"`
+	Expire    uint32 `json:"expire"`
+	Minimum   uint32 `json:"minimum"` // negative caching TTL
+}
+
+type CAAData struct {
+	Flag     uint8  `json:"flag"`
+	Critical bool   `json:"critical"`
+	Tag      string `json:"tag"` // issue | issuewild | iodef | ...
+	Value    string `json:"value"`
+}
+
+type SRVData struct {
+	Priority uint16 `json:"priority"`
+	Weight   uint16 `json:"weight"`
+	Port     uint16 `json:"port"`
+	Target   string `json:"target"`
+}
+
+type TLSAData struct {
+	Usage        uint8  `json:"usage"`    // 0 PKIX-TA, 1 PKIX-EE, 2 DANE-TA, 3 DANE-EE
+	Selector     uint8  `json:"selector"` // 0 full cert, 1 SubjectPublicKeyInfo
+	MatchingType uint8  `json:"matchingType"`
+	Certificate  string `json:"certificate"` // hex
+}
+
+type SVCBData struct {
+	Priority uint16            `json:"priority"` // 0 = alias mode
+	Target   string            `json:"target"`
+	Params   map[string]string `json:"params,omitempty"` // alpn, port, ipv4hint, ech, ...
+}
+
+type NAPTRData struct {
+	Order       uint16 `json:"order"`
+	Preference  uint16 `json:"preference"`
+	Flags       string `json:"flags"`
+	Service     string `json:"service"`
+	Regexp      string `json:"regexp"`
+	Replacement string `json:"replacement"`
+}
+
+type DSData struct {
+	KeyTag     uint16 `json:"keyTag"`
+	Algorithm  uint8  `json:"algorithm"`
+	DigestType uint8  `json:"digestType"`
+	Digest     string `json:"digest"`
+}
+
+type SSHFPData struct {
+	Algorithm   uint8  `json:"algorithm"` // 1 RSA, 2 DSA, 3 ECDSA, 4 Ed25519
+	Type        uint8  `json:"type"`      // 1 SHA-1, 2 SHA-256
+	Fingerprint string `json:"fingerprint"`
+}
+
 // =======================
 // DNSSEC
 // =======================
@@ -58,6 +139,61 @@ type DNSSECInfo struct {
 	Status  string         `json:"status"` // SECURE | BOGUS | INSECURE | ERROR
 	Message string         `json:"message,omitempty"`
 	Records []DNSSECRecord `json:"records,omitempty"`
+
+	// Chain of trust from the root down, one link per zone
+	Chain []DNSSECLink `json:"chain,omitempty"`
+
+	// Where validation stopped (BOGUS / INSECURE only)
+	BrokenAt   string `json:"brokenAt,omitempty"`   // zone or owner name
+	BrokenStep string `json:"brokenStep,omitempty"` // DS | DNSKEY | RRSIG | NSEC
+
+	// Proof of non-existence when the name / type has no records
+	Denial *DenialInfo `json:"denial,omitempty"`
+}
+
+// DNSSECLink is one zone of the chain of trust.
+type DNSSECLink struct {
+	Zone    string   `json:"zone"`
+	Status  string   `json:"status"` // SECURE | BOGUS | INSECURE
+	Step    string   `json:"step,omitempty"`
+	KeyTags []uint16 `json:"keyTags,omitempty"` // keys that signed the DNSKEY set
+	Message string   `json:"message,omitempty"`
+}
+
+// DenialInfo explains why a name / type has no records: the NSEC or NSEC3
+// records the zone returned as proof, and whether they validate.
+type DenialInfo struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Result  string `json:"result"` // NXDOMAIN | NODATA | WILDCARD
+	Method  string `json:"method"` // NSEC | NSEC3 | NONE
+	Valid   bool   `json:"valid"`
+	Message string `json:"message,omitempty"`
+
+	Records []DenialRecord `json:"records,omitempty"`
+	NSEC3   *NSEC3Params   `json:"nsec3,omitempty"`
+
+	// NSEC chains can be walked to list every name of the zone
+	Walkable bool     `json:"walkable"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// DenialRecord is one NSEC / NSEC3 record of the proof. For NSEC3, Owner
+// and Next are hashes.
+type DenialRecord struct {
+	Type           string   `json:"type"` // NSEC | NSEC3
+	Owner          string   `json:"owner"`
+	Next           string   `json:"next"`
+	Types          []string `json:"types,omitempty"`
+	Proves         []string `json:"proves,omitempty"` // nodata | name | wildcard | closest_encloser | next_closer | opt_out
+	SignatureValid bool     `json:"signatureValid"`
+}
+
+type NSEC3Params struct {
+	HashAlgorithm uint8  `json:"hashAlgorithm"` // 1 = SHA-1
+	Iterations    uint16 `json:"iterations"`
+	Salt          string `json:"salt,omitempty"` // hex, empty when "-"
+	OptOut        bool   `json:"optOut"`
 }
 
 // =======================
@@ -70,6 +206,191 @@ type NameserverInfo struct {
 	Domain     string `json:"domain,omitempty"`
 }
 
+// =======================
+// Mail audit
+// =======================
+
+// MailFinding is one pass / warn / fail result of a mail check.
+type MailFinding struct {
+	Status  string `json:"status"` // pass | warn | fail
+	Message string `json:"message"`
+}
+
+// MailCheck is the outcome of one check: its worst finding and all of them.
+type MailCheck struct {
+	Status   string        `json:"status"`
+	Findings []MailFinding `json:"findings"`
+}
+
+type SPFInclude struct {
+	Domain  string `json:"domain"`
+	Record  string `json:"record,omitempty"`
+	Depth   int    `json:"depth"`
+	Lookups int    `json:"lookups"` // lookups of this record alone
+	Error   string `json:"error,omitempty"`
+}
+
+type SPFResult struct {
+	MailCheck
+	Record     string       `json:"record,omitempty"`
+	Mechanisms []string     `json:"mechanisms,omitempty"`
+	All        string       `json:"all,omitempty"` // -all | ~all | ?all | +all
+	Lookups    int          `json:"lookups"`       // DNS lookups, includes expanded (max 10)
+	Includes   []SPFInclude `json:"includes,omitempty"`
+}
+
+type DKIMResult struct {
+	MailCheck
+	Selector string `json:"selector"`
+	Record   string `json:"record,omitempty"`
+	KeyType  string `json:"keyType,omitempty"` // rsa | ed25519
+	KeyBits  int    `json:"keyBits,omitempty"`
+	Testing  bool   `json:"testing,omitempty"` // t=y
+}
+
+type DKIMCheck struct {
+	MailCheck
+	Tried []string     `json:"tried"` // selectors queried
+	Keys  []DKIMResult `json:"keys"`  // selectors that publish a key
+}
+
+type DMARCResult struct {
+	MailCheck
+	Record  string   `json:"record,omitempty"`
+	Domain  string   `json:"domain,omitempty"` // where the record was found (organizational domain fallback)
+	Policy  string   `json:"policy,omitempty"` // none | quarantine | reject
+	SubPol  string   `json:"subdomainPolicy,omitempty"`
+	Percent int      `json:"pct"`
+	ADKIM   string   `json:"adkim"` // r | s alignment
+	ASPF    string   `json:"aspf"`
+	RUA     []string `json:"rua,omitempty"`
+	RUF     []string `json:"ruf,omitempty"`
+}
+
+type MTASTSResult struct {
+	MailCheck
+	Record string   `json:"record,omitempty"`
+	ID     string   `json:"id,omitempty"`
+	Mode   string   `json:"mode,omitempty"` // enforce | testing | none
+	MX     []string `json:"mx,omitempty"`
+	MaxAge int      `json:"maxAge,omitempty"`
+}
+
+type TLSRPTResult struct {
+	MailCheck
+	Record string   `json:"record,omitempty"`
+	RUA    []string `json:"rua,omitempty"`
+}
+
+type BIMIResult struct {
+	MailCheck
+	Record    string `json:"record,omitempty"`
+	Logo      string `json:"logo,omitempty"`      // l= SVG URL
+	Authority string `json:"authority,omitempty"` // a= VMC URL
+}
+
+type MailAudit struct {
+	Domain string `json:"domain"`
+	Status string `json:"status"` // worst status of all checks
+
+	SPF    SPFResult    `json:"spf"`
+	DKIM   DKIMCheck    `json:"dkim"`
+	DMARC  DMARCResult  `json:"dmarc"`
+	MTASTS MTASTSResult `json:"mtaSts"`
+	TLSRPT TLSRPTResult `json:"tlsRpt"`
+	BIMI   BIMIResult   `json:"bimi"`
+}
+
+// =======================
+// Trace
+// =======================
+
+// TraceStep is one query of an iterative resolution, from the root down.
+type TraceStep struct {
+	Zone          string `json:"zone"`    // zone whose nameserver was asked
+	Server        string `json:"server"`  // nameserver name
+	Address       string `json:"address"` // ip:port
+	Query         string `json:"query"`   // name and type asked
+	Rcode         string `json:"rcode,omitempty"`
+	Authoritative bool   `json:"authoritative"`
+	LatencyMs     int64  `json:"latencyMs"`
+	Error         string `json:"error,omitempty"`
+
+	// Delegation to the next zone
+	NextZone string   `json:"nextZone,omitempty"`
+	Referral []string `json:"referral,omitempty"` // NS names
+	Glue     []string `json:"glue,omitempty"`     // glue A / AAAA
+
+	Answers []string `json:"answers,omitempty"`
+	DNSSEC  []string `json:"dnssec,omitempty"` // DS, RRSIG, NSEC, NSEC3
+}
+
+type TraceInfo struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	Complete bool        `json:"complete"` // an authoritative server gave the final answer
+	Rcode    string      `json:"rcode,omitempty"`
+	Message  string      `json:"message,omitempty"`
+	Steps    []TraceStep `json:"steps"`
+}
+
+// =======================
+// Authoritative nameservers
+// =======================
+
+// AuthServer is one address of an authoritative nameserver of the zone.
+type AuthServer struct {
+	Nameserver  string `json:"nameserver"`
+	Address     string `json:"address,omitempty"`
+	InBailiwick bool   `json:"inBailiwick"` // the NS name is inside the zone, glue is required
+	Glue        bool   `json:"glue"`        // address came from the parent referral
+
+	Authoritative bool   `json:"authoritative"` // AA set on the SOA answer
+	Lame          bool   `json:"lame"`
+	Rcode         string `json:"rcode,omitempty"`
+	Serial        uint32 `json:"serial,omitempty"`
+	Error         string `json:"error,omitempty"`
+	LatencyMs     int64  `json:"latencyMs"`
+
+	ASN   uint   `json:"asn,omitempty"`
+	ASOrg string `json:"asOrg,omitempty"`
+}
+
+// RecordSetAnswer is the set returned by some nameservers.
+type RecordSetAnswer struct {
+	Values  []string `json:"values"`
+	Servers []string `json:"servers"` // nameserver (address)
+}
+
+// RecordSetCheck compares one record type across the nameservers.
+type RecordSetCheck struct {
+	Type       string            `json:"type"`
+	Consistent bool              `json:"consistent"`
+	Answers    []RecordSetAnswer `json:"answers"`
+}
+
+type NameserverIssue struct {
+	Severity string   `json:"severity"` // error | warning
+	Code     string   `json:"code"`     // lame | serial_mismatch | ns_mismatch | missing_glue | ...
+	Message  string   `json:"message"`
+	Servers  []string `json:"servers,omitempty"`
+}
+
+type NameserverCheck struct {
+	Zone   string `json:"zone"`
+	Parent string `json:"parent"`
+
+	ParentNS []string `json:"parentNs"` // delegation at the parent
+	ChildNS  []string `json:"childNs"`  // NS RRset served by the zone
+
+	Servers []AuthServer      `json:"servers"`
+	Records []RecordSetCheck  `json:"records"`
+	Issues  []NameserverIssue `json:"issues"`
+
+	// No error-level issue
+	Healthy bool `json:"healthy"`
+}
+
 // =======================
 // Blacklist
 // =======================
@@ -78,8 +399,53 @@ type BlacklistRecord struct {
 	Provider string `json:"provider"`
 	Type     string `json:"type"` // BLACKLIST
 	Level    string `json:"level"`
-	Status   string `json:"status"` // OK | LISTED
+	Status   string `json:"status"` // OK | LISTED | TIMEOUT | ERROR_BLOCKED
 	IP       string `json:"ip"`
+	Host     string `json:"host,omitempty"`   // host the IP belongs to (domain input)
+	Domain   string `json:"domain,omitempty"` // set for domain blocklists
+
+	Listings []RBLListing `json:"listings,omitempty"`
+	Reason   string       `json:"reason,omitempty"` // TXT record of the listing
+
+	Weight    float64   `json:"weight"` // score weight of the provider
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// BlacklistScore is the weighted reputation of a blacklist input.
+type BlacklistScore struct {
+	Score   int     `json:"score"`   // 0-100: 100 - % of Weight listing the input, at most 94 on a High list
+	Rating  string  `json:"rating"`  // clean | fair | poor | bad | unknown (no list answered)
+	Penalty float64 `json:"penalty"` // weights of the lists listing it
+	Weight  float64 `json:"weight"`  // weights of the lists that answered
+}
+
+// BlacklistReport is the exportable result of a blacklist check.
+type BlacklistReport struct {
+	Input     string            `json:"input"`
+	CheckedAt time.Time         `json:"checkedAt"`
+	Targets   []BlacklistTarget `json:"targets"`
+	Checked   int               `json:"checked"` // lists that answered
+	Listed    int               `json:"listed"`
+	Total     int               `json:"total"`
+	Score     BlacklistScore    `json:"score"`
+	Listings  []BlacklistRecord `json:"listings"` // LISTED records, heaviest first
+	Records   []BlacklistRecord `json:"records"`  // every query
+}
+
+// RBLListing is one decoded return code of a blocklist answer.
+type RBLListing struct {
+	Code    string `json:"code"` // 127.0.0.x
+	List    string `json:"list,omitempty"`
+	Meaning string `json:"meaning,omitempty"`
+	Removal string `json:"removal,omitempty"` // delisting page
+}
+
+// BlacklistTarget is one address or domain checked for a blacklist input.
+type BlacklistTarget struct {
+	IP     string `json:"ip,omitempty"`
+	Domain string `json:"domain,omitempty"`
+	Host   string `json:"host,omitempty"`
+	Source string `json:"source"` // INPUT | A | AAAA | MX | DOMAIN
 }
 
 type BlacklistSummary struct {
@@ -102,11 +468,74 @@ type BlacklistEvent struct {
 type BlacklistStreamEvent struct {
 	Type     string `json:"type"`     // BLACKLIST | BLACKLIST_SUMMARY
 	Provider string `json:"provider"` // rbl host
-	Status   string `json:"status"`   // OK | LISTED | TIMEOUT
+	Status   string `json:"status"`   // OK | LISTED | TIMEOUT | ERROR_BLOCKED
 	Level    string `json:"level,omitempty"`
 	IP       string `json:"ip,omitempty"`
+	Host     string `json:"host,omitempty"`
+	Domain   string `json:"domain,omitempty"`
 	Listed   int    `json:"listed"`
 	Total    int    `json:"total"`
+
+	Listings []RBLListing `json:"listings,omitempty"`
+	Reason   string       `json:"reason,omitempty"`
+
+	// BLACKLIST_INIT: what is being checked
+	Targets []BlacklistTarget `json:"targets,omitempty"`
+
+	// BLACKLIST_SUMMARY
+	Score *BlacklistScore `json:"score,omitempty"`
+}
+
+// =======================
+// Propagation
+// =======================
+
+// PropagationResolver is a public resolver the propagation check asks
+// besides the registered providers.
+type PropagationResolver struct {
+	Name   string `json:"name"`
+	Region string `json:"region"`
+	Server string `json:"server"` // ip:port
+}
+
+// PropagationAnswer is what one resolver returned.
+type PropagationAnswer struct {
+	Resolver  string   `json:"resolver"` // provider key or regional resolver name
+	Region    string   `json:"region,omitempty"`
+	Transport string   `json:"transport"`
+	Rcode     string   `json:"rcode,omitempty"`
+	Values    []string `json:"values"`        // sorted record data
+	TTL       uint32   `json:"ttl,omitempty"` // lowest TTL of the answer
+	LatencyMs int64    `json:"latencyMs"`
+	Error     string   `json:"error,omitempty"`
+
+	// false when the answer differs from the majority
+	Agrees bool `json:"agrees"`
+}
+
+// PropagationGroup is a set of resolvers with the same answer.
+type PropagationGroup struct {
+	Rcode     string   `json:"rcode"`
+	Values    []string `json:"values"`
+	Resolvers []string `json:"resolvers"`
+	Majority  bool     `json:"majority"`
+}
+
+type PropagationInfo struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// Every resolver that answered agrees
+	Consistent bool `json:"consistent"`
+
+	Total    int `json:"total"`
+	Answered int `json:"answered"`
+	Agreeing int `json:"agreeing"`
+	Failed   int `json:"failed"`
+
+	Groups      []PropagationGroup  `json:"groups"`
+	Answers     []PropagationAnswer `json:"answers"`
+	Disagreeing []string            `json:"disagreeing,omitempty"`
 }
 
 // =======================
@@ -120,17 +549,79 @@ type DNSLookupResponse struct {
 		Records     []interface{}    `json:"records"` // DNSRecord | Blacklist*
 		Nameservers []NameserverInfo `json:"nameservers,omitempty"`
 		DNSSEC      *DNSSECInfo      `json:"dnssec,omitempty"`
+		Denial      *DenialInfo      `json:"denial,omitempty"`
+
+		Propagation *PropagationInfo `json:"propagation,omitempty"`
+		NSCheck     *NameserverCheck `json:"nsCheck,omitempty"`
+		Trace       *TraceInfo       `json:"trace,omitempty"`
+		Mail        *MailAudit       `json:"mail,omitempty"`
+
+		// One entry per query sent (record type + name)
+		Responses []ResponseInfo `json:"responses,omitempty"`
 	} `json:"data"`
 	Message string `json:"message,omitempty"`
 }
 
+// ResponseInfo is how the resolver answered one query: RCODE, header
+// flags, transport error and latency.
+type ResponseInfo struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Server    string `json:"server"`
+	Transport string `json:"transport"`
+	Fallback  bool   `json:"fallback,omitempty"`
+
+	Rcode string `json:"rcode,omitempty"` // NOERROR | NXDOMAIN | SERVFAIL | REFUSED | ...
+	AD    bool   `json:"ad"`              // authenticated data (DNSSEC validated)
+	TC    bool   `json:"tc"`              // truncated
+	RA    bool   `json:"ra"`              // recursion available
+
+	// Transport failure of the requested transport (timeout, TLS, HTTP, ...)
+	Error string `json:"error,omitempty"`
+
+	LatencyMs int64 `json:"latencyMs"`
+	Answers   int   `json:"answers"`
+}
+
 // =======================
 // Misc
 // =======================
 
 type RBLProvider struct {
-	Host  string
-	Level string
+	Host   string  `json:"host" yaml:"host"`
+	Level  string  `json:"level" yaml:"level"`         // High | Medium | Low
+	Type   string  `json:"type" yaml:"type"`           // ip | domain
+	Weight float64 `json:"weight" yaml:"weight"`       // score weight, from Level when 0
+	IPv6   bool    `json:"ipv6,omitempty" yaml:"ipv6"` // answers nibble-reversed IPv6 queries
+
+	// Return codes (127.0.0.x) of the list; with Bitmask the last octet
+	// is a sum of the codes (URIBL / SURBL multi)
+	Codes   map[string]RBLReturnCode `json:"codes,omitempty" yaml:"codes"`
+	Bitmask bool                     `json:"bitmask,omitempty" yaml:"bitmask"`
+	Removal string                   `json:"removal,omitempty" yaml:"removal"` // delisting page when the code has none
+
+	// Entry the list always has (127.0.0.2 / "test" when empty, RFC 5782 5)
+	Test     string `json:"test,omitempty" yaml:"test"`
+	Disabled bool   `json:"disabled,omitempty" yaml:"disabled"`
+}
+
+type RBLReturnCode struct {
+	List    string `json:"list,omitempty" yaml:"list"` // sub-list
+	Meaning string `json:"meaning,omitempty" yaml:"meaning"`
+	Removal string `json:"removal,omitempty" yaml:"removal"`
+	Error   bool   `json:"error,omitempty" yaml:"error"` // not a listing: query refused / blocked
+}
+
+// RBLHealth is the probe state of a provider of the registry.
+type RBLHealth struct {
+	Host      string    `json:"host"`
+	Type      string    `json:"type"`
+	Level     string    `json:"level"`
+	Enabled   bool      `json:"enabled"`
+	State     string    `json:"state"` // unknown | ok | blocked | dead | listing_all | disabled
+	Failures  int       `json:"failures,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt,omitempty"`
 }
 
 type QueryInfo struct {
@@ -138,4 +629,8 @@ type QueryInfo struct {
 	Type        string `json:"type"`
 	Server      string `json:"server"`
 	IsSubdomain bool   `json:"isSubdomain"`
+
+	// Transport that answered; Fallback when it isn't the requested one
+	Transport string `json:"transport,omitempty"`
+	Fallback  bool   `json:"fallback,omitempty"`
 }
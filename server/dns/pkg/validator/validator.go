@@ -14,6 +14,13 @@ var domainRegex = regexp.MustCompile(
 	`^([a-zA-Z0-9]([a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]{2,}$`,
 )
 
+// DomainRecordTypes are the lookup types accepted for a domain name.
+var DomainRecordTypes = []string{
+	"A", "AAAA", "NS", "MX", "CNAME", "TXT",
+	"SOA", "CAA", "SRV", "TLSA", "HTTPS", "SVCB", "NAPTR", "DS", "SSHFP",
+	"DNSSEC", "PROPAGATION", "TRACE", "MAIL", "ALL",
+}
+
 type InputType int
 
 const (
@@ -51,7 +58,7 @@ func ValidateAndDetect(input string) ValidationResult {
 			Valid:      true,
 			Type:       InputTypeIPv4,
 			Input:      input,
-			ValidTypes: []string{"PTR", "BLACKLIST", "ALL"},
+			ValidTypes: []string{"PTR", "BLACKLIST", "TRACE", "ALL"},
 		}
 	}
 
@@ -61,7 +68,7 @@ func ValidateAndDetect(input string) ValidationResult {
 			Valid:      true,
 			Type:       InputTypeIPv6,
 			Input:      input,
-			ValidTypes: []string{"PTR", "ALL"},
+			ValidTypes: []string{"PTR", "TRACE", "ALL"},
 		}
 	}
 
@@ -71,7 +78,7 @@ func ValidateAndDetect(input string) ValidationResult {
 			Valid:      true,
 			Type:       InputTypeDomain,
 			Input:      input,
-			ValidTypes: []string{"A", "AAAA", "NS", "MX", "CNAME", "TXT", "DNSSEC", "ALL"},
+			ValidTypes: DomainRecordTypes,
 		}
 	}
 
@@ -153,7 +160,7 @@ func IsValidDomain(domain string) bool {
 // IsValidRecordType checks if the record type is valid for the input type
 func IsValidRecordType(inputType InputType, recordType string) bool {
 	validTypes := map[InputType][]string{
-		InputTypeDomain: {"A", "AAAA", "NS", "MX", "CNAME", "TXT", "DNSSEC", "ALL"},
+		InputTypeDomain: DomainRecordTypes,
 		InputTypeIPv4:   {"PTR", "BLACKLIST", "ALL"},
 		InputTypeIPv6:   {"PTR", "ALL"},
 	}
@@ -177,7 +184,7 @@ func IsValidRecordType(inputType InputType, recordType string) bool {
 func GetSuggestedRecordTypes(inputType InputType) []string {
 	switch inputType {
 	case InputTypeDomain:
-		return []string{"A", "AAAA", "NS", "MX", "CNAME", "TXT", "DNSSEC", "ALL"}
+		return DomainRecordTypes
 	case InputTypeIPv4:
 		return []string{"PTR", "BLACKLIST"}
 	case InputTypeIPv6:
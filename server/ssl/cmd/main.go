@@ -25,7 +25,7 @@ func main() {
 	server := &http.Server{
 		Addr:           config.ServerPort,
 		ReadTimeout:    15 * time.Second,
-		WriteTimeout:   15 * time.Second,
+		WriteTimeout:   config.FullScanTimeout + 15*time.Second, // full scans run long
 		IdleTimeout:    60 * time.Second,
 		MaxHeaderBytes: 1 << 20, // 1MB
 	}
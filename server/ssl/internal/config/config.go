@@ -14,6 +14,85 @@ const (
 	OCSPCheckTimeout = 5 * time.Second
 	TLSScanTimeout   = 6 * time.Second
 	ContextTimeout   = 10 * time.Second
+
+	// CRLs can be several MB, give them more time than an OCSP request
+	CRLFetchTimeout = 10 * time.Second
+
+	// Full scan (protocol / cipher enumeration)
+	ProtocolProbeTimeout = 4 * time.Second
+	FullScanTimeout      = 45 * time.Second
+)
+
+// Scan modes
+const (
+	ScanModeQuick = "quick"
+	ScanModeFull  = "full"
+)
+
+// Revocation checking
+const (
+	MaxOCSPResponseSize = 64 << 10 // 64KB
+	MaxCRLSize          = 20 << 20 // 20MB
+
+	// How far an OCSP response's thisUpdate may be ahead of our clock
+	OCSPClockSkew = 5 * time.Minute
+)
+
+// Certificate Transparency log list (Chrome's v3 schema)
+const (
+	CTLogListURL             = "https://www.gstatic.com/ct/log_list/v3/log_list.json"
+	CTLogListRefreshInterval = 24 * time.Hour
+	CTLogListFetchTimeout    = 15 * time.Second
+	MaxCTLogListSize         = 5 << 20 // 5MB
+
+	// Certificates valid longer than this need 3 embedded SCTs instead of 2
+	CTLongLivedCertDays = 180
+)
+
+// Multi-endpoint scans (every A / AAAA record)
+const (
+	MaxScanEndpoints = 16
+)
+
+// Certificate monitoring (watch list + scheduled rescans)
+const (
+	MonitorRescanInterval     = 24 * time.Hour
+	MonitorSchedulerTick      = time.Minute
+	MonitorScanTimeout        = 30 * time.Second
+	MonitorScanConcurrency    = 4
+	MonitorHistoryLimit       = 90  // results kept per watch
+	MonitorAlertLimit         = 500 // most recent alerts kept
+	MaxMonitorWatches         = 1000
+	MaxMonitorWatchesPerOwner = 50 // per access token
+
+	// Access tokens created per client IP, so one client can't rotate
+	// tokens to fill MaxMonitorWatches
+	MonitorTokenRateLimitRequests = 3
+	MonitorTokenRateLimitWindow   = time.Hour
+
+	// DNS / blacklist status from the DNS service (MONITOR_DNS_SERVICE_URL)
+	MonitorDNSTimeout   = 60 * time.Second
+	MaxMonitorDNSReport = 4 << 20 // 4MB
+)
+
+// Notifications (webhook / Slack / email)
+const (
+	NotifyTimeout        = 10 * time.Second
+	NotifyMaxAttempts    = 5
+	NotifyInitialBackoff = 2 * time.Second
+	NotifyMaxBackoff     = time.Minute
+	NotifyQueueSize      = 256
+)
+
+// Batch checks (/api/ssl/check/batch)
+const (
+	MaxBatchDomains  = 500
+	BatchConcurrency = 8
+	MaxBatchBodySize = 1 << 20 // 1MB (JSON list or CSV upload)
+
+	// A batch is expensive: allowed a few times per minute per client IP
+	BatchRateLimitRequests = 3
+	BatchRateLimitWindow   = time.Minute
 )
 
 // Cache configuration
@@ -28,6 +107,12 @@ const (
 	RateLimitWindow   = time.Second
 )
 
+// fresh=true (cache bypass) has its own, stricter limit per client IP
+const (
+	FreshRateLimitRequests = 3
+	FreshRateLimitWindow   = time.Minute
+)
+
 // Rate limiter advanced configuration (PRO)
 const (
 
@@ -50,12 +135,39 @@ const (
 	CircuitBreakerCleanupWindow = 20 * time.Minute
 )
 
+// Connection protocols. Everything except https runs a plaintext
+// upgrade (STARTTLS, SSLRequest, AUTH TLS...) before the TLS handshake.
+const (
+	ProtocolHTTPS    = "https"
+	ProtocolTLS      = "tls" // implicit TLS on any port (465, 993, 995, 636...)
+	ProtocolSMTP     = "smtp"
+	ProtocolIMAP     = "imap"
+	ProtocolPOP3     = "pop3"
+	ProtocolLDAP     = "ldap"
+	ProtocolPostgres = "postgres"
+	ProtocolFTP      = "ftp"
+	ProtocolXMPP     = "xmpp"
+)
+
+var DefaultPorts = map[string]int{
+	ProtocolHTTPS:    443,
+	ProtocolTLS:      443,
+	ProtocolSMTP:     25,
+	ProtocolIMAP:     143,
+	ProtocolPOP3:     110,
+	ProtocolLDAP:     389,
+	ProtocolPostgres: 5432,
+	ProtocolFTP:      21,
+	ProtocolXMPP:     5222,
+}
+
 // TLS versions
 const (
 	TLSVersion13 = "TLS 1.3"
 	TLSVersion12 = "TLS 1.2"
 	TLSVersion11 = "TLS 1.1"
 	TLSVersion10 = "TLS 1.0"
+	TLSVersion30 = "SSL 3.0"
 )
 
 // Certificate status
@@ -63,6 +175,7 @@ const (
 	StatusOK       = "ok"
 	StatusWarning  = "warning"
 	StatusCritical = "critical"
+	StatusError    = "error" // the check itself failed
 )
 
 // Grades
@@ -74,6 +187,15 @@ const (
 	GradeF     = "F"
 )
 
+// Grading weights (%) and A+ requirements
+const (
+	GradeWeightProtocol    = 30
+	GradeWeightKeyExchange = 30
+	GradeWeightCipher      = 40
+
+	HSTSMinMaxAge = 180 * 24 * 60 * 60 // 6 months
+)
+
 // Domain validation
 const (
 	MaxDomainLength = 253
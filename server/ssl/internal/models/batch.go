@@ -0,0 +1,35 @@
+package models
+
+/* ===========================
+   Batch SSL Check
+=========================== */
+
+// BatchEvent is one line of the batch stream: a "result" per domain, then
+// a single "summary".
+type BatchEvent struct {
+	Type string `json:"type"` // result | summary
+
+	*BatchItem    `json:",omitempty"`
+	*BatchSummary `json:",omitempty"`
+}
+
+type BatchItem struct {
+	Index    int    `json:"index"` // position in the submitted list
+	Domain   string `json:"domain"`
+	Port     int    `json:"port,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+
+	Status string `json:"status"` // ok | warning | critical | error
+	Error  string `json:"error,omitempty"`
+
+	Result *SSLCheckResponse `json:"result,omitempty"`
+}
+
+type BatchSummary struct {
+	Total    int   `json:"total"`
+	OK       int   `json:"ok"`
+	Warning  int   `json:"warning"`
+	Critical int   `json:"critical"`
+	Errored  int   `json:"errored"`
+	Duration int64 `json:"duration_ms"`
+}
@@ -41,6 +41,12 @@ const (
 	// Hostname
 	TrustNameMismatch TrustCode = "name_mismatch" // 10
 
+	// Revocation (OCSP / CRL)
+	TrustRevoked TrustCode = "revoked" // 23
+
+	// Certificate Transparency (browser CT policy)
+	TrustCTPolicy TrustCode = "ct_policy"
+
 	// Fallback
 	TrustUnknown TrustCode = "unknown"
 )
@@ -57,6 +63,89 @@ const (
 	CertLevelUnknown      CertLevel = "Unknown"
 )
 
+/* ===========================
+   Revocation
+=========================== */
+
+type RevocationStatus string
+
+const (
+	RevocationGood    RevocationStatus = "good"
+	RevocationRevoked RevocationStatus = "revoked"
+	RevocationUnknown RevocationStatus = "unknown"
+)
+
+type RevocationSource string
+
+const (
+	RevocationSourceStapled RevocationSource = "ocsp_stapled"
+	RevocationSourceOCSP    RevocationSource = "ocsp"
+	RevocationSourceCRL     RevocationSource = "crl"
+)
+
+// RevocationInfo is the revocation status of one certificate and where it
+// came from. Error explains an unknown status.
+type RevocationInfo struct {
+	Status    RevocationStatus `json:"status"`
+	Source    RevocationSource `json:"source,omitempty"`
+	Responder string           `json:"responder,omitempty"` // OCSP URL or CRL distribution point
+
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Reason    string     `json:"reason,omitempty"` // e.g. keyCompromise
+
+	ThisUpdate *time.Time `json:"this_update,omitempty"`
+	NextUpdate *time.Time `json:"next_update,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+/* ===========================
+   Certificate Transparency
+=========================== */
+
+type SCTSource string
+
+const (
+	SCTSourceEmbedded SCTSource = "embedded"      // X.509 extension
+	SCTSourceTLS      SCTSource = "tls_extension" // signed_certificate_timestamp
+	SCTSourceOCSP     SCTSource = "ocsp"          // stapled OCSP response
+)
+
+type SCTStatus string
+
+const (
+	SCTValid      SCTStatus = "valid"
+	SCTInvalid    SCTStatus = "invalid"
+	SCTUnknownLog SCTStatus = "unknown_log"
+)
+
+type SCTInfo struct {
+	Source      SCTSource `json:"source"`
+	LogID       string    `json:"log_id"` // base64
+	LogName     string    `json:"log_name,omitempty"`
+	LogOperator string    `json:"log_operator,omitempty"`
+	LogState    string    `json:"log_state,omitempty"` // usable | readonly | retired ...
+	Timestamp   time.Time `json:"timestamp"`
+	Status      SCTStatus `json:"status"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type CTPolicyStatus string
+
+const (
+	CTCompliant    CTPolicyStatus = "compliant"
+	CTNotCompliant CTPolicyStatus = "not_compliant"
+	CTNotChecked   CTPolicyStatus = "not_checked" // private CA or no log list
+)
+
+type CTInfo struct {
+	SCTs   []SCTInfo      `json:"scts"`
+	Policy CTPolicyStatus `json:"policy"`
+	Reason string         `json:"reason,omitempty"`
+
+	LogListVersion string `json:"log_list_version,omitempty"`
+}
+
 /* ===========================
    Certificate Detail
 =========================== */
@@ -82,10 +171,122 @@ type CertDetail struct {
 
 	SignatureAlgo string `json:"signature_algo"`
 
+	KeyAlgorithm string `json:"key_algorithm"`
+	KeySize      int    `json:"key_size"`
+
 	FingerprintSHA1   string `json:"fingerprint_sha1"`
 	FingerprintSHA256 string `json:"fingerprint_sha256"`
 
 	IsCA bool `json:"is_ca"`
+
+	Revocation *RevocationInfo `json:"revocation,omitempty"`
+}
+
+/* ===========================
+   Protocol & Cipher Support
+=========================== */
+
+type CipherStrength string
+
+const (
+	CipherSecure   CipherStrength = "secure"
+	CipherWeak     CipherStrength = "weak"     // CBC / no forward secrecy
+	CipherInsecure CipherStrength = "insecure" // RC4, DES, 3DES, NULL, EXPORT, anon
+)
+
+type CipherSuiteInfo struct {
+	ID       string         `json:"id"` // IANA code, e.g. 0xC02F
+	Name     string         `json:"name"`
+	Strength CipherStrength `json:"strength"`
+}
+
+// ProtocolSupport lists the cipher suites a server accepted for one
+// protocol version, in the order the server picked them.
+type ProtocolSupport struct {
+	Version   string            `json:"version"`
+	Supported bool              `json:"supported"`
+	Ciphers   []CipherSuiteInfo `json:"ciphers,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+/* ===========================
+   HSTS
+=========================== */
+
+type HSTSInfo struct {
+	Enabled           bool   `json:"enabled"`
+	MaxAge            int64  `json:"max_age"`
+	IncludeSubDomains bool   `json:"include_subdomains"`
+	Preload           bool   `json:"preload"`
+	Header            string `json:"header,omitempty"`
+}
+
+/* ===========================
+   Grade
+=========================== */
+
+type GradeCategory string
+
+const (
+	GradeCategoryCertificate GradeCategory = "certificate"
+	GradeCategoryProtocol    GradeCategory = "protocol"
+	GradeCategoryKeyExchange GradeCategory = "key_exchange"
+	GradeCategoryCipher      GradeCategory = "cipher_strength"
+)
+
+type CategoryScore struct {
+	Category GradeCategory `json:"category"`
+	Score    int           `json:"score"`  // 0 - 100
+	Weight   int           `json:"weight"` // % of the overall score
+}
+
+// GradeCap is a rule that limited the grade below what the score allows.
+type GradeCap struct {
+	Code   string `json:"code"`
+	Grade  string `json:"grade"`
+	Reason string `json:"reason"`
+}
+
+type GradeReport struct {
+	Grade      string          `json:"grade"`
+	Score      int             `json:"score"`
+	Categories []CategoryScore `json:"categories"`
+	Caps       []GradeCap      `json:"caps,omitempty"`
+
+	// Partial is set when protocols / ciphers were not enumerated and the
+	// grade only reflects the negotiated connection.
+	Partial bool `json:"partial"`
+}
+
+/* ===========================
+   Endpoints (multi-IP scan)
+=========================== */
+
+type EndpointDiff string
+
+const (
+	EndpointDiffLeaf       EndpointDiff = "leaf_certificate"
+	EndpointDiffChain      EndpointDiff = "chain"
+	EndpointDiffTLSVersion EndpointDiff = "tls_version"
+)
+
+// EndpointResult summarises the scan of one resolved address. Differences
+// lists what this endpoint serves differently from the majority.
+type EndpointResult struct {
+	IP      string `json:"ip"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+
+	ServerType        string   `json:"server_type,omitempty"`
+	TLSVersion        string   `json:"tls_version,omitempty"`
+	CipherSuite       string   `json:"cipher_suite,omitempty"`
+	LeafFingerprint   string   `json:"leaf_fingerprint,omitempty"` // SHA-256
+	ChainFingerprints []string `json:"chain_fingerprints,omitempty"`
+	DaysLeft          int64    `json:"days_left"`
+	Trusted           bool     `json:"trusted"`
+	Grade             string   `json:"grade,omitempty"`
+
+	Differences []EndpointDiff `json:"differences,omitempty"`
 }
 
 /* ===========================
@@ -97,6 +298,8 @@ type SSLCheckResponse struct {
 	/* ---- Basic Info ---- */
 	Hostname string `json:"hostname" validate:"required,hostname"`
 	IP       string `json:"ip" validate:"required,ip"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"` // https | smtp | imap | ...
 
 	ServerType string `json:"server_type"`
 
@@ -113,13 +316,33 @@ type SSLCheckResponse struct {
 	TrustReason string       `json:"trust_reason,omitempty"`
 
 	/* ---- TLS ---- */
-	TLSVersion string `json:"tls_version"`
+	TLSVersion  string            `json:"tls_version"`
+	CipherSuite string            `json:"cipher_suite"`
+	Protocols   []ProtocolSupport `json:"protocols,omitempty"` // full scan only
+
+	HSTS *HSTSInfo `json:"hsts,omitempty"`
+
+	// OCSPStapled is set when the server stapled an OCSP response for the leaf
+	OCSPStapled bool `json:"ocsp_stapled"`
+
+	/* ---- Certificate Transparency ---- */
+	CT *CTInfo `json:"ct,omitempty"`
+
+	/* ---- Grade ---- */
+	Grade *GradeReport `json:"grade,omitempty"`
 
 	/* ---- Chain */
 	CertChain []CertDetail `json:"cert_chain"`
 
+	/* ---- Endpoints (all_endpoints scan only) */
+	Endpoints        []EndpointResult `json:"endpoints,omitempty"`
+	EndpointMismatch bool             `json:"endpoint_mismatch,omitempty"`
+
 	/* ---- Meta */
 
 	CheckTime time.Time `json:"check_time"`
 	Success   bool      `json:"success"`
+
+	Cached   bool  `json:"cached"`
+	CacheAge int64 `json:"cache_age"` // seconds since CheckTime
 }
@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+/* ===========================
+   Certificate Monitoring
+=========================== */
+
+// Watch is a domain / port rescanned on a schedule.
+type Watch struct {
+	ID       string `json:"id"`
+	Domain   string `json:"domain"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	LastCheck *time.Time `json:"last_check,omitempty"`
+
+	// Latest result, nil until the first scan finished
+	Last *WatchResult `json:"last,omitempty"`
+}
+
+// WatchResult is one entry of a watch's history.
+type WatchResult struct {
+	CheckTime time.Time `json:"check_time"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+
+	DaysLeft          int64       `json:"days_left"`
+	NotAfter          time.Time   `json:"not_after"`
+	FingerprintSHA256 string      `json:"fingerprint_sha256,omitempty"`
+	Trusted           bool        `json:"trusted"`
+	TrustIssues       []TrustCode `json:"trust_issues,omitempty"`
+	Grade             string      `json:"grade,omitempty"`
+
+	// DNS side, nil when no DNS service is configured or it failed
+	DNS      *WatchDNSStatus `json:"dns,omitempty"`
+	DNSError string          `json:"dns_error,omitempty"`
+}
+
+// WatchDNSStatus is what the DNS service reports for a watched domain.
+type WatchDNSStatus struct {
+	Addresses []string `json:"addresses"`          // A / AAAA, sorted
+	Listings  []string `json:"listings,omitempty"` // "provider target", sorted
+}
+
+type AlertType string
+
+const (
+	AlertExpirySoon         AlertType = "expiry_soon"
+	AlertFingerprintChanged AlertType = "fingerprint_changed"
+	AlertNewTrustIssue      AlertType = "new_trust_issue"
+
+	// Raised from the DNS service's results
+	AlertAddressesChanged AlertType = "addresses_changed"
+	AlertBlacklisted      AlertType = "blacklisted"
+	AlertDelisted         AlertType = "delisted"
+)
+
+type MonitorAlert struct {
+	ID       string    `json:"id"`
+	WatchID  string    `json:"watch_id"`
+	Domain   string    `json:"domain"`
+	Port     int       `json:"port"`
+	Type     AlertType `json:"type"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+	Previous string    `json:"previous,omitempty"`
+	Current  string    `json:"current,omitempty"`
+}
+
+// WatchDetail is a watch with its full history (newest last).
+type WatchDetail struct {
+	Watch
+	History []WatchResult `json:"history"`
+}
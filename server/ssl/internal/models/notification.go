@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+/* ===========================
+   Notifications
+=========================== */
+
+type NotificationSource string
+
+const (
+	NotificationSourceSSL       NotificationSource = "ssl"
+	NotificationSourceDNS       NotificationSource = "dns"
+	NotificationSourceBlacklist NotificationSource = "blacklist"
+)
+
+type NotificationSeverity string
+
+const (
+	SeverityInfo     NotificationSeverity = "info"
+	SeverityWarning  NotificationSeverity = "warning"
+	SeverityCritical NotificationSeverity = "critical"
+)
+
+// Notification is what every notifier receives, whatever raised it.
+type Notification struct {
+	ID       string               `json:"id"`
+	Source   NotificationSource   `json:"source"`
+	Event    string               `json:"event"` // e.g. expiry_soon
+	Severity NotificationSeverity `json:"severity"`
+	Target   string               `json:"target"` // domain[:port] or IP
+	Subject  string               `json:"subject"`
+	Message  string               `json:"message"`
+	Time     time.Time            `json:"time"`
+
+	Data any `json:"data,omitempty"`
+}
@@ -0,0 +1,217 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+/* ===========================
+   DISPATCHER
+===========================
+
+   Notifications are queued and delivered in the background so a slow
+   channel never blocks a scan. Each notifier is retried with exponential
+   backoff; what still fails ends up in the dead-letter log.
+*/
+
+// DeadLetter is one line of the dead-letter log.
+type DeadLetter struct {
+	Notifier     string              `json:"notifier"`
+	Attempts     int                 `json:"attempts"`
+	Error        string              `json:"error"`
+	FailedAt     time.Time           `json:"failed_at"`
+	Notification models.Notification `json:"notification"`
+}
+
+var errQueueFull = errors.New("notification queue full")
+
+type Dispatcher struct {
+	notifiers      []Notifier
+	deadLetterPath string
+
+	queue  chan models.Notification
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	dlMu sync.Mutex
+
+	// overridable in tests
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+func NewDispatcher(notifiers []Notifier, deadLetterPath string) *Dispatcher {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d := &Dispatcher{
+		notifiers:      notifiers,
+		deadLetterPath: deadLetterPath,
+		queue:          make(chan models.Notification, config.NotifyQueueSize),
+		ctx:            ctx,
+		cancel:         cancel,
+		maxAttempts:    config.NotifyMaxAttempts,
+		initialBackoff: config.NotifyInitialBackoff,
+		maxBackoff:     config.NotifyMaxBackoff,
+	}
+
+	d.wg.Add(1)
+	go d.run()
+
+	return d
+}
+
+// Enabled reports whether at least one notifier is configured.
+func (d *Dispatcher) Enabled() bool {
+	return len(d.notifiers) > 0
+}
+
+// Dispatch queues n. When the queue is full the notification is
+// dead-lettered instead of blocking the caller.
+func (d *Dispatcher) Dispatch(n models.Notification) {
+
+	if !d.Enabled() {
+		return
+	}
+
+	if n.Time.IsZero() {
+		n.Time = time.Now().UTC()
+	}
+
+	select {
+	case <-d.ctx.Done():
+		d.deadLetter("*", 0, context.Canceled, n)
+	default:
+		select {
+		case d.queue <- n:
+		default:
+			d.deadLetter("*", 0, errQueueFull, n)
+		}
+	}
+}
+
+// Stop delivers what is already queued (without retry waits) and returns.
+func (d *Dispatcher) Stop() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) run() {
+
+	defer d.wg.Done()
+
+	for {
+		select {
+		case n := <-d.queue:
+			d.deliver(n)
+
+		case <-d.ctx.Done():
+			// Drain
+			for {
+				select {
+				case n := <-d.queue:
+					d.deliver(n)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(n models.Notification) {
+
+	var wg sync.WaitGroup
+
+	for _, nt := range d.notifiers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			attempts, err := d.send(nt, n)
+			if err != nil {
+				d.deadLetter(nt.Name(), attempts, err, n)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// send retries one notifier with exponential backoff. Once the dispatcher
+// is stopping, the remaining attempts are skipped.
+func (d *Dispatcher) send(nt Notifier, n models.Notification) (int, error) {
+
+	backoff := d.initialBackoff
+
+	var err error
+
+	for attempt := 1; ; attempt++ {
+
+		ctx, cancel := context.WithTimeout(context.Background(), config.NotifyTimeout)
+		err = nt.Notify(ctx, n)
+		cancel()
+
+		if err == nil {
+			return attempt, nil
+		}
+
+		if attempt >= d.maxAttempts {
+			return attempt, err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-d.ctx.Done():
+			return attempt, err
+		}
+
+		backoff = min(backoff*2, d.maxBackoff)
+	}
+}
+
+func (d *Dispatcher) deadLetter(notifier string, attempts int, err error, n models.Notification) {
+
+	log.Printf(
+		"[NOTIFY] dead letter notifier=%s event=%s target=%s attempts=%d: %v",
+		notifier, n.Event, n.Target, attempts, err,
+	)
+
+	if d.deadLetterPath == "" {
+		return
+	}
+
+	line, mErr := json.Marshal(DeadLetter{
+		Notifier:     notifier,
+		Attempts:     attempts,
+		Error:        err.Error(),
+		FailedAt:     time.Now().UTC(),
+		Notification: n,
+	})
+	if mErr != nil {
+		return
+	}
+
+	d.dlMu.Lock()
+	defer d.dlMu.Unlock()
+
+	f, oErr := os.OpenFile(d.deadLetterPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if oErr != nil {
+		log.Printf("[NOTIFY] cannot open dead-letter file: %v", oErr)
+		return
+	}
+	defer f.Close()
+
+	f.Write(append(line, '\n'))
+}
@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+const emailClientName = "tools.bctechvibe.io.vn"
+
+// Email sends notifications over SMTP. STARTTLS is used whenever the server
+// offers it; credentials are only sent over TLS.
+type Email struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func (e *Email) Name() string { return "email" }
+
+func (e *Email) Notify(ctx context.Context, n models.Notification) error {
+
+	if e.From == "" || len(e.To) == 0 {
+		return errors.New("email notifier needs a sender and at least one recipient")
+	}
+
+	msg, err := e.message(n)
+	if err != nil {
+		return err
+	}
+
+	host, _, err := net.SplitHostPort(e.Addr)
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{Timeout: config.NotifyTimeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", e.Addr)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(config.NotifyTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if err := c.Hello(emailClientName); err != nil {
+		return err
+	}
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+
+	if e.Username != "" {
+		// PlainAuth itself refuses to send credentials without TLS
+		if err := c.Auth(smtp.PlainAuth("", e.Username, e.Password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(e.From); err != nil {
+		return err
+	}
+
+	for _, rcpt := range e.To {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+func (e *Email) message(n models.Notification) ([]byte, error) {
+
+	var buf bytes.Buffer
+
+	headers := []string{
+		"From: " + e.From,
+		"To: " + strings.Join(e.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", n.Subject),
+		"Date: " + n.Time.Format(time.RFC1123Z),
+		fmt.Sprintf("Message-ID: <%s@%s>", n.ID, emailClientName),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: quoted-printable",
+	}
+
+	for _, h := range headers {
+		buf.WriteString(h + "\r\n")
+	}
+	buf.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+
+	body := fmt.Sprintf("%s\r\n\r\nĐối tượng: %s\r\nSự kiện: %s\r\nThời gian: %s\r\n",
+		n.Message, n.Target, n.Event, n.Time.Format(time.RFC3339))
+
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,187 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+// Notifier delivers a notification to one channel. Notify should return an
+// error for anything worth retrying.
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, n models.Notification) error
+}
+
+// FromEnv builds the notifiers configured through environment variables:
+//
+//	NOTIFY_WEBHOOK_URL, NOTIFY_WEBHOOK_SECRET
+//	NOTIFY_SLACK_WEBHOOK_URL
+//	NOTIFY_SMTP_ADDR, NOTIFY_SMTP_USER, NOTIFY_SMTP_PASSWORD,
+//	NOTIFY_SMTP_FROM, NOTIFY_SMTP_TO (comma separated)
+func FromEnv() []Notifier {
+
+	var out []Notifier
+
+	if url := os.Getenv("NOTIFY_WEBHOOK_URL"); url != "" {
+		out = append(out, NewWebhook(url, os.Getenv("NOTIFY_WEBHOOK_SECRET")))
+	}
+
+	if url := os.Getenv("NOTIFY_SLACK_WEBHOOK_URL"); url != "" {
+		out = append(out, NewSlack(url))
+	}
+
+	if addr := os.Getenv("NOTIFY_SMTP_ADDR"); addr != "" {
+
+		var to []string
+		for r := range strings.SplitSeq(os.Getenv("NOTIFY_SMTP_TO"), ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				to = append(to, r)
+			}
+		}
+
+		out = append(out, &Email{
+			Addr:     addr,
+			Username: os.Getenv("NOTIFY_SMTP_USER"),
+			Password: os.Getenv("NOTIFY_SMTP_PASSWORD"),
+			From:     os.Getenv("NOTIFY_SMTP_FROM"),
+			To:       to,
+		})
+	}
+
+	return out
+}
+
+/* ===========================
+   HTTP HELPER
+=========================== */
+
+func postJSON(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	body []byte,
+	headers map[string]string,
+) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+/* ===========================
+   GENERIC WEBHOOK
+=========================== */
+
+// Webhook POSTs the notification as JSON. With a secret, the body is
+// signed with HMAC-SHA256 in the X-Signature-256 header ("sha256=<hex>").
+type Webhook struct {
+	URL    string
+	Secret string
+	client *http.Client
+}
+
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{
+		URL:    url,
+		Secret: secret,
+		client: &http.Client{Timeout: config.NotifyTimeout},
+	}
+}
+
+func (w *Webhook) Name() string { return "webhook" }
+
+// Sign returns the X-Signature-256 value for body.
+func Sign(secret string, body []byte) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *Webhook) Notify(ctx context.Context, n models.Notification) error {
+
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{
+		"X-Notification-Event": n.Event,
+		"X-Notification-ID":    n.ID,
+	}
+
+	if w.Secret != "" {
+		headers["X-Signature-256"] = Sign(w.Secret, body)
+	}
+
+	return postJSON(ctx, w.client, w.URL, body, headers)
+}
+
+/* ===========================
+   SLACK INCOMING WEBHOOK
+=========================== */
+
+type Slack struct {
+	URL    string
+	client *http.Client
+}
+
+func NewSlack(url string) *Slack {
+	return &Slack{
+		URL:    url,
+		client: &http.Client{Timeout: config.NotifyTimeout},
+	}
+}
+
+func (s *Slack) Name() string { return "slack" }
+
+var severityEmoji = map[models.NotificationSeverity]string{
+	models.SeverityInfo:     ":information_source:",
+	models.SeverityWarning:  ":warning:",
+	models.SeverityCritical: ":rotating_light:",
+}
+
+func (s *Slack) Notify(ctx context.Context, n models.Notification) error {
+
+	body, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("%s *%s*\n%s", severityEmoji[n.Severity], n.Subject, n.Message),
+	})
+	if err != nil {
+		return err
+	}
+
+	return postJSON(ctx, s.client, s.URL, body, nil)
+}
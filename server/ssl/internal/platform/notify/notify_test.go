@@ -0,0 +1,306 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+func testNotification() models.Notification {
+	return models.Notification{
+		ID:       "abc123",
+		Source:   models.NotificationSourceSSL,
+		Event:    "expiry_soon",
+		Severity: models.SeverityWarning,
+		Target:   "example.com:443",
+		Subject:  "Chứng chỉ sắp hết hạn: example.com:443",
+		Message:  "Chứng chỉ còn 10 ngày",
+		Time:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+
+	var (
+		gotBody []byte
+		gotSig  string
+		gotEvt  string
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotSig = r.Header.Get("X-Signature-256")
+		gotEvt = r.Header.Get("X-Notification-Event")
+	}))
+	defer srv.Close()
+
+	if err := NewWebhook(srv.URL, "s3cret").Notify(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+
+	if gotSig != Sign("s3cret", gotBody) || !strings.HasPrefix(gotSig, "sha256=") {
+		t.Errorf("bad signature %q", gotSig)
+	}
+	if gotEvt != "expiry_soon" {
+		t.Errorf("event header = %q", gotEvt)
+	}
+
+	var n models.Notification
+	if err := json.Unmarshal(gotBody, &n); err != nil || n.Target != "example.com:443" {
+		t.Errorf("unexpected body %s (%v)", gotBody, err)
+	}
+}
+
+func TestSlackPayload(t *testing.T) {
+
+	var payload map[string]string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer srv.Close()
+
+	if err := NewSlack(srv.URL).Notify(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+
+	text := payload["text"]
+	if !strings.HasPrefix(text, ":warning: *Chứng chỉ sắp hết hạn") || !strings.Contains(text, "còn 10 ngày") {
+		t.Errorf("unexpected slack text %q", text)
+	}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+
+	var calls atomic.Int32
+
+	// Fails twice, then accepts
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	dl := filepath.Join(t.TempDir(), "dead.jsonl")
+
+	d := NewDispatcher([]Notifier{NewWebhook(srv.URL, "")}, dl)
+	d.initialBackoff = time.Millisecond
+
+	d.Dispatch(testNotification())
+
+	waitFor(t, func() bool { return calls.Load() == 3 })
+	d.Stop()
+
+	if _, err := os.Stat(dl); !os.IsNotExist(err) {
+		t.Errorf("delivered notification was dead-lettered")
+	}
+}
+
+type failingNotifier struct{ calls atomic.Int32 }
+
+func (f *failingNotifier) Name() string { return "failing" }
+
+func (f *failingNotifier) Notify(ctx context.Context, n models.Notification) error {
+	f.calls.Add(1)
+	return errors.New("boom")
+}
+
+func TestDispatcherDeadLetter(t *testing.T) {
+
+	dl := filepath.Join(t.TempDir(), "dead.jsonl")
+
+	fn := &failingNotifier{}
+
+	d := NewDispatcher([]Notifier{fn}, dl)
+	d.maxAttempts = 3
+	d.initialBackoff = time.Millisecond
+
+	d.Dispatch(testNotification())
+
+	waitFor(t, func() bool { _, err := os.Stat(dl); return err == nil })
+	d.Stop()
+
+	if fn.calls.Load() != 3 {
+		t.Errorf("notifier called %d times, want 3", fn.calls.Load())
+	}
+
+	data, _ := os.ReadFile(dl)
+
+	var entry DeadLetter
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("bad dead-letter line %q: %v", data, err)
+	}
+
+	if entry.Notifier != "failing" || entry.Attempts != 3 || entry.Error != "boom" || entry.Notification.ID != "abc123" {
+		t.Errorf("unexpected dead letter %+v", entry)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+/* ===========================
+   SMTP STUB
+=========================== */
+
+type smtpStub struct {
+	addr string
+
+	mu   sync.Mutex
+	auth string
+	from string
+	rcpt []string
+	data string
+}
+
+func startSMTPStub(t *testing.T) *smtpStub {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &smtpStub{addr: ln.Addr().String()}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpStub) serve(conn net.Conn) {
+
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP stub")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		s.mu.Lock()
+
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			s.auth = line
+			reply("235 ok")
+		case "MAIL":
+			s.from = line
+			reply("250 ok")
+		case "RCPT":
+			s.rcpt = append(s.rcpt, line)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					s.mu.Unlock()
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.data = b.String()
+
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			s.mu.Unlock()
+			return
+		default:
+			reply("502 not implemented")
+		}
+
+		s.mu.Unlock()
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+
+	stub := startSMTPStub(t)
+
+	e := &Email{
+		Addr:     stub.addr,
+		Username: "user",
+		Password: "pass",
+		From:     "alerts@example.com",
+		To:       []string{"ops@example.com", "dev@example.com"},
+	}
+
+	if err := e.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatal(err)
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+
+	if !strings.HasPrefix(stub.auth, "AUTH PLAIN") {
+		t.Errorf("no AUTH sent: %q", stub.auth)
+	}
+	if stub.from != "MAIL FROM:<alerts@example.com>" || len(stub.rcpt) != 2 {
+		t.Errorf("unexpected envelope %q %q", stub.from, stub.rcpt)
+	}
+
+	var subject string
+	for _, l := range strings.Split(stub.data, "\r\n") {
+		if v, ok := strings.CutPrefix(l, "Subject: "); ok {
+			subject, _ = new(mime.WordDecoder).DecodeHeader(v)
+		}
+	}
+
+	if subject != "Chứng chỉ sắp hết hạn: example.com:443" {
+		t.Errorf("subject = %q", subject)
+	}
+	if !strings.Contains(stub.data, "Content-Type: text/plain; charset=utf-8") {
+		t.Errorf("missing content type:\n%s", stub.data)
+	}
+}
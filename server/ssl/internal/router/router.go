@@ -6,9 +6,12 @@ import (
 	"strings"
 
 	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
 	"tools.bctechvibe.io.vn/server/ssl/internal/platform/middleware"
+	"tools.bctechvibe.io.vn/server/ssl/internal/platform/notify"
 	"tools.bctechvibe.io.vn/server/ssl/internal/tools/checker"
 	"tools.bctechvibe.io.vn/server/ssl/internal/tools/csr"
+	"tools.bctechvibe.io.vn/server/ssl/internal/tools/monitor"
 )
 
 /* ===============================
@@ -36,8 +39,9 @@ func setCORS(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	w.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
+	w.Header().Set("Access-Control-Allow-Methods", "GET,POST,DELETE,OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization")
+	w.Header().Set("Access-Control-Expose-Headers", "X-Cache,Age")
 }
 
 /* ===============================
@@ -45,7 +49,13 @@ func setCORS(w http.ResponseWriter, r *http.Request) {
 ================================*/
 
 type Router struct {
-	limiter *middleware.RateLimiter
+	limiter      *middleware.RateLimiter
+	freshLimiter *middleware.RateLimiter
+	batchLimiter *middleware.RateLimiter
+	tokenLimiter *middleware.RateLimiter
+	ctLogs       *checker.CTLogUpdater
+	monitor      *monitor.Service
+	notifier     *notify.Dispatcher
 }
 
 func Register() *Router {
@@ -57,15 +67,82 @@ func Register() *Router {
 		config.TrustProxy,
 	)
 
+	freshLimiter := middleware.NewRateLimiter(
+		config.FreshRateLimitRequests,
+		config.FreshRateLimitWindow,
+		config.MaxRateLimitBuckets,
+		config.TrustProxy,
+	)
+
+	batchLimiter := middleware.NewRateLimiter(
+		config.BatchRateLimitRequests,
+		config.BatchRateLimitWindow,
+		config.MaxRateLimitBuckets,
+		config.TrustProxy,
+	)
+
+	tokenLimiter := middleware.NewRateLimiter(
+		config.MonitorTokenRateLimitRequests,
+		config.MonitorTokenRateLimitWindow,
+		config.MaxRateLimitBuckets,
+		config.TrustProxy,
+	)
+
 	loadWhitelist(limiter)
+	loadWhitelist(freshLimiter)
+	loadWhitelist(batchLimiter)
+	loadWhitelist(tokenLimiter)
+
+	// CT log list: bundled snapshot, refreshed in the background
+	ctLogs := checker.NewCTLogUpdater(os.Getenv("CT_LOG_LIST_FILE"))
+	ctLogs.Start()
+
+	// One checker service so the monitor shares its cache / breaker
+	checkerSvc := checker.New()
 
 	handler := &RateLimitHandler{
 		limiter:     limiter,
-		nextHandler: checker.NewHandler(),
+		nextHandler: checker.NewHandler(checkerSvc, freshLimiter),
 	}
 
 	http.Handle("/api/ssl/check", handler)
 
+	batchHandler := &RateLimitHandler{
+		limiter:     limiter,
+		nextHandler: checker.NewBatchHandler(checkerSvc, batchLimiter),
+	}
+	http.Handle("/api/ssl/check/batch", batchHandler)
+
+	statsHandler := &RateLimitHandler{
+		limiter:     limiter,
+		nextHandler: checker.NewStatsHandler(checkerSvc),
+	}
+	http.Handle("/api/ssl/check/stats", statsHandler)
+
+	// Certificate monitoring
+	monitorSvc := monitor.New(checkerSvc, os.Getenv("MONITOR_STORE_FILE"))
+
+	// Addresses and blocklist listings come from the DNS service
+	if url := os.Getenv("MONITOR_DNS_SERVICE_URL"); url != "" {
+		monitorSvc.SetDNSChecker(monitor.NewDNSService(url))
+	}
+
+	// Alert delivery (webhook / Slack / email), configured through NOTIFY_*
+	dispatcher := notify.NewDispatcher(notify.FromEnv(), os.Getenv("NOTIFY_DEAD_LETTER_FILE"))
+	monitorSvc.Subscribe(func(a models.MonitorAlert) {
+		dispatcher.Dispatch(monitor.Notification(a))
+	})
+
+	monitorSvc.Start()
+
+	monitorHandler := &RateLimitHandler{
+		limiter:     limiter,
+		nextHandler: monitor.NewHandler(monitorSvc, tokenLimiter),
+	}
+	http.Handle("/api/ssl/monitor/watches", monitorHandler)
+	http.Handle("/api/ssl/monitor/watches/{id}", monitorHandler)
+	http.Handle("/api/ssl/monitor/alerts", monitorHandler)
+
 	csrHandler := &RateLimitHandler{
 		limiter:     limiter,
 		nextHandler: csr.NewHandler(csr.New()),
@@ -73,7 +150,13 @@ func Register() *Router {
 	http.Handle("/api/ssl/csr/decode", csrHandler)
 
 	return &Router{
-		limiter: limiter,
+		limiter:      limiter,
+		freshLimiter: freshLimiter,
+		batchLimiter: batchLimiter,
+		tokenLimiter: tokenLimiter,
+		ctLogs:       ctLogs,
+		monitor:      monitorSvc,
+		notifier:     dispatcher,
 	}
 }
 
@@ -82,6 +165,31 @@ func (r *Router) Shutdown() {
 	if r.limiter != nil {
 		r.limiter.Stop()
 	}
+
+	if r.freshLimiter != nil {
+		r.freshLimiter.Stop()
+	}
+
+	if r.batchLimiter != nil {
+		r.batchLimiter.Stop()
+	}
+
+	if r.tokenLimiter != nil {
+		r.tokenLimiter.Stop()
+	}
+
+	if r.ctLogs != nil {
+		r.ctLogs.Stop()
+	}
+
+	if r.monitor != nil {
+		r.monitor.Stop()
+	}
+
+	// After the monitor so its last alerts are still delivered
+	if r.notifier != nil {
+		r.notifier.Stop()
+	}
 }
 
 /* ===============================
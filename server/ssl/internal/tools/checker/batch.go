@@ -0,0 +1,527 @@
+package checker
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+	"tools.bctechvibe.io.vn/server/ssl/internal/platform/middleware"
+	"tools.bctechvibe.io.vn/server/ssl/internal/platform/shared"
+)
+
+/* ===========================
+   BATCH CHECK
+===========================
+
+   POST /api/ssl/check/batch
+
+   Body is either JSON ({"domains": [...], "mode", "protocol", "port"}),
+   a CSV file (text/csv or multipart field "file") with rows of
+   domain[,port[,protocol]]. For CSV, mode / protocol / port / format are
+   read from the query string or form fields.
+
+   Every domain goes through Service.Check, so the cache, singleflight and
+   the breaker apply as for single checks. Results are streamed as they
+   finish (NDJSON, or SSE with format=sse / Accept: text/event-stream),
+   followed by one summary.
+*/
+
+type BatchRequest struct {
+	Domains  []string `json:"domains"`
+	Mode     string   `json:"mode,omitempty"`
+	Protocol string   `json:"protocol,omitempty"`
+	Port     int      `json:"port,omitempty"`
+	Format   string   `json:"format,omitempty"` // ndjson (default) | sse
+}
+
+type batchTarget struct {
+	index    int // position in the submitted list, kept across dedupe
+	domain   string
+	port     int
+	protocol string
+}
+
+type BatchHandler struct {
+	svc *Service
+
+	// a batch costs up to MaxBatchDomains scans, so it has its own limit
+	limiter *middleware.RateLimiter
+}
+
+func NewBatchHandler(svc *Service, limiter *middleware.RateLimiter) *BatchHandler {
+	return &BatchHandler{
+		svc:     svc,
+		limiter: limiter,
+	}
+}
+
+func (h *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	defer func() {
+		log.Printf("[%s] %s %s - took %v", r.Method, r.RequestURI, r.RemoteAddr, time.Since(startTime))
+	}()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Phương thức HTTP không được hỗ trợ", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, config.MaxBatchBodySize)
+	defer r.Body.Close()
+
+	req, targets, err := parseBatchRequest(r)
+	if err != nil {
+		shared.ErrorDecode(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(targets) == 0 {
+		shared.ErrorDecode(w, "Danh sách tên miền trống", http.StatusBadRequest)
+		return
+	}
+
+	if len(targets) > config.MaxBatchDomains {
+		shared.ErrorDecode(w, fmt.Sprintf("Tối đa %d tên miền cho mỗi lần kiểm tra", config.MaxBatchDomains), http.StatusBadRequest)
+		return
+	}
+
+	var opts ScanOptions
+	timeout := 15 * time.Second
+
+	switch strings.ToLower(strings.TrimSpace(req.Mode)) {
+	case "", config.ScanModeQuick:
+	case config.ScanModeFull:
+		opts.EnumerateProtocols = true
+		timeout = config.FullScanTimeout
+	default:
+		shared.ErrorDecode(w, "Chế độ kiểm tra không hợp lệ (quick | full)", http.StatusBadRequest)
+		return
+	}
+
+	defaultProtocol := strings.ToLower(strings.TrimSpace(req.Protocol))
+	if defaultProtocol == "" {
+		defaultProtocol = config.ProtocolHTTPS
+	}
+
+	if _, ok := config.DefaultPorts[defaultProtocol]; !ok {
+		shared.ErrorDecode(w, "Giao thức không được hỗ trợ (https | tls | smtp | imap | pop3 | ldap | postgres | ftp | xmpp)", http.StatusBadRequest)
+		return
+	}
+
+	if req.Port < 0 || req.Port > 65535 {
+		shared.ErrorDecode(w, "Cổng không hợp lệ (1 - 65535)", http.StatusBadRequest)
+		return
+	}
+
+	for i := range targets {
+		if targets[i].protocol == "" {
+			targets[i].protocol = defaultProtocol
+		}
+		if targets[i].port == 0 {
+			targets[i].port = req.Port
+		}
+	}
+
+	if h.limiter != nil {
+		ip := h.limiter.GetClientIP(r.RemoteAddr, r.Header)
+
+		if !h.limiter.IsAllowed(ip) {
+			shared.ErrorDecode(w, "Bạn đã gửi quá nhiều yêu cầu kiểm tra hàng loạt, vui lòng thử lại sau ít phút.", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	sse := strings.EqualFold(req.Format, "sse") ||
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+
+	stream := newBatchStream(w, sse)
+
+	summary := h.run(r.Context(), targets, opts, timeout, stream.result)
+	summary.Duration = time.Since(startTime).Milliseconds()
+
+	stream.summary(summary)
+}
+
+// run checks every target with at most config.BatchConcurrency in flight
+// and reports each result to emit (called from a single goroutine).
+func (h *BatchHandler) run(
+	ctx context.Context,
+	targets []batchTarget,
+	opts ScanOptions,
+	timeout time.Duration,
+	emit func(models.BatchItem),
+) models.BatchSummary {
+
+	jobs := make(chan int)
+	results := make(chan models.BatchItem)
+
+	var wg sync.WaitGroup
+
+	for range min(config.BatchConcurrency, len(targets)) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				results <- h.checkOne(ctx, targets[i], opts, timeout)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+
+		for i := range targets {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	summary := models.BatchSummary{Total: len(targets)}
+
+	for item := range results {
+
+		switch item.Status {
+		case config.StatusOK:
+			summary.OK++
+		case config.StatusWarning:
+			summary.Warning++
+		case config.StatusCritical:
+			summary.Critical++
+		default:
+			summary.Errored++
+		}
+
+		emit(item)
+	}
+
+	// Targets never started because the client went away
+	summary.Errored = summary.Total - summary.OK - summary.Warning - summary.Critical
+
+	return summary
+}
+
+func (h *BatchHandler) checkOne(
+	ctx context.Context,
+	t batchTarget,
+	opts ScanOptions,
+	timeout time.Duration,
+) models.BatchItem {
+
+	item := models.BatchItem{
+		Index:    t.index,
+		Domain:   t.domain,
+		Port:     t.port,
+		Protocol: t.protocol,
+	}
+
+	fail := func(msg string) models.BatchItem {
+		item.Status = config.StatusError
+		item.Error = msg
+		return item
+	}
+
+	d, err := shared.ParseDomain(strings.TrimSuffix(strings.TrimSpace(t.domain), "."))
+	if err != nil {
+		return fail("Định dạng tên miền không hợp lệ")
+	}
+	item.Domain = d
+
+	if _, ok := config.DefaultPorts[t.protocol]; !ok {
+		return fail("Giao thức không được hỗ trợ")
+	}
+
+	if t.port < 0 || t.port > 65535 {
+		return fail("Cổng không hợp lệ (1 - 65535)")
+	}
+
+	opts.Protocol = t.protocol
+	opts.Port = t.port
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	res, err := h.svc.Check(ctx, d, opts)
+	if err != nil {
+		return fail(checkErrorMessage(d, err))
+	}
+
+	item.Result = res
+	item.Status = batchStatus(res)
+
+	return item
+}
+
+// batchStatus buckets a result for the batch summary.
+func batchStatus(res *models.SSLCheckResponse) string {
+
+	grade := ""
+	if res.Grade != nil {
+		grade = res.Grade.Grade
+	}
+
+	switch {
+	case !res.Trusted, res.DaysLeft <= 0, grade == config.GradeF:
+		return config.StatusCritical
+	case res.DaysLeft < config.CertExpirySoonThreshold, grade == config.GradeC, res.EndpointMismatch:
+		return config.StatusWarning
+	default:
+		return config.StatusOK
+	}
+}
+
+func checkErrorMessage(domain string, err error) string {
+
+	if errors.Is(err, shared.ErrBlocked) {
+		return err.Error()
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return fmt.Sprintf("Tên miền %s chưa phân giải được địa chỉ IP. Vui lòng kiểm tra bản ghi DNS (A/AAAA).", domain)
+	}
+
+	return fmt.Sprintf("Kiểm tra SSL thất bại cho %s: %v", domain, err)
+}
+
+/* ===========================
+   REQUEST PARSING
+=========================== */
+
+func parseBatchRequest(r *http.Request) (*BatchRequest, []batchTarget, error) {
+
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch ct {
+	case "", "application/json":
+
+		var req BatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, nil, errors.New("Dữ liệu request không hợp lệ")
+		}
+
+		if q := r.URL.Query().Get("format"); q != "" {
+			req.Format = q
+		}
+
+		var targets []batchTarget
+		for i, d := range req.Domains {
+			targets = append(targets, batchTarget{index: i, domain: d})
+		}
+
+		return &req, dedupeTargets(targets), nil
+
+	case "text/csv", "text/plain":
+
+		req, err := batchOptions(r.URL.Query().Get)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		targets, err := parseBatchCSV(r.Body)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return req, targets, nil
+
+	case "multipart/form-data":
+
+		if err := r.ParseMultipartForm(config.MaxBatchBodySize); err != nil {
+			return nil, nil, errors.New("Dữ liệu request không hợp lệ")
+		}
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, nil, errors.New("Thiếu tệp CSV (trường \"file\")")
+		}
+		defer file.Close()
+
+		req, err := batchOptions(r.FormValue)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		targets, err := parseBatchCSV(file)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return req, targets, nil
+
+	default:
+		return nil, nil, errors.New("Content-Type không được hỗ trợ")
+	}
+}
+
+func batchOptions(get func(string) string) (*BatchRequest, error) {
+
+	req := &BatchRequest{
+		Mode:     get("mode"),
+		Protocol: get("protocol"),
+		Format:   get("format"),
+	}
+
+	if p := get("port"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, errors.New("Cổng không hợp lệ (1 - 65535)")
+		}
+		req.Port = n
+	}
+
+	return req, nil
+}
+
+// parseBatchCSV reads rows of domain[,port[,protocol]]. An optional header
+// row and lines starting with # are skipped.
+func parseBatchCSV(r io.Reader) ([]batchTarget, error) {
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+
+	var targets []batchTarget
+
+	for line := 1; ; line++ {
+
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Tệp CSV không hợp lệ: %v", err)
+		}
+
+		domain := strings.TrimSpace(rec[0])
+
+		if domain == "" {
+			continue
+		}
+
+		if line == 1 && (strings.EqualFold(domain, "domain") || strings.EqualFold(domain, "hostname")) {
+			continue
+		}
+
+		t := batchTarget{index: len(targets), domain: domain}
+
+		if len(rec) > 1 && strings.TrimSpace(rec[1]) != "" {
+			port, err := strconv.Atoi(strings.TrimSpace(rec[1]))
+			if err != nil || port < 1 || port > 65535 {
+				return nil, fmt.Errorf("Cổng không hợp lệ ở dòng %d", line)
+			}
+			t.port = port
+		}
+
+		if len(rec) > 2 {
+			t.protocol = strings.ToLower(strings.TrimSpace(rec[2]))
+		}
+
+		targets = append(targets, t)
+
+		// stop early instead of parsing a huge file
+		if len(targets) > config.MaxBatchDomains {
+			break
+		}
+	}
+
+	return dedupeTargets(targets), nil
+}
+
+func dedupeTargets(targets []batchTarget) []batchTarget {
+
+	seen := make(map[batchTarget]bool, len(targets))
+	out := targets[:0]
+
+	for _, t := range targets {
+
+		key := t
+		key.index = 0
+		key.domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(t.domain), "."))
+
+		if key.domain == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		out = append(out, t)
+	}
+
+	return out
+}
+
+/* ===========================
+   STREAMING
+=========================== */
+
+type batchStream struct {
+	w   http.ResponseWriter
+	rc  *http.ResponseController
+	sse bool
+}
+
+func newBatchStream(w http.ResponseWriter, sse bool) *batchStream {
+
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+
+	// tell nginx not to buffer the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	return &batchStream{w: w, rc: http.NewResponseController(w), sse: sse}
+}
+
+func (s *batchStream) result(item models.BatchItem) {
+	s.write(models.BatchEvent{Type: "result", BatchItem: &item})
+}
+
+func (s *batchStream) summary(sum models.BatchSummary) {
+	s.write(models.BatchEvent{Type: "summary", BatchSummary: &sum})
+}
+
+func (s *batchStream) write(ev models.BatchEvent) {
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+
+	// the server's WriteTimeout is sized for one scan, not a whole batch
+	s.rc.SetWriteDeadline(time.Now().Add(config.FullScanTimeout + 15*time.Second))
+
+	if s.sse {
+		fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", ev.Type, data)
+	} else {
+		s.w.Write(append(data, '\n'))
+	}
+
+	s.rc.Flush()
+}
@@ -0,0 +1,173 @@
+package checker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+	"tools.bctechvibe.io.vn/server/ssl/internal/platform/breaker"
+	"tools.bctechvibe.io.vn/server/ssl/internal/platform/cache"
+)
+
+func newBatchTestHandler(t *testing.T) (*BatchHandler, *atomic.Int32) {
+	t.Helper()
+
+	c := cache.NewMemoryCache(time.Minute)
+	b := breaker.New()
+	t.Cleanup(func() { c.Stop(); b.Stop() })
+
+	var scans atomic.Int32
+
+	svc := NewWithDeps(c, b)
+	svc.scanFunc = func(ctx context.Context, domain string, opts ScanOptions) (*models.SSLCheckResponse, error) {
+		scans.Add(1)
+
+		res := &models.SSLCheckResponse{Hostname: domain, Port: opts.port(), CheckTime: time.Now(), Success: true, Trusted: true, DaysLeft: 90}
+
+		switch {
+		case strings.HasPrefix(domain, "expiring."):
+			res.DaysLeft = 10
+		case strings.HasPrefix(domain, "untrusted."):
+			res.Trusted = false
+		case strings.HasPrefix(domain, "down."):
+			return nil, errors.New("connection refused")
+		}
+
+		return res, nil
+	}
+
+	return NewBatchHandler(svc, nil), &scans
+}
+
+func readNDJSON(t *testing.T, body string) ([]models.BatchItem, models.BatchSummary) {
+	t.Helper()
+
+	var (
+		items   []models.BatchItem
+		summary *models.BatchSummary
+	)
+
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		var ev models.BatchEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("bad line %q: %v", sc.Text(), err)
+		}
+
+		switch ev.Type {
+		case "result":
+			items = append(items, *ev.BatchItem)
+		case "summary":
+			summary = ev.BatchSummary
+		}
+	}
+
+	if summary == nil {
+		t.Fatalf("no summary in stream:\n%s", body)
+	}
+
+	return items, *summary
+}
+
+func TestBatchJSON(t *testing.T) {
+	h, scans := newBatchTestHandler(t)
+
+	body := `{"domains": ["ok.example.com", "expiring.example.com", "untrusted.example.com",
+		"down.example.com", "not a domain", "OK.example.com."]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/api/ssl/check/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("content type = %q (%s)", ct, rec.Body)
+	}
+
+	items, sum := readNDJSON(t, rec.Body.String())
+
+	// the duplicate is dropped
+	if len(items) != 5 || sum.Total != 5 {
+		t.Fatalf("got %d items, summary %+v", len(items), sum)
+	}
+
+	if sum.OK != 1 || sum.Warning != 1 || sum.Critical != 1 || sum.Errored != 2 {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+
+	if scans.Load() != 4 {
+		t.Errorf("invalid domain should not be scanned (scans=%d)", scans.Load())
+	}
+
+	for _, it := range items {
+		if it.Index == 4 && (it.Status != "error" || it.Result != nil) {
+			t.Errorf("invalid domain item %+v", it)
+		}
+	}
+}
+
+func TestBatchCSVAndSSE(t *testing.T) {
+	h, _ := newBatchTestHandler(t)
+
+	csv := "domain,port,protocol\n# comment\nok.example.com\nmail.example.com,587,smtp\n"
+
+	req := httptest.NewRequest(http.MethodPost, "/api/ssl/check/batch?format=sse", strings.NewReader(csv))
+	req.Header.Set("Content-Type", "text/csv")
+	rec := httptest.NewRecorder()
+
+	h.ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type = %q (%s)", ct, rec.Body)
+	}
+
+	out := rec.Body.String()
+
+	if strings.Count(out, "event: result\n") != 2 || !strings.Contains(out, "event: summary\n") {
+		t.Fatalf("unexpected stream:\n%s", out)
+	}
+
+	if !strings.Contains(out, `"port":587,"protocol":"smtp"`) {
+		t.Errorf("per-row port / protocol not applied:\n%s", out)
+	}
+}
+
+func TestBatchRejectsBadInput(t *testing.T) {
+	h, _ := newBatchTestHandler(t)
+
+	cases := map[string]string{
+		"empty":    `{"domains": []}`,
+		"mode":     `{"domains": ["a.example.com"], "mode": "deep"}`,
+		"protocol": `{"domains": ["a.example.com"], "protocol": "gopher"}`,
+		"too many": `{"domains": [` + batchDomains(501) + `]}`,
+	}
+
+	for name, body := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/api/ssl/check/batch", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d", name, rec.Code)
+		}
+	}
+}
+
+func batchDomains(n int) string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf(`"d%d.example.com"`, i)
+	}
+	return strings.Join(names, ",")
+}
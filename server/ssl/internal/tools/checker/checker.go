@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -227,6 +228,7 @@ func detectTLSVersion(state tls.ConnectionState) string {
 func Scan(
 	ctx context.Context,
 	domain string,
+	opts ScanOptions,
 ) (*models.SSLCheckResponse, error) {
 
 	select {
@@ -235,38 +237,60 @@ func Scan(
 	default:
 	}
 
+	if opts.AllEndpoints {
+		return scanAllEndpoints(ctx, domain, opts)
+	}
+
 	ip, err := resolveIP(ctx, domain)
 	if err != nil {
 		return nil, fmt.Errorf("dns resolve failed: %w", err)
 	}
 
+	return scanEndpoint(ctx, domain, ip, opts)
+}
+
+// scanEndpoint runs the full check against one resolved address.
+func scanEndpoint(
+	ctx context.Context,
+	domain string,
+	ip string,
+	opts ScanOptions,
+) (*models.SSLCheckResponse, error) {
+
 	dialer := &net.Dialer{Timeout: config.TLSDialTimeout}
 
-	addrDomain := net.JoinHostPort(domain, "443")
-	addrIP := net.JoinHostPort(ip, "443")
+	protocol := opts.protocol()
+	port := strconv.Itoa(opts.port())
+
+	addrDomain := net.JoinHostPort(domain, port)
+	addrIP := net.JoinHostPort(ip, port)
 
 	baseConf := &tls.Config{ServerName: domain}
 
-	var (
-		conn *tls.Conn
-	)
+	// Endpoint scans must stay on their address, no domain fallback
+	fallback := !opts.AllEndpoints
 
 	// Dial IP directly to bypass DNS hang, fallback to Domain just in case
-	conn, err = dialTLS(ctx, dialer, addrIP, baseConf)
-	if err != nil {
-		conn, err = dialTLS(ctx, dialer, addrDomain, baseConf)
+	conn, banner, err := dialTLS(ctx, dialer, addrIP, baseConf, protocol)
+	if err != nil && !isUpgradeError(err) && fallback {
+		conn, banner, err = dialTLS(ctx, dialer, addrDomain, baseConf, protocol)
 	}
 
 	if err != nil {
 
+		// Retrying without verification can't fix a refused STARTTLS
+		if isUpgradeError(err) {
+			return nil, err
+		}
+
 		log.Printf("[SSL] strict TLS failed (%s): %v", domain, err)
 
 		insecure := baseConf.Clone()
 		insecure.InsecureSkipVerify = true
 
-		conn, err = dialTLS(ctx, dialer, addrIP, insecure)
-		if err != nil {
-			conn, err = dialTLS(ctx, dialer, addrDomain, insecure)
+		conn, banner, err = dialTLS(ctx, dialer, addrIP, insecure, protocol)
+		if err != nil && fallback {
+			conn, banner, err = dialTLS(ctx, dialer, addrDomain, insecure, protocol)
 		}
 
 		if err != nil {
@@ -283,10 +307,32 @@ func Scan(
 		return nil, errors.New("no certificates found")
 	}
 
+	// Revocation lookups (OCSP / CRL) run alongside the HTTP probes and
+	// stop with the scan
+	revCtx, revCancel := context.WithTimeout(ctx, config.CRLFetchTimeout)
+	defer revCancel()
+
+	revDone := make(chan map[string]*models.RevocationInfo, 1)
+	go func() {
+		revDone <- checkRevocation(revCtx, revocationChain(certs), state.OCSPResponse)
+	}()
+
 	// Give the server type detector a fresh context since dialTLS might have consumed the parent
 	srvCtx, srvCancel := context.WithTimeout(context.Background(), 6*time.Second)
 	defer srvCancel()
-	serverType := detectServerType(srvCtx, domain, ip)
+
+	// HTTP probes only make sense for HTTPS; other services report their greeting
+	serverType := banner
+	var hsts *models.HSTSInfo
+
+	if protocol == config.ProtocolHTTPS {
+		probes := collectProbes(srvCtx, domain, ip, port)
+		serverType = detectServerType(probes)
+		hsts = detectHSTS(probes)
+	} else if serverType == "" {
+		serverType = "Unknown"
+	}
+
 	tlsVersion := detectTLSVersion(state)
 	hostnameOK := certs[0].VerifyHostname(domain) == nil
 
@@ -294,6 +340,42 @@ func Scan(
 	trust := analyzeTrust(certs, domain)
 
 	trusted := trust.Trusted
+
+	// [UPDATED] Gọi hàm buildFullCertChain đã refactor
+	chain := buildFullCertChain(certs, trusted)
+
+	revocations := <-revDone
+	for i := range chain {
+		chain[i].Revocation = revocations[chain[i].FingerprintSHA256]
+	}
+
+	if revoked := revocationIssues(chain); len(revoked) > 0 {
+		trust.Issues = append(trust.Issues, revoked...)
+		trusted = false
+	}
+
+	// Certificate Transparency (browsers only enforce it for public CAs)
+	verified, verifyErr := buildVerifiedChain(certs)
+	if verifyErr != nil {
+		verified = certs
+	}
+
+	ct := checkCT(
+		certs[0],
+		findIssuer(certs[0], verified),
+		state.SignedCertificateTimestamps,
+		state.OCSPResponse,
+		verifyErr == nil,
+	)
+
+	if ct.Policy == models.CTNotCompliant {
+		trust.Issues = append(trust.Issues, models.TrustIssue{
+			Code:    models.TrustCTPolicy,
+			Message: "Chứng chỉ không đáp ứng chính sách Certificate Transparency của trình duyệt: " + ct.Reason,
+		})
+		trusted = false
+	}
+
 	var trustReason string
 	if len(trust.Issues) > 0 {
 		var msgs []string
@@ -303,22 +385,31 @@ func Scan(
 		trustReason = strings.Join(msgs, " ")
 	}
 
-	// [UPDATED] Gọi hàm buildFullCertChain đã refactor
-	chain := buildFullCertChain(certs, trusted)
-
 	mainCert := certs[0]
 	now := time.Now()
 
 	daysLeft := int64(time.Until(mainCert.NotAfter).Hours() / 24)
 	valid := now.After(mainCert.NotBefore) && now.Before(mainCert.NotAfter)
 
-	return &models.SSLCheckResponse{
+	var protocols []models.ProtocolSupport
+	if opts.EnumerateProtocols {
+		protocols = enumerateProtocols(ctx, addrIP, domain, protocol)
+	}
+
+	res := &models.SSLCheckResponse{
 		Hostname:    domain,
 		IP:          ip,
+		Port:        opts.port(),
+		Protocol:    protocol,
 		ServerType:  serverType,
 		Valid:       valid,
 		DaysLeft:    daysLeft,
 		TLSVersion:  tlsVersion,
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		Protocols:   protocols,
+		HSTS:        hsts,
+		OCSPStapled: len(state.OCSPResponse) > 0,
+		CT:          ct,
 		HostnameOK:  hostnameOK,
 		Trusted:     trusted,
 		TrustIssues: trust.Issues,
@@ -326,7 +417,11 @@ func Scan(
 		CertChain:   chain,
 		CheckTime:   time.Now(),
 		Success:     true,
-	}, nil
+	}
+
+	res.Grade = gradeResponse(res)
+
+	return res, nil
 }
 
 // ===========================
@@ -465,6 +560,8 @@ func isFatalTrustIssue(code models.TrustCode) bool {
 		models.TrustUntrustedRoot,
 		models.TrustCertExpired,
 		models.TrustChainExpired,
+		models.TrustRevoked,
+		models.TrustCTPolicy,
 		// models.TrustNameMismatch,
 		models.TrustUnknown:
 
@@ -506,21 +603,32 @@ func dialTLS(
 	dialer *net.Dialer,
 	addr string,
 	conf *tls.Config,
-) (*tls.Conn, error) {
+	protocol string,
+) (*tls.Conn, string, error) {
 
 	raw, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	banner, err := upgradeConn(ctx, raw, protocol, conf.ServerName)
+	if err != nil {
+		raw.Close()
+		return nil, banner, err
 	}
 
 	conn := tls.Client(raw, conf)
 
 	if err = conn.HandshakeContext(ctx); err != nil {
 		raw.Close()
-		return nil, err
+		return nil, banner, err
 	}
 
-	return conn, nil
+	return conn, banner, nil
+}
+
+func isUpgradeError(err error) bool {
+	return errors.Is(err, ErrStartTLSUnsupported) || errors.Is(err, ErrStartTLSRefused)
 }
 
 // [MODIFIED] Logic xây dựng chain hiển thị (đã lọc Root hệ thống)
@@ -562,6 +670,7 @@ func buildFullCertChain(
 	for i, cert := range chainCerts {
 
 		fp1, fp256 := buildFingerprint(cert)
+		keyAlgo, keySize := publicKeyInfo(cert)
 
 		// Tính level dựa trên mảng đã rút gọn
 		level := detectChainLevel(i, total)
@@ -585,6 +694,9 @@ func buildFullCertChain(
 
 			SignatureAlgo: cert.SignatureAlgorithm.String(),
 
+			KeyAlgorithm: keyAlgo,
+			KeySize:      keySize,
+
 			FingerprintSHA1:   fp1,
 			FingerprintSHA256: fp256,
 
@@ -1,6 +1,11 @@
 package checker
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
 
 func isSecureCipher(cipher string) bool {
 
@@ -13,6 +18,7 @@ func isSecureCipher(cipher string) bool {
 		"MD5",
 		"NULL",
 		"EXPORT",
+		"_ANON_",
 	}
 
 	for _, bad := range insecure {
@@ -23,3 +29,155 @@ func isSecureCipher(cipher string) bool {
 
 	return true
 }
+
+/* ===========================
+   CIPHER CLASSIFICATION
+=========================== */
+
+func classifyCipher(name string) models.CipherStrength {
+
+	if !isSecureCipher(name) {
+		return models.CipherInsecure
+	}
+
+	// TLS 1.3 suites are always AEAD + forward secret
+	if !strings.Contains(name, "_WITH_") {
+		return models.CipherSecure
+	}
+
+	// No forward secrecy (static RSA key exchange) or CBC mode
+	if strings.HasPrefix(name, "TLS_RSA_") || strings.Contains(name, "_CBC_") {
+		return models.CipherWeak
+	}
+
+	return models.CipherSecure
+}
+
+func describeCipher(id uint16) models.CipherSuiteInfo {
+
+	name, ok := cipherSuiteNames[id]
+	if !ok {
+		name = fmt.Sprintf("UNKNOWN_0x%04X", id)
+	}
+
+	return models.CipherSuiteInfo{
+		ID:       fmt.Sprintf("0x%04X", id),
+		Name:     name,
+		Strength: classifyCipher(name),
+	}
+}
+
+// tls12Only reports whether a legacy suite needs TLS 1.2 (AEAD / SHA-2 MAC).
+func tls12Only(name string) bool {
+
+	return strings.Contains(name, "_GCM_") ||
+		strings.Contains(name, "_CHACHA20_") ||
+		strings.HasSuffix(name, "_SHA256") ||
+		strings.HasSuffix(name, "_SHA384")
+}
+
+/* ===========================
+   IANA CIPHER SUITES
+=========================== */
+
+var tls13CipherSuites = []uint16{0x1301, 0x1302, 0x1303}
+
+// Legacy suites offered to TLS 1.2 and below. Includes suites Go's
+// crypto/tls cannot negotiate, since probing only needs the ServerHello.
+var legacyCipherSuites = []uint16{
+	// ECDHE-ECDSA
+	0xC02B, 0xC02C, 0xCCA9, 0xC023, 0xC024, 0xC009, 0xC00A, 0xC008, 0xC007, 0xC006,
+
+	// ECDHE-RSA
+	0xC02F, 0xC030, 0xCCA8, 0xC027, 0xC028, 0xC013, 0xC014, 0xC012, 0xC011, 0xC010,
+
+	// DHE
+	0x009E, 0x009F, 0xCCAA, 0x0067, 0x006B, 0x0033, 0x0039, 0x0045, 0x0088,
+	0x0016, 0x0015, 0x0014, 0x0032, 0x0038, 0x0013,
+
+	// Static RSA
+	0x009C, 0x009D, 0x003C, 0x003D, 0x002F, 0x0035, 0x0041, 0x0084, 0x0096,
+	0x0007, 0x000A, 0x0009, 0x0005, 0x0004, 0x0003, 0x0006, 0x0008,
+	0x0001, 0x0002, 0x003B,
+
+	// Anonymous
+	0x0018, 0x001B, 0x0034, 0x003A, 0xC018, 0xC019,
+}
+
+var cipherSuiteNames = map[uint16]string{
+	// TLS 1.3
+	0x1301: "TLS_AES_128_GCM_SHA256",
+	0x1302: "TLS_AES_256_GCM_SHA384",
+	0x1303: "TLS_CHACHA20_POLY1305_SHA256",
+
+	// ECDHE-ECDSA
+	0xC02B: "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	0xC02C: "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	0xCCA9: "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+	0xC023: "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256",
+	0xC024: "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA384",
+	0xC009: "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+	0xC00A: "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+	0xC008: "TLS_ECDHE_ECDSA_WITH_3DES_EDE_CBC_SHA",
+	0xC007: "TLS_ECDHE_ECDSA_WITH_RC4_128_SHA",
+	0xC006: "TLS_ECDHE_ECDSA_WITH_NULL_SHA",
+
+	// ECDHE-RSA
+	0xC02F: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	0xC030: "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	0xCCA8: "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	0xC027: "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256",
+	0xC028: "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA384",
+	0xC013: "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
+	0xC014: "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
+	0xC012: "TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA",
+	0xC011: "TLS_ECDHE_RSA_WITH_RC4_128_SHA",
+	0xC010: "TLS_ECDHE_RSA_WITH_NULL_SHA",
+
+	// DHE
+	0x009E: "TLS_DHE_RSA_WITH_AES_128_GCM_SHA256",
+	0x009F: "TLS_DHE_RSA_WITH_AES_256_GCM_SHA384",
+	0xCCAA: "TLS_DHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	0x0067: "TLS_DHE_RSA_WITH_AES_128_CBC_SHA256",
+	0x006B: "TLS_DHE_RSA_WITH_AES_256_CBC_SHA256",
+	0x0033: "TLS_DHE_RSA_WITH_AES_128_CBC_SHA",
+	0x0039: "TLS_DHE_RSA_WITH_AES_256_CBC_SHA",
+	0x0045: "TLS_DHE_RSA_WITH_CAMELLIA_128_CBC_SHA",
+	0x0088: "TLS_DHE_RSA_WITH_CAMELLIA_256_CBC_SHA",
+	0x0016: "TLS_DHE_RSA_WITH_3DES_EDE_CBC_SHA",
+	0x0015: "TLS_DHE_RSA_WITH_DES_CBC_SHA",
+	0x0014: "TLS_DHE_RSA_EXPORT_WITH_DES40_CBC_SHA",
+	0x0032: "TLS_DHE_DSS_WITH_AES_128_CBC_SHA",
+	0x0038: "TLS_DHE_DSS_WITH_AES_256_CBC_SHA",
+	0x0013: "TLS_DHE_DSS_WITH_3DES_EDE_CBC_SHA",
+
+	// Static RSA
+	0x009C: "TLS_RSA_WITH_AES_128_GCM_SHA256",
+	0x009D: "TLS_RSA_WITH_AES_256_GCM_SHA384",
+	0x003C: "TLS_RSA_WITH_AES_128_CBC_SHA256",
+	0x003D: "TLS_RSA_WITH_AES_256_CBC_SHA256",
+	0x002F: "TLS_RSA_WITH_AES_128_CBC_SHA",
+	0x0035: "TLS_RSA_WITH_AES_256_CBC_SHA",
+	0x0041: "TLS_RSA_WITH_CAMELLIA_128_CBC_SHA",
+	0x0084: "TLS_RSA_WITH_CAMELLIA_256_CBC_SHA",
+	0x0096: "TLS_RSA_WITH_SEED_CBC_SHA",
+	0x0007: "TLS_RSA_WITH_IDEA_CBC_SHA",
+	0x000A: "TLS_RSA_WITH_3DES_EDE_CBC_SHA",
+	0x0009: "TLS_RSA_WITH_DES_CBC_SHA",
+	0x0005: "TLS_RSA_WITH_RC4_128_SHA",
+	0x0004: "TLS_RSA_WITH_RC4_128_MD5",
+	0x0003: "TLS_RSA_EXPORT_WITH_RC4_40_MD5",
+	0x0006: "TLS_RSA_EXPORT_WITH_RC2_CBC_40_MD5",
+	0x0008: "TLS_RSA_EXPORT_WITH_DES40_CBC_SHA",
+	0x0001: "TLS_RSA_WITH_NULL_MD5",
+	0x0002: "TLS_RSA_WITH_NULL_SHA",
+	0x003B: "TLS_RSA_WITH_NULL_SHA256",
+
+	// Anonymous
+	0x0018: "TLS_DH_anon_WITH_RC4_128_MD5",
+	0x001B: "TLS_DH_anon_WITH_3DES_EDE_CBC_SHA",
+	0x0034: "TLS_DH_anon_WITH_AES_128_CBC_SHA",
+	0x003A: "TLS_DH_anon_WITH_AES_256_CBC_SHA",
+	0xC018: "TLS_ECDH_anon_WITH_AES_128_CBC_SHA",
+	0xC019: "TLS_ECDH_anon_WITH_AES_256_CBC_SHA",
+}
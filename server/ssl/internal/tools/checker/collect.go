@@ -23,7 +23,7 @@ type Probe struct {
 	Error    error
 }
 
-func collectProbes(ctx context.Context, domain string, ip string) []*Probe {
+func collectProbes(ctx context.Context, domain string, ip string, port string) []*Probe {
 	// Create custom dialer enforcing the resolved IP
 	dialer := &net.Dialer{Timeout: 5 * time.Second} // Use HTTPHeadTimeout roughly
 
@@ -58,14 +58,21 @@ func collectProbes(ctx context.Context, domain string, ip string) []*Probe {
 	insecureClient := &http.Client{Timeout: timeout, Transport: insecureTransport, CheckRedirect: noRedirect}
 	plainClient := &http.Client{Timeout: timeout, Transport: plainTransport, CheckRedirect: noRedirect}
 
+	host := domain
+	if port != "443" {
+		host = net.JoinHostPort(domain, port)
+	}
+
 	defs := []probeDef{
 		// Prefer HTTPS GET first as it yields the most complete headers
-		{strictClient, "https://" + domain, http.MethodGet},
-		{insecureClient, "https://" + domain, http.MethodGet},
-		{strictClient, "https://" + domain, http.MethodHead},
+		{strictClient, "https://" + host, http.MethodGet},
+		{insecureClient, "https://" + host, http.MethodGet},
+		{strictClient, "https://" + host, http.MethodHead},
+	}
 
-		// Fallbacks
-		{plainClient, "http://" + domain, http.MethodGet},
+	// Fallbacks (plain HTTP only lives on :80 next to the default port)
+	if port == "443" {
+		defs = append(defs, probeDef{plainClient, "http://" + domain, http.MethodGet})
 	}
 
 	var validProbes []*Probe
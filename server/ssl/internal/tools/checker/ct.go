@@ -0,0 +1,491 @@
+package checker
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cbasn1 "golang.org/x/crypto/cryptobyte/asn1"
+	"golang.org/x/crypto/ocsp"
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+/* ===========================
+   CERTIFICATE TRANSPARENCY (RFC 6962)
+===========================
+
+   SCTs arrive three ways: embedded in the leaf (signed over the
+   precertificate), in the TLS extension or in the stapled OCSP
+   response (both signed over the final certificate).
+*/
+
+var (
+	oidSCTList     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+	oidOCSPSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 5}
+
+	errMalformedSCT = errors.New("malformed SCT")
+)
+
+const (
+	sctVersionV1 = 0
+
+	entryTypeX509    uint16 = 0
+	entryTypePrecert uint16 = 1
+
+	sigTypeCertificateTimestamp = 0
+
+	hashAlgSHA256 = 4
+	sigAlgRSA     = 1
+	sigAlgECDSA   = 3
+)
+
+type sct struct {
+	source     models.SCTSource
+	version    uint8
+	logID      [32]byte
+	timestamp  uint64
+	extensions []byte
+	hashAlg    uint8
+	sigAlg     uint8
+	signature  []byte
+}
+
+func (s *sct) time() time.Time {
+	return time.UnixMilli(int64(s.timestamp)).UTC()
+}
+
+/* ===========================
+   PARSING
+=========================== */
+
+func parseSCT(raw []byte, source models.SCTSource) (*sct, error) {
+
+	in := cryptobyte.String(raw)
+	s := &sct{source: source}
+
+	var logID []byte
+	var ext, sig cryptobyte.String
+
+	if !in.ReadUint8(&s.version) ||
+		!in.ReadBytes(&logID, 32) ||
+		!in.ReadUint64(&s.timestamp) ||
+		!in.ReadUint16LengthPrefixed(&ext) ||
+		!in.ReadUint8(&s.hashAlg) ||
+		!in.ReadUint8(&s.sigAlg) ||
+		!in.ReadUint16LengthPrefixed(&sig) ||
+		!in.Empty() {
+		return nil, errMalformedSCT
+	}
+
+	copy(s.logID[:], logID)
+	s.extensions = ext
+	s.signature = sig
+
+	return s, nil
+}
+
+// parseSCTList decodes a SignedCertificateTimestampList.
+func parseSCTList(raw []byte, source models.SCTSource) ([]*sct, error) {
+
+	in := cryptobyte.String(raw)
+
+	var list cryptobyte.String
+	if !in.ReadUint16LengthPrefixed(&list) || !in.Empty() {
+		return nil, errMalformedSCT
+	}
+
+	var out []*sct
+
+	for !list.Empty() {
+
+		var item cryptobyte.String
+		if !list.ReadUint16LengthPrefixed(&item) {
+			return out, errMalformedSCT
+		}
+
+		s, err := parseSCT(item, source)
+		if err != nil {
+			return out, err
+		}
+
+		out = append(out, s)
+	}
+
+	return out, nil
+}
+
+// sctListFromExtension unwraps the OCTET STRING around an SCT list extension.
+func sctListFromExtension(value []byte, source models.SCTSource) ([]*sct, error) {
+
+	var inner []byte
+	if _, err := asn1.Unmarshal(value, &inner); err != nil {
+		return nil, err
+	}
+
+	return parseSCTList(inner, source)
+}
+
+// collectSCTs gathers SCTs from every delivery mechanism. Parse errors are
+// returned alongside whatever could be read.
+func collectSCTs(
+	leaf *x509.Certificate,
+	issuer *x509.Certificate,
+	tlsSCTs [][]byte,
+	stapled []byte,
+) ([]*sct, []error) {
+
+	var (
+		out  []*sct
+		errs []error
+	)
+
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(oidSCTList) {
+			scts, err := sctListFromExtension(ext.Value, models.SCTSourceEmbedded)
+			out = append(out, scts...)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("embedded SCTs: %w", err))
+			}
+		}
+	}
+
+	for _, raw := range tlsSCTs {
+		s, err := parseSCT(raw, models.SCTSourceTLS)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("TLS SCT: %w", err))
+			continue
+		}
+		out = append(out, s)
+	}
+
+	if len(stapled) > 0 && issuer != nil {
+		if resp, err := ocsp.ParseResponseForCert(stapled, leaf, issuer); err == nil {
+			for _, ext := range resp.Extensions {
+				if ext.Id.Equal(oidOCSPSCTList) {
+					scts, err := sctListFromExtension(ext.Value, models.SCTSourceOCSP)
+					out = append(out, scts...)
+					if err != nil {
+						errs = append(errs, fmt.Errorf("OCSP SCTs: %w", err))
+					}
+				}
+			}
+		}
+	}
+
+	return out, errs
+}
+
+/* ===========================
+   VERIFICATION
+=========================== */
+
+// precertTBS rebuilds the TBSCertificate the log signed: the final one
+// without the SCT list extension.
+func precertTBS(tbs []byte) ([]byte, error) {
+
+	in := cryptobyte.String(tbs)
+
+	var body cryptobyte.String
+	if !in.ReadASN1(&body, cbasn1.SEQUENCE) {
+		return nil, errors.New("malformed TBSCertificate")
+	}
+
+	extTag := cbasn1.Tag(3).Constructed().ContextSpecific()
+
+	b := cryptobyte.NewBuilder(nil)
+	b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+
+		for !body.Empty() {
+
+			var elem cryptobyte.String
+			var tag cbasn1.Tag
+
+			if !body.ReadAnyASN1Element(&elem, &tag) {
+				b.SetError(errors.New("malformed TBSCertificate"))
+				return
+			}
+
+			if tag != extTag {
+				b.AddBytes(elem)
+				continue
+			}
+
+			var wrapper, exts cryptobyte.String
+			if !elem.ReadASN1(&wrapper, extTag) || !wrapper.ReadASN1(&exts, cbasn1.SEQUENCE) {
+				b.SetError(errors.New("malformed extensions"))
+				return
+			}
+
+			b.AddASN1(extTag, func(b *cryptobyte.Builder) {
+				b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+
+					for !exts.Empty() {
+
+						var ext, extBody cryptobyte.String
+						var oid asn1.ObjectIdentifier
+
+						if !exts.ReadASN1Element(&ext, cbasn1.SEQUENCE) {
+							b.SetError(errors.New("malformed extension"))
+							return
+						}
+
+						raw := ext
+						if !raw.ReadASN1(&extBody, cbasn1.SEQUENCE) ||
+							!extBody.ReadASN1ObjectIdentifier(&oid) {
+							b.SetError(errors.New("malformed extension"))
+							return
+						}
+
+						if oid.Equal(oidSCTList) {
+							continue
+						}
+
+						b.AddBytes(ext)
+					}
+				})
+			})
+		}
+	})
+
+	return b.Bytes()
+}
+
+// sctSignedData builds the digitally-signed struct of RFC 6962 3.2.
+func sctSignedData(
+	s *sct,
+	leaf *x509.Certificate,
+	issuer *x509.Certificate,
+) ([]byte, error) {
+
+	b := cryptobyte.NewBuilder(nil)
+
+	b.AddUint8(s.version)
+	b.AddUint8(sigTypeCertificateTimestamp)
+	b.AddUint64(s.timestamp)
+
+	if s.source == models.SCTSourceEmbedded {
+
+		if issuer == nil {
+			return nil, errors.New("issuer certificate not available")
+		}
+
+		tbs, err := precertTBS(leaf.RawTBSCertificate)
+		if err != nil {
+			return nil, err
+		}
+
+		keyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+
+		b.AddUint16(entryTypePrecert)
+		b.AddBytes(keyHash[:])
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(tbs)
+		})
+	} else {
+		b.AddUint16(entryTypeX509)
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(leaf.Raw)
+		})
+	}
+
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(s.extensions)
+	})
+
+	return b.Bytes()
+}
+
+func verifySCT(
+	s *sct,
+	key crypto.PublicKey,
+	leaf *x509.Certificate,
+	issuer *x509.Certificate,
+) error {
+
+	if s.version != sctVersionV1 {
+		return fmt.Errorf("unsupported SCT version %d", s.version)
+	}
+
+	if s.hashAlg != hashAlgSHA256 {
+		return fmt.Errorf("unsupported hash algorithm %d", s.hashAlg)
+	}
+
+	data, err := sctSignedData(s, leaf, issuer)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(data)
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if s.sigAlg != sigAlgECDSA || !ecdsa.VerifyASN1(k, digest[:], s.signature) {
+			return errors.New("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if s.sigAlg != sigAlgRSA || rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], s.signature) != nil {
+			return errors.New("invalid signature")
+		}
+	default:
+		return errors.New("unsupported log key type")
+	}
+
+	if s.time().After(time.Now().Add(time.Minute)) {
+		return errors.New("timestamp is in the future")
+	}
+
+	return nil
+}
+
+/* ===========================
+   REPORT + BROWSER POLICY
+=========================== */
+
+// checkCT verifies every SCT against the active log list and evaluates the
+// browser CT policy. publiclyTrusted is false for private CAs, which
+// browsers don't require CT for.
+func checkCT(
+	leaf *x509.Certificate,
+	issuer *x509.Certificate,
+	tlsSCTs [][]byte,
+	stapled []byte,
+	publiclyTrusted bool,
+) *models.CTInfo {
+
+	logs := currentCTLogs()
+	scts, errs := collectSCTs(leaf, issuer, tlsSCTs, stapled)
+
+	info := &models.CTInfo{
+		SCTs:           make([]models.SCTInfo, 0, len(scts)),
+		LogListVersion: logs.Version,
+	}
+
+	var qualifying []*sct
+
+	for _, s := range scts {
+
+		out := models.SCTInfo{
+			Source:    s.source,
+			LogID:     base64.StdEncoding.EncodeToString(s.logID[:]),
+			Timestamp: s.time(),
+		}
+
+		l, ok := logs.Logs[s.logID]
+		if !ok {
+			out.Status = models.SCTUnknownLog
+			info.SCTs = append(info.SCTs, out)
+			continue
+		}
+
+		out.LogName = l.Name
+		out.LogOperator = l.Operator
+		out.LogState = l.State
+
+		if err := verifySCT(s, l.Key, leaf, issuer); err != nil {
+			out.Status = models.SCTInvalid
+			out.Error = err.Error()
+		} else {
+			out.Status = models.SCTValid
+			if sctCountsForPolicy(l, s) {
+				qualifying = append(qualifying, s)
+			}
+		}
+
+		info.SCTs = append(info.SCTs, out)
+	}
+
+	switch {
+	case !publiclyTrusted:
+		info.Policy = models.CTNotChecked
+		info.Reason = "Chứng chỉ không do CA công khai cấp, trình duyệt không yêu cầu CT."
+	case len(logs.Logs) == 0:
+		info.Policy = models.CTNotChecked
+		info.Reason = "Chưa tải được danh sách CT log."
+	default:
+		info.Policy, info.Reason = evaluateCTPolicy(leaf, qualifying, logs)
+	}
+
+	if len(errs) > 0 && info.Policy != models.CTCompliant && info.Reason != "" {
+		info.Reason += " (" + errors.Join(errs...).Error() + ")"
+	}
+
+	return info
+}
+
+// sctCountsForPolicy follows Chrome: logs must be qualified, usable or
+// read-only, or retired after the SCT was issued.
+func sctCountsForPolicy(l *ctLog, s *sct) bool {
+
+	switch l.State {
+	case "qualified", "usable", "readonly":
+		return true
+	case "retired":
+		return s.time().Before(l.StateSince)
+	default:
+		return false
+	}
+}
+
+// evaluateCTPolicy: SCTs from at least two log operators; embedded-only
+// certificates need 2 SCTs (<= 180 days lifetime) or 3 SCTs.
+func evaluateCTPolicy(
+	leaf *x509.Certificate,
+	scts []*sct,
+	logs *ctLogList,
+) (models.CTPolicyStatus, string) {
+
+	operators := map[string]bool{}
+	distinctLogs := map[[32]byte]bool{}
+	embedded := 0
+	delivered := 0
+
+	for _, s := range scts {
+
+		if distinctLogs[s.logID] {
+			continue
+		}
+		distinctLogs[s.logID] = true
+		operators[logs.Logs[s.logID].Operator] = true
+
+		if s.source == models.SCTSourceEmbedded {
+			embedded++
+		} else {
+			delivered++
+		}
+	}
+
+	if len(distinctLogs) == 0 {
+		return models.CTNotCompliant, "Chứng chỉ không có SCT hợp lệ nào từ CT log được trình duyệt tin cậy."
+	}
+
+	if len(operators) < 2 {
+		return models.CTNotCompliant, "Các SCT hợp lệ chỉ đến từ một đơn vị vận hành CT log (cần ít nhất 2)."
+	}
+
+	if delivered > 0 {
+		return models.CTCompliant, ""
+	}
+
+	required := 2
+	if leaf.NotAfter.Sub(leaf.NotBefore) > config.CTLongLivedCertDays*24*time.Hour {
+		required = 3
+	}
+
+	if embedded < required {
+		return models.CTNotCompliant, fmt.Sprintf(
+			"Chứng chỉ chỉ có %d SCT hợp lệ, chính sách CT của trình duyệt yêu cầu %d.",
+			embedded, required,
+		)
+	}
+
+	return models.CTCompliant, ""
+}
@@ -0,0 +1,291 @@
+package checker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+type testLog struct {
+	name     string
+	operator string
+	key      *ecdsa.PrivateKey
+	id       [32]byte
+}
+
+func newTestLog(t *testing.T, name, operator string) *testLog {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testLog{name: name, operator: operator, key: key, id: sha256.Sum256(der)}
+}
+
+// installLogs swaps the active log list for the test logs.
+func installLogs(t *testing.T, logs ...*testLog) {
+	t.Helper()
+
+	type logEntry struct {
+		Description string                    `json:"description"`
+		Key         string                    `json:"key"`
+		State       map[string]map[string]any `json:"state"`
+	}
+
+	operators := map[string][]logEntry{}
+
+	for _, l := range logs {
+		der, _ := x509.MarshalPKIXPublicKey(&l.key.PublicKey)
+		operators[l.operator] = append(operators[l.operator], logEntry{
+			Description: l.name,
+			Key:         base64.StdEncoding.EncodeToString(der),
+			State:       map[string]map[string]any{"usable": {"timestamp": "2024-01-01T00:00:00Z"}},
+		})
+	}
+
+	var doc struct {
+		Version   string `json:"version"`
+		Operators []any  `json:"operators"`
+	}
+	doc.Version = "test"
+
+	for name, entries := range operators {
+		doc.Operators = append(doc.Operators, map[string]any{"name": name, "logs": entries})
+	}
+
+	data, _ := json.Marshal(doc)
+
+	list, err := parseCTLogList(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orig := ctLogs.Load()
+	ctLogs.Store(list)
+	t.Cleanup(func() { ctLogs.Store(orig) })
+}
+
+// sign creates a serialized SCT from l over the given entry.
+func (l *testLog) sign(t *testing.T, entryType uint16, entry []byte, issuerKeyHash []byte) []byte {
+	t.Helper()
+
+	ts := uint64(time.Now().Add(-time.Minute).UnixMilli())
+
+	data := cryptobyte.NewBuilder(nil)
+	data.AddUint8(sctVersionV1)
+	data.AddUint8(sigTypeCertificateTimestamp)
+	data.AddUint64(ts)
+	data.AddUint16(entryType)
+	data.AddBytes(issuerKeyHash)
+	data.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(entry) })
+	data.AddUint16(0) // no extensions
+
+	digest := sha256.Sum256(data.BytesOrPanic())
+
+	sig, err := ecdsa.SignASN1(rand.Reader, l.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := cryptobyte.NewBuilder(nil)
+	out.AddUint8(sctVersionV1)
+	out.AddBytes(l.id[:])
+	out.AddUint64(ts)
+	out.AddUint16(0)
+	out.AddUint8(hashAlgSHA256)
+	out.AddUint8(sigAlgECDSA)
+	out.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sig) })
+
+	return out.BytesOrPanic()
+}
+
+// issueWithSCTs issues a leaf whose embedded SCTs cover its precertificate.
+func issueWithSCTs(t *testing.T, ca *testCA, lifetime time.Duration, logs ...*testLog) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(99),
+		Subject:      pkix.Name{CommonName: "example.test"},
+		DNSNames:     []string{"example.test"},
+		NotBefore:    now,
+		NotAfter:     now.Add(lifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	create := func() *x509.Certificate {
+		der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	precert := create()
+	keyHash := sha256.Sum256(ca.cert.RawSubjectPublicKeyInfo)
+
+	list := cryptobyte.NewBuilder(nil)
+	list.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, l := range logs {
+			raw := l.sign(t, entryTypePrecert, precert.RawTBSCertificate, keyHash[:])
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(raw) })
+		}
+	})
+
+	value, err := asn1.Marshal(list.BytesOrPanic())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template.ExtraExtensions = []pkix.Extension{{Id: oidSCTList, Value: value}}
+
+	return create()
+}
+
+func TestCTEmbeddedSCTs(t *testing.T) {
+	ca := newTestCA(t)
+	argon := newTestLog(t, "Test Argon", "Google")
+	nimbus := newTestLog(t, "Test Nimbus", "Cloudflare")
+	installLogs(t, argon, nimbus)
+
+	leaf := issueWithSCTs(t, ca, 90*24*time.Hour, argon, nimbus)
+
+	info := checkCT(leaf, ca.cert, nil, nil, true)
+
+	if len(info.SCTs) != 2 {
+		t.Fatalf("expected 2 SCTs, got %+v", info.SCTs)
+	}
+	for _, s := range info.SCTs {
+		if s.Status != models.SCTValid || s.Source != models.SCTSourceEmbedded || s.LogName == "" {
+			t.Errorf("unexpected SCT %+v", s)
+		}
+	}
+	if info.Policy != models.CTCompliant {
+		t.Errorf("expected compliant, got %s (%s)", info.Policy, info.Reason)
+	}
+
+	// A year-long certificate needs a third SCT
+	long := issueWithSCTs(t, ca, 365*24*time.Hour, argon, nimbus)
+	if info := checkCT(long, ca.cert, nil, nil, true); info.Policy != models.CTNotCompliant {
+		t.Errorf("expected not compliant for long-lived cert, got %s", info.Policy)
+	}
+
+	// Wrong issuer breaks the precertificate signature
+	other := newTestCA(t)
+	if info := checkCT(leaf, other.cert, nil, nil, true); info.SCTs[0].Status != models.SCTInvalid {
+		t.Errorf("expected invalid SCT with wrong issuer, got %+v", info.SCTs[0])
+	}
+}
+
+func TestCTTLSExtensionSCTs(t *testing.T) {
+	ca := newTestCA(t)
+	argon := newTestLog(t, "Test Argon", "Google")
+	nimbus := newTestLog(t, "Test Nimbus", "Cloudflare")
+	installLogs(t, argon)
+
+	leaf := ca.issue(t, 5, "", "")
+
+	tlsSCTs := [][]byte{
+		argon.sign(t, entryTypeX509, leaf.Raw, nil),
+		nimbus.sign(t, entryTypeX509, leaf.Raw, nil),
+	}
+
+	info := checkCT(leaf, ca.cert, tlsSCTs, nil, true)
+
+	if info.SCTs[0].Status != models.SCTValid || info.SCTs[0].Source != models.SCTSourceTLS {
+		t.Errorf("expected valid TLS SCT, got %+v", info.SCTs[0])
+	}
+	if info.SCTs[1].Status != models.SCTUnknownLog {
+		t.Errorf("expected unknown log, got %+v", info.SCTs[1])
+	}
+
+	// One operator only
+	if info.Policy != models.CTNotCompliant {
+		t.Errorf("expected not compliant, got %s", info.Policy)
+	}
+
+	// Private CAs are not subject to the policy
+	if info := checkCT(leaf, ca.cert, nil, nil, false); info.Policy != models.CTNotChecked {
+		t.Errorf("expected not checked, got %s", info.Policy)
+	}
+}
+
+// The bundled snapshot must hold the real logs: it is all there is until
+// CTLogUpdater succeeds, which may be never on an offline host.
+func TestCTBundledLogList(t *testing.T) {
+	list, err := parseCTLogList(bundledCTLogList)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operators := map[string]bool{}
+	for _, l := range list.Logs {
+		operators[l.Operator] = true
+	}
+
+	// Chrome's policy needs SCTs from two operators
+	if len(list.Logs) == 0 || len(operators) < 2 {
+		t.Fatalf("bundled list has %d logs from %d operators, run go generate", len(list.Logs), len(operators))
+	}
+}
+
+func TestCTLogStatePriority(t *testing.T) {
+
+	l := newTestLog(t, "Multi", "Op")
+	der, _ := x509.MarshalPKIXPublicKey(&l.key.PublicKey)
+
+	data, _ := json.Marshal(map[string]any{
+		"version": "test",
+		"operators": []any{map[string]any{
+			"name": "Op",
+			"logs": []any{map[string]any{
+				"description": "Multi",
+				"key":         base64.StdEncoding.EncodeToString(der),
+				"state": map[string]any{
+					"usable":  map[string]any{"timestamp": "2024-01-01T00:00:00Z"},
+					"retired": map[string]any{"timestamp": "2025-01-01T00:00:00Z"},
+				},
+			}},
+		}},
+	})
+
+	for range 20 {
+		list, err := parseCTLogList(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := list.Logs[l.id].State; got != "retired" {
+			t.Fatalf("expected retired, got %s", got)
+		}
+	}
+}
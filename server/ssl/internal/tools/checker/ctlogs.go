@@ -0,0 +1,272 @@
+package checker
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+)
+
+/* ===========================
+   CT LOG LIST
+===========================
+
+   The bundled snapshot uses Chrome's v3 schema. Refresh it with
+   `go generate`, or let CTLogUpdater download the current list at
+   runtime (and persist it to CT_LOG_LIST_FILE when set).
+*/
+
+//go:generate curl -sSfLo ct_log_list.json https://www.gstatic.com/ct/log_list/v3/log_list.json
+
+//go:embed ct_log_list.json
+var bundledCTLogList []byte
+
+type ctLog struct {
+	ID       [32]byte
+	Name     string
+	Operator string
+	Key      crypto.PublicKey
+
+	// State is the current log state; StateSince when it was entered
+	State      string
+	StateSince time.Time
+}
+
+type ctLogList struct {
+	Version   string
+	Timestamp time.Time
+	Logs      map[[32]byte]*ctLog
+}
+
+var ctLogs atomic.Pointer[ctLogList]
+
+func init() {
+
+	list, err := parseCTLogList(bundledCTLogList)
+	if err != nil {
+		log.Printf("[CT] bundled log list is invalid: %v", err)
+		list = &ctLogList{Logs: map[[32]byte]*ctLog{}}
+	}
+
+	ctLogs.Store(list)
+}
+
+// currentCTLogs returns the active log list (never nil).
+func currentCTLogs() *ctLogList {
+	return ctLogs.Load()
+}
+
+/* ---- JSON schema (v3) ---- */
+
+type logListJSON struct {
+	Version          string         `json:"version"`
+	LogListTimestamp time.Time      `json:"log_list_timestamp"`
+	Operators        []operatorJSON `json:"operators"`
+}
+
+type operatorJSON struct {
+	Name      string    `json:"name"`
+	Logs      []logJSON `json:"logs"`
+	TiledLogs []logJSON `json:"tiled_logs"`
+}
+
+type logJSON struct {
+	Description string                  `json:"description"`
+	Key         string                  `json:"key"`
+	State       map[string]logStateJSON `json:"state"`
+}
+
+type logStateJSON struct {
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ctLogStatePriority orders log states from most to least restrictive, so
+// a log listed with several states is treated as the most restrictive one.
+var ctLogStatePriority = []string{
+	"rejected",
+	"retired",
+	"readonly",
+	"pending",
+	"qualified",
+	"usable",
+}
+
+func parseCTLogList(data []byte) (*ctLogList, error) {
+
+	var raw logListJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	list := &ctLogList{
+		Version:   raw.Version,
+		Timestamp: raw.LogListTimestamp,
+		Logs:      make(map[[32]byte]*ctLog),
+	}
+
+	for _, op := range raw.Operators {
+		for _, l := range slices.Concat(op.Logs, op.TiledLogs) {
+
+			der, err := base64.StdEncoding.DecodeString(l.Key)
+			if err != nil {
+				return nil, fmt.Errorf("log %q: bad key: %w", l.Description, err)
+			}
+
+			key, err := x509.ParsePKIXPublicKey(der)
+			if err != nil {
+				return nil, fmt.Errorf("log %q: %w", l.Description, err)
+			}
+
+			entry := &ctLog{
+				ID:       sha256.Sum256(der),
+				Name:     l.Description,
+				Operator: op.Name,
+				Key:      key,
+			}
+
+			// The schema allows one state, but never let map order decide
+			for _, state := range ctLogStatePriority {
+				if v, ok := l.State[state]; ok {
+					entry.State = state
+					entry.StateSince = v.Timestamp
+					break
+				}
+			}
+
+			list.Logs[entry.ID] = entry
+		}
+	}
+
+	return list, nil
+}
+
+// LoadCTLogListFile replaces the active log list with the one in path.
+func LoadCTLogListFile(path string) error {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	list, err := parseCTLogList(data)
+	if err != nil {
+		return err
+	}
+
+	ctLogs.Store(list)
+
+	return nil
+}
+
+/* ===========================
+   UPDATER
+=========================== */
+
+// CTLogUpdater periodically downloads the published log list.
+type CTLogUpdater struct {
+	url    string
+	path   string
+	client *http.Client
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewCTLogUpdater creates an updater; path may be empty to keep the list
+// in memory only.
+func NewCTLogUpdater(path string) *CTLogUpdater {
+	return &CTLogUpdater{
+		url:    config.CTLogListURL,
+		path:   path,
+		client: &http.Client{Timeout: config.CTLogListFetchTimeout},
+		stop:   make(chan struct{}),
+	}
+}
+
+func (u *CTLogUpdater) Start() {
+
+	// A previously downloaded list beats the bundled snapshot
+	if u.path != "" {
+		if err := LoadCTLogListFile(u.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[CT] cannot load %s: %v", u.path, err)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.CTLogListRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := u.refresh(); err != nil {
+				log.Printf("[CT] log list refresh failed: %v", err)
+			}
+
+			select {
+			case <-u.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (u *CTLogUpdater) Stop() {
+	u.once.Do(func() { close(u.stop) })
+}
+
+func (u *CTLogUpdater) refresh() error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.CTLogListFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, config.MaxCTLogListSize))
+	if err != nil {
+		return err
+	}
+
+	list, err := parseCTLogList(data)
+	if err != nil {
+		return err
+	}
+
+	ctLogs.Store(list)
+	log.Printf("[CT] log list %s loaded (%d logs)", list.Version, len(list.Logs))
+
+	if u.path != "" {
+		if err := os.WriteFile(u.path, data, 0o644); err != nil {
+			log.Printf("[CT] cannot save %s: %v", u.path, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+/* ===========================
+   MULTI-ENDPOINT SCAN
+===========================
+
+   Anycast / round-robin setups can serve a different certificate per
+   backend. Every public A / AAAA address is scanned with the same SNI and
+   the endpoints that disagree with the majority are flagged.
+*/
+
+// resolveAllIPs merges the answers of both resolvers, IPv4 first.
+func resolveAllIPs(ctx context.Context, domain string) ([]string, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		all     []net.IP
+		lastErr error
+	)
+
+	lookups := []func() ([]net.IP, error){
+		func() ([]net.IP, error) { return lookupWithDNS(ctx, domain, "8.8.8.8:53") },
+		func() ([]net.IP, error) { return (&net.Resolver{}).LookupIP(ctx, "ip", domain) },
+	}
+
+	for _, lookup := range lookups {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ips, err := lookup()
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				lastErr = err
+				return
+			}
+			all = append(all, ips...)
+		}()
+	}
+
+	wg.Wait()
+
+	var v4, v6 []string
+
+	for _, ip := range all {
+
+		if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+			continue
+		}
+
+		if ip4 := ip.To4(); ip4 != nil {
+			if s := ip4.String(); !slices.Contains(v4, s) {
+				v4 = append(v4, s)
+			}
+		} else if s := ip.String(); !slices.Contains(v6, s) {
+			v6 = append(v6, s)
+		}
+	}
+
+	ips := append(v4, v6...)
+
+	if len(ips) == 0 {
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, ErrNoIP
+	}
+
+	return ips, nil
+}
+
+func scanAllEndpoints(
+	ctx context.Context,
+	domain string,
+	opts ScanOptions,
+) (*models.SSLCheckResponse, error) {
+
+	ips, err := resolveAllIPs(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("dns resolve failed: %w", err)
+	}
+
+	if len(ips) > config.MaxScanEndpoints {
+		ips = ips[:config.MaxScanEndpoints]
+	}
+
+	results := make([]*models.SSLCheckResponse, len(ips))
+	errs := make([]error, len(ips))
+
+	var wg sync.WaitGroup
+
+	for i, ip := range ips {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = scanEndpoint(ctx, domain, ip, opts)
+		}()
+	}
+
+	wg.Wait()
+
+	// The first address that answered is reported in full
+	primary := slices.IndexFunc(results, func(r *models.SSLCheckResponse) bool { return r != nil })
+	if primary < 0 {
+		return nil, errs[0]
+	}
+
+	endpoints := make([]models.EndpointResult, len(ips))
+
+	for i, ip := range ips {
+		endpoints[i] = summarizeEndpoint(ip, results[i], errs[i])
+	}
+
+	res := results[primary]
+	res.Endpoints = endpoints
+	res.EndpointMismatch = compareEndpoints(res.Endpoints)
+
+	return res, nil
+}
+
+func summarizeEndpoint(
+	ip string,
+	res *models.SSLCheckResponse,
+	err error,
+) models.EndpointResult {
+
+	if err != nil {
+		return models.EndpointResult{IP: ip, Error: err.Error()}
+	}
+
+	ep := models.EndpointResult{
+		IP:          ip,
+		Success:     true,
+		ServerType:  res.ServerType,
+		TLSVersion:  res.TLSVersion,
+		CipherSuite: res.CipherSuite,
+		DaysLeft:    res.DaysLeft,
+		Trusted:     res.Trusted,
+	}
+
+	for _, c := range res.CertChain {
+		ep.ChainFingerprints = append(ep.ChainFingerprints, c.FingerprintSHA256)
+	}
+
+	if len(ep.ChainFingerprints) > 0 {
+		ep.LeafFingerprint = ep.ChainFingerprints[0]
+	}
+
+	if res.Grade != nil {
+		ep.Grade = res.Grade.Grade
+	}
+
+	return ep
+}
+
+// compareEndpoints marks every endpoint that differs from the most common
+// value and reports whether any did.
+func compareEndpoints(endpoints []models.EndpointResult) bool {
+
+	attrs := []struct {
+		diff  models.EndpointDiff
+		value func(models.EndpointResult) string
+	}{
+		{models.EndpointDiffLeaf, func(e models.EndpointResult) string { return e.LeafFingerprint }},
+		{models.EndpointDiffChain, func(e models.EndpointResult) string { return strings.Join(e.ChainFingerprints, ",") }},
+		{models.EndpointDiffTLSVersion, func(e models.EndpointResult) string { return e.TLSVersion }},
+	}
+
+	mismatch := false
+
+	for _, attr := range attrs {
+
+		counts := map[string]int{}
+		majority := ""
+
+		// Ties go to the earliest endpoint
+		for _, e := range endpoints {
+			if !e.Success {
+				continue
+			}
+
+			v := attr.value(e)
+			counts[v]++
+
+			if counts[v] > counts[majority] {
+				majority = v
+			}
+		}
+
+		for i := range endpoints {
+			if endpoints[i].Success && attr.value(endpoints[i]) != majority {
+				endpoints[i].Differences = append(endpoints[i].Differences, attr.diff)
+				mismatch = true
+			}
+		}
+	}
+
+	return mismatch
+}
@@ -0,0 +1,54 @@
+package checker
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+func TestCompareEndpoints(t *testing.T) {
+	endpoint := func(ip, leaf, version string) models.EndpointResult {
+		return summarizeEndpoint(ip, &models.SSLCheckResponse{
+			TLSVersion: version,
+			CertChain: []models.CertDetail{
+				{FingerprintSHA256: leaf},
+				{FingerprintSHA256: "intermediate"},
+			},
+		}, nil)
+	}
+
+	endpoints := []models.EndpointResult{
+		endpoint("192.0.2.1", "aa", config.TLSVersion13),
+		endpoint("192.0.2.2", "aa", config.TLSVersion13),
+		endpoint("192.0.2.3", "bb", config.TLSVersion12),
+		summarizeEndpoint("2001:db8::1", nil, errors.New("connection refused")),
+	}
+
+	if !compareEndpoints(endpoints) {
+		t.Fatal("expected a mismatch")
+	}
+
+	if len(endpoints[0].Differences) != 0 || len(endpoints[1].Differences) != 0 {
+		t.Errorf("majority endpoints should not be flagged: %+v", endpoints[:2])
+	}
+
+	want := []models.EndpointDiff{models.EndpointDiffLeaf, models.EndpointDiffChain, models.EndpointDiffTLSVersion}
+	if !slices.Equal(endpoints[2].Differences, want) {
+		t.Errorf("unexpected differences %v", endpoints[2].Differences)
+	}
+
+	// Failed endpoints are reported but never compared
+	if endpoints[3].Success || endpoints[3].Error == "" || len(endpoints[3].Differences) != 0 {
+		t.Errorf("unexpected failed endpoint %+v", endpoints[3])
+	}
+
+	same := []models.EndpointResult{endpoints[0], endpoints[1]}
+	same[0].Differences, same[1].Differences = nil, nil
+
+	if compareEndpoints(same) {
+		t.Error("identical endpoints should not mismatch")
+	}
+}
@@ -0,0 +1,388 @@
+package checker
+
+import (
+	"crypto/x509"
+	"slices"
+	"strings"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+/* ===========================
+   GRADING (SSL Labs style)
+===========================
+
+   score = protocol 30% + key exchange 30% + cipher strength 40%
+   The letter from the score is then capped by the worst rule that
+   applies (untrusted chain, legacy protocols, weak ciphers, ...).
+   On HTTPS, A+ also needs HSTS.
+*/
+
+// weakSignatures are the leaf signature algorithms graded F, by the names
+// CertDetail.SignatureAlgo gets from x509.SignatureAlgorithm.String().
+var weakSignatures = map[string]bool{
+	x509.MD2WithRSA.String():    true,
+	x509.MD5WithRSA.String():    true,
+	x509.SHA1WithRSA.String():   true,
+	x509.DSAWithSHA1.String():   true,
+	x509.ECDSAWithSHA1.String(): true,
+}
+
+func gradeRank(grade string) int {
+
+	switch grade {
+	case config.GradeAPlus:
+		return 5
+	case config.GradeA:
+		return 4
+	case config.GradeB:
+		return 3
+	case config.GradeC:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func scoreToGrade(score int) string {
+
+	switch {
+	case score >= 90:
+		return config.GradeAPlus
+	case score >= 80:
+		return config.GradeA
+	case score >= 65:
+		return config.GradeB
+	case score >= 50:
+		return config.GradeC
+	default:
+		return config.GradeF
+	}
+}
+
+func gradeResponse(res *models.SSLCheckResponse) *models.GradeReport {
+
+	versions, ciphers := supportedSuites(res)
+
+	certScore := 0
+	if res.Trusted && res.HostnameOK {
+		certScore = 100
+	}
+
+	protoScore := protocolScore(versions)
+	kxScore := keyExchangeScore(res.CertChain)
+	cipherScore := cipherStrengthScore(ciphers)
+
+	score := (protoScore*config.GradeWeightProtocol +
+		kxScore*config.GradeWeightKeyExchange +
+		cipherScore*config.GradeWeightCipher) / 100
+
+	report := &models.GradeReport{
+		Score: score,
+		Categories: []models.CategoryScore{
+			{Category: models.GradeCategoryCertificate, Score: certScore, Weight: 0},
+			{Category: models.GradeCategoryProtocol, Score: protoScore, Weight: config.GradeWeightProtocol},
+			{Category: models.GradeCategoryKeyExchange, Score: kxScore, Weight: config.GradeWeightKeyExchange},
+			{Category: models.GradeCategoryCipher, Score: cipherScore, Weight: config.GradeWeightCipher},
+		},
+		Partial: len(res.Protocols) == 0,
+	}
+
+	grade := scoreToGrade(score)
+	uncapped := gradeRank(grade)
+
+	// Only keep the caps that actually lowered the grade
+	for _, c := range collectGradeCaps(res, versions, ciphers) {
+
+		if gradeRank(c.Grade) >= uncapped {
+			continue
+		}
+
+		report.Caps = append(report.Caps, c)
+
+		if gradeRank(c.Grade) < gradeRank(grade) {
+			grade = c.Grade
+		}
+	}
+
+	report.Grade = grade
+
+	return report
+}
+
+// supportedSuites returns the enumerated protocols and ciphers, or only the
+// negotiated ones when the scan did not enumerate.
+func supportedSuites(res *models.SSLCheckResponse) ([]string, []string) {
+
+	if len(res.Protocols) == 0 {
+		return []string{res.TLSVersion}, []string{res.CipherSuite}
+	}
+
+	var versions, ciphers []string
+
+	for _, p := range res.Protocols {
+
+		if !p.Supported {
+			continue
+		}
+
+		versions = append(versions, p.Version)
+
+		for _, c := range p.Ciphers {
+			if !slices.Contains(ciphers, c.Name) {
+				ciphers = append(ciphers, c.Name)
+			}
+		}
+	}
+
+	return versions, ciphers
+}
+
+/* ===========================
+   CATEGORY SCORES
+=========================== */
+
+func protocolVersionScore(version string) int {
+
+	switch version {
+	case config.TLSVersion13, config.TLSVersion12:
+		return 100
+	case config.TLSVersion11:
+		return 95
+	case config.TLSVersion10:
+		return 90
+	case config.TLSVersion30:
+		return 80
+	default:
+		return 0
+	}
+}
+
+// protocolScore averages the best and worst supported protocol.
+func protocolScore(versions []string) int {
+
+	if len(versions) == 0 {
+		return 0
+	}
+
+	best, worst := 0, 100
+
+	for _, v := range versions {
+		s := protocolVersionScore(v)
+		best = max(best, s)
+		worst = min(worst, s)
+	}
+
+	return (best + worst) / 2
+}
+
+// rsaEquivalentBits maps the leaf key to RSA-equivalent strength.
+func rsaEquivalentBits(algo string, size int) int {
+
+	switch algo {
+	case "RSA":
+		return size
+	case "Ed25519":
+		return 3072
+	case "ECDSA":
+		switch {
+		case size >= 512:
+			return 15360
+		case size >= 384:
+			return 7680
+		case size >= 256:
+			return 3072
+		case size >= 224:
+			return 2048
+		default:
+			return 1024
+		}
+	default:
+		return 0
+	}
+}
+
+func keyExchangeScore(chain []models.CertDetail) int {
+
+	if len(chain) == 0 {
+		return 0
+	}
+
+	bits := rsaEquivalentBits(chain[0].KeyAlgorithm, chain[0].KeySize)
+
+	switch {
+	case bits == 0:
+		return 0
+	case bits < 512:
+		return 20
+	case bits < 1024:
+		return 40
+	case bits < 2048:
+		return 80
+	case bits < 4096:
+		return 90
+	default:
+		return 100
+	}
+}
+
+func cipherBits(name string) int {
+
+	name = strings.ToUpper(name)
+
+	switch {
+	case name == "" || strings.Contains(name, "NULL"):
+		return 0
+	case strings.Contains(name, "EXPORT"):
+		return 40
+	case strings.Contains(name, "_DES_CBC_"), strings.Contains(name, "_DES40_"):
+		return 56
+	case strings.Contains(name, "3DES"):
+		return 112
+	case strings.Contains(name, "_256_"), strings.Contains(name, "CHACHA20"):
+		return 256
+	default:
+		return 128
+	}
+}
+
+func cipherBitsScore(bits int) int {
+
+	switch {
+	case bits == 0:
+		return 0
+	case bits < 128:
+		return 20
+	case bits < 256:
+		return 80
+	default:
+		return 100
+	}
+}
+
+// cipherStrengthScore averages the strongest and weakest accepted cipher.
+func cipherStrengthScore(ciphers []string) int {
+
+	if len(ciphers) == 0 {
+		return 0
+	}
+
+	strongest, weakest := 0, 100
+
+	for _, c := range ciphers {
+		s := cipherBitsScore(cipherBits(c))
+		strongest = max(strongest, s)
+		weakest = min(weakest, s)
+	}
+
+	return (strongest + weakest) / 2
+}
+
+/* ===========================
+   CAPS
+=========================== */
+
+func collectGradeCaps(
+	res *models.SSLCheckResponse,
+	versions []string,
+	ciphers []string,
+) []models.GradeCap {
+
+	var caps []models.GradeCap
+
+	add := func(code, grade, reason string) {
+		caps = append(caps, models.GradeCap{Code: code, Grade: grade, Reason: reason})
+	}
+
+	// ---- Certificate / trust
+	for _, issue := range res.TrustIssues {
+
+		switch {
+		case issue.Code == models.TrustMissingIssuer:
+			add(string(issue.Code), config.GradeB,
+				"Chuỗi chứng chỉ không đầy đủ, máy chủ cần gửi kèm chứng chỉ trung gian.")
+
+		case isFatalTrustIssue(issue.Code), issue.Code == models.TrustNameMismatch:
+			add(string(issue.Code), config.GradeF, issue.Message)
+		}
+	}
+
+	if len(res.CertChain) > 0 {
+
+		leaf := res.CertChain[0]
+		if weakSignatures[leaf.SignatureAlgo] {
+			add("weak_signature", config.GradeF,
+				"Chứng chỉ sử dụng thuật toán chữ ký yếu ("+leaf.SignatureAlgo+").")
+		}
+
+		switch bits := rsaEquivalentBits(leaf.KeyAlgorithm, leaf.KeySize); {
+		case bits > 0 && bits < 1024:
+			add("weak_key", config.GradeF, "Khoá công khai của chứng chỉ quá ngắn (dưới 1024 bit RSA).")
+		case bits > 0 && bits < 2048:
+			add("weak_key", config.GradeB, "Khoá công khai của chứng chỉ dưới 2048 bit RSA.")
+		}
+	}
+
+	// ---- Protocols
+	if slices.Contains(versions, config.TLSVersion30) {
+		add("ssl3", config.GradeC, "Máy chủ hỗ trợ SSL 3.0, có thể bị tấn công POODLE.")
+	}
+
+	if slices.Contains(versions, config.TLSVersion10) || slices.Contains(versions, config.TLSVersion11) {
+		add("legacy_tls", config.GradeB, "Máy chủ vẫn hỗ trợ TLS 1.0 / TLS 1.1 đã lỗi thời.")
+	}
+
+	if !slices.Contains(versions, config.TLSVersion12) && !slices.Contains(versions, config.TLSVersion13) {
+		add("no_tls12", config.GradeC, "Máy chủ không hỗ trợ TLS 1.2 trở lên.")
+	}
+
+	// ---- Ciphers
+	var hasRC4, has3DES, hasBroken, hasFS bool
+
+	for _, c := range ciphers {
+
+		upper := strings.ToUpper(c)
+
+		switch {
+		case strings.Contains(upper, "NULL"),
+			strings.Contains(upper, "EXPORT"),
+			strings.Contains(upper, "_ANON_"):
+			hasBroken = true
+		case strings.Contains(upper, "RC4"):
+			hasRC4 = true
+		case strings.Contains(upper, "DES"):
+			has3DES = true
+		}
+
+		if strings.HasPrefix(upper, "TLS_ECDHE_") ||
+			strings.HasPrefix(upper, "TLS_DHE_") ||
+			!strings.Contains(upper, "_WITH_") {
+			hasFS = true
+		}
+	}
+
+	if hasBroken {
+		add("insecure_cipher", config.GradeF, "Máy chủ chấp nhận bộ mã hoá không an toàn (NULL / EXPORT / anonymous).")
+	}
+	if hasRC4 {
+		add("rc4", config.GradeC, "Máy chủ chấp nhận bộ mã hoá RC4.")
+	}
+	if has3DES {
+		add("64bit_cipher", config.GradeC, "Máy chủ chấp nhận bộ mã hoá DES / 3DES (khối 64 bit, lỗ hổng Sweet32).")
+	}
+	if len(ciphers) > 0 && !hasFS {
+		add("no_forward_secrecy", config.GradeB, "Máy chủ không hỗ trợ Forward Secrecy (ECDHE / DHE).")
+	}
+
+	// ---- HSTS (only separates A+ from A, HTTPS only)
+	if res.Protocol != config.ProtocolHTTPS {
+		return caps
+	}
+
+	if res.HSTS == nil || !res.HSTS.Enabled || res.HSTS.MaxAge < config.HSTSMinMaxAge {
+		add("hsts", config.GradeA, "Chưa bật HSTS với max-age tối thiểu 6 tháng.")
+	}
+
+	return caps
+}
@@ -0,0 +1,92 @@
+package checker
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+func modernResponse() *models.SSLCheckResponse {
+	return &models.SSLCheckResponse{
+		Protocol:    config.ProtocolHTTPS,
+		Trusted:     true,
+		HostnameOK:  true,
+		TLSVersion:  config.TLSVersion13,
+		CipherSuite: "TLS_AES_256_GCM_SHA384",
+		HSTS:        &models.HSTSInfo{Enabled: true, MaxAge: 31536000},
+		CertChain: []models.CertDetail{
+			{KeyAlgorithm: "ECDSA", KeySize: 384, SignatureAlgo: "ECDSA-SHA384"},
+		},
+	}
+}
+
+func TestGradeModernServer(t *testing.T) {
+	report := gradeResponse(modernResponse())
+
+	if report.Grade != config.GradeAPlus {
+		t.Fatalf("expected A+, got %s (caps %+v)", report.Grade, report.Caps)
+	}
+	if !report.Partial {
+		t.Error("grade without enumeration should be partial")
+	}
+}
+
+func TestGradeScoreA(t *testing.T) {
+	// RSA 2048 and AES-128: 30 + 27 + 32 = 89
+	res := modernResponse()
+	res.TLSVersion = config.TLSVersion12
+	res.CipherSuite = "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"
+	res.CertChain = []models.CertDetail{{KeyAlgorithm: "RSA", KeySize: 2048, SignatureAlgo: "SHA256-RSA"}}
+
+	report := gradeResponse(res)
+	if report.Score != 89 || report.Grade != config.GradeA || len(report.Caps) != 0 {
+		t.Errorf("expected A from the score alone, got %d %s %+v", report.Score, report.Grade, report.Caps)
+	}
+}
+
+func TestGradeCaps(t *testing.T) {
+	res := modernResponse()
+	res.HSTS = nil
+
+	if got := gradeResponse(res); got.Grade != config.GradeA || len(got.Caps) != 1 {
+		t.Errorf("missing HSTS should cap at A, got %s %+v", got.Grade, got.Caps)
+	}
+
+	res.Protocols = []models.ProtocolSupport{
+		{Version: config.TLSVersion10, Supported: true, Ciphers: []models.CipherSuiteInfo{
+			{Name: "TLS_RSA_WITH_3DES_EDE_CBC_SHA"},
+		}},
+		{Version: config.TLSVersion12, Supported: true, Ciphers: []models.CipherSuiteInfo{
+			{Name: "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+		}},
+	}
+
+	got := gradeResponse(res)
+	if got.Grade != config.GradeC {
+		t.Errorf("3DES should cap at C, got %s %+v", got.Grade, got.Caps)
+	}
+
+	res.Trusted = false
+	res.TrustIssues = []models.TrustIssue{{Code: models.TrustCertExpired, Message: "expired"}}
+
+	if got := gradeResponse(res); got.Grade != config.GradeF {
+		t.Errorf("expired cert should be F, got %s", got.Grade)
+	}
+}
+
+func TestGradeWeakSignature(t *testing.T) {
+	for _, sig := range []x509.SignatureAlgorithm{x509.SHA1WithRSA, x509.ECDSAWithSHA1, x509.DSAWithSHA1, x509.MD5WithRSA, x509.MD2WithRSA} {
+		res := modernResponse()
+		res.CertChain[0].SignatureAlgo = sig.String()
+
+		if got := gradeResponse(res); got.Grade != config.GradeF {
+			t.Errorf("%s should be F, got %s %+v", sig, got.Grade, got.Caps)
+		}
+	}
+
+	if got := gradeResponse(modernResponse()); got.Grade == config.GradeF {
+		t.Errorf("ECDSA-SHA384 should not be F, got %+v", got.Caps)
+	}
+}
@@ -8,25 +8,40 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/platform/middleware"
 	"tools.bctechvibe.io.vn/server/ssl/internal/platform/shared"
 )
 
 type Handler struct {
 	svc *Service
+
+	// separate limiter for fresh=true so the cache can't be bypassed at will
+	freshLimiter *middleware.RateLimiter
 }
 
 type CheckRequest struct {
 	Domain string `json:"domain"`
+	Mode   string `json:"mode,omitempty"` // quick (default) | full
+	Fresh  bool   `json:"fresh,omitempty"`
+
+	Port     int    `json:"port,omitempty"`     // default: the protocol's port
+	Protocol string `json:"protocol,omitempty"` // https (default) | smtp | imap | ...
+
+	// AllEndpoints scans every A / AAAA address of the domain
+	AllEndpoints bool `json:"all_endpoints,omitempty"`
 }
 
 // var ErrNoIP = errors.New("no ip")
 
-func NewHandler() *Handler {
+func NewHandler(svc *Service, freshLimiter *middleware.RateLimiter) *Handler {
 	return &Handler{
-		svc: New(),
+		svc:          svc,
+		freshLimiter: freshLimiter,
 	}
 }
 
@@ -37,11 +52,28 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		log.Printf("[%s] %s %s - took %v", r.Method, r.RequestURI, r.RemoteAddr, duration)
 	}()
 
-	var domain string
+	var (
+		domain, mode, protocol string
+		port                   int
+		fresh, allEndpoints    bool
+	)
 
 	switch r.Method {
 	case http.MethodGet:
 		domain = r.URL.Query().Get("domain")
+		mode = r.URL.Query().Get("mode")
+		fresh, _ = strconv.ParseBool(r.URL.Query().Get("fresh"))
+		allEndpoints, _ = strconv.ParseBool(r.URL.Query().Get("all_endpoints"))
+		protocol = r.URL.Query().Get("protocol")
+
+		if p := r.URL.Query().Get("port"); p != "" {
+			n, err := strconv.Atoi(p)
+			if err != nil {
+				shared.Error(w, "Cổng không hợp lệ (1 - 65535)", http.StatusBadRequest, domain)
+				return
+			}
+			port = n
+		}
 	case http.MethodPost:
 		// protect from huge bodies
 		r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB
@@ -60,6 +92,11 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		domain = req.Domain
+		mode = req.Mode
+		fresh = req.Fresh
+		port = req.Port
+		protocol = req.Protocol
+		allEndpoints = req.AllEndpoints
 	default:
 		http.Error(w, "Phương thức HTTP không được hỗ trợ", http.StatusMethodNotAllowed)
 		return
@@ -75,11 +112,55 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// set a request timeout and pass context to service (requires Service.Check(ctx, domain) refactor)
-	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	var opts ScanOptions
+	timeout := 15 * time.Second
+
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", config.ScanModeQuick:
+	case config.ScanModeFull:
+		opts.EnumerateProtocols = true
+		timeout = config.FullScanTimeout
+	default:
+		shared.Error(w, "Chế độ kiểm tra không hợp lệ (quick | full)", http.StatusBadRequest, domain)
+		return
+	}
+
+	protocol = strings.ToLower(strings.TrimSpace(protocol))
+	if protocol == "" {
+		protocol = config.ProtocolHTTPS
+	}
+
+	if _, ok := config.DefaultPorts[protocol]; !ok {
+		shared.Error(w, "Giao thức không được hỗ trợ (https | tls | smtp | imap | pop3 | ldap | postgres | ftp | xmpp)", http.StatusBadRequest, domain)
+		return
+	}
+
+	if port < 0 || port > 65535 {
+		shared.Error(w, "Cổng không hợp lệ (1 - 65535)", http.StatusBadRequest, domain)
+		return
+	}
+
+	opts.Protocol = protocol
+	opts.Port = port
+	opts.AllEndpoints = allEndpoints
+
+	if fresh {
+		if h.freshLimiter != nil {
+			ip := h.freshLimiter.GetClientIP(r.RemoteAddr, r.Header)
+
+			if !h.freshLimiter.IsAllowed(ip) {
+				shared.Error(w, "Bạn đã yêu cầu quét mới quá nhiều lần, vui lòng thử lại sau ít phút.", http.StatusTooManyRequests, domain)
+				return
+			}
+		}
+		opts.Fresh = true
+	}
+
+	// set a request timeout and pass context to service
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
 	defer cancel()
 
-	res, err := h.svc.Check(ctx, d)
+	res, err := h.svc.Check(ctx, d, opts)
 	if err != nil {
 		// blocked by rate limiter / breaker
 		if err == shared.ErrBlocked {
@@ -113,5 +194,30 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cacheStatus := "MISS"
+	if res.Cached {
+		cacheStatus = "HIT"
+	}
+	w.Header().Set("X-Cache", cacheStatus)
+	w.Header().Set("Age", strconv.FormatInt(res.CacheAge, 10))
+
 	shared.JSON(w, res)
 }
+
+// StatsHandler serves the cache hit / miss counters of svc.
+type StatsHandler struct {
+	svc *Service
+}
+
+func NewStatsHandler(svc *Service) *StatsHandler {
+	return &StatsHandler{svc: svc}
+}
+
+func (h *StatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		shared.ErrorDecode(w, "Phương thức HTTP không được hỗ trợ", http.StatusMethodNotAllowed)
+		return
+	}
+
+	shared.JSON(w, h.svc.CacheStats())
+}
@@ -0,0 +1,48 @@
+package checker
+
+import (
+	"strconv"
+	"strings"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+// detectHSTS reads Strict-Transport-Security from the first HTTPS probe
+// that answered. Browsers ignore the header over plain HTTP.
+func detectHSTS(probes []*Probe) *models.HSTSInfo {
+
+	for _, p := range probes {
+		if p.Response == nil || !strings.HasPrefix(p.URL, "https://") {
+			continue
+		}
+
+		return parseHSTS(p.Response.Header.Get("Strict-Transport-Security"))
+	}
+
+	return nil
+}
+
+func parseHSTS(header string) *models.HSTSInfo {
+
+	info := &models.HSTSInfo{Header: header}
+
+	for directive := range strings.SplitSeq(header, ";") {
+
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "max-age":
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+				info.MaxAge = n
+				info.Enabled = n > 0
+			}
+		case "includesubdomains":
+			info.IncludeSubDomains = true
+		case "preload":
+			info.Preload = true
+		}
+	}
+
+	return info
+}
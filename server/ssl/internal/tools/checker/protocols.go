@@ -0,0 +1,467 @@
+package checker
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+/* ===========================
+   PROTOCOL ENUMERATION
+===========================
+
+   crypto/tls cannot speak SSLv3 and refuses most legacy suites, so each
+   probe sends a hand-built ClientHello and only reads the ServerHello.
+   The handshake is never completed.
+*/
+
+var (
+	errHandshakeRejected = errors.New("handshake rejected")
+	errMalformedHello    = errors.New("malformed server hello")
+)
+
+const (
+	versionSSL30 uint16 = 0x0300
+	versionTLS10 uint16 = 0x0301
+	versionTLS11 uint16 = 0x0302
+	versionTLS12 uint16 = 0x0303
+	versionTLS13 uint16 = 0x0304
+
+	recordTypeAlert     = 21
+	recordTypeHandshake = 22
+
+	handshakeClientHello = 1
+	handshakeServerHello = 2
+
+	extServerName          uint16 = 0
+	extSupportedGroups     uint16 = 10
+	extECPointFormats      uint16 = 11
+	extSignatureAlgorithms uint16 = 13
+	extSupportedVersions   uint16 = 43
+	extKeyShare            uint16 = 51
+	extRenegotiationInfo   uint16 = 0xff01
+
+	groupX25519 uint16 = 0x001d
+
+	maxRecordLen = 1<<14 + 2048
+)
+
+var probeVersions = []uint16{
+	versionSSL30,
+	versionTLS10,
+	versionTLS11,
+	versionTLS12,
+	versionTLS13,
+}
+
+var probeGroups = []uint16{groupX25519, 0x0017, 0x0018, 0x0019, 0x0100}
+
+var probeSignatureAlgorithms = []uint16{
+	0x0403, 0x0503, 0x0603, // ECDSA
+	0x0804, 0x0805, 0x0806, // RSA-PSS
+	0x0401, 0x0501, 0x0601, // RSA PKCS#1
+	0x0203, 0x0201, // SHA-1
+}
+
+func protocolName(v uint16) string {
+
+	switch v {
+	case versionTLS13:
+		return config.TLSVersion13
+	case versionTLS12:
+		return config.TLSVersion12
+	case versionTLS11:
+		return config.TLSVersion11
+	case versionTLS10:
+		return config.TLSVersion10
+	case versionSSL30:
+		return config.TLSVersion30
+	default:
+		return fmt.Sprintf("0x%04X", v)
+	}
+}
+
+func suitesForVersion(v uint16) []uint16 {
+
+	if v == versionTLS13 {
+		return slices.Clone(tls13CipherSuites)
+	}
+
+	out := make([]uint16, 0, len(legacyCipherSuites))
+
+	for _, id := range legacyCipherSuites {
+		if v < versionTLS12 && tls12Only(cipherSuiteNames[id]) {
+			continue
+		}
+		out = append(out, id)
+	}
+
+	return out
+}
+
+// enumerateProtocols probes every protocol version concurrently and lists
+// the accepted cipher suites of each one.
+func enumerateProtocols(
+	ctx context.Context,
+	addr string,
+	serverName string,
+	protocol string,
+) []models.ProtocolSupport {
+
+	out := make([]models.ProtocolSupport, len(probeVersions))
+
+	var wg sync.WaitGroup
+
+	for i, v := range probeVersions {
+		wg.Add(1)
+
+		go func(i int, v uint16) {
+			defer wg.Done()
+			out[i] = enumerateCiphers(ctx, addr, serverName, protocol, v)
+		}(i, v)
+	}
+
+	wg.Wait()
+
+	return out
+}
+
+// enumerateCiphers repeatedly offers the remaining suites and removes the
+// one the server picked, so the result follows server preference order.
+func enumerateCiphers(
+	ctx context.Context,
+	addr string,
+	serverName string,
+	protocol string,
+	version uint16,
+) models.ProtocolSupport {
+
+	res := models.ProtocolSupport{Version: protocolName(version)}
+	remaining := suitesForVersion(version)
+
+	for len(remaining) > 0 {
+
+		if ctx.Err() != nil {
+			res.Error = ctx.Err().Error()
+			break
+		}
+
+		got, cipher, err := probeHello(ctx, addr, serverName, protocol, version, remaining)
+		if err != nil {
+			// A network error on the first probe means we learned nothing
+			if !errors.Is(err, errHandshakeRejected) && len(res.Ciphers) == 0 {
+				res.Error = err.Error()
+			}
+			break
+		}
+
+		idx := slices.Index(remaining, cipher)
+		if got != version || idx < 0 {
+			break
+		}
+
+		res.Supported = true
+		res.Ciphers = append(res.Ciphers, describeCipher(cipher))
+		remaining = slices.Delete(remaining, idx, idx+1)
+	}
+
+	return res
+}
+
+/* ===========================
+   RAW HANDSHAKE
+=========================== */
+
+func probeHello(
+	ctx context.Context,
+	addr string,
+	serverName string,
+	protocol string,
+	version uint16,
+	suites []uint16,
+) (uint16, uint16, error) {
+
+	hello, err := buildClientHello(serverName, version, suites)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	dialer := &net.Dialer{Timeout: config.ProtocolProbeTimeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	if _, err := upgradeConn(ctx, conn, protocol, serverName); err != nil {
+		return 0, 0, err
+	}
+
+	deadline := time.Now().Add(config.ProtocolProbeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write(hello); err != nil {
+		return 0, 0, err
+	}
+
+	return readServerHello(conn)
+}
+
+func buildClientHello(
+	serverName string,
+	version uint16,
+	suites []uint16,
+) ([]byte, error) {
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+
+	recordVersion := versionTLS10
+	helloVersion := version
+	var sessionID, keyShare []byte
+
+	switch version {
+	case versionSSL30:
+		recordVersion = versionSSL30
+
+	case versionTLS13:
+		helloVersion = versionTLS12
+
+		// Middlebox compatibility mode (RFC 8446 D.4)
+		sessionID = make([]byte, 32)
+		if _, err := rand.Read(sessionID); err != nil {
+			return nil, err
+		}
+
+		key, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		keyShare = key.PublicKey().Bytes()
+	}
+
+	b := cryptobyte.NewBuilder(nil)
+
+	b.AddUint8(recordTypeHandshake)
+	b.AddUint16(recordVersion)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(handshakeClientHello)
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+
+			b.AddUint16(helloVersion)
+			b.AddBytes(random)
+
+			b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddBytes(sessionID)
+			})
+
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				for _, s := range suites {
+					b.AddUint16(s)
+				}
+			})
+
+			// Compression: null only
+			b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint8(0)
+			})
+
+			// SSLv3 servers frequently choke on extensions
+			if version == versionSSL30 {
+				return
+			}
+
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				addHelloExtensions(b, serverName, version, keyShare)
+			})
+		})
+	})
+
+	return b.Bytes()
+}
+
+func addHelloExtensions(
+	b *cryptobyte.Builder,
+	serverName string,
+	version uint16,
+	keyShare []byte,
+) {
+
+	if serverName != "" && net.ParseIP(serverName) == nil {
+		b.AddUint16(extServerName)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint8(0) // host_name
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes([]byte(serverName))
+				})
+			})
+		})
+	}
+
+	b.AddUint16(extSupportedGroups)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, g := range probeGroups {
+				b.AddUint16(g)
+			}
+		})
+	})
+
+	b.AddUint16(extECPointFormats)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint8(0) // uncompressed
+		})
+	})
+
+	if version >= versionTLS12 {
+		b.AddUint16(extSignatureAlgorithms)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				for _, s := range probeSignatureAlgorithms {
+					b.AddUint16(s)
+				}
+			})
+		})
+	}
+
+	b.AddUint16(extRenegotiationInfo)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(0)
+	})
+
+	if version == versionTLS13 {
+		b.AddUint16(extSupportedVersions)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint16(versionTLS13)
+			})
+		})
+
+		b.AddUint16(extKeyShare)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint16(groupX25519)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(keyShare)
+				})
+			})
+		})
+	}
+}
+
+// readServerHello reads records until a full ServerHello is buffered and
+// returns the negotiated version and cipher suite.
+func readServerHello(r io.Reader) (uint16, uint16, error) {
+
+	var handshake []byte
+	header := make([]byte, 5)
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return 0, 0, errHandshakeRejected
+			}
+			return 0, 0, err
+		}
+
+		n := int(binary.BigEndian.Uint16(header[3:]))
+		if n > maxRecordLen {
+			return 0, 0, errMalformedHello
+		}
+
+		body := make([]byte, n)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return 0, 0, errHandshakeRejected
+		}
+
+		switch header[0] {
+		case recordTypeAlert:
+			return 0, 0, errHandshakeRejected
+		case recordTypeHandshake:
+			handshake = append(handshake, body...)
+		default:
+			return 0, 0, errMalformedHello
+		}
+
+		if len(handshake) < 4 {
+			continue
+		}
+
+		msgLen := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+		if len(handshake) < 4+msgLen {
+			continue
+		}
+
+		if handshake[0] != handshakeServerHello {
+			return 0, 0, errMalformedHello
+		}
+
+		return parseServerHello(handshake[4 : 4+msgLen])
+	}
+}
+
+func parseServerHello(msg []byte) (uint16, uint16, error) {
+
+	s := cryptobyte.String(msg)
+
+	var (
+		version, cipher uint16
+		sessionID       cryptobyte.String
+	)
+
+	if !s.ReadUint16(&version) ||
+		!s.Skip(32) ||
+		!s.ReadUint8LengthPrefixed(&sessionID) ||
+		!s.ReadUint16(&cipher) ||
+		!s.Skip(1) {
+		return 0, 0, errMalformedHello
+	}
+
+	if s.Empty() {
+		return version, cipher, nil
+	}
+
+	var exts cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&exts) {
+		return 0, 0, errMalformedHello
+	}
+
+	for !exts.Empty() {
+
+		var (
+			typ  uint16
+			data cryptobyte.String
+		)
+
+		if !exts.ReadUint16(&typ) || !exts.ReadUint16LengthPrefixed(&data) {
+			return 0, 0, errMalformedHello
+		}
+
+		// TLS 1.3 hides the real version in supported_versions
+		if typ == extSupportedVersions && !data.ReadUint16(&version) {
+			return 0, 0, errMalformedHello
+		}
+	}
+
+	return version, cipher, nil
+}
@@ -0,0 +1,135 @@
+package checker
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+func selfSignedCert(t *testing.T, host string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// startTLSServer accepts connections and runs the handshake until the test ends.
+func startTLSServer(t *testing.T, conf *tls.Config) string {
+	t.Helper()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(2 * time.Second))
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestEnumerateProtocols(t *testing.T) {
+	addr := startTLSServer(t, &tls.Config{
+		Certificates: []tls.Certificate{selfSignedCert(t, "example.test")},
+		MinVersion:   tls.VersionTLS12,
+		MaxVersion:   tls.VersionTLS13,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	got := map[string]models.ProtocolSupport{}
+	for _, p := range enumerateProtocols(ctx, addr, "example.test", config.ProtocolHTTPS) {
+		got[p.Version] = p
+	}
+
+	for _, v := range []string{config.TLSVersion30, config.TLSVersion10, config.TLSVersion11} {
+		if got[v].Supported {
+			t.Errorf("%s should be rejected, got %+v", v, got[v])
+		}
+	}
+
+	tls12 := got[config.TLSVersion12]
+	if !tls12.Supported || len(tls12.Ciphers) != 2 {
+		t.Fatalf("expected 2 TLS 1.2 suites, got %+v", tls12)
+	}
+
+	strength := map[string]models.CipherStrength{}
+	for _, c := range tls12.Ciphers {
+		strength[c.Name] = c.Strength
+	}
+	if strength["TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"] != models.CipherSecure {
+		t.Errorf("GCM suite should be secure, got %v", strength)
+	}
+	if strength["TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA"] != models.CipherWeak {
+		t.Errorf("CBC suite should be weak, got %v", strength)
+	}
+
+	if tls13 := got[config.TLSVersion13]; !tls13.Supported || len(tls13.Ciphers) == 0 {
+		t.Errorf("expected TLS 1.3 support, got %+v", tls13)
+	}
+}
+
+func TestClassifyCipher(t *testing.T) {
+	cases := map[string]models.CipherStrength{
+		"TLS_AES_256_GCM_SHA384":                "secure",
+		"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256": "secure",
+		"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":    "weak",
+		"TLS_RSA_WITH_AES_128_GCM_SHA256":       "weak",
+		"TLS_RSA_WITH_3DES_EDE_CBC_SHA":         "insecure",
+		"TLS_ECDHE_RSA_WITH_RC4_128_SHA":        "insecure",
+		"TLS_DH_anon_WITH_AES_128_CBC_SHA":      "insecure",
+		"TLS_RSA_EXPORT_WITH_RC4_40_MD5":        "insecure",
+	}
+
+	for name, want := range cases {
+		if got := classifyCipher(name); got != want {
+			t.Errorf("classifyCipher(%s) = %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,466 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+/* ===========================
+   REVOCATION (OCSP / CRL)
+===========================
+
+   Order per certificate:
+   1. OCSP response stapled by the server (leaf only)
+   2. OCSP responders from the AIA extension
+   3. CRL distribution points
+*/
+
+var errNonPublicAddress = errors.New("refusing to connect to a non-public address")
+
+// revocationClient only talks to public addresses: responder URLs come from
+// the scanned certificate and must not reach into our own network.
+var revocationClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout: config.OCSPCheckTimeout,
+			Control: publicOnly,
+		}).DialContext,
+		TLSHandshakeTimeout: config.OCSPCheckTimeout,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 3 {
+			return errors.New("too many redirects")
+		}
+		return nil
+	},
+}
+
+func publicOnly(_, address string, _ syscall.RawConn) error {
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return errNonPublicAddress
+	}
+
+	return nil
+}
+
+// RFC 5280 CRLReason
+var revocationReasons = map[int]string{
+	ocsp.Unspecified:          "unspecified",
+	ocsp.KeyCompromise:        "keyCompromise",
+	ocsp.CACompromise:         "cACompromise",
+	ocsp.AffiliationChanged:   "affiliationChanged",
+	ocsp.Superseded:           "superseded",
+	ocsp.CessationOfOperation: "cessationOfOperation",
+	ocsp.CertificateHold:      "certificateHold",
+	ocsp.RemoveFromCRL:        "removeFromCRL",
+	ocsp.PrivilegeWithdrawn:   "privilegeWithdrawn",
+	ocsp.AACompromise:         "aACompromise",
+}
+
+func revocationReason(code int) string {
+
+	if r, ok := revocationReasons[code]; ok {
+		return r
+	}
+
+	return fmt.Sprintf("reason(%d)", code)
+}
+
+// revocationChain prefers the verified chain so intermediates the server
+// forgot to send still get an issuer.
+func revocationChain(certs []*x509.Certificate) []*x509.Certificate {
+
+	if verified, err := buildVerifiedChain(certs); err == nil {
+		return verified
+	}
+
+	return certs
+}
+
+// checkRevocation checks every non-root certificate concurrently. Results are
+// keyed by SHA-256 fingerprint so they can be matched to CertDetail.
+func checkRevocation(
+	ctx context.Context,
+	chain []*x509.Certificate,
+	stapled []byte,
+) map[string]*models.RevocationInfo {
+
+	out := make(map[string]*models.RevocationInfo, len(chain))
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for i, cert := range chain {
+
+		// Roots are trusted directly, nothing can revoke them
+		if isSelfSigned(cert) {
+			continue
+		}
+
+		var issuer *x509.Certificate
+		if i+1 < len(chain) && cert.CheckSignatureFrom(chain[i+1]) == nil {
+			issuer = chain[i+1]
+		} else {
+			issuer = findIssuer(cert, chain)
+		}
+
+		var staple []byte
+		if i == 0 {
+			staple = stapled
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			info := revocationStatus(ctx, cert, issuer, staple)
+			_, fp256 := buildFingerprint(cert)
+
+			mu.Lock()
+			out[fp256] = info
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	return out
+}
+
+func revocationStatus(
+	ctx context.Context,
+	cert *x509.Certificate,
+	issuer *x509.Certificate,
+	stapled []byte,
+) *models.RevocationInfo {
+
+	if issuer == nil {
+		return &models.RevocationInfo{
+			Status: models.RevocationUnknown,
+			Error:  "issuer certificate not available",
+		}
+	}
+
+	var (
+		fallback *models.RevocationInfo
+		lastErr  error
+	)
+
+	// ---- 1. Stapled OCSP
+	if len(stapled) > 0 {
+
+		resp, err := ocsp.ParseResponseForCert(stapled, cert, issuer)
+		if err == nil {
+			err = checkOCSPFreshness(resp)
+		}
+
+		if err != nil {
+			lastErr = fmt.Errorf("stapled ocsp: %w", err)
+		} else {
+			info := fromOCSP(resp, models.RevocationSourceStapled, "")
+			if info.Status != models.RevocationUnknown {
+				return info
+			}
+			fallback = info
+		}
+	}
+
+	// ---- 2. OCSP responders
+	for _, url := range cert.OCSPServer {
+
+		resp, err := queryOCSP(ctx, url, cert, issuer)
+		if err == nil {
+			err = checkOCSPFreshness(resp)
+		}
+
+		if err != nil {
+			lastErr = fmt.Errorf("ocsp %s: %w", url, err)
+			continue
+		}
+
+		info := fromOCSP(resp, models.RevocationSourceOCSP, url)
+		if info.Status != models.RevocationUnknown {
+			return info
+		}
+		fallback = info
+	}
+
+	// ---- 3. CRL
+	for _, url := range cert.CRLDistributionPoints {
+
+		info, err := checkCRL(ctx, url, cert, issuer)
+		if err != nil {
+			lastErr = fmt.Errorf("crl %s: %w", url, err)
+			continue
+		}
+
+		return info
+	}
+
+	if fallback != nil {
+		return fallback
+	}
+
+	info := &models.RevocationInfo{Status: models.RevocationUnknown}
+
+	switch {
+	case lastErr != nil:
+		info.Error = lastErr.Error()
+	default:
+		info.Error = "certificate has no OCSP responder or CRL distribution point"
+	}
+
+	return info
+}
+
+/* ===========================
+   OCSP
+=========================== */
+
+func queryOCSP(
+	ctx context.Context,
+	url string,
+	cert *x509.Certificate,
+	issuer *x509.Certificate,
+) (*ocsp.Response, error) {
+
+	body, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.OCSPCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	raw, err := fetch(req, config.MaxOCSPResponseSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return ocsp.ParseResponseForCert(raw, cert, issuer)
+}
+
+// checkOCSPFreshness rejects "good" responses outside their validity
+// window, so an old staple replayed after revocation can't pass. Revoked
+// responses are kept: revocation is permanent.
+func checkOCSPFreshness(resp *ocsp.Response) error {
+
+	if resp.Status != ocsp.Good {
+		return nil
+	}
+
+	now := time.Now()
+
+	if resp.ThisUpdate.After(now.Add(config.OCSPClockSkew)) {
+		return fmt.Errorf("ocsp response from the future (this update %s)", resp.ThisUpdate.UTC().Format(time.RFC3339))
+	}
+
+	if !resp.NextUpdate.IsZero() && now.After(resp.NextUpdate) {
+		return fmt.Errorf("stale ocsp response (next update %s)", resp.NextUpdate.UTC().Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func fromOCSP(
+	resp *ocsp.Response,
+	source models.RevocationSource,
+	responder string,
+) *models.RevocationInfo {
+
+	info := &models.RevocationInfo{
+		Source:     source,
+		Responder:  responder,
+		ThisUpdate: timePtr(resp.ThisUpdate),
+		NextUpdate: timePtr(resp.NextUpdate),
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+		info.Status = models.RevocationGood
+	case ocsp.Revoked:
+		info.Status = models.RevocationRevoked
+		info.RevokedAt = timePtr(resp.RevokedAt)
+		info.Reason = revocationReason(resp.RevocationReason)
+	default:
+		info.Status = models.RevocationUnknown
+		info.Error = "responder does not know this certificate"
+	}
+
+	return info
+}
+
+/* ===========================
+   CRL
+=========================== */
+
+func checkCRL(
+	ctx context.Context,
+	url string,
+	cert *x509.Certificate,
+	issuer *x509.Certificate,
+) (*models.RevocationInfo, error) {
+
+	// ldap:// distribution points are not supported
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, errors.New("unsupported distribution point scheme")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.CRLFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := fetch(req, config.MaxCRLSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if block, _ := pem.Decode(raw); block != nil {
+		raw = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return nil, fmt.Errorf("bad crl signature: %w", err)
+	}
+
+	info := &models.RevocationInfo{
+		Status:     models.RevocationGood,
+		Source:     models.RevocationSourceCRL,
+		Responder:  url,
+		ThisUpdate: timePtr(crl.ThisUpdate),
+		NextUpdate: timePtr(crl.NextUpdate),
+	}
+
+	for _, entry := range crl.RevokedCertificateEntries {
+
+		if entry.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+			continue
+		}
+
+		info.Status = models.RevocationRevoked
+		info.RevokedAt = timePtr(entry.RevocationTime)
+		info.Reason = revocationReason(entry.ReasonCode)
+		break
+	}
+
+	// Revocation is permanent, but a stale CRL can't vouch for "good"
+	if info.Status == models.RevocationGood && !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate) {
+		return nil, fmt.Errorf("stale crl (next update %s)", crl.NextUpdate.UTC().Format(time.RFC3339))
+	}
+
+	return info, nil
+}
+
+/* ===========================
+   HELPERS
+=========================== */
+
+func fetch(req *http.Request, limit int64) ([]byte, error) {
+
+	resp, err := revocationClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("response larger than %d bytes", limit)
+	}
+
+	return body, nil
+}
+
+func timePtr(t time.Time) *time.Time {
+
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+// revocationIssues turns revoked certificates into trust issues.
+func revocationIssues(
+	chain []models.CertDetail,
+) []models.TrustIssue {
+
+	var issues []models.TrustIssue
+
+	for _, c := range chain {
+
+		if c.Revocation == nil || c.Revocation.Status != models.RevocationRevoked {
+			continue
+		}
+
+		who := "Chứng chỉ của website"
+		if c.Level != models.CertLevelDomain {
+			who = fmt.Sprintf("Chứng chỉ trung gian %s", c.CommonName)
+		}
+
+		when := ""
+		if c.Revocation.RevokedAt != nil {
+			when = " vào " + c.Revocation.RevokedAt.Format("02/01/2006")
+		}
+
+		issues = append(issues, models.TrustIssue{
+			Code: models.TrustRevoked,
+			Message: fmt.Sprintf(
+				"%s đã bị CA thu hồi%s (lý do: %s).",
+				who, when, c.Revocation.Reason,
+			),
+		})
+	}
+
+	return issues
+}
@@ -0,0 +1,364 @@
+package checker
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) issue(t *testing.T, serial int64, ocspURL, crlURL string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "example.test"},
+		DNSNames:     []string{"example.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ocspURL != "" {
+		template.OCSPServer = []string{ocspURL}
+	}
+	if crlURL != "" {
+		template.CRLDistributionPoints = []string{crlURL}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func (ca *testCA) ocspResponse(t *testing.T, serial *big.Int, status int, revokedAt time.Time) []byte {
+	t.Helper()
+
+	resp, err := ocsp.CreateResponse(ca.cert, ca.cert, ocsp.Response{
+		Status:           status,
+		SerialNumber:     serial,
+		ThisUpdate:       time.Now().Add(-time.Minute),
+		NextUpdate:       time.Now().Add(time.Hour),
+		RevokedAt:        revokedAt,
+		RevocationReason: ocsp.KeyCompromise,
+	}, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp
+}
+
+// allowLoopback lets the revocation client reach httptest servers.
+func allowLoopback(t *testing.T) {
+	orig := revocationClient
+	revocationClient = &http.Client{}
+	t.Cleanup(func() { revocationClient = orig })
+}
+
+func TestRevocationOCSP(t *testing.T) {
+	allowLoopback(t)
+
+	ca := newTestCA(t)
+	revokedAt := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		status := ocsp.Good
+		if req.SerialNumber.Int64() == 66 {
+			status = ocsp.Revoked
+		}
+
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(ca.ocspResponse(t, req.SerialNumber, status, revokedAt))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	good := ca.issue(t, 42, srv.URL, "")
+	if info := revocationStatus(ctx, good, ca.cert, nil); info.Status != models.RevocationGood ||
+		info.Source != models.RevocationSourceOCSP {
+		t.Errorf("expected good via ocsp, got %+v", info)
+	}
+
+	revoked := ca.issue(t, 66, srv.URL, "")
+	info := revocationStatus(ctx, revoked, ca.cert, nil)
+
+	if info.Status != models.RevocationRevoked || info.Reason != "keyCompromise" {
+		t.Fatalf("expected revoked (keyCompromise), got %+v", info)
+	}
+	if info.RevokedAt == nil || !info.RevokedAt.Equal(revokedAt) {
+		t.Errorf("unexpected revocation time %v", info.RevokedAt)
+	}
+
+	// The whole chain: only the leaf is checked, the root is skipped
+	results := checkRevocation(ctx, []*x509.Certificate{revoked, ca.cert}, nil)
+	if len(results) != 1 {
+		t.Errorf("expected one result, got %d", len(results))
+	}
+}
+
+func TestRevocationStapled(t *testing.T) {
+	ca := newTestCA(t)
+
+	// The responder URL must never be used when a staple is present
+	leaf := ca.issue(t, 7, "http://192.0.2.1/ocsp", "")
+	staple := ca.ocspResponse(t, leaf.SerialNumber, ocsp.Good, time.Time{})
+
+	info := revocationStatus(context.Background(), leaf, ca.cert, staple)
+	if info.Status != models.RevocationGood || info.Source != models.RevocationSourceStapled {
+		t.Errorf("expected good via staple, got %+v", info)
+	}
+}
+
+func TestRevocationCRLFallback(t *testing.T) {
+	allowLoopback(t)
+
+	ca := newTestCA(t)
+	revokedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: big.NewInt(9), RevocationTime: revokedAt, ReasonCode: ocsp.Superseded},
+		},
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// OCSP responder is down, the CRL must be used instead
+	ocspSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ocspSrv.Close()
+
+	crlSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(crl)
+	}))
+	defer crlSrv.Close()
+
+	ctx := context.Background()
+
+	info := revocationStatus(ctx, ca.issue(t, 9, ocspSrv.URL, crlSrv.URL), ca.cert, nil)
+	if info.Status != models.RevocationRevoked || info.Source != models.RevocationSourceCRL ||
+		info.Reason != "superseded" {
+		t.Errorf("expected revoked via crl, got %+v", info)
+	}
+
+	info = revocationStatus(ctx, ca.issue(t, 10, "", crlSrv.URL), ca.cert, nil)
+	if info.Status != models.RevocationGood {
+		t.Errorf("expected good via crl, got %+v", info)
+	}
+
+	info = revocationStatus(ctx, ca.issue(t, 11, "", ""), ca.cert, nil)
+	if info.Status != models.RevocationUnknown || info.Error == "" {
+		t.Errorf("expected unknown without responders, got %+v", info)
+	}
+}
+
+func TestRevocationClientRejectsPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+
+	if _, err := fetch(req, 1024); !errors.Is(err, errNonPublicAddress) {
+		t.Fatalf("expected errNonPublicAddress, got %v", err)
+	}
+}
+
+func TestRevocationStaleCRL(t *testing.T) {
+	allowLoopback(t)
+
+	ca := newTestCA(t)
+
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-48 * time.Hour),
+		NextUpdate: time.Now().Add(-24 * time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: big.NewInt(9), RevocationTime: time.Now().Add(-72 * time.Hour)},
+		},
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crlSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(crl)
+	}))
+	defer crlSrv.Close()
+
+	ctx := context.Background()
+
+	// Not listed in an expired CRL: unknown, not good
+	info := revocationStatus(ctx, ca.issue(t, 10, "", crlSrv.URL), ca.cert, nil)
+	if info.Status != models.RevocationUnknown || !strings.Contains(info.Error, "stale crl") {
+		t.Errorf("expected unknown for a stale crl, got %+v", info)
+	}
+
+	// Listed: still revoked
+	info = revocationStatus(ctx, ca.issue(t, 9, "", crlSrv.URL), ca.cert, nil)
+	if info.Status != models.RevocationRevoked {
+		t.Errorf("expected revoked, got %+v", info)
+	}
+
+	// A cancelled scan doesn't fetch anything
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	info = revocationStatus(cancelled, ca.issue(t, 11, "", crlSrv.URL), ca.cert, nil)
+	if info.Status != models.RevocationUnknown {
+		t.Errorf("expected unknown for a cancelled scan, got %+v", info)
+	}
+}
+
+func TestRevocationStaleOCSP(t *testing.T) {
+	allowLoopback(t)
+
+	ca := newTestCA(t)
+
+	response := func(serial int64, status int, thisUpdate, nextUpdate time.Time) []byte {
+		resp, err := ocsp.CreateResponse(ca.cert, ca.cert, ocsp.Response{
+			Status:       status,
+			SerialNumber: big.NewInt(serial),
+			ThisUpdate:   thisUpdate,
+			NextUpdate:   nextUpdate,
+			RevokedAt:    thisUpdate,
+		}, ca.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Minute),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: big.NewInt(12), RevocationTime: time.Now().Add(-time.Hour)},
+		},
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crlSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(crl)
+	}))
+	defer crlSrv.Close()
+
+	ctx := context.Background()
+	expired := response(12, ocsp.Good, time.Now().Add(-10*24*time.Hour), time.Now().Add(-3*24*time.Hour))
+
+	// An expired "good" staple of a since-revoked certificate falls through to the CRL
+	info := revocationStatus(ctx, ca.issue(t, 12, "", crlSrv.URL), ca.cert, expired)
+	if info.Status != models.RevocationRevoked || info.Source != models.RevocationSourceCRL {
+		t.Errorf("expected revoked via crl, got %+v", info)
+	}
+
+	// Nothing else to ask: unknown, not good
+	info = revocationStatus(ctx, ca.issue(t, 12, "", ""), ca.cert, expired)
+	if info.Status != models.RevocationUnknown || !strings.Contains(info.Error, "stale ocsp response") {
+		t.Errorf("expected unknown for an expired staple, got %+v", info)
+	}
+
+	// Issued in the future beyond the clock skew
+	future := response(13, ocsp.Good, time.Now().Add(time.Hour), time.Now().Add(2*time.Hour))
+	info = revocationStatus(ctx, ca.issue(t, 13, "", ""), ca.cert, future)
+	if info.Status != models.RevocationUnknown || !strings.Contains(info.Error, "from the future") {
+		t.Errorf("expected unknown for a future staple, got %+v", info)
+	}
+
+	// Fetched responses get the same check
+	ocspSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(response(14, ocsp.Good, time.Now().Add(-10*24*time.Hour), time.Now().Add(-3*24*time.Hour)))
+	}))
+	defer ocspSrv.Close()
+
+	info = revocationStatus(ctx, ca.issue(t, 14, ocspSrv.URL, ""), ca.cert, nil)
+	if info.Status != models.RevocationUnknown || !strings.Contains(info.Error, "stale ocsp response") {
+		t.Errorf("expected unknown for an expired ocsp response, got %+v", info)
+	}
+
+	// A revoked response stays revoked however old it is
+	revoked := response(15, ocsp.Revoked, time.Now().Add(-10*24*time.Hour), time.Now().Add(-3*24*time.Hour))
+	info = revocationStatus(ctx, ca.issue(t, 15, "", ""), ca.cert, revoked)
+	if info.Status != models.RevocationRevoked {
+		t.Errorf("expected revoked, got %+v", info)
+	}
+}
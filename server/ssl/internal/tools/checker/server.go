@@ -1,7 +1,6 @@
 package checker
 
 import (
-	"context"
 	"strings"
 )
 
@@ -53,9 +52,7 @@ func normalizeServer(v string) string {
    Public API
 =========================== */
 
-func detectServerType(ctx context.Context, domain string, ip string) string {
-	probes := collectProbes(ctx, domain, ip)
-
+func detectServerType(probes []*Probe) string {
 	scores := map[string]int{}
 	var fallback string
 
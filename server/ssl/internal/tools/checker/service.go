@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/sync/singleflight"
 	"tools.bctechvibe.io.vn/server/ssl/internal/config"
@@ -13,6 +15,49 @@ import (
 	"tools.bctechvibe.io.vn/server/ssl/internal/platform/shared"
 )
 
+// ScanOptions controls the optional, slower parts of a scan.
+type ScanOptions struct {
+	// EnumerateProtocols probes every protocol version and cipher suite
+	EnumerateProtocols bool
+
+	// Fresh skips the cached result (the new result is still cached)
+	Fresh bool
+
+	// Port and Protocol select the service; zero values mean HTTPS on 443
+	Port     int
+	Protocol string
+
+	// AllEndpoints scans every A / AAAA address instead of one
+	AllEndpoints bool
+}
+
+func (o ScanOptions) protocol() string {
+	if o.Protocol == "" {
+		return config.ProtocolHTTPS
+	}
+	return o.Protocol
+}
+
+func (o ScanOptions) port() int {
+	if o.Port > 0 {
+		return o.Port
+	}
+	return config.DefaultPorts[o.protocol()]
+}
+
+// key identifies scans that can share a result
+func (o ScanOptions) key(domain string) string {
+	mode := config.ScanModeQuick
+	if o.EnumerateProtocols {
+		mode = config.ScanModeFull
+	}
+	key := fmt.Sprintf("%s|%s|%d|%s", domain, o.protocol(), o.port(), mode)
+	if o.AllEndpoints {
+		key += "|all"
+	}
+	return key
+}
+
 type Service struct {
 	cache   *cache.MemoryCache
 	breaker *breaker.CircuitBreaker
@@ -22,11 +67,21 @@ type Service struct {
 
 	// allow overriding scanner for tests (optional)
 	// return concrete type to match Scan
-	scanFunc func(ctx context.Context, domain string) (*models.SSLCheckResponse, error)
+	scanFunc func(ctx context.Context, domain string, opts ScanOptions) (*models.SSLCheckResponse, error)
+
+	// cache counters
+	hits   atomic.Uint64
+	misses atomic.Uint64
 
 	mu sync.Mutex
 }
 
+// CacheStats reports how often Check was served from cache.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
 func New() *Service {
 	s := &Service{
 		cache:   cache.NewMemoryCacheWithCleanup(config.CacheTTL, config.CacheCleanupInterval),
@@ -47,21 +102,35 @@ func NewWithDeps(c *cache.MemoryCache, b *breaker.CircuitBreaker) *Service {
 }
 
 // Check now accepts caller's context so timeouts/cancellation propagate
-func (s *Service) Check(ctx context.Context, domain string) (*models.SSLCheckResponse, error) {
+func (s *Service) Check(ctx context.Context, domain string, opts ScanOptions) (*models.SSLCheckResponse, error) {
+	key := opts.key(domain)
+
+	// cached result (served even while the breaker is open)
+	if !opts.Fresh {
+		if cached, ok := s.cachedResult(key); ok {
+			s.hits.Add(1)
+			return cached, nil
+		}
+	}
+
 	// breaker
 	if !s.breaker.Allow(domain) {
 		return nil, shared.ErrBlocked
 	}
 
+	// only scans that actually run count as misses
+	s.misses.Add(1)
+
 	// coalesce concurrent scans
-	v, err, _ := s.sf.Do(domain, func() (interface{}, error) {
+	v, err, _ := s.sf.Do(key, func() (interface{}, error) {
 		// call the injected scan func (uses ctx)
-		res, err := s.scanFunc(ctx, domain)
+		res, err := s.scanFunc(ctx, domain, opts)
 		if err != nil {
 			s.breaker.Fail(domain)
 			return nil, err
 		}
 		s.breaker.Success(domain)
+		s.cache.Set(key, res)
 		return res, nil
 	})
 	if err != nil {
@@ -74,3 +143,28 @@ func (s *Service) Check(ctx context.Context, domain string) (*models.SSLCheckRes
 	}
 	return resp, nil
 }
+
+// cachedResult returns a copy of the cached scan tagged with its age
+func (s *Service) cachedResult(key string) (*models.SSLCheckResponse, bool) {
+	v, ok := s.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	res, ok := v.(*models.SSLCheckResponse)
+	if !ok {
+		return nil, false
+	}
+
+	cp := *res
+	cp.Cached = true
+	cp.CacheAge = int64(time.Since(res.CheckTime).Seconds())
+	return &cp, true
+}
+
+func (s *Service) CacheStats() CacheStats {
+	return CacheStats{
+		Hits:   s.hits.Load(),
+		Misses: s.misses.Load(),
+	}
+}
@@ -0,0 +1,86 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+	"tools.bctechvibe.io.vn/server/ssl/internal/platform/breaker"
+	"tools.bctechvibe.io.vn/server/ssl/internal/platform/cache"
+)
+
+func TestCheckUsesCache(t *testing.T) {
+	c := cache.NewMemoryCache(time.Minute)
+	defer c.Stop()
+	b := breaker.New()
+	defer b.Stop()
+
+	scans := 0
+	svc := NewWithDeps(c, b)
+	svc.scanFunc = func(ctx context.Context, domain string, opts ScanOptions) (*models.SSLCheckResponse, error) {
+		scans++
+		return &models.SSLCheckResponse{Hostname: domain, CheckTime: time.Now(), Success: true}, nil
+	}
+
+	ctx := context.Background()
+
+	first, err := svc.Check(ctx, "example.com", ScanOptions{})
+	if err != nil || first.Cached {
+		t.Fatalf("first check should be a miss: %+v %v", first, err)
+	}
+
+	second, _ := svc.Check(ctx, "example.com", ScanOptions{})
+	if !second.Cached || scans != 1 {
+		t.Errorf("second check should be served from cache (scans=%d)", scans)
+	}
+	if first.Cached {
+		t.Error("cached copy must not mutate the stored result")
+	}
+
+	if fresh, _ := svc.Check(ctx, "example.com", ScanOptions{Fresh: true}); fresh.Cached || scans != 2 {
+		t.Errorf("fresh check should rescan (scans=%d)", scans)
+	}
+
+	// full scans are cached separately from quick scans
+	if full, _ := svc.Check(ctx, "example.com", ScanOptions{EnumerateProtocols: true}); full.Cached {
+		t.Error("full scan must not reuse the quick scan result")
+	}
+
+	if stats := svc.CacheStats(); stats.Hits != 1 || stats.Misses != 3 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestCacheStatsSkipBlocked(t *testing.T) {
+	c := cache.NewMemoryCache(time.Minute)
+	defer c.Stop()
+	b := breaker.New()
+	defer b.Stop()
+
+	svc := NewWithDeps(c, b)
+	svc.scanFunc = func(ctx context.Context, domain string, opts ScanOptions) (*models.SSLCheckResponse, error) {
+		return nil, errors.New("handshake failed")
+	}
+
+	for range config.CircuitBreakerThreshold + 3 {
+		svc.Check(context.Background(), "down.example", ScanOptions{})
+	}
+
+	if stats := svc.CacheStats(); stats.Misses != config.CircuitBreakerThreshold {
+		t.Errorf("blocked checks counted as misses: %+v", stats)
+	}
+
+	rec := httptest.NewRecorder()
+	NewStatsHandler(svc).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/ssl/check/stats", nil))
+
+	var stats CacheStats
+	if err := json.NewDecoder(rec.Body).Decode(&stats); err != nil || stats.Misses != config.CircuitBreakerThreshold {
+		t.Errorf("stats endpoint %d %+v %v", rec.Code, stats, err)
+	}
+}
@@ -0,0 +1,412 @@
+package checker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+)
+
+/* ===========================
+   STARTTLS / PROTOCOL UPGRADE
+===========================
+
+   upgradeConn runs the plaintext part of a protocol so the next bytes on
+   the connection are the TLS handshake. It returns the server greeting,
+   which doubles as the server type for non-HTTP services.
+*/
+
+var (
+	ErrStartTLSUnsupported = errors.New("server does not offer TLS upgrade")
+	ErrStartTLSRefused     = errors.New("server refused TLS upgrade")
+)
+
+const (
+	startTLSClientName = "tools.bctechvibe.io.vn"
+	maxUpgradeResponse = 64 << 10
+
+	ldapStartTLSOID = "1.3.6.1.4.1.1466.20037"
+)
+
+func upgradeConn(
+	ctx context.Context,
+	conn net.Conn,
+	protocol string,
+	domain string,
+) (string, error) {
+
+	var upgrade func(net.Conn, *bufio.Reader, string) (string, error)
+
+	switch protocol {
+	case "", config.ProtocolHTTPS, config.ProtocolTLS:
+		return "", nil
+	case config.ProtocolSMTP:
+		upgrade = smtpStartTLS
+	case config.ProtocolIMAP:
+		upgrade = imapStartTLS
+	case config.ProtocolPOP3:
+		upgrade = pop3StartTLS
+	case config.ProtocolFTP:
+		upgrade = ftpAuthTLS
+	case config.ProtocolLDAP:
+		upgrade = ldapStartTLS
+	case config.ProtocolPostgres:
+		upgrade = postgresSSLRequest
+	case config.ProtocolXMPP:
+		upgrade = xmppStartTLS
+	default:
+		return "", fmt.Errorf("unsupported protocol %q", protocol)
+	}
+
+	deadline := time.Now().Add(config.TLSDialTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	defer conn.SetDeadline(time.Time{})
+
+	r := bufio.NewReader(io.LimitReader(conn, maxUpgradeResponse))
+
+	banner, err := upgrade(conn, r, domain)
+	if err != nil {
+		return banner, fmt.Errorf("%s upgrade failed: %w", protocol, err)
+	}
+
+	// Anything already buffered was sent before TLS started (STARTTLS injection)
+	if r.Buffered() > 0 {
+		return banner, fmt.Errorf("%s upgrade failed: unexpected data before handshake", protocol)
+	}
+
+	return banner, nil
+}
+
+/* ===========================
+   LINE BASED PROTOCOLS
+=========================== */
+
+func readLine(r *bufio.Reader) (string, error) {
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readReply reads an SMTP / FTP style reply ("250-..." continues,
+// "250 ..." ends) and checks its status code.
+func readReply(r *bufio.Reader, want int) (string, error) {
+
+	var lines []string
+
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return "", err
+		}
+
+		if len(line) < 3 {
+			return "", fmt.Errorf("malformed reply %q", line)
+		}
+
+		code, err := strconv.Atoi(line[:3])
+		if err != nil {
+			return "", fmt.Errorf("malformed reply %q", line)
+		}
+
+		lines = append(lines, strings.TrimSpace(line[min(4, len(line)):]))
+
+		if len(line) > 3 && line[3] == '-' {
+			continue
+		}
+
+		if code != want {
+			return strings.Join(lines, "\n"), fmt.Errorf("%w: %s", ErrStartTLSRefused, line)
+		}
+
+		return strings.Join(lines, "\n"), nil
+	}
+}
+
+func smtpStartTLS(conn net.Conn, r *bufio.Reader, _ string) (string, error) {
+
+	greeting, err := readReply(r, 220)
+	if err != nil {
+		return "", err
+	}
+	banner, _, _ := strings.Cut(greeting, "\n")
+
+	fmt.Fprintf(conn, "EHLO %s\r\n", startTLSClientName)
+
+	ehlo, err := readReply(r, 250)
+	if err != nil {
+		return banner, err
+	}
+
+	if !strings.Contains(strings.ToUpper(ehlo), "STARTTLS") {
+		return banner, ErrStartTLSUnsupported
+	}
+
+	fmt.Fprint(conn, "STARTTLS\r\n")
+
+	_, err = readReply(r, 220)
+	return banner, err
+}
+
+func ftpAuthTLS(conn net.Conn, r *bufio.Reader, _ string) (string, error) {
+
+	greeting, err := readReply(r, 220)
+	if err != nil {
+		return "", err
+	}
+	banner, _, _ := strings.Cut(greeting, "\n")
+
+	fmt.Fprint(conn, "AUTH TLS\r\n")
+
+	_, err = readReply(r, 234)
+	return banner, err
+}
+
+func imapStartTLS(conn net.Conn, r *bufio.Reader, _ string) (string, error) {
+
+	greeting, err := readLine(r)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(greeting, "* OK") {
+		return greeting, fmt.Errorf("unexpected greeting %q", greeting)
+	}
+	banner := strings.TrimSpace(strings.TrimPrefix(greeting, "* OK"))
+
+	fmt.Fprint(conn, "a1 STARTTLS\r\n")
+
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return banner, err
+		}
+
+		// Skip untagged responses
+		if !strings.HasPrefix(line, "a1 ") {
+			continue
+		}
+
+		if strings.HasPrefix(strings.ToUpper(line), "A1 OK") {
+			return banner, nil
+		}
+
+		return banner, fmt.Errorf("%w: %s", ErrStartTLSRefused, line)
+	}
+}
+
+func pop3StartTLS(conn net.Conn, r *bufio.Reader, _ string) (string, error) {
+
+	greeting, err := readLine(r)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(greeting, "+OK") {
+		return greeting, fmt.Errorf("unexpected greeting %q", greeting)
+	}
+	banner := strings.TrimSpace(strings.TrimPrefix(greeting, "+OK"))
+
+	fmt.Fprint(conn, "STLS\r\n")
+
+	line, err := readLine(r)
+	if err != nil {
+		return banner, err
+	}
+
+	if !strings.HasPrefix(line, "+OK") {
+		return banner, fmt.Errorf("%w: %s", ErrStartTLSRefused, line)
+	}
+
+	return banner, nil
+}
+
+/* ===========================
+   XMPP (RFC 6120)
+=========================== */
+
+// readUntil reads XML tokens until one of the markers shows up.
+func readUntil(r *bufio.Reader, markers ...string) (string, error) {
+
+	var buf strings.Builder
+
+	for {
+		chunk, err := r.ReadString('>')
+		buf.WriteString(chunk)
+
+		for _, m := range markers {
+			if strings.Contains(buf.String(), m) {
+				return buf.String(), nil
+			}
+		}
+
+		if err != nil {
+			return buf.String(), err
+		}
+	}
+}
+
+func xmppStartTLS(conn net.Conn, r *bufio.Reader, domain string) (string, error) {
+
+	fmt.Fprintf(conn,
+		"<?xml version='1.0'?><stream:stream to='%s' xmlns='jabber:client' "+
+			"xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>",
+		domain,
+	)
+
+	features, err := readUntil(r, "</stream:features>", "<stream:features/>")
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.Contains(features, "urn:ietf:params:xml:ns:xmpp-tls") {
+		return "XMPP", ErrStartTLSUnsupported
+	}
+
+	fmt.Fprint(conn, "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+
+	reply, err := readUntil(r, "<proceed", "<failure")
+	if err != nil {
+		return "XMPP", err
+	}
+
+	if strings.Contains(reply, "<failure") {
+		return "XMPP", ErrStartTLSRefused
+	}
+
+	return "XMPP", nil
+}
+
+/* ===========================
+   POSTGRESQL SSLRequest
+=========================== */
+
+func postgresSSLRequest(conn net.Conn, r *bufio.Reader, _ string) (string, error) {
+
+	// Int32 length (8) + Int32 SSLRequest code (80877103)
+	if _, err := conn.Write([]byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}); err != nil {
+		return "", err
+	}
+
+	b, err := r.ReadByte()
+	if err != nil {
+		return "PostgreSQL", err
+	}
+
+	switch b {
+	case 'S':
+		return "PostgreSQL", nil
+	case 'N':
+		return "PostgreSQL", ErrStartTLSUnsupported
+	default:
+		return "", fmt.Errorf("unexpected SSLRequest reply 0x%02x", b)
+	}
+}
+
+/* ===========================
+   LDAP StartTLS (RFC 4511)
+=========================== */
+
+func ldapStartTLSRequest() []byte {
+
+	oid := []byte(ldapStartTLSOID)
+
+	// ExtendedRequest ::= [APPLICATION 23] { requestName [0] LDAPOID }
+	ext := append([]byte{0x77, byte(len(oid) + 2), 0x80, byte(len(oid))}, oid...)
+
+	// LDAPMessage ::= SEQUENCE { messageID 1, protocolOp }
+	msg := append([]byte{0x02, 0x01, 0x01}, ext...)
+
+	return append([]byte{0x30, byte(len(msg))}, msg...)
+}
+
+// readBER reads one BER element and returns its tag and content.
+func readBER(r *bufio.Reader) (byte, []byte, error) {
+
+	tag, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length := int(first)
+
+	if first&0x80 != 0 {
+		n := int(first & 0x7f)
+		if n == 0 || n > 3 {
+			return 0, nil, errors.New("unsupported BER length")
+		}
+
+		length = 0
+		for i := 0; i < n; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return 0, nil, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+
+	return tag, body, nil
+}
+
+func ldapStartTLS(conn net.Conn, r *bufio.Reader, _ string) (string, error) {
+
+	if _, err := conn.Write(ldapStartTLSRequest()); err != nil {
+		return "", err
+	}
+
+	tag, msg, err := readBER(r)
+	if err != nil {
+		return "LDAP", err
+	}
+
+	if tag != 0x30 {
+		return "LDAP", fmt.Errorf("unexpected LDAP response tag 0x%02x", tag)
+	}
+
+	// messageID INTEGER, then ExtendedResponse [APPLICATION 24]
+	msgReader := bufio.NewReader(strings.NewReader(string(msg)))
+
+	if _, _, err := readBER(msgReader); err != nil {
+		return "LDAP", err
+	}
+
+	tag, resp, err := readBER(msgReader)
+	if err != nil {
+		return "LDAP", err
+	}
+
+	// resultCode ENUMERATED is the first field
+	if tag != 0x78 || len(resp) < 3 || resp[0] != 0x0a {
+		return "LDAP", fmt.Errorf("unexpected LDAP extended response")
+	}
+
+	if code := resp[2]; code != 0 {
+		return "LDAP", fmt.Errorf("%w: LDAP result code %d", ErrStartTLSRefused, code)
+	}
+
+	return "LDAP", nil
+}
@@ -0,0 +1,190 @@
+package checker
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+)
+
+// startPlainServer runs the plaintext dialog of a fake server and, when it
+// returns true, completes a TLS handshake on the same connection.
+func startPlainServer(t *testing.T, dialog func(net.Conn, *bufio.Reader) bool) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	conf := &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t, "mail.example.test")}}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+				// Unbuffered so no handshake bytes are swallowed
+				if dialog(conn, bufio.NewReaderSize(conn, 16)) {
+					tls.Server(conn, conf).Handshake()
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func expectLine(r *bufio.Reader, prefix string) bool {
+	line, err := r.ReadString('\n')
+	return err == nil && strings.HasPrefix(line, prefix)
+}
+
+func TestUpgradeConn(t *testing.T) {
+	cases := []struct {
+		protocol string
+		banner   string
+		dialog   func(net.Conn, *bufio.Reader) bool
+	}{
+		{config.ProtocolSMTP, "mail.example.test ESMTP", func(c net.Conn, r *bufio.Reader) bool {
+			fmt.Fprint(c, "220 mail.example.test ESMTP\r\n")
+			if !expectLine(r, "EHLO ") {
+				return false
+			}
+			fmt.Fprint(c, "250-mail.example.test\r\n250-PIPELINING\r\n250 STARTTLS\r\n")
+			if !expectLine(r, "STARTTLS") {
+				return false
+			}
+			fmt.Fprint(c, "220 Ready to start TLS\r\n")
+			return true
+		}},
+		{config.ProtocolIMAP, "IMAP4rev1 ready", func(c net.Conn, r *bufio.Reader) bool {
+			fmt.Fprint(c, "* OK IMAP4rev1 ready\r\n")
+			if !expectLine(r, "a1 STARTTLS") {
+				return false
+			}
+			fmt.Fprint(c, "a1 OK Begin TLS negotiation now\r\n")
+			return true
+		}},
+		{config.ProtocolPOP3, "POP3 ready", func(c net.Conn, r *bufio.Reader) bool {
+			fmt.Fprint(c, "+OK POP3 ready\r\n")
+			if !expectLine(r, "STLS") {
+				return false
+			}
+			fmt.Fprint(c, "+OK Begin TLS\r\n")
+			return true
+		}},
+		{config.ProtocolFTP, "FTP server ready", func(c net.Conn, r *bufio.Reader) bool {
+			fmt.Fprint(c, "220 FTP server ready\r\n")
+			if !expectLine(r, "AUTH TLS") {
+				return false
+			}
+			fmt.Fprint(c, "234 AUTH TLS successful\r\n")
+			return true
+		}},
+		{config.ProtocolPostgres, "PostgreSQL", func(c net.Conn, r *bufio.Reader) bool {
+			buf := make([]byte, 8)
+			if _, err := io.ReadFull(r, buf); err != nil || buf[4] != 0x04 || buf[7] != 0x2f {
+				return false
+			}
+			c.Write([]byte{'S'})
+			return true
+		}},
+		{config.ProtocolLDAP, "LDAP", func(c net.Conn, r *bufio.Reader) bool {
+			req := ldapStartTLSRequest()
+			buf := make([]byte, len(req))
+			if _, err := io.ReadFull(r, buf); err != nil || string(buf) != string(req) {
+				return false
+			}
+			// messageID 1, ExtendedResponse { success, "", "" }
+			c.Write([]byte{0x30, 0x0c, 0x02, 0x01, 0x01, 0x78, 0x07, 0x0a, 0x01, 0x00, 0x04, 0x00, 0x04, 0x00})
+			return true
+		}},
+		{config.ProtocolXMPP, "XMPP", func(c net.Conn, r *bufio.Reader) bool {
+			if _, err := readUntil(r, "version='1.0'>"); err != nil {
+				return false
+			}
+			fmt.Fprint(c, "<?xml version='1.0'?><stream:stream from='example.test' version='1.0'>"+
+				"<stream:features><starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'><required/></starttls></stream:features>")
+			if _, err := readUntil(r, "<starttls"); err != nil {
+				return false
+			}
+			fmt.Fprint(c, "<proceed xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+			return true
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.protocol, func(t *testing.T) {
+			addr := startPlainServer(t, tc.dialog)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			conn, banner, err := dialTLS(ctx, &net.Dialer{}, addr,
+				&tls.Config{ServerName: "mail.example.test", InsecureSkipVerify: true}, tc.protocol)
+			if err != nil {
+				t.Fatalf("upgrade failed: %v", err)
+			}
+			defer conn.Close()
+
+			if banner != tc.banner {
+				t.Errorf("banner = %q, want %q", banner, tc.banner)
+			}
+			if conn.ConnectionState().PeerCertificates[0].Subject.CommonName != "mail.example.test" {
+				t.Error("unexpected peer certificate")
+			}
+		})
+	}
+}
+
+func TestUpgradeConnNoStartTLS(t *testing.T) {
+	addr := startPlainServer(t, func(c net.Conn, r *bufio.Reader) bool {
+		fmt.Fprint(c, "220 mail.example.test ESMTP\r\n")
+		if expectLine(r, "EHLO ") {
+			fmt.Fprint(c, "250-mail.example.test\r\n250 PIPELINING\r\n")
+		}
+		return false
+	})
+
+	_, _, err := dialTLS(context.Background(), &net.Dialer{}, addr,
+		&tls.Config{ServerName: "mail.example.test", InsecureSkipVerify: true}, config.ProtocolSMTP)
+
+	if !errors.Is(err, ErrStartTLSUnsupported) {
+		t.Fatalf("expected ErrStartTLSUnsupported, got %v", err)
+	}
+}
+
+func TestEnumerateProtocolsOverStartTLS(t *testing.T) {
+	addr := startPlainServer(t, func(c net.Conn, r *bufio.Reader) bool {
+		fmt.Fprint(c, "+OK POP3 ready\r\n")
+		if !expectLine(r, "STLS") {
+			return false
+		}
+		fmt.Fprint(c, "+OK Begin TLS\r\n")
+		return true
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	for _, p := range enumerateProtocols(ctx, addr, "mail.example.test", config.ProtocolPOP3) {
+		if p.Version == config.TLSVersion13 && !p.Supported {
+			t.Errorf("TLS 1.3 should be detected after STLS: %+v", p)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+// DNSChecker reports the addresses of a domain and the blocklists that
+// list it.
+type DNSChecker interface {
+	CheckDNS(ctx context.Context, domain string) (*models.WatchDNSStatus, error)
+}
+
+// DNSService reads the DNS service's blacklist report
+// (GET {URL}/api/dns/blacklist-report/{domain}), which holds both the
+// domain's addresses and its listings.
+type DNSService struct {
+	URL    string
+	client *http.Client
+}
+
+func NewDNSService(baseURL string) *DNSService {
+	return &DNSService{
+		URL:    strings.TrimSuffix(baseURL, "/"),
+		client: &http.Client{Timeout: config.MonitorDNSTimeout},
+	}
+}
+
+// The fields of the report the monitor uses
+type blacklistReport struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Data    struct {
+		Targets []struct {
+			IP     string `json:"ip"`
+			Source string `json:"source"`
+		} `json:"targets"`
+		Listings []struct {
+			Provider string `json:"provider"`
+			IP       string `json:"ip"`
+			Domain   string `json:"domain"`
+		} `json:"listings"`
+	} `json:"data"`
+}
+
+func (d *DNSService) CheckDNS(ctx context.Context, domain string) (*models.WatchDNSStatus, error) {
+
+	u := d.URL + "/api/dns/blacklist-report/" + url.PathEscape(domain) + "?format=json"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var report blacklistReport
+	if err := json.NewDecoder(io.LimitReader(resp.Body, config.MaxMonitorDNSReport)).Decode(&report); err != nil {
+		return nil, fmt.Errorf("DNS service: %s: %w", resp.Status, err)
+	}
+
+	if resp.StatusCode != http.StatusOK || !report.Success {
+		return nil, fmt.Errorf("DNS service: %s: %s", resp.Status, report.Message)
+	}
+
+	out := &models.WatchDNSStatus{Addresses: []string{}}
+
+	for _, t := range report.Data.Targets {
+		if (t.Source == "A" || t.Source == "AAAA") && !slices.Contains(out.Addresses, t.IP) {
+			out.Addresses = append(out.Addresses, t.IP)
+		}
+	}
+
+	for _, l := range report.Data.Listings {
+
+		target := l.IP
+		if l.Domain != "" {
+			target = l.Domain
+		}
+
+		if entry := l.Provider + " " + target; !slices.Contains(out.Listings, entry) {
+			out.Listings = append(out.Listings, entry)
+		}
+	}
+
+	slices.Sort(out.Addresses)
+	slices.Sort(out.Listings)
+
+	return out, nil
+}
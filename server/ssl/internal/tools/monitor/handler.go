@@ -0,0 +1,163 @@
+package monitor
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+	"tools.bctechvibe.io.vn/server/ssl/internal/platform/middleware"
+	"tools.bctechvibe.io.vn/server/ssl/internal/platform/shared"
+)
+
+/*
+   GET    /api/ssl/monitor/watches       list watches
+   POST   /api/ssl/monitor/watches       add a watch
+   GET    /api/ssl/monitor/watches/{id}  watch + history
+   DELETE /api/ssl/monitor/watches/{id}  remove a watch
+   GET    /api/ssl/monitor/alerts        recent alerts
+
+   Every request but the first POST carries "Authorization: Bearer
+   <token>". A POST without a token creates one and returns it with the
+   watch; all routes only see the watches of their token. Tokens the
+   service did not issue are rejected, and each client IP may only create
+   a few tokens per hour.
+*/
+
+type Handler struct {
+	svc *Service
+
+	// limits token creation so the per-token cap can't be dodged
+	tokenLimiter *middleware.RateLimiter
+}
+
+type WatchRequest struct {
+	Domain   string `json:"domain"`
+	Port     int    `json:"port,omitempty"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// WatchCreated is the answer to a POST: the watch and the token owning it.
+type WatchCreated struct {
+	models.Watch
+	Token string `json:"token"`
+}
+
+func NewHandler(svc *Service, tokenLimiter *middleware.RateLimiter) *Handler {
+	return &Handler{
+		svc:          svc,
+		tokenLimiter: tokenLimiter,
+	}
+}
+
+// bearerToken returns the token of the Authorization header, or "".
+func bearerToken(r *http.Request) string {
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	id := r.PathValue("id")
+	token := bearerToken(r)
+
+	if token == "" && r.Method != http.MethodPost {
+		shared.ErrorDecode(w, "Thiếu mã truy cập (Authorization: Bearer <token>)", http.StatusUnauthorized)
+		return
+	}
+
+	if token != "" && !h.svc.ValidToken(token) {
+		shared.ErrorDecode(w, ErrUnknownToken.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/alerts") && r.Method == http.MethodGet:
+		shared.JSON(w, h.svc.Alerts(token))
+
+	case id == "" && r.Method == http.MethodGet:
+		shared.JSON(w, h.svc.List(token))
+
+	case id == "" && r.Method == http.MethodPost:
+		h.add(w, r, token)
+
+	case id != "" && r.Method == http.MethodGet:
+		detail, err := h.svc.Get(token, id)
+		if err != nil {
+			shared.ErrorDecode(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		shared.JSON(w, detail)
+
+	case id != "" && r.Method == http.MethodDelete:
+		if err := h.svc.Remove(token, id); err != nil {
+			shared.ErrorDecode(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		shared.JSON(w, map[string]any{"success": true})
+
+	default:
+		shared.ErrorDecode(w, "Phương thức HTTP không được hỗ trợ", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) add(w http.ResponseWriter, r *http.Request, token string) {
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	defer r.Body.Close()
+
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "application/json") {
+		shared.ErrorDecode(w, "Content-Type không được hỗ trợ", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var req WatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Không thể parse request body: %v", err)
+		shared.ErrorDecode(w, "Dữ liệu request không hợp lệ", http.StatusBadRequest)
+		return
+	}
+
+	domain, err := shared.ParseDomain(strings.TrimSuffix(strings.TrimSpace(req.Domain), "."))
+	if err != nil {
+		shared.ErrorDecode(w, "Định dạng tên miền không hợp lệ", http.StatusBadRequest)
+		return
+	}
+
+	if req.Port < 0 || req.Port > 65535 {
+		shared.ErrorDecode(w, "Cổng không hợp lệ (1 - 65535)", http.StatusBadRequest)
+		return
+	}
+
+	if token == "" {
+
+		if h.tokenLimiter != nil && !h.tokenLimiter.IsAllowed(h.tokenLimiter.GetClientIP(r.RemoteAddr, r.Header)) {
+			shared.ErrorDecode(w, "Bạn đã tạo quá nhiều mã truy cập, vui lòng thử lại sau.", http.StatusTooManyRequests)
+			return
+		}
+
+		token = h.svc.IssueToken()
+	}
+
+	watch, err := h.svc.Add(token, domain, req.Port, strings.ToLower(strings.TrimSpace(req.Protocol)))
+
+	switch {
+	case errors.Is(err, ErrWatchExists):
+		shared.ErrorDecode(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrUnknownToken):
+		shared.ErrorDecode(w, err.Error(), http.StatusUnauthorized)
+	case err != nil:
+		shared.ErrorDecode(w, err.Error(), http.StatusBadRequest)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(WatchCreated{Watch: watch, Token: token})
+	}
+}
@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"fmt"
+	"strconv"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+)
+
+var alertSeverity = map[models.AlertType]models.NotificationSeverity{
+	models.AlertExpirySoon:         models.SeverityWarning,
+	models.AlertFingerprintChanged: models.SeverityInfo,
+	models.AlertNewTrustIssue:      models.SeverityCritical,
+	models.AlertAddressesChanged:   models.SeverityWarning,
+	models.AlertBlacklisted:        models.SeverityCritical,
+	models.AlertDelisted:           models.SeverityInfo,
+}
+
+var alertSubject = map[models.AlertType]string{
+	models.AlertExpirySoon:         "Chứng chỉ sắp hết hạn",
+	models.AlertFingerprintChanged: "Chứng chỉ đã thay đổi",
+	models.AlertNewTrustIssue:      "Chứng chỉ có lỗi tin cậy mới",
+	models.AlertAddressesChanged:   "Địa chỉ IP đã thay đổi",
+	models.AlertBlacklisted:        "Bị liệt kê trong danh sách đen",
+	models.AlertDelisted:           "Đã được gỡ khỏi danh sách đen",
+}
+
+// Alerts not listed here come from the certificate checks
+var alertSource = map[models.AlertType]models.NotificationSource{
+	models.AlertAddressesChanged: models.NotificationSourceDNS,
+	models.AlertBlacklisted:      models.NotificationSourceBlacklist,
+	models.AlertDelisted:         models.NotificationSourceBlacklist,
+}
+
+var sourceTag = map[models.NotificationSource]string{
+	models.NotificationSourceSSL:       "SSL",
+	models.NotificationSourceDNS:       "DNS",
+	models.NotificationSourceBlacklist: "Blacklist",
+}
+
+// Notification converts a monitor alert for the notifiers.
+func Notification(a models.MonitorAlert) models.Notification {
+
+	target := a.Domain
+	if a.Port != 0 {
+		target += ":" + strconv.Itoa(a.Port)
+	}
+
+	source, ok := alertSource[a.Type]
+	if !ok {
+		source = models.NotificationSourceSSL
+	}
+
+	return models.Notification{
+		ID:       a.ID,
+		Source:   source,
+		Event:    string(a.Type),
+		Severity: alertSeverity[a.Type],
+		Target:   target,
+		Subject:  fmt.Sprintf("[%s] %s: %s", sourceTag[source], alertSubject[a.Type], target),
+		Message:  a.Message,
+		Time:     a.Time,
+		Data:     a,
+	}
+}
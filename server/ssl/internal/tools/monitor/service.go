@@ -0,0 +1,726 @@
+package monitor
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/config"
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+	"tools.bctechvibe.io.vn/server/ssl/internal/tools/checker"
+)
+
+var (
+	ErrWatchNotFound   = errors.New("Không tìm thấy tên miền trong danh sách theo dõi")
+	ErrWatchExists     = errors.New("Tên miền và cổng này đã có trong danh sách theo dõi")
+	ErrTooManyWatches  = errors.New("Danh sách theo dõi đã đạt số lượng tối đa")
+	ErrInvalidProtocol = errors.New("Giao thức không được hỗ trợ")
+	ErrTooManyOwned    = errors.New("Mã truy cập này đã theo dõi số lượng tên miền tối đa")
+	ErrUnknownToken    = errors.New("Mã truy cập không hợp lệ")
+)
+
+// Checker is the part of checker.Service the monitor needs.
+type Checker interface {
+	Check(ctx context.Context, domain string, opts checker.ScanOptions) (*models.SSLCheckResponse, error)
+}
+
+// Service keeps the watch list, rescans it on a schedule and raises alerts
+// when a result changes for the worse. Every watch belongs to the access
+// token it was created with; only that token can see or remove it. Tokens
+// are issued by the service, any other token is rejected.
+type Service struct {
+	checker Checker
+	dns     DNSChecker // optional
+	path    string     // JSON file, empty = memory only
+
+	mu          sync.RWMutex
+	watches     map[string]*models.WatchDetail
+	owners      map[string]string // watch ID → owner (hashed token)
+	tokens      map[string]bool   // owners of the issued tokens
+	alerts      []models.MonitorAlert
+	subscribers []func(models.MonitorAlert)
+	scanning    map[string]bool
+
+	saveMu sync.Mutex
+
+	stop chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+
+	now func() time.Time
+}
+
+func New(c Checker, path string) *Service {
+
+	s := &Service{
+		checker:  c,
+		path:     path,
+		watches:  make(map[string]*models.WatchDetail),
+		owners:   make(map[string]string),
+		tokens:   make(map[string]bool),
+		scanning: make(map[string]bool),
+		stop:     make(chan struct{}),
+		now:      time.Now,
+	}
+
+	if err := s.load(); err != nil {
+		log.Printf("[MONITOR] cannot load %s: %v", path, err)
+	}
+
+	return s
+}
+
+/* ===========================
+   WATCH LIST
+=========================== */
+
+func watchKey(domain string, port int) string {
+	return fmt.Sprintf("%s:%d", domain, port)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// IssueToken creates an access token for a new owner. Only issued tokens
+// can add watches.
+func (s *Service) IssueToken() string {
+
+	b := make([]byte, 32)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	s.tokens[owner(token)] = true
+	s.mu.Unlock()
+
+	s.save()
+
+	return token
+}
+
+// ValidToken reports whether token was issued by IssueToken.
+func (s *Service) ValidToken(token string) bool {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return token != "" && s.tokens[owner(token)]
+}
+
+// owner is what is stored for a token, so the state file holds no tokens.
+func owner(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// owns reports whether token owns watch id. Call with s.mu held.
+func (s *Service) owns(token, id string) bool {
+	o, ok := s.owners[id]
+	return ok && token != "" && o == owner(token)
+}
+
+// Add registers a watch owned by token and schedules its first scan right
+// away.
+func (s *Service) Add(token, domain string, port int, protocol string) (models.Watch, error) {
+
+	if protocol == "" {
+		protocol = config.ProtocolHTTPS
+	}
+
+	defPort, ok := config.DefaultPorts[protocol]
+	if !ok {
+		return models.Watch{}, ErrInvalidProtocol
+	}
+
+	if port == 0 {
+		port = defPort
+	}
+
+	s.mu.Lock()
+
+	if token == "" || !s.tokens[owner(token)] {
+		s.mu.Unlock()
+		return models.Watch{}, ErrUnknownToken
+	}
+
+	if len(s.watches) >= config.MaxMonitorWatches {
+		s.mu.Unlock()
+		return models.Watch{}, ErrTooManyWatches
+	}
+
+	owned := 0
+	for _, w := range s.watches {
+
+		if !s.owns(token, w.ID) {
+			continue
+		}
+		owned++
+
+		if watchKey(w.Domain, w.Port) == watchKey(domain, port) {
+			s.mu.Unlock()
+			return models.Watch{}, ErrWatchExists
+		}
+	}
+
+	if owned >= config.MaxMonitorWatchesPerOwner {
+		s.mu.Unlock()
+		return models.Watch{}, ErrTooManyOwned
+	}
+
+	w := &models.WatchDetail{
+		Watch: models.Watch{
+			ID:        newID(),
+			Domain:    domain,
+			Port:      port,
+			Protocol:  protocol,
+			CreatedAt: s.now(),
+		},
+	}
+
+	s.watches[w.ID] = w
+	s.owners[w.ID] = owner(token)
+	out := w.Watch
+
+	s.mu.Unlock()
+
+	s.save()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.scanWatch(context.Background(), out.ID)
+	}()
+
+	return out, nil
+}
+
+// Remove deletes a watch of token. Someone else's watch is reported as
+// not found.
+func (s *Service) Remove(token, id string) error {
+
+	s.mu.Lock()
+
+	if !s.owns(token, id) {
+		s.mu.Unlock()
+		return ErrWatchNotFound
+	}
+
+	delete(s.watches, id)
+	delete(s.owners, id)
+	s.mu.Unlock()
+
+	s.save()
+
+	return nil
+}
+
+// List returns the watches of token.
+func (s *Service) List(token string) []models.Watch {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]models.Watch, 0)
+
+	for id, w := range s.watches {
+		if s.owns(token, id) {
+			out = append(out, w.Watch)
+		}
+	}
+
+	slices.SortFunc(out, func(a, b models.Watch) int {
+		return strings.Compare(watchKey(a.Domain, a.Port), watchKey(b.Domain, b.Port))
+	})
+
+	return out
+}
+
+func (s *Service) Get(token, id string) (models.WatchDetail, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	w, ok := s.watches[id]
+	if !ok || !s.owns(token, id) {
+		return models.WatchDetail{}, ErrWatchNotFound
+	}
+
+	out := *w
+	out.History = slices.Clone(w.History)
+
+	return out, nil
+}
+
+// Alerts returns the recent alerts of token's watches, newest first.
+func (s *Service) Alerts(token string) []models.MonitorAlert {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]models.MonitorAlert, 0)
+
+	for _, a := range slices.Backward(s.alerts) {
+		if s.owns(token, a.WatchID) {
+			out = append(out, a)
+		}
+	}
+
+	return out
+}
+
+// Subscribe registers fn to be called for every new alert.
+func (s *Service) Subscribe(fn func(models.MonitorAlert)) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// SetDNSChecker also tracks each watch's addresses and blocklist
+// listings. Call it before Start.
+func (s *Service) SetDNSChecker(d DNSChecker) {
+	s.dns = d
+}
+
+/* ===========================
+   SCHEDULER
+=========================== */
+
+func (s *Service) Start() {
+
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(config.MonitorSchedulerTick)
+		defer ticker.Stop()
+
+		for {
+			s.RunDue(context.Background())
+
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Service) Stop() {
+	s.once.Do(func() { close(s.stop) })
+	s.wg.Wait()
+}
+
+// RunDue rescans every watch whose last check is older than
+// config.MonitorRescanInterval, a few at a time.
+func (s *Service) RunDue(ctx context.Context) {
+
+	now := s.now()
+	var due []string
+
+	s.mu.RLock()
+	for id, w := range s.watches {
+		if w.LastCheck == nil || now.Sub(*w.LastCheck) >= config.MonitorRescanInterval {
+			due = append(due, id)
+		}
+	}
+	s.mu.RUnlock()
+
+	sem := make(chan struct{}, config.MonitorScanConcurrency)
+	var wg sync.WaitGroup
+
+	for _, id := range due {
+
+		select {
+		case <-s.stop:
+			wg.Wait()
+			return
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			s.scanWatch(ctx, id)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (s *Service) scanWatch(ctx context.Context, id string) {
+
+	s.mu.Lock()
+
+	w, ok := s.watches[id]
+	if !ok || s.scanning[id] {
+		s.mu.Unlock()
+		return
+	}
+
+	s.scanning[id] = true
+	domain, port, protocol := w.Domain, w.Port, w.Protocol
+
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.scanning, id)
+		s.mu.Unlock()
+	}()
+
+	scanCtx, cancel := context.WithTimeout(ctx, config.MonitorScanTimeout)
+	defer cancel()
+
+	res, err := s.checker.Check(scanCtx, domain, checker.ScanOptions{
+		Port:     port,
+		Protocol: protocol,
+		Fresh:    true,
+	})
+
+	result := toResult(res, err, s.now())
+
+	if s.dns != nil {
+
+		dnsCtx, cancel := context.WithTimeout(ctx, config.MonitorDNSTimeout)
+		defer cancel()
+
+		result.DNS, err = s.dns.CheckDNS(dnsCtx, domain)
+		if err != nil {
+			result.DNSError = err.Error()
+		}
+	}
+
+	s.mu.Lock()
+
+	// Removed while scanning
+	w, ok = s.watches[id]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+
+	alerts := evaluate(w.Watch, lastSuccess(w.History), result, config.CertExpirySoonThreshold)
+	alerts = append(alerts, evaluateDNS(w.Watch, lastDNS(w.History), result)...)
+
+	w.LastCheck = &result.CheckTime
+	w.Last = &result
+	w.History = append(w.History, result)
+
+	if over := len(w.History) - config.MonitorHistoryLimit; over > 0 {
+		w.History = slices.Delete(w.History, 0, over)
+	}
+
+	for i := range alerts {
+		alerts[i].ID = newID()
+	}
+
+	s.alerts = append(s.alerts, alerts...)
+	if over := len(s.alerts) - config.MonitorAlertLimit; over > 0 {
+		s.alerts = slices.Delete(s.alerts, 0, over)
+	}
+
+	subscribers := slices.Clone(s.subscribers)
+
+	s.mu.Unlock()
+
+	s.save()
+
+	for _, a := range alerts {
+		log.Printf("[MONITOR] %s %s:%d: %s", a.Type, a.Domain, a.Port, a.Message)
+
+		for _, fn := range subscribers {
+			fn(a)
+		}
+	}
+}
+
+func toResult(res *models.SSLCheckResponse, err error, now time.Time) models.WatchResult {
+
+	if err != nil {
+		return models.WatchResult{CheckTime: now, Error: err.Error()}
+	}
+
+	out := models.WatchResult{
+		CheckTime: now,
+		Success:   true,
+		DaysLeft:  res.DaysLeft,
+		Trusted:   res.Trusted,
+	}
+
+	if len(res.CertChain) > 0 {
+		out.FingerprintSHA256 = res.CertChain[0].FingerprintSHA256
+		out.NotAfter = res.CertChain[0].NotAfter
+	}
+
+	for _, issue := range res.TrustIssues {
+		if !slices.Contains(out.TrustIssues, issue.Code) {
+			out.TrustIssues = append(out.TrustIssues, issue.Code)
+		}
+	}
+
+	if res.Grade != nil {
+		out.Grade = res.Grade.Grade
+	}
+
+	return out
+}
+
+func lastSuccess(history []models.WatchResult) *models.WatchResult {
+
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Success {
+			return &history[i]
+		}
+	}
+
+	return nil
+}
+
+func lastDNS(history []models.WatchResult) *models.WatchDNSStatus {
+
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].DNS != nil {
+			return history[i].DNS
+		}
+	}
+
+	return nil
+}
+
+/* ===========================
+   ALERT RULES
+=========================== */
+
+// evaluate compares a new result with the previous successful one. The
+// first scan only raises expiry alerts; it is the baseline for the rest.
+func evaluate(
+	w models.Watch,
+	prev *models.WatchResult,
+	cur models.WatchResult,
+	threshold int64,
+) []models.MonitorAlert {
+
+	if !cur.Success {
+		return nil
+	}
+
+	var alerts []models.MonitorAlert
+
+	add := func(t models.AlertType, msg, before, after string) {
+		alerts = append(alerts, models.MonitorAlert{
+			WatchID:  w.ID,
+			Domain:   w.Domain,
+			Port:     w.Port,
+			Type:     t,
+			Message:  msg,
+			Time:     cur.CheckTime,
+			Previous: before,
+			Current:  after,
+		})
+	}
+
+	// ---- Expiry threshold crossed
+	if cur.DaysLeft <= threshold && (prev == nil || prev.DaysLeft > threshold) {
+
+		msg := fmt.Sprintf("Chứng chỉ của %s sẽ hết hạn sau %d ngày.", w.Domain, cur.DaysLeft)
+		if cur.DaysLeft < 0 {
+			msg = fmt.Sprintf("Chứng chỉ của %s đã hết hạn.", w.Domain)
+		}
+
+		before := ""
+		if prev != nil {
+			before = fmt.Sprint(prev.DaysLeft)
+		}
+
+		add(models.AlertExpirySoon, msg, before, fmt.Sprint(cur.DaysLeft))
+	}
+
+	if prev == nil {
+		return alerts
+	}
+
+	// ---- Certificate replaced
+	if prev.FingerprintSHA256 != "" && cur.FingerprintSHA256 != prev.FingerprintSHA256 {
+		add(models.AlertFingerprintChanged,
+			fmt.Sprintf("Chứng chỉ của %s đã thay đổi.", w.Domain),
+			prev.FingerprintSHA256, cur.FingerprintSHA256)
+	}
+
+	// ---- New trust issues
+	for _, code := range cur.TrustIssues {
+		if !slices.Contains(prev.TrustIssues, code) {
+			add(models.AlertNewTrustIssue,
+				fmt.Sprintf("Phát hiện lỗi tin cậy mới trên %s: %s.", w.Domain, code),
+				"", string(code))
+		}
+	}
+
+	return alerts
+}
+
+// evaluateDNS compares the DNS status with the previous one. The first
+// check only raises listings; its addresses are the baseline.
+func evaluateDNS(
+	w models.Watch,
+	prev *models.WatchDNSStatus,
+	cur models.WatchResult,
+) []models.MonitorAlert {
+
+	if cur.DNS == nil {
+		return nil
+	}
+
+	var alerts []models.MonitorAlert
+
+	add := func(t models.AlertType, msg, before, after string) {
+		alerts = append(alerts, models.MonitorAlert{
+			WatchID:  w.ID,
+			Domain:   w.Domain,
+			Port:     w.Port,
+			Type:     t,
+			Message:  msg,
+			Time:     cur.CheckTime,
+			Previous: before,
+			Current:  after,
+		})
+	}
+
+	var prevListings []string
+	if prev != nil {
+		prevListings = prev.Listings
+	}
+
+	// ---- Newly listed
+	for _, l := range cur.DNS.Listings {
+		if !slices.Contains(prevListings, l) {
+			add(models.AlertBlacklisted,
+				fmt.Sprintf("%s bị liệt kê trong danh sách đen: %s.", w.Domain, l),
+				"", l)
+		}
+	}
+
+	if prev == nil {
+		return alerts
+	}
+
+	// ---- Delisted
+	for _, l := range prev.Listings {
+		if !slices.Contains(cur.DNS.Listings, l) {
+			add(models.AlertDelisted,
+				fmt.Sprintf("%s đã được gỡ khỏi danh sách đen: %s.", w.Domain, l),
+				l, "")
+		}
+	}
+
+	// ---- Addresses changed
+	if !slices.Equal(prev.Addresses, cur.DNS.Addresses) {
+		add(models.AlertAddressesChanged,
+			fmt.Sprintf("Địa chỉ IP của %s đã thay đổi.", w.Domain),
+			strings.Join(prev.Addresses, ", "), strings.Join(cur.DNS.Addresses, ", "))
+	}
+
+	return alerts
+}
+
+/* ===========================
+   PERSISTENCE
+=========================== */
+
+type snapshot struct {
+	Watches []*models.WatchDetail `json:"watches"`
+	Owners  map[string]string     `json:"owners"`
+	Tokens  []string              `json:"tokens"`
+	Alerts  []models.MonitorAlert `json:"alerts"`
+}
+
+func (s *Service) load() error {
+
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+
+	for _, w := range snap.Watches {
+		s.watches[w.ID] = w
+	}
+	for id, o := range snap.Owners {
+		s.owners[id] = o
+		s.tokens[o] = true
+	}
+	for _, o := range snap.Tokens {
+		s.tokens[o] = true
+	}
+	s.alerts = snap.Alerts
+
+	return nil
+}
+
+// save writes the state atomically (temp file + rename).
+func (s *Service) save() {
+
+	if s.path == "" {
+		return
+	}
+
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.RLock()
+
+	snap := snapshot{Owners: s.owners, Tokens: slices.Sorted(maps.Keys(s.tokens)), Alerts: s.alerts}
+	for _, w := range s.watches {
+		snap.Watches = append(snap.Watches, w)
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+
+	s.mu.RUnlock()
+
+	if err != nil {
+		log.Printf("[MONITOR] cannot encode state: %v", err)
+		return
+	}
+
+	tmp := s.path + ".tmp"
+
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Printf("[MONITOR] cannot save %s: %v", s.path, err)
+		return
+	}
+
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("[MONITOR] cannot save %s: %v", s.path, err)
+	}
+}
@@ -0,0 +1,347 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tools.bctechvibe.io.vn/server/ssl/internal/models"
+	"tools.bctechvibe.io.vn/server/ssl/internal/platform/middleware"
+	"tools.bctechvibe.io.vn/server/ssl/internal/tools/checker"
+)
+
+type fakeChecker struct {
+	mu  sync.Mutex
+	res *models.SSLCheckResponse
+}
+
+func (f *fakeChecker) set(daysLeft int64, fingerprint string, issues ...models.TrustCode) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	res := &models.SSLCheckResponse{
+		Success:   true,
+		DaysLeft:  daysLeft,
+		Trusted:   len(issues) == 0,
+		CertChain: []models.CertDetail{{FingerprintSHA256: fingerprint}},
+	}
+	for _, code := range issues {
+		res.TrustIssues = append(res.TrustIssues, models.TrustIssue{Code: code})
+	}
+
+	f.res = res
+}
+
+func (f *fakeChecker) Check(ctx context.Context, domain string, opts checker.ScanOptions) (*models.SSLCheckResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cp := *f.res
+	cp.Hostname = domain
+	return &cp, nil
+}
+
+func alertTypes(alerts []models.MonitorAlert) []models.AlertType {
+	var out []models.AlertType
+	for _, a := range alerts {
+		out = append(out, a.Type)
+	}
+	return out
+}
+
+func TestMonitorAlerts(t *testing.T) {
+	fc := &fakeChecker{}
+	fc.set(60, "aa")
+
+	svc := New(fc, "")
+	token := svc.IssueToken()
+
+	var received []models.MonitorAlert
+	svc.Subscribe(func(a models.MonitorAlert) { received = append(received, a) })
+
+	w, err := svc.Add(token, "example.com", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.wg.Wait() // first scan
+
+	if w.Port != 443 || w.Protocol != "https" {
+		t.Errorf("expected https:443 defaults, got %+v", w)
+	}
+	if _, err := svc.Add("made-up", "example.org", 0, ""); err != ErrUnknownToken {
+		t.Fatalf("expected ErrUnknownToken, got %v", err)
+	}
+
+	if _, err := svc.Add(token, "example.com", 443, "https"); err != ErrWatchExists {
+		t.Errorf("expected ErrWatchExists, got %v", err)
+	}
+	if len(svc.Alerts(token)) != 0 {
+		t.Fatalf("healthy first scan should not alert: %+v", svc.Alerts(token))
+	}
+
+	// Crosses the expiry threshold and the certificate got replaced
+	fc.set(20, "bb")
+	svc.scanWatch(context.Background(), w.ID)
+
+	// Still below the threshold: no repeated expiry alert, but a new trust issue
+	fc.set(19, "bb", models.TrustRevoked)
+	svc.scanWatch(context.Background(), w.ID)
+
+	got := alertTypes(svc.Alerts(token))
+	want := []models.AlertType{models.AlertNewTrustIssue, models.AlertFingerprintChanged, models.AlertExpirySoon}
+
+	if len(got) != len(want) {
+		t.Fatalf("alerts = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("alerts = %v, want %v", got, want)
+			break
+		}
+	}
+
+	if len(received) != 3 {
+		t.Errorf("subscriber got %d alerts", len(received))
+	}
+
+	detail, err := svc.Get(token, w.ID)
+	if err != nil || len(detail.History) != 3 || detail.Last.DaysLeft != 19 {
+		t.Errorf("unexpected history %+v %v", detail, err)
+	}
+}
+
+func TestMonitorRunDueAndPersistence(t *testing.T) {
+	fc := &fakeChecker{}
+	fc.set(60, "aa")
+
+	path := filepath.Join(t.TempDir(), "monitor.json")
+	svc := New(fc, path)
+	token := svc.IssueToken()
+
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+
+	w, _ := svc.Add(token, "example.com", 8443, "https")
+	svc.wg.Wait()
+
+	// Not due yet
+	svc.RunDue(context.Background())
+	if d, _ := svc.Get(token, w.ID); len(d.History) != 1 {
+		t.Fatalf("watch rescanned too early: %d results", len(d.History))
+	}
+
+	now = now.Add(25 * time.Hour)
+	svc.RunDue(context.Background())
+	if d, _ := svc.Get(token, w.ID); len(d.History) != 2 {
+		t.Fatalf("due watch not rescanned: %d results", len(d.History))
+	}
+
+	// A new service picks the watch list up from disk
+	reloaded := New(fc, path)
+	if list := reloaded.List(token); len(list) != 1 || list[0].Port != 8443 {
+		t.Fatalf("watch list not persisted: %+v", list)
+	}
+	if !reloaded.ValidToken(token) {
+		t.Fatal("issued tokens not persisted")
+	}
+
+	// Other tokens neither see nor remove it
+	if list := reloaded.List("other"); len(list) != 0 {
+		t.Errorf("watch visible to another token: %+v", list)
+	}
+	if _, err := reloaded.Get("", w.ID); err != ErrWatchNotFound {
+		t.Errorf("expected ErrWatchNotFound without a token, got %v", err)
+	}
+	if err := reloaded.Remove("other", w.ID); err != ErrWatchNotFound {
+		t.Errorf("expected ErrWatchNotFound for another token, got %v", err)
+	}
+
+	if err := reloaded.Remove(token, w.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.Remove(token, w.ID); err != ErrWatchNotFound {
+		t.Errorf("expected ErrWatchNotFound, got %v", err)
+	}
+}
+
+type fakeDNS struct {
+	mu     sync.Mutex
+	status *models.WatchDNSStatus
+}
+
+func (f *fakeDNS) set(addresses []string, listings ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = &models.WatchDNSStatus{Addresses: addresses, Listings: listings}
+}
+
+func (f *fakeDNS) CheckDNS(ctx context.Context, domain string) (*models.WatchDNSStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cp := *f.status
+	return &cp, nil
+}
+
+func TestMonitorDNSAlerts(t *testing.T) {
+	fc := &fakeChecker{}
+	fc.set(60, "aa")
+
+	fd := &fakeDNS{}
+	fd.set([]string{"192.0.2.1"}, "zen.spamhaus.org 192.0.2.1")
+
+	svc := New(fc, "")
+	token := svc.IssueToken()
+	svc.SetDNSChecker(fd)
+
+	w, _ := svc.Add(token, "example.com", 0, "")
+	svc.wg.Wait()
+
+	// Listed on the first check
+	if got := alertTypes(svc.Alerts(token)); !slices.Equal(got, []models.AlertType{models.AlertBlacklisted}) {
+		t.Fatalf("first check alerts = %v", got)
+	}
+
+	// Delisted and moved to another address
+	fd.set([]string{"192.0.2.2"})
+	svc.scanWatch(context.Background(), w.ID)
+
+	alerts := svc.Alerts(token)
+	got := alertTypes(alerts)
+	want := []models.AlertType{models.AlertAddressesChanged, models.AlertDelisted, models.AlertBlacklisted}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("alerts = %v, want %v", got, want)
+	}
+
+	if n := Notification(alerts[0]); n.Source != models.NotificationSourceDNS || n.Subject != "[DNS] Địa chỉ IP đã thay đổi: example.com:443" {
+		t.Errorf("unexpected notification %+v", n)
+	}
+	if n := Notification(alerts[1]); n.Source != models.NotificationSourceBlacklist {
+		t.Errorf("unexpected source %s", n.Source)
+	}
+}
+
+func TestDNSService(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/dns/blacklist-report/example.com" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write([]byte(`{"success":true,"data":{
+			"targets":[
+				{"domain":"example.com","source":"DOMAIN"},
+				{"ip":"2001:db8::1","host":"example.com","source":"A"},
+				{"ip":"192.0.2.1","host":"example.com","source":"A"},
+				{"ip":"198.51.100.1","host":"mx.example.com","source":"MX"}
+			],
+			"listings":[
+				{"provider":"dbl.spamhaus.org","domain":"example.com","status":"LISTED"},
+				{"provider":"zen.spamhaus.org","ip":"192.0.2.1","status":"LISTED"}
+			]
+		}}`))
+	}))
+	defer srv.Close()
+
+	status, err := NewDNSService(srv.URL+"/").CheckDNS(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(status.Addresses, []string{"192.0.2.1", "2001:db8::1"}) {
+		t.Errorf("addresses = %v", status.Addresses)
+	}
+	if !slices.Equal(status.Listings, []string{"dbl.spamhaus.org example.com", "zen.spamhaus.org 192.0.2.1"}) {
+		t.Errorf("listings = %v", status.Listings)
+	}
+
+	if _, err := NewDNSService(srv.URL).CheckDNS(context.Background(), "other.com"); err == nil {
+		t.Error("expected an error for a 404")
+	}
+}
+
+func TestHandlerOwnership(t *testing.T) {
+	fc := &fakeChecker{}
+	fc.set(60, "aa")
+
+	svc := New(fc, "")
+
+	limiter := middleware.NewRateLimiter(1, time.Hour, 10, false)
+	defer limiter.Stop()
+
+	h := NewHandler(svc, limiter)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		if i := strings.LastIndex(path, "/watches/"); i >= 0 {
+			r.SetPathValue("id", path[i+len("/watches/"):])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/api/ssl/monitor/watches", "", `{"domain":"example.com"}`)
+	svc.wg.Wait()
+
+	var created WatchCreated
+	if rec.Code != http.StatusCreated || json.Unmarshal(rec.Body.Bytes(), &created) != nil || created.Token == "" {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := do(http.MethodGet, "/api/ssl/monitor/watches", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("list without a token: %d", rec.Code)
+	}
+
+	// Tokens the service did not issue are refused everywhere
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if rec := do(method, "/api/ssl/monitor/watches", "made-up", `{"domain":"example.org"}`); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s with a made-up token: %d", method, rec.Code)
+		}
+	}
+
+	if rec := do(http.MethodDelete, "/api/ssl/monitor/watches/"+created.ID, "made-up", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("delete with a made-up token: %d", rec.Code)
+	}
+
+	// Another issued token doesn't see the watch
+	other := svc.IssueToken()
+
+	if rec := do(http.MethodGet, "/api/ssl/monitor/watches", other, ""); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("list with another token: %s", rec.Body)
+	}
+
+	if rec := do(http.MethodDelete, "/api/ssl/monitor/watches/"+created.ID, other, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("delete with another token: %d", rec.Code)
+	}
+
+	// The same client can't keep minting tokens
+	if rec := do(http.MethodPost, "/api/ssl/monitor/watches", "", `{"domain":"example.org"}`); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("second token from the same client: %d", rec.Code)
+	}
+
+	// ... but can add more watches with the token it has
+	if rec := do(http.MethodPost, "/api/ssl/monitor/watches", created.Token, `{"domain":"example.org"}`); rec.Code != http.StatusCreated {
+		t.Errorf("add with the owner token: %d %s", rec.Code, rec.Body)
+	}
+	svc.wg.Wait()
+
+	if rec := do(http.MethodGet, "/api/ssl/monitor/watches/"+created.ID, created.Token, ""); rec.Code != http.StatusOK {
+		t.Errorf("get with the owner token: %d", rec.Code)
+	}
+
+	if rec := do(http.MethodDelete, "/api/ssl/monitor/watches/"+created.ID, created.Token, ""); rec.Code != http.StatusOK {
+		t.Errorf("delete with the owner token: %d", rec.Code)
+	}
+}